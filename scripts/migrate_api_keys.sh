@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the api_keys table to an existing database. Safe to run more than
+# once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Creating api_keys table..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS "public"."api_keys" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "user_id" int8 NOT NULL REFERENCES "public"."users"("id") ON DELETE CASCADE,
+	    "name" varchar NOT NULL,
+	    "prefix" varchar NOT NULL,
+	    "key_hash" varchar NOT NULL,
+	    "scopes" "public"."user_role"[] NOT NULL DEFAULT '{}',
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "last_used_at" timestamptz,
+	    "expires_at" timestamptz,
+	    PRIMARY KEY ("id")
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_keys_key_hash_key ON public.api_keys USING btree (key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON public.api_keys USING btree (user_id);
+
+COMMIT;
+SQL
@@ -0,0 +1,50 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the append-only audit_log table to an existing database. Changes
+# made before the migration are not in the log. Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Creating audit_log table..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS "public"."audit_log" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "actor_hash" varchar NOT NULL DEFAULT '',
+	    "api_key_id" int8,
+	    "action" varchar NOT NULL,
+	    "target" varchar NOT NULL DEFAULT '',
+	    "method" varchar NOT NULL,
+	    "path" varchar NOT NULL,
+	    "ip" varchar NOT NULL DEFAULT '',
+	    "user_agent" varchar NOT NULL DEFAULT '',
+	    "status" int4 NOT NULL,
+	    "outcome" varchar NOT NULL CHECK (outcome IN ('success', 'denied', 'failure')),
+	    PRIMARY KEY ("id")
+);
+
+CREATE OR REPLACE FUNCTION "public"."audit_log_append_only"() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON "public"."audit_log";
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON "public"."audit_log"
+	FOR EACH ROW EXECUTE FUNCTION "public"."audit_log_append_only"();
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON "public"."audit_log";
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON "public"."audit_log"
+	FOR EACH STATEMENT EXECUTE FUNCTION "public"."audit_log_append_only"();
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON public.audit_log USING btree (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_hash ON public.audit_log USING btree (actor_hash);
+
+COMMIT;
+SQL
@@ -0,0 +1,55 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the import_jobs table of background imports to an existing database.
+# Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Creating import_jobs table..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'import_job_status') THEN
+		CREATE TYPE "public"."import_job_status" AS ENUM (
+			'queued',
+			'downloading',
+			'parsing',
+			'persisting',
+			'done',
+			'failed'
+		);
+	END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS "public"."import_jobs" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "status" "public"."import_job_status" NOT NULL DEFAULT 'queued',
+	    "source_url" varchar,
+	    "file_name" varchar,
+	    "content_hash" varchar,
+	    "version" int8 REFERENCES "public"."versions"("id") ON DELETE SET NULL,
+	    "error" varchar,
+	    "rows" int8 NOT NULL DEFAULT 0,
+	    "parsed" jsonb NOT NULL DEFAULT '{}',
+	    "persisted" jsonb NOT NULL DEFAULT '{}',
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "updated_at" timestamptz NOT NULL DEFAULT now(),
+	    "downloading_at" timestamptz,
+	    "parsing_at" timestamptz,
+	    "persisting_at" timestamptz,
+	    "finished_at" timestamptz,
+	    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON public.import_jobs USING btree (status);
+
+COMMIT;
+SQL
@@ -0,0 +1,27 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the rate_limits table used by ROISTOT_RATE_LIMIT_STORE=postgres to an
+# existing database. Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Creating rate_limits table..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+CREATE UNLOGGED TABLE IF NOT EXISTS "public"."rate_limits" (
+	    "key" varchar NOT NULL,
+	    "tokens" float8 NOT NULL,
+	    "updated_at" timestamptz NOT NULL DEFAULT now(),
+	    PRIMARY KEY ("key")
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limits_updated_at ON public.rate_limits USING btree (updated_at);
+
+COMMIT;
+SQL
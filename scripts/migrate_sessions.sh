@@ -0,0 +1,40 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the sessions table to an existing database, or the columns it has
+# gained since to one that already has it. Sessions signed in before
+# the migration have no row, so their users need to sign in again. Safe to
+# run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Creating sessions table..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS "public"."sessions" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "user_id" int8 NOT NULL REFERENCES "public"."users"("id") ON DELETE CASCADE,
+	    "key_hash" varchar NOT NULL,
+	    "previous_key_hash" varchar,
+	    "user_agent" varchar NOT NULL DEFAULT '',
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "refreshed_at" timestamptz NOT NULL DEFAULT now(),
+	    "expires_at" timestamptz NOT NULL,
+	    PRIMARY KEY ("id")
+);
+
+ALTER TABLE "public"."sessions" ADD COLUMN IF NOT EXISTS "previous_key_hash" varchar;
+COMMENT ON COLUMN "public"."sessions"."previous_key_hash" IS 'key replaced by the last refresh, accepted for a short grace period after refreshed_at';
+
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_key_hash_key ON public.sessions USING btree (key_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_key_hash ON public.sessions USING btree (previous_key_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON public.sessions USING btree (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON public.sessions USING btree (expires_at);
+
+COMMIT;
+SQL
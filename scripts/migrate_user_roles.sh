@@ -0,0 +1,45 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Brings the users table of an existing database up to date: replaces
+# users.is_admin with users.roles, giving former admins every role, and adds
+# the encrypted email columns. Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Migrating users table..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'user_role') THEN
+		CREATE TYPE "public"."user_role" AS ENUM ('viewer', 'editor', 'publisher', 'user-admin');
+	END IF;
+END
+$$;
+
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "roles" "public"."user_role"[] NOT NULL DEFAULT '{}';
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "email" varchar;
+ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "email_iv" varchar;
+
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = 'users' AND column_name = 'is_admin'
+	) THEN
+		UPDATE "public"."users"
+		SET roles = '{viewer,editor,publisher,user-admin}'
+		WHERE is_admin;
+		ALTER TABLE "public"."users" DROP COLUMN "is_admin";
+	END IF;
+END
+$$;
+
+COMMIT;
+SQL
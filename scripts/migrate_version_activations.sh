@@ -0,0 +1,71 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the version_activations history and the scheduled_activations table
+# to an existing database, and keeps history rows when a version is deleted.
+# Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Creating version_activations and scheduled_activations tables..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS "public"."version_activations" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "version" int8,
+	    "previous_version" int8 REFERENCES "public"."versions"("id") ON DELETE SET NULL,
+	    "activated_by" int8 REFERENCES "public"."users"("id") ON DELETE SET NULL,
+	    "reason" varchar NOT NULL,
+	    "forced" bool NOT NULL DEFAULT false,
+	    "activated_at" timestamptz NOT NULL DEFAULT now(),
+	    PRIMARY KEY ("id")
+);
+
+-- Earlier schemas deleted the history of a version together with it.
+ALTER TABLE "public"."version_activations" ALTER COLUMN "version" DROP NOT NULL;
+ALTER TABLE "public"."version_activations" DROP CONSTRAINT IF EXISTS "version_activations_version_fkey";
+ALTER TABLE "public"."version_activations" ADD CONSTRAINT "version_activations_version_fkey"
+	FOREIGN KEY ("version") REFERENCES "public"."versions"("id") ON DELETE SET NULL;
+
+COMMENT ON TABLE "public"."version_activations" IS 'Activation history. previous_version is the version that was active before, used for rollback';
+COMMENT ON COLUMN "public"."version_activations"."version" IS 'null once the activated version is deleted, so that the history is kept';
+COMMENT ON COLUMN "public"."version_activations"."activated_by" IS 'null when the server activated the version, e.g. after a scheduled import';
+
+CREATE INDEX IF NOT EXISTS idx_version_activations_activated_at ON public.version_activations USING btree (activated_at);
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'scheduled_activation_status') THEN
+		CREATE TYPE "public"."scheduled_activation_status" AS ENUM (
+			'pending',
+			'running',
+			'done',
+			'failed',
+			'cancelled'
+		);
+	END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS "public"."scheduled_activations" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "version" int8 NOT NULL REFERENCES "public"."versions"("id") ON DELETE CASCADE,
+	    "activate_at" timestamptz NOT NULL,
+	    "force" bool NOT NULL DEFAULT false,
+	    "status" "public"."scheduled_activation_status" NOT NULL DEFAULT 'pending',
+	    "error" varchar,
+	    "created_by" int8 REFERENCES "public"."users"("id") ON DELETE SET NULL,
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "processed_at" timestamptz,
+	    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_activations_pending ON public.scheduled_activations USING btree (activate_at) WHERE status = 'pending';
+
+COMMIT;
+SQL
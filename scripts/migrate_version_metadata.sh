@@ -0,0 +1,41 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds the import metadata, label and notes columns of versions to an
+# existing database. Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Adding version metadata columns..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+ALTER TABLE "public"."versions"
+	ADD COLUMN IF NOT EXISTS "label" varchar NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS "notes" text NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS "imported_by" int8,
+	ADD COLUMN IF NOT EXISTS "source_url" varchar,
+	ADD COLUMN IF NOT EXISTS "file_name" varchar,
+	ADD COLUMN IF NOT EXISTS "content_hash" varchar,
+	ADD COLUMN IF NOT EXISTS "importer_revision" varchar,
+	ADD COLUMN IF NOT EXISTS "rows" int4 NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS "counts" jsonb NOT NULL DEFAULT '{}';
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'versions_imported_by_fkey') THEN
+		ALTER TABLE "public"."versions" ADD CONSTRAINT "versions_imported_by_fkey"
+			FOREIGN KEY ("imported_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
+	END IF;
+END
+$$;
+
+COMMENT ON COLUMN "public"."versions"."rows" IS 'spreadsheet data rows the version was imported from';
+COMMENT ON COLUMN "public"."versions"."counts" IS 'imported entity counts, see db.ImportEntityCounts';
+
+COMMIT;
+SQL
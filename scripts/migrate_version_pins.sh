@@ -0,0 +1,22 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Adds versions.is_pinned, used by version pruning, to an existing database.
+# Safe to run more than once.
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+cd "${ROOT_DIR}"
+
+echo "Ensuring database container is running..."
+docker compose up -d db
+
+echo "Adding versions.is_pinned..."
+docker compose exec -T db psql -U tex -d tex -v ON_ERROR_STOP=1 <<'SQL'
+BEGIN;
+
+ALTER TABLE "public"."versions" ADD COLUMN IF NOT EXISTS "is_pinned" bool NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN "public"."versions"."is_pinned" IS 'pinned versions are kept by version pruning';
+
+COMMIT;
+SQL
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+// devlogin prints a development login token for ROISTOT_DEV_LOGIN_EMAIL.
+// Sign in with it by posting it as dev_token to /api/login, e.g.
+//
+//	curl -c cookies.txt -d "dev_token=$(go run cmd/devlogin/devlogin.go)" localhost:6969/api/login
+func main() {
+	email := flag.String("email", config.DevLoginEmail, "email to sign in as, must match ROISTOT_DEV_LOGIN_EMAIL on the server")
+	expiresAfter := flag.Duration("expires", 24*time.Hour, "lifetime of the token")
+	flag.Parse()
+
+	if *email == "" {
+		panic("set ROISTOT_DEV_LOGIN_EMAIL or -email")
+	}
+	token, err := auth.NewAuthService().CreateDevLoginToken(*email, *expiresAfter)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(token)
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"os"
+
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/kokkoniemi/texinroistot/internal/db"
 	"github.com/kokkoniemi/texinroistot/internal/importer"
 )
 
+const defaultInputPath = "Texinroistot.xlsx"
+
 func main() {
 	err := parseExcel()
 	if err != nil {
@@ -12,7 +17,22 @@ func main() {
 	}
 }
 
+// parseExcel imports the spreadsheet given as the first argument. The
+// format (xlsx, ods or csv) is detected from the file contents. The import
+// lock is shared with the admin import endpoint, so the command fails
+// instead of importing alongside a running backend import.
 func parseExcel() error {
-	_, err := importer.ImportSpreadsheetFromFile("Texinroistot.xlsx")
+	path := defaultInputPath
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+
+	lock, err := db.TryImportLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	_, err = importer.ImportSpreadsheetFromFile(path)
 	return err
 }
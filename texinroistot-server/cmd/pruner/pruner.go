@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func main() {
+	keep := flag.Int("keep", config.RetentionKeepVersions, "number of newest versions to keep")
+	apply := flag.Bool("apply", false, "delete the versions instead of only listing them")
+	flag.Parse()
+
+	if err := prune(db.RetentionPolicy{KeepLatest: *keep}, *apply); err != nil {
+		panic(err)
+	}
+}
+
+// prune prints the versions the retention policy keeps and prunes, and
+// deletes the pruned ones with apply. Like the importer it takes the import
+// lock, so it never prunes a version while an import is writing it.
+func prune(policy db.RetentionPolicy, apply bool) error {
+	if policy.KeepLatest < 0 {
+		return fmt.Errorf("keep must be a non-negative integer")
+	}
+
+	lock, err := db.TryImportLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	versionRepo := db.NewVersionRepository()
+	plan, err := db.PlanPruning(versionRepo, db.NewVersionActivationRepository(), policy)
+	if err != nil {
+		return err
+	}
+
+	for _, retained := range plan.Keep {
+		fmt.Printf("keep  %d (%s)\n", retained.Version.ID, strings.Join(retained.Reasons, ", "))
+	}
+	for _, version := range plan.Prune {
+		fmt.Printf("prune %d\n", version.ID)
+	}
+	fmt.Printf("%d versions, about %d bytes of rows, can be pruned\n", len(plan.Prune), plan.PruneBytes)
+
+	if !apply || len(plan.Prune) == 0 {
+		return nil
+	}
+	deleted, err := db.ApplyPruning(versionRepo, plan)
+	fmt.Printf("deleted %d versions\n", len(deleted))
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func main() {
+	apply := flag.Bool("apply", false, "re-encrypt the emails instead of only counting them")
+	flag.Parse()
+
+	if err := rekey(*apply); err != nil {
+		panic(err)
+	}
+}
+
+// rekey re-encrypts stored user emails that use a retired key or predate key
+// IDs with the first key of ROISTOT_ENCRYPTION_KEYS. Once it has run, keys
+// other than the first one can be removed. Tokens are re-issued by the
+// server as users come back.
+func rekey(apply bool) error {
+	if err := crypt.CheckKeys(); err != nil {
+		return err
+	}
+	// Legacy values are what this command exists to re-encrypt.
+	config.CryptAllowLegacy = true
+
+	userRepo := db.NewUserRepository()
+	users, err := userRepo.ListWithEmail()
+	if err != nil {
+		return err
+	}
+
+	stale := 0
+	for _, user := range users {
+		encrypted := crypt.NewEncrypted(user.EmailIv, user.EmailContent)
+		if !crypt.NeedsRotation(encrypted) {
+			continue
+		}
+		stale++
+		if !apply {
+			continue
+		}
+
+		email, err := crypt.Decrypt(encrypted)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", user.Hash, err)
+		}
+		rotated, err := crypt.Encrypt(email)
+		if err != nil {
+			return err
+		}
+		if err := userRepo.SetEmail(user.Hash, rotated.GetContent(), rotated.GetIv()); err != nil {
+			return err
+		}
+	}
+
+	if apply {
+		fmt.Printf("re-encrypted %d of %d stored emails\n", stale, len(users))
+	} else {
+		fmt.Printf("%d of %d stored emails use an old key; run with -apply to re-encrypt them\n", stale, len(users))
+	}
+	return nil
+}
@@ -1,40 +1,186 @@
 package main
 
 import (
+	"errors"
+	"log"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/kokkoniemi/texinroistot/internal/admin"
-	"github.com/kokkoniemi/texinroistot/internal/authors"
 	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/authors"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/gql"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+	"github.com/kokkoniemi/texinroistot/internal/ratelimit"
+	"github.com/kokkoniemi/texinroistot/internal/security"
 	"github.com/kokkoniemi/texinroistot/internal/stories"
 	"github.com/kokkoniemi/texinroistot/internal/versions"
 	"github.com/kokkoniemi/texinroistot/internal/villains"
 )
 
+const apiVersion = "1.0.0"
+
 func main() {
-	app := fiber.New()
+	if err := crypt.CheckKeys(); err != nil {
+		log.Fatal(err)
+	}
+	if err := ratelimit.Configure(); err != nil {
+		log.Fatal(err)
+	}
+	if ratelimit.Enabled() && config.ProxyHeader == "" {
+		log.Printf("WARNING: rate limits are on without ROISTOT_PROXY_HEADER; behind a proxy every client shares the bucket of the proxy IP")
+	}
+	app, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := admin.RecoverImportJobs(); err != nil {
+		log.Printf("failed to recover interrupted import jobs: %v", err)
+	}
+	if err := admin.RecoverScheduledActivations(); err != nil {
+		log.Printf("failed to recover interrupted scheduled activations: %v", err)
+	}
+	if config.ImportScheduleInterval > 0 {
+		admin.StartImportScheduler(config.ImportScheduleInterval)
+	}
+	if config.ActivationPollInterval > 0 {
+		admin.StartActivationScheduler(config.ActivationPollInterval)
+	}
+	if config.DevLogin {
+		if config.CookieSecure {
+			log.Printf("ROISTOT_DEV_LOGIN is ignored with ROISTOT_COOKIE_SECURE=true")
+		} else {
+			log.Printf("WARNING: dev login is enabled for %s, never use it in production", config.DevLoginEmail)
+		}
+	}
+	if config.SessionCleanupInterval > 0 {
+		auth.StartSessionCleanup(config.SessionCleanupInterval)
+	}
+	ratelimit.StartCleanup(ratelimit.IdleAfter)
+
+	app.Listen(":6969") // TODO: add to .env file
+}
+
+func apiSpec() (*openapi.Document, error) {
+	return openapi.NewDocument(
+		"Texinroistot API",
+		apiVersion,
+		ratelimit.WithTooManyRequests(auth.WithCSRFHeader(auth.Operations()), "/api/login"),
+		ratelimit.WithTooManyRequests(versions.Operations(), "/api/"),
+		ratelimit.WithTooManyRequests(stories.Operations(), "/api/"),
+		ratelimit.WithTooManyRequests(villains.Operations(), "/api/"),
+		ratelimit.WithTooManyRequests(authors.Operations(), "/api/"),
+		ratelimit.WithTooManyRequests(linkeddata.Operations(), "/api/"),
+		ratelimit.WithTooManyRequests(auth.WithCSRFHeader(gql.Operations()), "/api/"),
+		auth.WithCSRFHeader(admin.Operations()),
+	)
+}
+
+func newApp() (*fiber.App, error) {
+	spec, err := apiSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a trusted proxy list any client could name its own IP in the
+	// header and so get a fresh rate limit bucket for every request.
+	if config.ProxyHeader != "" && config.TrustedProxies == "" {
+		return nil, errors.New("ROISTOT_PROXY_HEADER is set without ROISTOT_TRUSTED_PROXIES")
+	}
+
+	app := fiber.New(fiber.Config{
+		// Bodies are streamed so that security.BodyLimit can allow
+		// spreadsheet uploads without raising the limit of every route.
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		// Rate limits are per client IP, so behind a proxy it comes from
+		// ProxyHeader, which is only read on requests from TrustedProxies.
+		ProxyHeader:             config.ProxyHeader,
+		EnableTrustedProxyCheck: config.ProxyHeader != "",
+		TrustedProxies:          splitList(config.TrustedProxies),
+		EnableIPValidation:      true,
+	})
+	app.Use(security.Headers)
+	app.Use(security.BodyLimit(fiber.DefaultBodyLimit, map[string]int{
+		fiber.MethodPost + " /api/admin/versions/import": admin.MaxImportRequestBytes,
+	}))
 	app.Get("/healthz", func(c *fiber.Ctx) error {
 		return c.SendStatus(fiber.StatusOK)
 	})
 
-	api := app.Group("/api")
-	api.Post("/login", auth.LoginHandler)
+	// Requests with authentication cookies must carry the token of GET
+	// /api/csrf, except for logins.
+	api := app.Group("/api", auth.CSRFProtection)
+	api.Get("/openapi.json", spec.Handler)
+	api.Get("/csrf", auth.CSRFTokenHandler)
+	api.Post("/login", ratelimit.Login, auth.LoginHandler)
+	api.Get("/login/providers", ratelimit.Public, auth.LoginProvidersHandler)
+	api.Get("/login/:provider/callback", ratelimit.Login, auth.LoginCallbackHandler)
+	api.Get("/login/:provider", ratelimit.Login, auth.StartLoginHandler)
+	api.Post("/login/:provider", ratelimit.Login, auth.StartLoginHandler)
 	api.Post("/logout", auth.LogoutHandler)
+	api.Post("/refresh", auth.RefreshHandler)
 	api.Get("/me", auth.UserInfoHandler)
 	api.Delete("/me", auth.DeleteMeHandler)
-	api.Get("/version/active", versions.GetActiveVersionHandler)
-	api.Get("/stories", stories.ListStoriesHandler)
-	api.Get("/stories/:storyHash/villains", stories.ListStoryVillainsHandler)
-	api.Get("/villains", villains.ListVillainsHandler)
-	api.Get("/authors", authors.ListAuthorsHandler)
-	api.Get("/authors/:authorHash/stories", authors.ListAuthorStoriesHandler)
-
-	adminapi := api.Group("/admin", auth.ProtectedRoute)
-	adminapi.Get("/users", admin.ListUsersHandler)
-	adminapi.Post("/users/grant-admin", admin.GrantAdminHandler)
+	api.Delete("/me/sessions", auth.LogoutEverywhereHandler)
+	api.Get("/me/export", auth.ExportMeHandler)
+	api.Get("/version/active", ratelimit.Public, versions.GetActiveVersionHandler)
+	api.Get("/stories", ratelimit.Public, stories.ListStoriesHandler)
+	api.Get("/stories/:storyHash/villains", ratelimit.Public, stories.ListStoryVillainsHandler)
+	api.Get("/villains", ratelimit.Public, villains.ListVillainsHandler)
+	api.Get("/authors", ratelimit.Public, authors.ListAuthorsHandler)
+	api.Get("/authors/:authorHash/stories", ratelimit.Public, authors.ListAuthorStoriesHandler)
+	api.Get("/export/jsonld", ratelimit.Public, linkeddata.ExportHandler)
+	api.Get("/graphql", ratelimit.GraphQL, gql.GraphQLHandler)
+	api.Post("/graphql", ratelimit.GraphQL, gql.GraphQLHandler)
+
+	editor := auth.RequireRole(db.RoleEditor)
+	publisher := auth.RequireRole(db.RolePublisher)
+	userAdmin := auth.RequireRole(db.RoleUserAdmin)
+
+	// Every role may read; editors import, publishers change what is public.
+	// AuditLog records every change made through the group, also those
+	// ProtectedRoute refuses.
+	adminapi := api.Group("/admin", admin.AuditLog, auth.ProtectedRoute)
+	adminapi.Get("/audit", userAdmin, admin.ListAuditLogHandler)
+	adminapi.Get("/users", userAdmin, admin.ListUsersHandler)
+	adminapi.Post("/users/grant-admin", userAdmin, admin.GrantAdminHandler)
+	adminapi.Post("/users/revoke-admin", userAdmin, admin.RevokeAdminHandler)
+	adminapi.Put("/users/:userHash/roles", userAdmin, admin.SetUserRolesHandler)
+	adminapi.Delete("/users/:userHash/sessions", userAdmin, admin.RevokeUserSessionsHandler)
+	adminapi.Get("/api-keys", admin.ListAPIKeysHandler)
+	adminapi.Post("/api-keys", admin.CreateAPIKeyHandler)
+	adminapi.Delete("/api-keys/:keyID", admin.RevokeAPIKeyHandler)
 	adminapi.Get("/versions", admin.ListVersionsHandler)
-	adminapi.Post("/versions/import", admin.ImportVersionHandler)
-	adminapi.Post("/versions/:versionID/activate", admin.ActivateVersionHandler)
-	adminapi.Delete("/versions/:versionID", admin.DeleteVersionHandler)
+	adminapi.Post("/versions/import", editor, admin.ImportVersionHandler)
+	adminapi.Get("/imports/:jobID", admin.ImportJobHandler)
+	adminapi.Get("/versions/activations", admin.ListActivationsHandler)
+	adminapi.Get("/versions/disk-usage", admin.VersionDiskUsageHandler)
+	adminapi.Get("/versions/prune", admin.PreviewPruneHandler)
+	adminapi.Post("/versions/prune", publisher, admin.PruneVersionsHandler)
+	adminapi.Post("/versions/rollback", publisher, admin.RollbackVersionHandler)
+	adminapi.Get("/versions/scheduled-activations", admin.ListScheduledActivationsHandler)
+	adminapi.Delete("/versions/scheduled-activations/:scheduleID", publisher, admin.CancelScheduledActivationHandler)
+	adminapi.Post("/versions/:versionID/activate", publisher, admin.ActivateVersionHandler)
+	adminapi.Post("/versions/:versionID/schedule-activation", publisher, admin.ScheduleActivationHandler)
+	adminapi.Put("/versions/:versionID/pin", publisher, admin.PinVersionHandler)
+	adminapi.Delete("/versions/:versionID/pin", publisher, admin.UnpinVersionHandler)
+	adminapi.Patch("/versions/:versionID", auth.RequireRole(db.RoleEditor, db.RolePublisher), admin.UpdateVersionHandler)
+	adminapi.Delete("/versions/:versionID", publisher, admin.DeleteVersionHandler)
 
-	app.Listen(":6969") // TODO: add to .env file
+	return app, nil
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+var routeParam = regexp.MustCompile(`:[A-Za-z0-9_]+`)
+
+func testAppAndSpec(t *testing.T) (*fiber.App, *openapi.Document) {
+	t.Helper()
+
+	app, err := newApp()
+	if err != nil {
+		t.Fatalf("failed to build app: %v", err)
+	}
+	spec, err := apiSpec()
+	if err != nil {
+		t.Fatalf("failed to build spec: %v", err)
+	}
+	return app, spec
+}
+
+func doRequest(t *testing.T, app *fiber.App, method string, target string) (int, []byte) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, nil)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, target, err)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	return res.StatusCode, body
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	app, spec := testAppAndSpec(t)
+
+	registered := map[string]bool{}
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		// the document does not describe itself
+		if route.Path == "/api/openapi.json" {
+			continue
+		}
+		registered[route.Method+" "+route.Path] = true
+		if _, ok := spec.Find(route.Method, route.Path); !ok {
+			t.Errorf("route %s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+
+	for _, op := range spec.Operations {
+		if !registered[strings.ToUpper(op.Method)+" "+op.Path] {
+			t.Errorf("documented operation %s %s has no route", op.Method, op.Path)
+		}
+	}
+}
+
+func TestInvalidParametersReturnDocumentedError(t *testing.T) {
+	app, spec := testAppAndSpec(t)
+
+	for _, op := range spec.Operations {
+		if op.Protected {
+			continue
+		}
+		path := routeParam.ReplaceAllString(op.Path, "x")
+
+		for _, param := range op.Parameters {
+			if param.In != openapi.ParamInQuery {
+				continue
+			}
+			var invalid string
+			switch {
+			case len(param.Enum) > 0:
+				invalid = "not-a-documented-value"
+			case param.Minimum != nil:
+				invalid = "0"
+			default:
+				continue
+			}
+
+			target := path + "?" + url.Values{param.Name: {invalid}}.Encode()
+			status, body := doRequest(t, app, op.Method, target)
+			if status != fiber.StatusBadRequest {
+				t.Errorf("%s %s: expected 400, got %d", op.Method, target, status)
+				continue
+			}
+			if err := spec.ValidateResponse(op, status, body); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestProtectedOperationsRejectAnonymousRequests(t *testing.T) {
+	app, spec := testAppAndSpec(t)
+
+	for _, op := range spec.Operations {
+		if !op.Protected {
+			continue
+		}
+		target := routeParam.ReplaceAllString(op.Path, "1")
+		status, body := doRequest(t, app, op.Method, target)
+		if status != fiber.StatusUnauthorized {
+			t.Errorf("%s %s: expected 401, got %d", op.Method, target, status)
+			continue
+		}
+		if err := spec.ValidateResponse(op, status, body); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestAnonymousSessionResponsesMatchSpec(t *testing.T) {
+	app, spec := testAppAndSpec(t)
+
+	for _, route := range []struct {
+		method string
+		path   string
+	}{
+		{fiber.MethodGet, "/api/me"},
+		{fiber.MethodPost, "/api/logout"},
+		{fiber.MethodPost, "/api/refresh"},
+	} {
+		op, ok := spec.Find(route.method, route.path)
+		if !ok {
+			t.Fatalf("%s %s is not documented", route.method, route.path)
+		}
+		status, body := doRequest(t, app, route.method, route.path)
+		if err := spec.ValidateResponse(op, status, body); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	app, spec := testAppAndSpec(t)
+
+	status, body := doRequest(t, app, fiber.MethodGet, "/api/openapi.json")
+	if status != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	var document struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(body, &document); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(document.OpenAPI, "3.") {
+		t.Fatalf("expected an OpenAPI 3 document, got %q", document.OpenAPI)
+	}
+
+	for _, op := range spec.Operations {
+		pathItem, ok := document.Paths[openapi.PathTemplate(op.Path)]
+		if !ok {
+			t.Fatalf("path %s missing from served document", op.Path)
+		}
+		if _, ok := pathItem[strings.ToLower(op.Method)]; !ok {
+			t.Fatalf("%s %s missing from served document", op.Method, op.Path)
+		}
+	}
+}
+
+func TestProxyHeaderRequiresTrustedProxies(t *testing.T) {
+	proxyHeader, trustedProxies := config.ProxyHeader, config.TrustedProxies
+	t.Cleanup(func() { config.ProxyHeader, config.TrustedProxies = proxyHeader, trustedProxies })
+
+	config.ProxyHeader, config.TrustedProxies = "X-Real-IP", ""
+	if _, err := newApp(); err == nil {
+		t.Fatalf("expected a proxy header without trusted proxies to be refused")
+	}
+
+	config.TrustedProxies = "127.0.0.1"
+	if _, err := newApp(); err != nil {
+		t.Fatalf("expected a proxy header with trusted proxies to be accepted: %v", err)
+	}
+}
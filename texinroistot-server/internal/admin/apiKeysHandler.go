@@ -0,0 +1,163 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var newAPIKeyRepository = db.NewAPIKeyRepository
+
+const maxAPIKeyNameLength = 100
+
+type APIKeysListResponse struct {
+	APIKeys []*db.APIKey `json:"apiKeys"`
+}
+
+type CreateAPIKeyPayload struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// CreateAPIKeyResponse carries the key itself, which is shown only once.
+type CreateAPIKeyResponse struct {
+	APIKey *db.APIKey `json:"apiKey"`
+	Key    string     `json:"key"`
+}
+
+type RevokeAPIKeyResponse struct {
+	Revoked bool `json:"revoked"`
+	KeyID   int  `json:"keyID"`
+}
+
+// ListAPIKeysHandler lists the keys of the signed-in user. User admins see
+// the keys of every user with ?all=true.
+func ListAPIKeysHandler(c *fiber.Ctx) error {
+	all, err := parseAll(c.Query("all"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	userID := currentUserID(c)
+	if all {
+		if user := auth.CurrentUser(c); user == nil || !user.HasRole(db.RoleUserAdmin) {
+			return c.Status(403).JSON(api.Error("all=true requires the user-admin role"))
+		}
+		userID = 0
+	}
+
+	keys, err := newAPIKeyRepository().List(userID)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list api keys"))
+	}
+	return c.JSON(APIKeysListResponse{APIKeys: keys})
+}
+
+// CreateAPIKeyHandler creates a key for the signed-in user. A key can only
+// be given roles its owner holds, and keys cannot create more keys.
+func CreateAPIKeyHandler(c *fiber.Ctx) error {
+	user := auth.CurrentUser(c)
+	if user == nil {
+		return c.Status(401).JSON(api.Error("unauthorized"))
+	}
+	if user.APIKeyID != 0 {
+		return c.Status(403).JSON(api.Error("api keys cannot create api keys"))
+	}
+
+	var payload CreateAPIKeyPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(api.Error("invalid request body"))
+	}
+	name, err := parseAPIKeyName(payload.Name)
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+	if len(payload.Scopes) == 0 {
+		return c.Status(400).JSON(api.Error("scopes is required"))
+	}
+	for _, scope := range payload.Scopes {
+		if !db.IsRole(scope) {
+			return c.Status(400).JSON(api.Error(fmt.Sprintf("unknown role %q", scope)))
+		}
+		if !user.HasRole(scope) {
+			return c.Status(403).JSON(api.Error(fmt.Sprintf("you do not have the %s role", scope)))
+		}
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		return c.Status(400).JSON(api.Error("expiresAt must be in the future"))
+	}
+
+	key, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to create api key"))
+	}
+	apiKey, err := newAPIKeyRepository().Create(db.APIKey{
+		UserID:    user.UserID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   auth.APIKeyHash(key),
+		Scopes:    payload.Scopes,
+		ExpiresAt: payload.ExpiresAt,
+	})
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to create api key"))
+	}
+	setAuditTarget(c, fmt.Sprintf("keyID=%d", apiKey.ID))
+
+	return c.Status(201).JSON(CreateAPIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKeyHandler deletes a key of the signed-in user. User admins can
+// revoke any key; keys of other users are reported as not found to
+// everyone else.
+func RevokeAPIKeyHandler(c *fiber.Ctx) error {
+	keyID, err := strconv.Atoi(strings.TrimSpace(c.Params("keyID")))
+	if err != nil || keyID <= 0 {
+		return c.Status(400).JSON(api.Error("keyID must be a positive integer"))
+	}
+
+	userID := currentUserID(c)
+	if user := auth.CurrentUser(c); user != nil && user.HasRole(db.RoleUserAdmin) {
+		userID = 0
+	}
+
+	if err := newAPIKeyRepository().Remove(keyID, userID); err != nil {
+		if errors.Is(err, db.ErrAPIKeyNotFound) {
+			return c.Status(404).JSON(api.Error("api key not found"))
+		}
+		return c.Status(500).JSON(api.Error("failed to revoke api key"))
+	}
+
+	return c.JSON(RevokeAPIKeyResponse{Revoked: true, KeyID: keyID})
+}
+
+func parseAPIKeyName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return "", fmt.Errorf("name must be at most %d characters", maxAPIKeyNameLength)
+	}
+	return name, nil
+}
+
+func parseAll(raw string) (bool, error) {
+	if strings.TrimSpace(raw) == "" {
+		return false, nil
+	}
+	all, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, errors.New("all must be true or false")
+	}
+	return all, nil
+}
@@ -0,0 +1,214 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type fakeAPIKeyRepo struct {
+	db.APIKeyRepository
+	keys       map[int]*db.APIKey
+	listUserID int
+}
+
+func (r *fakeAPIKeyRepo) Create(key db.APIKey) (*db.APIKey, error) {
+	key.ID = len(r.keys) + 1
+	r.keys[key.ID] = &key
+	return &key, nil
+}
+
+func (r *fakeAPIKeyRepo) List(userID int) ([]*db.APIKey, error) {
+	r.listUserID = userID
+	return []*db.APIKey{}, nil
+}
+
+func (r *fakeAPIKeyRepo) Remove(keyID int, userID int) error {
+	key, ok := r.keys[keyID]
+	if !ok || (userID != 0 && key.UserID != userID) {
+		return db.ErrAPIKeyNotFound
+	}
+	delete(r.keys, keyID)
+	return nil
+}
+
+func newAPIKeysTestApp(t *testing.T, keyRepo *fakeAPIKeyRepo, user *auth.UserInfo) *fiber.App {
+	t.Helper()
+
+	newAPIKeyRepository = func() db.APIKeyRepository { return keyRepo }
+	t.Cleanup(func() { newAPIKeyRepository = db.NewAPIKeyRepository })
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	})
+	app.Get("/api/admin/api-keys", ListAPIKeysHandler)
+	app.Post("/api/admin/api-keys", CreateAPIKeyHandler)
+	app.Delete("/api/admin/api-keys/:keyID", RevokeAPIKeyHandler)
+	return app
+}
+
+func TestCreateAPIKeyHandler(t *testing.T) {
+	editor := &auth.UserInfo{LoggedIn: true, IsAdmin: true, UserID: 7, Roles: []string{db.RoleViewer, db.RoleEditor}}
+	editorKey := &auth.UserInfo{LoggedIn: true, IsAdmin: true, UserID: 7, Roles: []string{db.RoleEditor}, APIKeyID: 3}
+
+	tests := []struct {
+		name       string
+		user       *auth.UserInfo
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "editor key",
+			user:       editor,
+			body:       `{"name":" nightly import ","scopes":["editor"],"expiresAt":"2999-01-01T00:00:00Z"}`,
+			wantStatus: fiber.StatusCreated,
+		},
+		{
+			name:       "role the user does not hold",
+			user:       editor,
+			body:       `{"name":"deploy","scopes":["publisher"]}`,
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "unknown role",
+			user:       editor,
+			body:       `{"name":"deploy","scopes":["owner"]}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "missing name",
+			user:       editor,
+			body:       `{"scopes":["editor"]}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "missing scopes",
+			user:       editor,
+			body:       `{"name":"deploy"}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "expiry in the past",
+			user:       editor,
+			body:       `{"name":"deploy","scopes":["editor"],"expiresAt":"2000-01-01T00:00:00Z"}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "signed with an api key",
+			user:       editorKey,
+			body:       `{"name":"deploy","scopes":["editor"]}`,
+			wantStatus: fiber.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRepo := &fakeAPIKeyRepo{keys: map[int]*db.APIKey{}}
+			app := newAPIKeysTestApp(t, keyRepo, tt.user)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/api-keys", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusCreated {
+				if len(keyRepo.keys) != 0 {
+					t.Fatalf("expected no key to be created, got %d", len(keyRepo.keys))
+				}
+				return
+			}
+
+			var payload CreateAPIKeyResponse
+			if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			stored := keyRepo.keys[payload.APIKey.ID]
+			if stored.KeyHash != auth.APIKeyHash(payload.Key) || !strings.HasPrefix(payload.Key, stored.Prefix) {
+				t.Fatalf("stored key does not match the returned key")
+			}
+			if stored.Name != "nightly import" || stored.UserID != 7 || !reflect.DeepEqual(stored.Scopes, []string{db.RoleEditor}) {
+				t.Fatalf("unexpected stored key %+v", stored)
+			}
+		})
+	}
+}
+
+func TestListAPIKeysHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		query      string
+		wantStatus int
+		wantUserID int
+	}{
+		{name: "own keys", roles: []string{db.RoleViewer}, wantStatus: fiber.StatusOK, wantUserID: 7},
+		{name: "every key", roles: []string{db.RoleUserAdmin}, query: "?all=true", wantStatus: fiber.StatusOK},
+		{name: "every key without user-admin", roles: []string{db.RoleViewer}, query: "?all=true", wantStatus: fiber.StatusForbidden},
+		{name: "invalid all", roles: []string{db.RoleViewer}, query: "?all=maybe", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRepo := &fakeAPIKeyRepo{keys: map[int]*db.APIKey{}, listUserID: -1}
+			user := &auth.UserInfo{LoggedIn: true, IsAdmin: true, UserID: 7, Roles: tt.roles}
+			app := newAPIKeysTestApp(t, keyRepo, user)
+
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/admin/api-keys"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus == fiber.StatusOK && keyRepo.listUserID != tt.wantUserID {
+				t.Fatalf("expected keys of user %d, got %d", tt.wantUserID, keyRepo.listUserID)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKeyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []string
+		path       string
+		wantStatus int
+	}{
+		{name: "own key", roles: []string{db.RoleViewer}, path: "/api/admin/api-keys/1", wantStatus: fiber.StatusOK},
+		{name: "key of another user", roles: []string{db.RoleViewer}, path: "/api/admin/api-keys/2", wantStatus: fiber.StatusNotFound},
+		{name: "user admin revokes any key", roles: []string{db.RoleUserAdmin}, path: "/api/admin/api-keys/2", wantStatus: fiber.StatusOK},
+		{name: "invalid key ID", roles: []string{db.RoleViewer}, path: "/api/admin/api-keys/abc", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRepo := &fakeAPIKeyRepo{keys: map[int]*db.APIKey{
+				1: {ID: 1, UserID: 7},
+				2: {ID: 2, UserID: 8},
+			}}
+			user := &auth.UserInfo{LoggedIn: true, IsAdmin: true, UserID: 7, Roles: tt.roles}
+			app := newAPIKeysTestApp(t, keyRepo, user)
+
+			res, err := app.Test(httptest.NewRequest(http.MethodDelete, tt.path, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+		})
+	}
+}
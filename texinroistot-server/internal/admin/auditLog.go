@@ -0,0 +1,250 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var newAuditLogRepository = db.NewAuditLogRepository
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+	// maxAuditFieldLength keeps hostile headers and paths from bloating
+	// the audit log.
+	maxAuditFieldLength = 255
+)
+
+var auditOutcomes = []string{db.AuditSuccess, db.AuditDenied, db.AuditFailure}
+
+// systemActor is the actor of the changes the schedulers make on their own.
+const systemActor = "system"
+
+// Actions of the changes the schedulers make. They have no route, so they
+// are named instead.
+const (
+	scheduledActivationAction = "scheduled activation"
+	importActivationAction    = "import auto-activation"
+)
+
+type AuditListFilters struct {
+	Actor   string `json:"actor"`
+	Action  string `json:"action"`
+	Target  string `json:"target"`
+	Outcome string `json:"outcome"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+type AuditListResponse struct {
+	Entries []*db.AuditEntry `json:"entries"`
+	Meta    api.PageMeta     `json:"meta"`
+	Filters AuditListFilters `json:"filters"`
+}
+
+// AuditLog records every admin request that may change data, including
+// those refused by ProtectedRoute or RequireRole, after the handler has run.
+// It must run before ProtectedRoute, which sets the actor. Reads are not
+// recorded.
+func AuditLog(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead || c.Method() == fiber.MethodOptions {
+		return c.Next()
+	}
+
+	group := c.Route()
+	err := c.Next()
+
+	// A request refused by the group middleware never reached its route,
+	// so the action names the requested path instead of a route pattern.
+	action := c.Method() + " " + c.Route().Path
+	if c.Route() == group {
+		action = c.Method() + " " + c.Path()
+	}
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		}
+	}
+
+	entry := db.AuditEntry{
+		Action:    truncateAuditField(action),
+		Target:    truncateAuditField(auditTarget(c)),
+		Method:    c.Method(),
+		Path:      truncateAuditField(c.Path()),
+		IP:        c.IP(),
+		UserAgent: truncateAuditField(c.Get(fiber.HeaderUserAgent)),
+		Status:    status,
+		Outcome:   auditOutcome(status),
+	}
+	if user := auth.CurrentUser(c); user != nil {
+		entry.ActorHash = user.Hash
+		if user.APIKeyID != 0 {
+			apiKeyID := user.APIKeyID
+			entry.APIKeyID = &apiKeyID
+		}
+	}
+	if logErr := newAuditLogRepository().Create(entry); logErr != nil {
+		log.Printf("audit log: %s %s: %v", entry.Method, entry.Path, logErr)
+	}
+
+	return err
+}
+
+// auditSystemChange records a change a scheduler made without a request, so
+// the entry has no method, path, IP or user agent. Its status is the one the
+// admin API would answer with.
+func auditSystemChange(action string, target string, err error) {
+	status := fiber.StatusOK
+	switch {
+	case errors.Is(err, db.ErrActivationChecksFailed):
+		status = fiber.StatusUnprocessableEntity
+	case err != nil:
+		status = fiber.StatusInternalServerError
+	}
+
+	entry := db.AuditEntry{
+		ActorHash: systemActor,
+		Action:    action,
+		Target:    target,
+		Status:    status,
+		Outcome:   auditOutcome(status),
+	}
+	if logErr := newAuditLogRepository().Create(entry); logErr != nil {
+		log.Printf("audit log: %s %s: %v", action, target, logErr)
+	}
+}
+
+// setAuditTarget names what a request acted on when the route parameters do
+// not tell it, for example a user picked by email.
+func setAuditTarget(c *fiber.Ctx, target string) {
+	c.Locals("auditTarget", target)
+}
+
+// auditTarget defaults to the route parameters, e.g. versionID=5.
+func auditTarget(c *fiber.Ctx) string {
+	if target, ok := c.Locals("auditTarget").(string); ok {
+		return target
+	}
+	params := []string{}
+	for _, name := range c.Route().Params {
+		params = append(params, name+"="+c.Params(name))
+	}
+	return strings.Join(params, ",")
+}
+
+func auditOutcome(status int) string {
+	switch {
+	case status == fiber.StatusUnauthorized || status == fiber.StatusForbidden:
+		return db.AuditDenied
+	case status >= 400:
+		return db.AuditFailure
+	default:
+		return db.AuditSuccess
+	}
+}
+
+func truncateAuditField(value string) string {
+	if len(value) > maxAuditFieldLength {
+		return value[:maxAuditFieldLength]
+	}
+	return value
+}
+
+// parseAuditListParams reads page, pageSize, actor, action, target, outcome
+// and the RFC 3339 times from and to. An actor with an @ is an email and is
+// matched by its hash.
+func parseAuditListParams(c *fiber.Ctx) (db.AuditListParams, AuditListFilters, error) {
+	filters := AuditListFilters{
+		Actor:   strings.TrimSpace(c.Query("actor")),
+		Action:  strings.TrimSpace(c.Query("action")),
+		Target:  strings.TrimSpace(c.Query("target")),
+		Outcome: strings.TrimSpace(c.Query("outcome")),
+		From:    strings.TrimSpace(c.Query("from")),
+		To:      strings.TrimSpace(c.Query("to")),
+	}
+
+	page, err := parseUsersPageValue(c.Query("page"), 1, "page")
+	if err != nil {
+		return db.AuditListParams{}, filters, err
+	}
+	pageSize, err := parseUsersPageValue(c.Query("pageSize"), defaultAuditPageSize, "pageSize")
+	if err != nil {
+		return db.AuditListParams{}, filters, err
+	}
+	if pageSize > maxAuditPageSize {
+		pageSize = maxAuditPageSize
+	}
+
+	params := db.AuditListParams{
+		ActorHash: strings.ToLower(filters.Actor),
+		Action:    filters.Action,
+		Target:    filters.Target,
+		Outcome:   filters.Outcome,
+		Page:      page,
+		PageSize:  pageSize,
+	}
+	if strings.Contains(filters.Actor, "@") {
+		params.ActorHash = userHashForEmail(filters.Actor)
+	}
+	if params.Outcome != "" && !containsString(auditOutcomes, params.Outcome) {
+		return db.AuditListParams{}, filters, fmt.Errorf("outcome must be one of %s", strings.Join(auditOutcomes, ", "))
+	}
+	if params.From, err = parseAuditTime(filters.From, "from"); err != nil {
+		return db.AuditListParams{}, filters, err
+	}
+	if params.To, err = parseAuditTime(filters.To, "to"); err != nil {
+		return db.AuditListParams{}, filters, err
+	}
+	return params, filters, nil
+}
+
+func parseAuditTime(raw string, name string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New(name + " must be an RFC 3339 time")
+	}
+	return value, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ListAuditLogHandler lists audit log entries newest first.
+func ListAuditLogHandler(c *fiber.Ctx) error {
+	params, filters, err := parseAuditListParams(c)
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	entries, meta, err := newAuditLogRepository().List(params)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list audit log"))
+	}
+
+	return c.JSON(AuditListResponse{
+		Entries: entries,
+		Meta:    api.NewPageMeta(meta.Total, meta.PageIndex+1, meta.PageSize),
+		Filters: filters,
+	})
+}
@@ -0,0 +1,252 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type fakeAuditLogRepo struct {
+	db.AuditLogRepository
+	entries    []db.AuditEntry
+	listParams db.AuditListParams
+}
+
+func (r *fakeAuditLogRepo) Create(entry db.AuditEntry) error {
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *fakeAuditLogRepo) List(params db.AuditListParams) ([]*db.AuditEntry, *db.ListMeta, error) {
+	r.listParams = params
+	return []*db.AuditEntry{}, &db.ListMeta{PageIndex: params.Page - 1, PageSize: params.PageSize}, nil
+}
+
+// stubAuditLog records audit entries in the returned fake.
+func stubAuditLog(t *testing.T) *fakeAuditLogRepo {
+	t.Helper()
+
+	auditRepo := &fakeAuditLogRepo{}
+	newAuditLogRepository = func() db.AuditLogRepository { return auditRepo }
+	t.Cleanup(func() { newAuditLogRepository = db.NewAuditLogRepository })
+	return auditRepo
+}
+
+// newAuditTestApp mounts AuditLog like the server does, in front of a
+// stand-in for ProtectedRoute that signs in user.
+func newAuditTestApp(t *testing.T, auditRepo *fakeAuditLogRepo, user *auth.UserInfo) *fiber.App {
+	t.Helper()
+
+	newAuditLogRepository = func() db.AuditLogRepository { return auditRepo }
+	t.Cleanup(func() { newAuditLogRepository = db.NewAuditLogRepository })
+
+	app := fiber.New()
+	adminapi := app.Group("/api/admin", AuditLog, func(c *fiber.Ctx) error {
+		if !user.IsAdmin {
+			return fiber.NewError(fiber.StatusForbidden, "forbidden")
+		}
+		c.Locals("user", user)
+		return c.Next()
+	})
+	adminapi.Get("/versions", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	adminapi.Post("/versions/:versionID/activate", auth.RequireRole(db.RolePublisher), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	adminapi.Delete("/versions/:versionID", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusConflict).JSON(nil)
+	})
+	adminapi.Post("/users/grant-admin", func(c *fiber.Ctx) error {
+		setAuditTarget(c, "userHash=abc")
+		return c.SendStatus(fiber.StatusOK)
+	})
+	adminapi.Get("/audit", ListAuditLogHandler)
+	return app
+}
+
+func TestAuditLog(t *testing.T) {
+	tests := []struct {
+		name        string
+		roles       []string
+		notAdmin    bool
+		method      string
+		path        string
+		wantEntry   bool
+		wantAction  string
+		wantTarget  string
+		wantActor   string
+		wantStatus  int
+		wantOutcome string
+	}{
+		{
+			name:      "reads are not recorded",
+			roles:     []string{db.RoleViewer},
+			method:    http.MethodGet,
+			path:      "/api/admin/versions",
+			wantEntry: false,
+		},
+		{
+			name:        "successful change",
+			roles:       []string{db.RolePublisher},
+			method:      http.MethodPost,
+			path:        "/api/admin/versions/5/activate",
+			wantEntry:   true,
+			wantAction:  "POST /api/admin/versions/:versionID/activate",
+			wantTarget:  "versionID=5",
+			wantActor:   "actor",
+			wantStatus:  fiber.StatusOK,
+			wantOutcome: db.AuditSuccess,
+		},
+		{
+			name:        "refused by role",
+			roles:       []string{db.RoleViewer},
+			method:      http.MethodPost,
+			path:        "/api/admin/versions/5/activate",
+			wantEntry:   true,
+			wantAction:  "POST /api/admin/versions/:versionID/activate",
+			wantTarget:  "versionID=5",
+			wantActor:   "actor",
+			wantStatus:  fiber.StatusForbidden,
+			wantOutcome: db.AuditDenied,
+		},
+		{
+			name:        "refused before reaching the route",
+			roles:       []string{db.RolePublisher},
+			notAdmin:    true,
+			method:      http.MethodPost,
+			path:        "/api/admin/versions/5/activate",
+			wantEntry:   true,
+			wantAction:  "POST /api/admin/versions/5/activate",
+			wantStatus:  fiber.StatusForbidden,
+			wantOutcome: db.AuditDenied,
+		},
+		{
+			name:        "failed change",
+			roles:       []string{db.RolePublisher},
+			method:      http.MethodDelete,
+			path:        "/api/admin/versions/3",
+			wantEntry:   true,
+			wantAction:  "DELETE /api/admin/versions/:versionID",
+			wantTarget:  "versionID=3",
+			wantActor:   "actor",
+			wantStatus:  fiber.StatusConflict,
+			wantOutcome: db.AuditFailure,
+		},
+		{
+			name:        "target set by the handler",
+			roles:       []string{db.RoleUserAdmin},
+			method:      http.MethodPost,
+			path:        "/api/admin/users/grant-admin",
+			wantEntry:   true,
+			wantAction:  "POST /api/admin/users/grant-admin",
+			wantTarget:  "userHash=abc",
+			wantActor:   "actor",
+			wantStatus:  fiber.StatusOK,
+			wantOutcome: db.AuditSuccess,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditRepo := &fakeAuditLogRepo{}
+			user := &auth.UserInfo{LoggedIn: true, IsAdmin: !tt.notAdmin, Hash: "actor", Roles: tt.roles, APIKeyID: 9}
+			app := newAuditTestApp(t, auditRepo, user)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(fiber.HeaderUserAgent, "script/1.0")
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+
+			if !tt.wantEntry {
+				if len(auditRepo.entries) != 0 {
+					t.Fatalf("expected no audit entries, got %+v", auditRepo.entries)
+				}
+				return
+			}
+			if len(auditRepo.entries) != 1 {
+				t.Fatalf("expected one audit entry, got %d", len(auditRepo.entries))
+			}
+			entry := auditRepo.entries[0]
+			if entry.Action != tt.wantAction || entry.Target != tt.wantTarget {
+				t.Fatalf("unexpected action %q and target %q", entry.Action, entry.Target)
+			}
+			if entry.Status != tt.wantStatus || entry.Outcome != tt.wantOutcome {
+				t.Fatalf("expected %d %s, got %d %s", tt.wantStatus, tt.wantOutcome, entry.Status, entry.Outcome)
+			}
+			if entry.ActorHash != tt.wantActor || (tt.wantActor != "" && (entry.APIKeyID == nil || *entry.APIKeyID != 9)) {
+				t.Fatalf("unexpected actor %q with key %v", entry.ActorHash, entry.APIKeyID)
+			}
+			if entry.Path != tt.path || entry.UserAgent != "script/1.0" {
+				t.Fatalf("unexpected request metadata %+v", entry)
+			}
+		})
+	}
+}
+
+func TestListAuditLogHandlerParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantParams db.AuditListParams
+	}{
+		{
+			name:       "defaults",
+			wantStatus: fiber.StatusOK,
+			wantParams: db.AuditListParams{Page: 1, PageSize: defaultAuditPageSize},
+		},
+		{
+			name:       "filters",
+			query:      "?actor=User@Example.com&outcome=denied&from=2026-01-01T00:00:00Z&pageSize=1000",
+			wantStatus: fiber.StatusOK,
+			wantParams: db.AuditListParams{
+				ActorHash: userHashForEmail("user@example.com"),
+				Outcome:   db.AuditDenied,
+				From:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				Page:      1,
+				PageSize:  maxAuditPageSize,
+			},
+		},
+		{
+			name:       "unknown outcome",
+			query:      "?outcome=maybe",
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "invalid time",
+			query:      "?to=yesterday",
+			wantStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditRepo := &fakeAuditLogRepo{}
+			app := newAuditTestApp(t, auditRepo, &auth.UserInfo{LoggedIn: true, IsAdmin: true, Roles: db.Roles})
+
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/admin/audit"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus == fiber.StatusOK && !auditRepo.listParams.From.Equal(tt.wantParams.From) {
+				t.Fatalf("expected from %v, got %v", tt.wantParams.From, auditRepo.listParams.From)
+			}
+			wantParams, gotParams := tt.wantParams, auditRepo.listParams
+			wantParams.From, gotParams.From = time.Time{}, time.Time{}
+			if tt.wantStatus == fiber.StatusOK && gotParams != wantParams {
+				t.Fatalf("expected params %+v, got %+v", tt.wantParams, auditRepo.listParams)
+			}
+			if len(auditRepo.entries) != 0 {
+				t.Fatalf("expected reading the audit log not to be recorded")
+			}
+		})
+	}
+}
@@ -2,10 +2,11 @@ package admin
 
 import (
 	"database/sql"
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/kokkoniemi/texinroistot/internal/crypt"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 )
 
@@ -13,26 +14,46 @@ type GrantAdminPayload struct {
 	Email string `json:"email"`
 }
 
+type GrantAdminResponse struct {
+	User *db.User `json:"user"`
+}
+
+// GrantAdminHandler gives every role to the user with the email.
 func GrantAdminHandler(c *fiber.Ctx) error {
+	return setRolesByEmail(c, db.Roles, "failed to grant admin rights")
+}
+
+// RevokeAdminHandler removes every role from the user with the email. The
+// last user admin cannot be revoked.
+func RevokeAdminHandler(c *fiber.Ctx) error {
+	return setRolesByEmail(c, []string{}, "failed to revoke admin rights")
+}
+
+func setRolesByEmail(c *fiber.Ctx, roles []string, failure string) error {
 	payload := new(GrantAdminPayload)
 	if err := c.BodyParser(payload); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+		return c.Status(400).JSON(api.Error("invalid request body"))
 	}
 
 	email := strings.ToLower(strings.TrimSpace(payload.Email))
 	if email == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "email is required"})
+		return c.Status(400).JSON(api.Error("email is required"))
 	}
 
-	userHash := crypt.Hash(email)
-	userRepo := db.NewUserRepository()
-	user, err := userRepo.SetAdmin(userHash)
+	userHash := userHashForEmail(email)
+	setAuditTarget(c, "userHash="+userHash)
+
+	userRepo := newUserRepository()
+	user, err := userRepo.SetRoles(userHash, roles)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return c.Status(404).JSON(fiber.Map{"error": "user not found"})
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(404).JSON(api.Error("user not found"))
+		}
+		if errors.Is(err, db.ErrLastUserAdmin) {
+			return c.Status(409).JSON(api.Error(err.Error()))
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "failed to grant admin rights"})
+		return c.Status(500).JSON(api.Error(failure))
 	}
 
-	return c.JSON(fiber.Map{"user": user})
+	return c.JSON(GrantAdminResponse{User: withDisplayEmail(user)})
 }
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/importer"
+)
+
+// buildImportCSV returns a one-villain spreadsheet with the default headers.
+func buildImportCSV(t *testing.T) []byte {
+	t.Helper()
+
+	values := map[string]string{
+		"villain_id":       "1",
+		"first_names":      "Mefisto",
+		"roles":            "johtaja",
+		"story_title":      "Mefiston paluu",
+		"story_written_by": "Bonelli, Gian Luigi",
+		"story_drawn_by":   "Galleppini, Aurelio",
+		"pub_year":         "1970",
+		"pub_from":         "1",
+		"pub_to":           "1",
+		"story_order_num":  "1",
+	}
+
+	mapping := importer.DefaultColumnMapping()
+	keys := make([]string, 0, len(mapping.Columns))
+	for key := range mapping.Columns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	header := make([]string, len(keys))
+	row := make([]string, len(keys))
+	for index, key := range keys {
+		header[index] = mapping.Columns[key][0]
+		row[index] = values[key]
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Comma = ';'
+	if err := writer.WriteAll([][]string{header, row}); err != nil {
+		t.Fatalf("failed to build csv: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func waitForImportJob(t *testing.T, jobID int) *db.ImportJob {
+	t.Helper()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		job, err := db.NewImportJobRepository().Read(jobID)
+		if err != nil {
+			t.Fatalf("failed to read import job: %v", err)
+		}
+		if job.Status == db.ImportJobDone || job.Status == db.ImportJobFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for import job %d", jobID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestConcurrentImportsWriteOneVersion starts an admin import and the
+// importer command at the same time, the way an admin and a cron job on
+// another host could, and checks that the database lock lets only one of
+// them write a version.
+func TestConcurrentImportsWriteOneVersion(t *testing.T) {
+	if config.DBConnectionString == "" {
+		t.Skip("DB_CONNECTION_STRING is not set")
+	}
+
+	content := buildImportCSV(t)
+	versionRepo := db.NewVersionRepository()
+	before, err := versionRepo.List()
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+
+	// Both imports try to take the lock before the winner starts importing.
+	var attempted sync.WaitGroup
+	attempted.Add(2)
+	importRunning = false
+	acquireImportLock = func() (databaseLock, error) {
+		lock, err := tryDatabaseImportLock()
+		attempted.Done()
+		if err == nil {
+			attempted.Wait()
+		}
+		return lock, err
+	}
+	t.Cleanup(func() {
+		acquireImportLock = tryDatabaseImportLock
+		importRunning = false
+	})
+
+	type handlerResult struct {
+		status int
+		body   string
+		err    error
+	}
+	handlerDone := make(chan handlerResult, 1)
+	commandDone := make(chan error, 1)
+
+	req := newUploadRequest(t, "roistot.csv", content)
+	go func() {
+		res, err := newImportTestApp().Test(req, -1)
+		if err != nil {
+			handlerDone <- handlerResult{err: err}
+			return
+		}
+		body, _ := io.ReadAll(res.Body)
+		handlerDone <- handlerResult{status: res.StatusCode, body: string(body)}
+	}()
+	go func() {
+		lock, err := db.TryImportLock()
+		attempted.Done()
+		if err != nil {
+			commandDone <- err
+			return
+		}
+		attempted.Wait()
+		defer lock.Release()
+
+		_, err = importer.ImportSpreadsheetWithProgress(content, importer.Source{FileName: "roistot.csv"}, nil)
+		commandDone <- err
+	}()
+
+	handler := <-handlerDone
+	if handler.err != nil {
+		t.Fatalf("request failed: %v", handler.err)
+	}
+	commandErr := <-commandDone
+
+	refused := 0
+	switch handler.status {
+	case fiber.StatusAccepted:
+		var response ImportJobResponse
+		if err := json.Unmarshal([]byte(handler.body), &response); err != nil {
+			t.Fatalf("invalid response %q: %v", handler.body, err)
+		}
+		if job := waitForImportJob(t, response.Job.ID); job.Status != db.ImportJobDone {
+			t.Fatalf("expected admin import to succeed, got %+v", job)
+		}
+	case fiber.StatusConflict:
+		if !strings.Contains(handler.body, "import already running") {
+			t.Fatalf("expected import already running, got %s", handler.body)
+		}
+		refused++
+	default:
+		t.Fatalf("unexpected status %d: %s", handler.status, handler.body)
+	}
+	switch {
+	case errors.Is(commandErr, db.ErrImportLocked):
+		refused++
+	case commandErr != nil:
+		t.Fatalf("importer command failed: %v", commandErr)
+	}
+
+	after, err := versionRepo.List()
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	existing := make(map[int]bool, len(before))
+	for _, version := range before {
+		existing[version.ID] = true
+	}
+	var written []*db.Version
+	for _, version := range after {
+		if !existing[version.ID] {
+			written = append(written, version)
+			t.Cleanup(func() { versionRepo.Remove(version.ID) })
+		}
+	}
+
+	if refused != 1 || len(written) != 1 {
+		t.Fatalf("expected one refused import and one written version, got %d refused and %d versions", refused, len(written))
+	}
+}
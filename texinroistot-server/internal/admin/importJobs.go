@@ -0,0 +1,165 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/importer"
+)
+
+const interruptedImportMessage = "import was interrupted by a server restart"
+
+// importTracker receives stage changes and entity counts of a running
+// import.
+type importTracker interface {
+	setStatus(status string)
+	setProgress(progress importer.Progress)
+	setContentHash(contentHash string)
+}
+
+// importJobTracker writes progress to the import_jobs table. Failing to
+// record progress is logged but does not abort the import itself.
+type importJobTracker struct {
+	repo   db.ImportJobRepository
+	jobID  int
+	status string
+}
+
+func (t *importJobTracker) setStatus(status string) {
+	if status == t.status {
+		return
+	}
+	t.status = status
+	if err := t.repo.SetStatus(t.jobID, status); err != nil {
+		log.Printf("import job %d: failed to set status %s: %v", t.jobID, status, err)
+	}
+}
+
+func (t *importJobTracker) setProgress(progress importer.Progress) {
+	t.setStatus(progress.Stage)
+	if err := t.repo.SetProgress(
+		t.jobID,
+		progress.VersionID,
+		progress.Rows,
+		progress.Parsed,
+		progress.Persisted,
+	); err != nil {
+		log.Printf("import job %d: failed to record progress: %v", t.jobID, err)
+	}
+}
+
+func (t *importJobTracker) setContentHash(contentHash string) {
+	if err := t.repo.SetContentHash(t.jobID, contentHash); err != nil {
+		log.Printf("import job %d: failed to record content hash: %v", t.jobID, err)
+	}
+}
+
+// runImportJob imports source and records the outcome on the job. It
+// returns the created version, or nil when the import failed. The caller
+// holds the import guard and lock.
+func runImportJob(jobRepo db.ImportJobRepository, jobID int, source importSource) *db.Version {
+	tracker := &importJobTracker{repo: jobRepo, jobID: jobID, status: db.ImportJobQueued}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("import job %d: panic: %v", jobID, recovered)
+			failImportJob(jobRepo, jobID, fmt.Errorf("panic: %v", recovered))
+		}
+	}()
+
+	version, err := runVersionImport(source, tracker)
+	if err != nil {
+		failImportJob(jobRepo, jobID, err)
+		return nil
+	}
+
+	if err := jobRepo.Finish(jobID, version.ID); err != nil {
+		log.Printf("import job %d: failed to mark done: %v", jobID, err)
+	}
+	return version
+}
+
+func failImportJob(jobRepo db.ImportJobRepository, jobID int, importErr error) {
+	if err := jobRepo.Fail(jobID, importErrorMessage(importErr)); err != nil {
+		log.Printf("import job %d: failed to mark failed: %v", jobID, err)
+	}
+}
+
+// importErrorMessage exposes problems with the spreadsheet itself to admins
+// and hides database and other internal errors.
+func importErrorMessage(err error) string {
+	var missingColumnsErr *importer.MissingColumnsError
+	if errors.As(err, &missingColumnsErr) ||
+		errors.Is(err, errInvalidImportURL) ||
+		errors.Is(err, errImportDownloadFailed) ||
+		errors.Is(err, errImportInvalidSpreadsheet) ||
+		errors.Is(err, errImportInvalidUpload) {
+		return err.Error()
+	}
+	log.Printf("import failed: %v", err)
+	return "failed to import version"
+}
+
+// RecoverImportJobs marks jobs left unfinished by a previous process as
+// failed so that the admin UI does not poll them forever, and removes the
+// versions they had partly written. Call it once at
+// startup before any import is started. When another replica holds the
+// import lock its job is still running, so nothing is recovered.
+func RecoverImportJobs() error {
+	lock, err := acquireImportLock()
+	if errors.Is(err, db.ErrImportLocked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer releaseImportLock(lock)
+
+	jobRepo := newImportJobRepository()
+	jobs, err := jobRepo.ListUnfinished()
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		removed, err := jobRepo.FailInterrupted(job.ID, interruptedImportMessage)
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			log.Printf("import job %d: removed partial version %d", job.ID, removed)
+		}
+	}
+	return nil
+}
+
+func parseImportJobID(raw string) (int, error) {
+	jobID, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || jobID <= 0 {
+		return 0, errors.New("jobID must be a positive integer")
+	}
+	return jobID, nil
+}
+
+func ImportJobHandler(c *fiber.Ctx) error {
+	jobID, err := parseImportJobID(c.Params("jobID"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	jobRepo := newImportJobRepository()
+	job, err := jobRepo.Read(jobID)
+	if err != nil {
+		if errors.Is(err, db.ErrImportJobNotFound) {
+			return c.Status(404).JSON(api.Error("import job not found"))
+		}
+		return c.Status(500).JSON(api.Error("failed to load import job"))
+	}
+
+	return c.JSON(ImportJobResponse{Job: job})
+}
@@ -0,0 +1,307 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+// fakeImportLock stands in for the advisory lock another replica may hold.
+type fakeImportLock struct {
+	mu   sync.Mutex
+	held bool
+}
+
+func (l *fakeImportLock) acquire() (databaseLock, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held {
+		return nil, db.ErrImportLocked
+	}
+	l.held = true
+	return l, nil
+}
+
+func (l *fakeImportLock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.held = false
+	return nil
+}
+
+func (l *fakeImportLock) isHeld() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held
+}
+
+type fakeImportJobRepo struct {
+	mu         sync.Mutex
+	jobs       map[int]*db.ImportJob
+	statuses   []string
+	finished   chan int
+	importLock *fakeImportLock
+	// removedVersions lists the partial versions removed by FailInterrupted.
+	removedVersions []int
+}
+
+func newFakeImportJobRepo() *fakeImportJobRepo {
+	return &fakeImportJobRepo{
+		jobs:       map[int]*db.ImportJob{},
+		finished:   make(chan int, 10),
+		importLock: &fakeImportLock{},
+	}
+}
+
+func (r *fakeImportJobRepo) Create(sourceURL string, fileName string) (*db.ImportJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := &db.ImportJob{
+		ID:        len(r.jobs) + 1,
+		Status:    db.ImportJobQueued,
+		SourceURL: sourceURL,
+		FileName:  fileName,
+	}
+	r.jobs[job.ID] = job
+	copied := *job
+	return &copied, nil
+}
+
+func (r *fakeImportJobRepo) Read(jobID int) (*db.ImportJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return nil, db.ErrImportJobNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (r *fakeImportJobRepo) ListUnfinished() ([]*db.ImportJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var jobs []*db.ImportJob
+	for _, job := range r.jobs {
+		if !job.IsFinished() {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (r *fakeImportJobRepo) SetContentHash(jobID int, contentHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jobs[jobID].ContentHash = contentHash
+	return nil
+}
+
+func (r *fakeImportJobRepo) LastContentHash() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lastHash := ""
+	for id := 1; id <= len(r.jobs); id++ {
+		job := r.jobs[id]
+		finished := job.Status == db.ImportJobFailed || (job.Status == db.ImportJobDone && job.VersionID != nil)
+		if finished && job.ContentHash != "" {
+			lastHash = job.ContentHash
+		}
+	}
+	return lastHash, nil
+}
+
+func (r *fakeImportJobRepo) SetStatus(jobID int, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.statuses = append(r.statuses, status)
+	r.jobs[jobID].Status = status
+	return nil
+}
+
+func (r *fakeImportJobRepo) SetProgress(jobID int, versionID int, rows int, parsed db.ImportEntityCounts, persisted db.ImportEntityCounts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := r.jobs[jobID]
+	if versionID > 0 {
+		job.VersionID = &versionID
+	}
+	job.Rows = rows
+	job.Parsed = parsed
+	job.Persisted = persisted
+	return nil
+}
+
+func (r *fakeImportJobRepo) Finish(jobID int, versionID int) error {
+	r.mu.Lock()
+	job := r.jobs[jobID]
+	job.Status = db.ImportJobDone
+	job.VersionID = &versionID
+	r.mu.Unlock()
+
+	r.finished <- jobID
+	return nil
+}
+
+func (r *fakeImportJobRepo) Fail(jobID int, message string) error {
+	r.mu.Lock()
+	job := r.jobs[jobID]
+	job.Status = db.ImportJobFailed
+	job.Error = message
+	r.mu.Unlock()
+
+	r.finished <- jobID
+	return nil
+}
+
+func (r *fakeImportJobRepo) FailInterrupted(jobID int, message string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := r.jobs[jobID]
+	job.Status = db.ImportJobFailed
+	job.Error = message
+	job.ContentHash = ""
+	if job.VersionID == nil {
+		return 0, nil
+	}
+	removed := *job.VersionID
+	job.VersionID = nil
+	r.removedVersions = append(r.removedVersions, removed)
+	return removed, nil
+}
+
+// wait blocks until the background job finishes and its import guard has
+// been released.
+func (r *fakeImportJobRepo) wait(t *testing.T, jobID int) *db.ImportJob {
+	t.Helper()
+
+	select {
+	case finishedID := <-r.finished:
+		if finishedID != jobID {
+			t.Fatalf("expected job %d to finish, got %d", jobID, finishedID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for import job %d", jobID)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for isImportRunning() || r.importLock.isHeld() {
+		if time.Now().After(deadline) {
+			t.Fatalf("import guard or lock was not released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	job, _ := r.Read(jobID)
+	return job
+}
+
+func isImportRunning() bool {
+	importStateMu.Lock()
+	defer importStateMu.Unlock()
+	return importRunning
+}
+
+func TestImportJobHandler(t *testing.T) {
+	jobRepo := resetImportState(t)
+	job, _ := jobRepo.Create("https://example.com/roistot.xlsx", "")
+
+	app := fiber.New()
+	app.Get("/api/admin/imports/:jobID", ImportJobHandler)
+
+	cases := map[string]int{
+		"/api/admin/imports/1":   fiber.StatusOK,
+		"/api/admin/imports/2":   fiber.StatusNotFound,
+		"/api/admin/imports/abc": fiber.StatusBadRequest,
+	}
+	for target, expected := range cases {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if res.StatusCode != expected {
+			t.Fatalf("%s: expected %d, got %d", target, expected, res.StatusCode)
+		}
+	}
+
+	if job.Status != db.ImportJobQueued {
+		t.Fatalf("expected new job to be queued, got %s", job.Status)
+	}
+}
+
+func TestRecoverImportJobsFailsUnfinishedJobs(t *testing.T) {
+	jobRepo := resetImportState(t)
+	jobRepo.Create("https://example.com/a.xlsx", "")
+	jobRepo.Create("https://example.com/b.xlsx", "")
+	jobRepo.Finish(2, 7)
+	<-jobRepo.finished
+	jobRepo.Create("https://example.com/c.xlsx", "")
+	jobRepo.SetProgress(3, 9, 120, db.ImportEntityCounts{}, db.ImportEntityCounts{})
+
+	if err := RecoverImportJobs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, jobID := range []int{1, 3} {
+		interrupted, _ := jobRepo.Read(jobID)
+		if interrupted.Status != db.ImportJobFailed || interrupted.Error != interruptedImportMessage {
+			t.Fatalf("expected interrupted job %d to be failed, got %+v", jobID, interrupted)
+		}
+	}
+	done, _ := jobRepo.Read(2)
+	if done.Status != db.ImportJobDone || done.VersionID == nil || *done.VersionID != 7 {
+		t.Fatalf("expected finished job to be left alone, got %+v", done)
+	}
+	if len(jobRepo.removedVersions) != 1 || jobRepo.removedVersions[0] != 9 {
+		t.Fatalf("expected only the partial version 9 to be removed, got %v", jobRepo.removedVersions)
+	}
+}
+
+func TestImportVersionHandlerReturnsConflictWhenAnotherReplicaImports(t *testing.T) {
+	jobRepo := resetImportState(t)
+	jobRepo.importLock.held = true
+
+	app := fiber.New()
+	app.Post("/api/admin/versions/import", ImportVersionHandler)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/import", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusConflict {
+		t.Fatalf("expected %d, got %d", fiber.StatusConflict, res.StatusCode)
+	}
+	if isImportRunning() {
+		t.Fatalf("expected in-process guard to be released")
+	}
+}
+
+func TestRecoverImportJobsSkipsWhileAnotherReplicaImports(t *testing.T) {
+	jobRepo := resetImportState(t)
+	jobRepo.Create("https://example.com/a.xlsx", "")
+	jobRepo.importLock.held = true
+
+	if err := RecoverImportJobs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	job, _ := jobRepo.Read(1)
+	if job.Status != db.ImportJobQueued {
+		t.Fatalf("expected running job to be left alone, got %+v", job)
+	}
+}
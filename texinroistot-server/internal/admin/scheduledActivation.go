@@ -0,0 +1,125 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+const interruptedActivationMessage = "activation was interrupted by a server restart"
+
+var acquireScheduledActivationLock = tryDatabaseScheduledActivationLock
+
+func tryDatabaseScheduledActivationLock() (databaseLock, error) {
+	return db.TryScheduledActivationLock()
+}
+
+func releaseScheduledActivationLock(lock databaseLock) {
+	if err := lock.Release(); err != nil {
+		log.Printf("failed to release scheduled activation lock: %v", err)
+	}
+}
+
+// RecoverScheduledActivations marks activations claimed by a previous
+// process that stopped before finishing them as failed, so that they do not
+// stay running forever. Call it once at startup before the scheduler runs.
+// Replicas hold the scheduled activation lock while running activations, so
+// when another replica holds it nothing is recovered; otherwise every
+// running activation was left behind by a stopped process.
+func RecoverScheduledActivations() error {
+	lock, err := acquireScheduledActivationLock()
+	if errors.Is(err, db.ErrScheduledActivationLocked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer releaseScheduledActivationLock(lock)
+
+	recovered, err := newVersionActivationRepository().FailRunning(interruptedActivationMessage)
+	if err != nil {
+		return err
+	}
+	if recovered > 0 {
+		log.Printf("marked %d interrupted scheduled activations as failed", recovered)
+	}
+	return nil
+}
+
+// StartActivationScheduler activates versions whose scheduled activation is
+// due, checking every interval. Call the returned function to stop it.
+func StartActivationScheduler(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := runDueActivations(); err != nil {
+					log.Printf("scheduled activation: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// runDueActivations claims the due activations and runs them in schedule
+// order. Claiming is atomic, so replicas never run the same activation. The
+// scheduled activation lock is held from claiming to finishing, which tells
+// RecoverScheduledActivations on other replicas that the claimed rows are
+// still being worked on; a replica that finds the lock taken skips the tick.
+func runDueActivations() error {
+	lock, err := acquireScheduledActivationLock()
+	if errors.Is(err, db.ErrScheduledActivationLocked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer releaseScheduledActivationLock(lock)
+
+	activationRepo := newVersionActivationRepository()
+	due, err := activationRepo.ClaimDue()
+	if err != nil {
+		return err
+	}
+
+	versionRepo := newVersionRepository()
+	for _, scheduled := range due {
+		err := runScheduledActivation(versionRepo, scheduled)
+		auditSystemChange(scheduledActivationAction, fmt.Sprintf("scheduleID=%d,versionID=%d", scheduled.ID, scheduled.VersionID), err)
+
+		failure := ""
+		if err != nil {
+			failure = err.Error()
+			log.Printf("scheduled activation %d: %v", scheduled.ID, err)
+		} else {
+			log.Printf("scheduled activation %d: activated version %d", scheduled.ID, scheduled.VersionID)
+		}
+		if err := activationRepo.FinishScheduled(scheduled.ID, failure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runScheduledActivation(versionRepo db.VersionRepository, scheduled *db.ScheduledActivation) error {
+	report, err := versionRepo.SetActive(scheduled.VersionID, db.Activation{
+		UserID: scheduled.CreatedByID,
+		Reason: db.ActivationScheduled,
+		Force:  scheduled.Force,
+	})
+	if errors.Is(err, db.ErrActivationChecksFailed) {
+		return fmt.Errorf("%w: %s", err, formatActivationIssues(report))
+	}
+	return err
+}
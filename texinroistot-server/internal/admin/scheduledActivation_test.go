@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func TestRunDueActivations(t *testing.T) {
+	versionRepo := &fakeVersionRepo{}
+	activationRepo := &fakeVersionActivationRepo{scheduled: []*db.ScheduledActivation{
+		{ID: 1, VersionID: 5, ActivateAt: time.Now().Add(-time.Minute), Status: db.ScheduledActivationPending, CreatedByID: 7},
+		{ID: 2, VersionID: 6, ActivateAt: time.Now().Add(time.Hour), Status: db.ScheduledActivationPending},
+	}}
+	newActivationTestApp(t, versionRepo, activationRepo)
+	auditRepo := stubAuditLog(t)
+
+	if err := runDueActivations(); err != nil {
+		t.Fatalf("runDueActivations failed: %v", err)
+	}
+
+	if len(versionRepo.activated) != 1 || versionRepo.activated[0] != 5 {
+		t.Fatalf("expected only version 5 to be activated, got %v", versionRepo.activated)
+	}
+	activation := versionRepo.activations[0]
+	if activation.Reason != db.ActivationScheduled || activation.UserID != 7 {
+		t.Fatalf("expected scheduled activation by user 7, got %+v", activation)
+	}
+	if failure, ok := activationRepo.finished[1]; !ok || failure != "" {
+		t.Fatalf("expected activation 1 to be done, got %q", failure)
+	}
+	if _, ok := activationRepo.finished[2]; ok {
+		t.Fatalf("expected activation 2 to stay pending")
+	}
+	assertSystemAudit(t, auditRepo, scheduledActivationAction, "scheduleID=1,versionID=5", db.AuditSuccess)
+}
+
+// assertSystemAudit checks that a scheduler recorded exactly one change.
+func assertSystemAudit(t *testing.T, auditRepo *fakeAuditLogRepo, action string, target string, outcome string) {
+	t.Helper()
+
+	if len(auditRepo.entries) != 1 {
+		t.Fatalf("expected one audit entry, got %+v", auditRepo.entries)
+	}
+	entry := auditRepo.entries[0]
+	if entry.ActorHash != systemActor || entry.Action != action || entry.Target != target || entry.Outcome != outcome {
+		t.Fatalf("expected %s %s by %s with outcome %s, got %+v", action, target, systemActor, outcome, entry)
+	}
+}
+
+func TestRunDueActivationsRecordsFailedChecks(t *testing.T) {
+	versionRepo := &fakeVersionRepo{issues: []db.ActivationIssue{
+		{Check: "empty-version", Message: "version has no villains"},
+	}}
+	activationRepo := &fakeVersionActivationRepo{scheduled: []*db.ScheduledActivation{
+		{ID: 1, VersionID: 5, ActivateAt: time.Now().Add(-time.Minute), Status: db.ScheduledActivationPending},
+	}}
+	newActivationTestApp(t, versionRepo, activationRepo)
+	auditRepo := stubAuditLog(t)
+
+	if err := runDueActivations(); err != nil {
+		t.Fatalf("runDueActivations failed: %v", err)
+	}
+
+	if len(versionRepo.activated) != 0 {
+		t.Fatalf("expected version not to be activated")
+	}
+	if failure := activationRepo.finished[1]; !strings.Contains(failure, "version has no villains") {
+		t.Fatalf("expected failure to list the issues, got %q", failure)
+	}
+	assertSystemAudit(t, auditRepo, scheduledActivationAction, "scheduleID=1,versionID=5", db.AuditFailure)
+}
+
+func TestRecoverScheduledActivations(t *testing.T) {
+	activationRepo := &fakeVersionActivationRepo{scheduled: []*db.ScheduledActivation{
+		{ID: 1, VersionID: 5, Status: db.ScheduledActivationRunning},
+		{ID: 2, VersionID: 6, Status: db.ScheduledActivationPending},
+	}}
+	newActivationTestApp(t, &fakeVersionRepo{}, activationRepo)
+
+	if err := RecoverScheduledActivations(); err != nil {
+		t.Fatalf("RecoverScheduledActivations failed: %v", err)
+	}
+
+	interrupted, pending := activationRepo.scheduled[0], activationRepo.scheduled[1]
+	if interrupted.Status != db.ScheduledActivationFailed || interrupted.Error != interruptedActivationMessage {
+		t.Fatalf("expected running activation to fail as interrupted, got %+v", interrupted)
+	}
+	if pending.Status != db.ScheduledActivationPending {
+		t.Fatalf("expected pending activation to stay pending, got %q", pending.Status)
+	}
+}
+
+func TestRecoverScheduledActivationsSkipsWhileAnotherReplicaRuns(t *testing.T) {
+	activationRepo := &fakeVersionActivationRepo{
+		scheduled: []*db.ScheduledActivation{
+			{ID: 1, VersionID: 5, Status: db.ScheduledActivationRunning},
+		},
+		lockedElsewhere: true,
+	}
+	newActivationTestApp(t, &fakeVersionRepo{}, activationRepo)
+
+	if err := RecoverScheduledActivations(); err != nil {
+		t.Fatalf("RecoverScheduledActivations failed: %v", err)
+	}
+	if status := activationRepo.scheduled[0].Status; status != db.ScheduledActivationRunning {
+		t.Fatalf("expected activation run by another replica to stay running, got %q", status)
+	}
+
+	if err := runDueActivations(); err != nil {
+		t.Fatalf("runDueActivations failed: %v", err)
+	}
+	if len(activationRepo.finished) != 0 {
+		t.Fatalf("expected the tick to be skipped while another replica runs activations")
+	}
+}
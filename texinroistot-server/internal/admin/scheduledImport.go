@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/importer"
+)
+
+var newVersionRepository = db.NewVersionRepository
+
+// StartImportScheduler checks the configured import URL every interval and
+// imports the spreadsheet when it has changed since the last import. Call
+// the returned function to stop the scheduler.
+func StartImportScheduler(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := runScheduledImport(); err != nil {
+					log.Printf("scheduled import: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// runScheduledImport downloads the configured spreadsheet and imports it
+// unless its content hash matches the file of the last import attempt, so
+// a file that failed to import is not retried on every tick. The download
+// happens under the import lock so that replicas running the scheduler do
+// not import the same file twice.
+func runScheduledImport() error {
+	fileURL, err := buildImportURL(config.ImportExcelURL)
+	if err != nil {
+		return err
+	}
+
+	if !startImport() {
+		return nil
+	}
+	defer finishImport()
+
+	lock, err := acquireImportLock()
+	if errors.Is(err, db.ErrImportLocked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer releaseImportLock(lock)
+
+	content, err := downloadSpreadsheet(fileURL)
+	if err != nil {
+		return err
+	}
+
+	jobRepo := newImportJobRepository()
+	lastHash, err := jobRepo.LastContentHash()
+	if err != nil {
+		return err
+	}
+	if importer.ContentHash(content) == lastHash {
+		return nil
+	}
+
+	job, err := jobRepo.Create(fileURL, "")
+	if err != nil {
+		return err
+	}
+	log.Printf("scheduled import: spreadsheet changed, started import job %d", job.ID)
+
+	version := runImportJob(jobRepo, job.ID, importSource{url: fileURL, content: content})
+	if version == nil || !config.ImportAutoActivate {
+		return nil
+	}
+
+	err = autoActivateVersion(newVersionRepository(), version)
+	auditSystemChange(importActivationAction, fmt.Sprintf("versionID=%d", version.ID), err)
+	if err != nil {
+		return fmt.Errorf("version %d was not activated: %w", version.ID, err)
+	}
+	log.Printf("scheduled import: activated version %d", version.ID)
+	return nil
+}
+
+// autoActivateVersion activates version unless the activation checks
+// report issues; scheduled imports never force activation.
+func autoActivateVersion(versionRepo db.VersionRepository, version *db.Version) error {
+	report, err := versionRepo.SetActive(version.ID, db.Activation{Reason: db.ActivationImport})
+	if errors.Is(err, db.ErrActivationChecksFailed) {
+		return fmt.Errorf("%w: %s", err, formatActivationIssues(report))
+	}
+	return err
+}
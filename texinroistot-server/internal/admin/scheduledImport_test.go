@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/importer"
+)
+
+// resetScheduledImportState serves content as the configured import file
+// and counts the imports runScheduledImport starts.
+func resetScheduledImportState(t *testing.T, content []byte) (*fakeImportJobRepo, *fakeVersionRepo, *int) {
+	t.Helper()
+
+	jobRepo := resetImportState(t)
+	versionRepo := &fakeVersionRepo{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+
+	imports := 0
+	runVersionImport = func(_ importSource, _ importTracker) (*db.Version, error) {
+		imports++
+		return &db.Version{ID: 40 + imports}, nil
+	}
+	newVersionRepository = func() db.VersionRepository { return versionRepo }
+	stubAuditLog(t)
+
+	importURL, autoActivate := config.ImportExcelURL, config.ImportAutoActivate
+	config.ImportExcelURL = server.URL
+	t.Cleanup(func() {
+		newVersionRepository = db.NewVersionRepository
+		config.ImportExcelURL, config.ImportAutoActivate = importURL, autoActivate
+	})
+
+	return jobRepo, versionRepo, &imports
+}
+
+var scheduledCSV = []byte("Nimi;Rooli\nMefisto;johtaja\n")
+
+func TestRunScheduledImportSkipsUnchangedFile(t *testing.T) {
+	jobRepo, _, imports := resetScheduledImportState(t, scheduledCSV)
+	job, _ := jobRepo.Create("https://example.com/roistot.csv", "")
+	jobRepo.SetContentHash(job.ID, importer.ContentHash(scheduledCSV))
+	jobRepo.Finish(job.ID, 7)
+
+	if err := runScheduledImport(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *imports != 0 || len(jobRepo.jobs) != 1 {
+		t.Fatalf("expected unchanged file to be skipped, got %d imports and %d jobs", *imports, len(jobRepo.jobs))
+	}
+	if isImportRunning() || jobRepo.importLock.isHeld() {
+		t.Fatalf("expected import guard and lock to be released")
+	}
+}
+
+func TestRunScheduledImportSkipsFileThatFailedToImport(t *testing.T) {
+	jobRepo, _, imports := resetScheduledImportState(t, scheduledCSV)
+	imported, _ := jobRepo.Create("https://example.com/roistot.csv", "")
+	jobRepo.SetContentHash(imported.ID, importer.ContentHash([]byte("older file")))
+	jobRepo.Finish(imported.ID, 7)
+	failed, _ := jobRepo.Create("https://example.com/roistot.csv", "")
+	jobRepo.SetContentHash(failed.ID, importer.ContentHash(scheduledCSV))
+	jobRepo.Fail(failed.ID, "missing required columns: roles")
+
+	if err := runScheduledImport(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *imports != 0 || len(jobRepo.jobs) != 2 {
+		t.Fatalf("expected failed file not to be retried, got %d imports and %d jobs", *imports, len(jobRepo.jobs))
+	}
+}
+
+func TestRunScheduledImportRetriesInterruptedImport(t *testing.T) {
+	jobRepo, _, imports := resetScheduledImportState(t, scheduledCSV)
+	interrupted, _ := jobRepo.Create("https://example.com/roistot.csv", "")
+	jobRepo.SetContentHash(interrupted.ID, importer.ContentHash(scheduledCSV))
+	if err := RecoverImportJobs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := runScheduledImport(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *imports != 1 {
+		t.Fatalf("expected interrupted import to be retried, got %d imports", *imports)
+	}
+}
+
+func TestRunScheduledImportImportsChangedFile(t *testing.T) {
+	jobRepo, versionRepo, imports := resetScheduledImportState(t, scheduledCSV)
+	job, _ := jobRepo.Create("https://example.com/roistot.csv", "")
+	jobRepo.SetContentHash(job.ID, importer.ContentHash([]byte("older file")))
+	jobRepo.Finish(job.ID, 7)
+
+	if err := runScheduledImport(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *imports != 1 {
+		t.Fatalf("expected changed file to be imported, got %d imports", *imports)
+	}
+	imported, _ := jobRepo.Read(2)
+	if imported.Status != db.ImportJobDone {
+		t.Fatalf("expected scheduled job to finish, got %+v", imported)
+	}
+	if len(versionRepo.activated) != 0 {
+		t.Fatalf("expected no activation without auto-activate, got %v", versionRepo.activated)
+	}
+}
+
+func TestRunScheduledImportAutoActivation(t *testing.T) {
+	_, versionRepo, _ := resetScheduledImportState(t, scheduledCSV)
+	config.ImportAutoActivate = true
+	auditRepo := stubAuditLog(t)
+
+	if err := runScheduledImport(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(versionRepo.activated) != 1 || versionRepo.activated[0] != 41 {
+		t.Fatalf("expected version 41 to be activated, got %v", versionRepo.activated)
+	}
+	assertSystemAudit(t, auditRepo, importActivationAction, "versionID=41", db.AuditSuccess)
+}
+
+func TestRunScheduledImportDoesNotForceActivation(t *testing.T) {
+	_, versionRepo, _ := resetScheduledImportState(t, scheduledCSV)
+	config.ImportAutoActivate = true
+	versionRepo.issues = []db.ActivationIssue{{Check: "count-drop", Message: "villains dropped from 100 to 10"}}
+	auditRepo := stubAuditLog(t)
+
+	err := runScheduledImport()
+	if !errors.Is(err, db.ErrActivationChecksFailed) {
+		t.Fatalf("expected ErrActivationChecksFailed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "villains dropped from 100 to 10") {
+		t.Fatalf("expected issues in error, got %v", err)
+	}
+	if len(versionRepo.activated) != 0 {
+		t.Fatalf("expected no activation, got %v", versionRepo.activated)
+	}
+	assertSystemAudit(t, auditRepo, importActivationAction, "versionID=41", db.AuditFailure)
+}
@@ -0,0 +1,515 @@
+package admin
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+var (
+	unauthorizedResponse = openapi.Response{Description: "Not signed in"}
+	forbiddenResponse    = openapi.Response{Description: "Missing the required role"}
+	publisherRoles       = []string{db.RolePublisher}
+)
+
+func Operations() []openapi.Operation {
+	versionIDParameter := openapi.Parameter{
+		Name:    "versionID",
+		In:      openapi.ParamInPath,
+		Type:    "integer",
+		Minimum: openapi.IntPtr(1),
+	}
+	keepParameter := openapi.Parameter{
+		Name:        "keep",
+		In:          openapi.ParamInQuery,
+		Description: "Number of newest versions to keep, defaults to ROISTOT_RETENTION_KEEP_VERSIONS",
+		Type:        "integer",
+		Minimum:     openapi.IntPtr(0),
+	}
+
+	userListParameters := append(openapi.PageParameters(defaultUsersPageSize, maxUsersPageSize),
+		openapi.Parameter{
+			Name: "role",
+			In:   openapi.ParamInQuery,
+			Enum: db.Roles,
+		},
+		openapi.Parameter{
+			Name:        "q",
+			In:          openapi.ParamInQuery,
+			Description: "An email matches its user exactly, anything else matches the beginning of the user hash.",
+		},
+	)
+
+	operations := []openapi.Operation{
+		{
+			Method:     "GET",
+			Path:       "/api/admin/users",
+			Summary:    "List users",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      []string{db.RoleUserAdmin},
+			Parameters: userListParameters,
+			Responses: map[int]openapi.Response{
+				200: {Body: UsersListResponse{}},
+				400: {Description: "Invalid query parameter", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:      "POST",
+			Path:        "/api/admin/users/grant-admin",
+			Summary:     "Grant every role to a user",
+			Tag:         "admin",
+			Protected:   true,
+			Roles:       []string{db.RoleUserAdmin},
+			RequestBody: GrantAdminPayload{},
+			Responses: map[int]openapi.Response{
+				200: {Body: GrantAdminResponse{}},
+				400: {Description: "Invalid request body", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "User not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:      "POST",
+			Path:        "/api/admin/users/revoke-admin",
+			Summary:     "Remove every role from a user",
+			Tag:         "admin",
+			Protected:   true,
+			Roles:       []string{db.RoleUserAdmin},
+			RequestBody: GrantAdminPayload{},
+			Responses: map[int]openapi.Response{
+				200: {Body: GrantAdminResponse{}},
+				400: {Description: "Invalid request body", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "User not found", Body: api.ErrorResponse{}},
+				409: {Description: "The user is the last user admin", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:      "PUT",
+			Path:        "/api/admin/users/:userHash/roles",
+			Summary:     "Replace the roles of a user",
+			Tag:         "admin",
+			Protected:   true,
+			Roles:       []string{db.RoleUserAdmin},
+			Parameters:  []openapi.Parameter{{Name: "userHash", In: openapi.ParamInPath}},
+			RequestBody: SetUserRolesPayload{},
+			Responses: map[int]openapi.Response{
+				200: {Body: UserResponse{}},
+				400: {Description: "Invalid request body or unknown role", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "User not found", Body: api.ErrorResponse{}},
+				409: {Description: "The user admin role would be removed from the last user admin", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "DELETE",
+			Path:       "/api/admin/users/:userHash/sessions",
+			Summary:    "Sign a user out on every device",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      []string{db.RoleUserAdmin},
+			Parameters: []openapi.Parameter{{Name: "userHash", In: openapi.ParamInPath}},
+			Responses: map[int]openapi.Response{
+				200: {Body: auth.RevokeSessionsResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "User not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/audit",
+			Summary:   "List changes made through the admin API, newest first",
+			Tag:       "admin",
+			Protected: true,
+			Roles:     []string{db.RoleUserAdmin},
+			Parameters: append(openapi.PageParameters(defaultAuditPageSize, maxAuditPageSize),
+				openapi.Parameter{
+					Name:        "actor",
+					In:          openapi.ParamInQuery,
+					Description: "User hash, or an email that is matched by its hash",
+				},
+				openapi.Parameter{
+					Name:        "action",
+					In:          openapi.ParamInQuery,
+					Description: "Method and route, e.g. POST /api/admin/versions/:versionID/activate",
+				},
+				openapi.Parameter{
+					Name:        "target",
+					In:          openapi.ParamInQuery,
+					Description: "Target of the action, e.g. versionID=5",
+				},
+				openapi.Parameter{
+					Name: "outcome",
+					In:   openapi.ParamInQuery,
+					Enum: auditOutcomes,
+				},
+				openapi.Parameter{
+					Name:        "from",
+					In:          openapi.ParamInQuery,
+					Description: "RFC 3339 time, inclusive",
+				},
+				openapi.Parameter{
+					Name:        "to",
+					In:          openapi.ParamInQuery,
+					Description: "RFC 3339 time, exclusive",
+				},
+			),
+			Responses: map[int]openapi.Response{
+				200: {Body: AuditListResponse{}},
+				400: {Description: "Invalid query parameter", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/api-keys",
+			Summary:   "List your API keys, or with all=true the keys of every user",
+			Tag:       "admin",
+			Protected: true,
+			Parameters: []openapi.Parameter{
+				{
+					Name:        "all",
+					In:          openapi.ParamInQuery,
+					Description: "List the keys of every user. Requires the user-admin role.",
+					Type:        "boolean",
+					Default:     false,
+				},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: APIKeysListResponse{}},
+				400: {Description: "Invalid all value", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:      "POST",
+			Path:        "/api/admin/api-keys",
+			Summary:     "Create an API key with some of your roles; the key is returned only once",
+			Tag:         "admin",
+			Protected:   true,
+			RequestBody: CreateAPIKeyPayload{},
+			Responses: map[int]openapi.Response{
+				201: {Body: CreateAPIKeyResponse{}},
+				400: {Description: "Invalid name, scopes or expiry", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: {Description: "A scope is not held by you, or the request used an API key", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "DELETE",
+			Path:      "/api/admin/api-keys/:keyID",
+			Summary:   "Revoke an API key; user admins can revoke the keys of any user",
+			Tag:       "admin",
+			Protected: true,
+			Parameters: []openapi.Parameter{
+				{Name: "keyID", In: openapi.ParamInPath, Type: "integer", Minimum: openapi.IntPtr(1)},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: RevokeAPIKeyResponse{}},
+				400: {Description: "Invalid key ID", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "API key not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/versions",
+			Summary:   "List imported versions",
+			Tag:       "admin",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: VersionListResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "POST",
+			Path:      "/api/admin/versions/import",
+			Summary:   "Queue an import of an uploaded file, an allowed URL or the configured spreadsheet",
+			Tag:       "admin",
+			Protected: true,
+			Roles:     []string{db.RoleEditor},
+			// JSON bodies with only the url field are accepted as well
+			RequestBody:         ImportVersionUpload{},
+			RequestContentType:  "multipart/form-data",
+			RequestBodyOptional: true,
+			Responses: map[int]openapi.Response{
+				202: {Description: "Import job queued", Body: ImportJobResponse{}},
+				400: {Description: "Import URL or uploaded file is invalid", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				409: {Description: "Another import is running", Body: api.ErrorResponse{}},
+				413: {Description: "Uploaded file is too large", Body: api.ErrorResponse{}},
+				500: {Description: "Import job could not be created", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/imports/:jobID",
+			Summary:   "Get the status and progress of an import job",
+			Tag:       "admin",
+			Protected: true,
+			Parameters: []openapi.Parameter{
+				{Name: "jobID", In: openapi.ParamInPath, Type: "integer", Minimum: openapi.IntPtr(1)},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: ImportJobResponse{}},
+				400: {Description: "Invalid job ID", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Import job not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "POST",
+			Path:      "/api/admin/versions/:versionID/activate",
+			Summary:   "Activate a version that passes the activation checks",
+			Tag:       "admin",
+			Protected: true,
+			Roles:     publisherRoles,
+			Parameters: []openapi.Parameter{
+				versionIDParameter,
+				{
+					Name:        "force",
+					In:          openapi.ParamInQuery,
+					Description: "Activate even when the activation checks report issues",
+					Type:        "boolean",
+					Default:     false,
+				},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: ActivateVersionResponse{}},
+				400: {Description: "Invalid version ID or force flag", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Version not found", Body: api.ErrorResponse{}},
+				422: {Description: "Activation checks reported issues", Body: ActivationFailedResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "POST",
+			Path:       "/api/admin/versions/:versionID/schedule-activation",
+			Summary:    "Schedule a version to be activated at a given time",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      publisherRoles,
+			Parameters: []openapi.Parameter{versionIDParameter},
+			// Activation checks run when the activation is due
+			RequestBody: ScheduleActivationPayload{},
+			Responses: map[int]openapi.Response{
+				201: {Body: ScheduledActivationResponse{}},
+				400: {Description: "Invalid version ID or activation time", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Version not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/versions/scheduled-activations",
+			Summary:   "List pending scheduled activations",
+			Tag:       "admin",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: ScheduledActivationsResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "DELETE",
+			Path:      "/api/admin/versions/scheduled-activations/:scheduleID",
+			Summary:   "Cancel a pending scheduled activation",
+			Tag:       "admin",
+			Protected: true,
+			Roles:     publisherRoles,
+			Parameters: []openapi.Parameter{
+				{Name: "scheduleID", In: openapi.ParamInPath, Type: "integer", Minimum: openapi.IntPtr(1)},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: CancelScheduledActivationResponse{}},
+				400: {Description: "Invalid schedule ID", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Scheduled activation not found", Body: api.ErrorResponse{}},
+				409: {Description: "Scheduled activation has already been processed", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "POST",
+			Path:      "/api/admin/versions/rollback",
+			Summary:   "Reactivate the version that was active before the current one",
+			Tag:       "admin",
+			Protected: true,
+			Roles:     publisherRoles,
+			Responses: map[int]openapi.Response{
+				200: {Body: ActivateVersionResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				409: {Description: "No previous version to roll back to", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/versions/activations",
+			Summary:   "List the latest version activations",
+			Tag:       "admin",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: ActivationHistoryResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:      "PATCH",
+			Path:        "/api/admin/versions/:versionID",
+			Summary:     "Edit the label and notes of a version",
+			Tag:         "admin",
+			Protected:   true,
+			Roles:       []string{db.RoleEditor, db.RolePublisher},
+			Parameters:  []openapi.Parameter{versionIDParameter},
+			RequestBody: UpdateVersionPayload{},
+			Responses: map[int]openapi.Response{
+				200: {Body: VersionResponse{}},
+				400: {Description: "Invalid version ID, label or notes", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Version not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "PUT",
+			Path:       "/api/admin/versions/:versionID/pin",
+			Summary:    "Pin a version so that pruning keeps it",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      publisherRoles,
+			Parameters: []openapi.Parameter{versionIDParameter},
+			Responses: map[int]openapi.Response{
+				200: {Body: VersionResponse{}},
+				400: {Description: "Invalid version ID", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Version not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "DELETE",
+			Path:       "/api/admin/versions/:versionID/pin",
+			Summary:    "Unpin a version",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      publisherRoles,
+			Parameters: []openapi.Parameter{versionIDParameter},
+			Responses: map[int]openapi.Response{
+				200: {Body: VersionResponse{}},
+				400: {Description: "Invalid version ID", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Version not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/admin/versions/disk-usage",
+			Summary:   "Get the approximate disk usage of each version",
+			Tag:       "admin",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: db.DiskUsageReport{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "GET",
+			Path:       "/api/admin/versions/prune",
+			Summary:    "Preview which versions the retention policy would delete",
+			Tag:        "admin",
+			Protected:  true,
+			Parameters: []openapi.Parameter{keepParameter},
+			Responses: map[int]openapi.Response{
+				200: {Body: db.PrunePlan{}},
+				400: {Description: "Invalid keep value", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "POST",
+			Path:       "/api/admin/versions/prune",
+			Summary:    "Delete the versions the retention policy does not keep",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      publisherRoles,
+			Parameters: []openapi.Parameter{keepParameter},
+			Responses: map[int]openapi.Response{
+				200: {Body: PruneVersionsResponse{}},
+				400: {Description: "Invalid keep value", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				409: {Description: "An import is running", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:     "DELETE",
+			Path:       "/api/admin/versions/:versionID",
+			Summary:    "Delete an inactive version",
+			Tag:        "admin",
+			Protected:  true,
+			Roles:      publisherRoles,
+			Parameters: []openapi.Parameter{versionIDParameter},
+			Responses: map[int]openapi.Response{
+				200: {Body: DeleteVersionResponse{}},
+				400: {Description: "Invalid version ID", Body: api.ErrorResponse{}},
+				401: unauthorizedResponse,
+				403: forbiddenResponse,
+				404: {Description: "Version not found", Body: api.ErrorResponse{}},
+				409: {Description: "Version is active", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+	}
+
+	// Every admin route goes through ProtectedRoute, which accepts API keys.
+	for i := range operations {
+		operations[i].APIKey = true
+	}
+	return operations
+}
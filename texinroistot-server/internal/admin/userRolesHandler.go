@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type SetUserRolesPayload struct {
+	Roles []string `json:"roles"`
+}
+
+type UserResponse struct {
+	User *db.User `json:"user"`
+}
+
+// SetUserRolesHandler replaces the roles of a user. An empty list revokes
+// access to the admin API. The last user admin keeps the user-admin role.
+func SetUserRolesHandler(c *fiber.Ctx) error {
+	userHash := strings.TrimSpace(c.Params("userHash"))
+	if userHash == "" {
+		return c.Status(400).JSON(api.Error("userHash is required"))
+	}
+
+	payload := new(SetUserRolesPayload)
+	if err := c.BodyParser(payload); err != nil || payload.Roles == nil {
+		return c.Status(400).JSON(api.Error("roles is required"))
+	}
+	roles, err := normalizeRoles(payload.Roles)
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	user, err := newUserRepository().SetRoles(userHash, roles)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(404).JSON(api.Error("user not found"))
+		}
+		if errors.Is(err, db.ErrLastUserAdmin) {
+			return c.Status(409).JSON(api.Error(err.Error()))
+		}
+		return c.Status(500).JSON(api.Error("failed to update roles"))
+	}
+
+	return c.JSON(UserResponse{User: withDisplayEmail(user)})
+}
+
+// normalizeRoles rejects unknown roles and returns the rest in db.Roles
+// order without duplicates.
+func normalizeRoles(requested []string) ([]string, error) {
+	wanted := map[string]bool{}
+	for _, role := range requested {
+		role = strings.TrimSpace(role)
+		if !db.IsRole(role) {
+			return nil, errors.New("unknown role " + role + ", expected one of " + strings.Join(db.Roles, ", "))
+		}
+		wanted[role] = true
+	}
+
+	roles := []string{}
+	for _, role := range db.Roles {
+		if wanted[role] {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
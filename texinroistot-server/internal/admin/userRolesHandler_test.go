@@ -0,0 +1,246 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type fakeUserRepo struct {
+	db.UserRepository
+	users         map[string]*db.User
+	lastUserAdmin string
+	listParams    db.UserListParams
+}
+
+func (r *fakeUserRepo) List(params db.UserListParams) ([]*db.User, *db.ListMeta, error) {
+	r.listParams = params
+	users := []*db.User{}
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	return users, &db.ListMeta{Total: 30, PageIndex: params.Page - 1, PageSize: params.PageSize}, nil
+}
+
+func (r *fakeUserRepo) SetRoles(userHash string, roles []string) (*db.User, error) {
+	user, ok := r.users[userHash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if userHash == r.lastUserAdmin && !reflect.DeepEqual(roles, db.Roles) {
+		return nil, db.ErrLastUserAdmin
+	}
+	user.Roles = roles
+	user.IsAdmin = len(roles) > 0
+	return user, nil
+}
+
+func newUserRolesTestApp(t *testing.T, userRepo *fakeUserRepo) *fiber.App {
+	t.Helper()
+
+	newUserRepository = func() db.UserRepository { return userRepo }
+	t.Cleanup(func() { newUserRepository = db.NewUserRepository })
+
+	app := fiber.New()
+	app.Get("/api/admin/users", ListUsersHandler)
+	app.Post("/api/admin/users/revoke-admin", RevokeAdminHandler)
+	app.Put("/api/admin/users/:userHash/roles", SetUserRolesHandler)
+	return app
+}
+
+func TestSetUserRolesHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantRoles  []string
+	}{
+		{
+			name:       "grant in canonical order",
+			path:       "/api/admin/users/abc/roles",
+			body:       `{"roles":["publisher","viewer","publisher"]}`,
+			wantStatus: fiber.StatusOK,
+			wantRoles:  []string{db.RoleViewer, db.RolePublisher},
+		},
+		{
+			name:       "revoke all",
+			path:       "/api/admin/users/abc/roles",
+			body:       `{"roles":[]}`,
+			wantStatus: fiber.StatusOK,
+			wantRoles:  []string{},
+		},
+		{
+			name:       "unknown role",
+			path:       "/api/admin/users/abc/roles",
+			body:       `{"roles":["owner"]}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "missing roles",
+			path:       "/api/admin/users/abc/roles",
+			body:       `{}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "unknown user",
+			path:       "/api/admin/users/nobody/roles",
+			body:       `{"roles":["viewer"]}`,
+			wantStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := &fakeUserRepo{users: map[string]*db.User{
+				"abc": {Hash: "abc", Roles: []string{db.RoleEditor}, IsAdmin: true},
+			}}
+			app := newUserRolesTestApp(t, userRepo)
+
+			req := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				if !reflect.DeepEqual(userRepo.users["abc"].Roles, []string{db.RoleEditor}) {
+					t.Fatalf("expected roles to be unchanged, got %v", userRepo.users["abc"].Roles)
+				}
+				return
+			}
+
+			var payload UserResponse
+			if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(payload.User.Roles, tt.wantRoles) {
+				t.Fatalf("expected roles %v, got %v", tt.wantRoles, payload.User.Roles)
+			}
+		})
+	}
+}
+
+func TestSetUserRolesHandlerKeepsLastUserAdmin(t *testing.T) {
+	userRepo := &fakeUserRepo{
+		users:         map[string]*db.User{"abc": {Hash: "abc", Roles: db.Roles, IsAdmin: true}},
+		lastUserAdmin: "abc",
+	}
+	app := newUserRolesTestApp(t, userRepo)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/users/abc/roles", strings.NewReader(`{"roles":["viewer"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusConflict {
+		t.Fatalf("expected %d, got %d", fiber.StatusConflict, res.StatusCode)
+	}
+}
+
+func TestRevokeAdminHandler(t *testing.T) {
+	hash := userHashForEmail("user@example.com")
+	userRepo := &fakeUserRepo{users: map[string]*db.User{
+		hash: {Hash: hash, Roles: db.Roles, IsAdmin: true},
+	}}
+	app := newUserRolesTestApp(t, userRepo)
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/api/admin/users/revoke-admin",
+		strings.NewReader(`{"email":" User@Example.com "}`),
+	)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected %d, got %d", fiber.StatusOK, res.StatusCode)
+	}
+	if user := userRepo.users[hash]; len(user.Roles) != 0 || user.IsAdmin {
+		t.Fatalf("expected every role to be revoked, got %+v", user)
+	}
+}
+
+func TestListUsersHandlerParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantParams db.UserListParams
+	}{
+		{
+			name:       "defaults",
+			wantStatus: fiber.StatusOK,
+			wantParams: db.UserListParams{Page: 1, PageSize: defaultUsersPageSize},
+		},
+		{
+			name:       "email search",
+			query:      "?q=User@Example.com&role=editor&page=2&pageSize=500",
+			wantStatus: fiber.StatusOK,
+			wantParams: db.UserListParams{
+				Hash:     userHashForEmail("user@example.com"),
+				Role:     db.RoleEditor,
+				Page:     2,
+				PageSize: maxUsersPageSize,
+			},
+		},
+		{
+			name:       "hash prefix search",
+			query:      "?q=ABC",
+			wantStatus: fiber.StatusOK,
+			wantParams: db.UserListParams{Search: "abc", Page: 1, PageSize: defaultUsersPageSize},
+		},
+		{
+			name:       "unknown role",
+			query:      "?role=owner",
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "invalid page",
+			query:      "?page=0",
+			wantStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := &fakeUserRepo{}
+			app := newUserRolesTestApp(t, userRepo)
+
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/admin/users"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+			if userRepo.listParams != tt.wantParams {
+				t.Fatalf("expected params %+v, got %+v", tt.wantParams, userRepo.listParams)
+			}
+
+			var payload UsersListResponse
+			if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if payload.Meta.Page != tt.wantParams.Page || payload.Meta.Total != 30 {
+				t.Fatalf("unexpected meta %+v", payload.Meta)
+			}
+		})
+	}
+}
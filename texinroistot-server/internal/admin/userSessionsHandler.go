@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var newSessionRepository = db.NewSessionRepository
+
+// RevokeUserSessionsHandler signs a user out on every device. The user keeps
+// their roles and may sign in again.
+func RevokeUserSessionsHandler(c *fiber.Ctx) error {
+	userHash := strings.TrimSpace(c.Params("userHash"))
+	if userHash == "" {
+		return c.Status(400).JSON(api.Error("userHash is required"))
+	}
+
+	user, err := newUserRepository().ReadByHash(userHash)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to read user"))
+	}
+	if user == nil {
+		return c.Status(404).JSON(api.Error("user not found"))
+	}
+
+	revoked, err := newSessionRepository().RemoveByUser(user.ID)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to revoke sessions"))
+	}
+
+	return c.JSON(auth.RevokeSessionsResponse{Revoked: revoked})
+}
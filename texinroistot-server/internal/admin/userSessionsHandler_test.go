@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func (r *fakeUserRepo) ReadByHash(userHash string) (*db.User, error) {
+	return r.users[userHash], nil
+}
+
+type fakeSessionRepo struct {
+	db.SessionRepository
+	sessions map[int]int
+}
+
+func (r *fakeSessionRepo) RemoveByUser(userID int) (int, error) {
+	removed := r.sessions[userID]
+	delete(r.sessions, userID)
+	return removed, nil
+}
+
+func TestRevokeUserSessionsHandler(t *testing.T) {
+	sessionRepo := &fakeSessionRepo{sessions: map[int]int{1: 3, 2: 1}}
+	newSessionRepository = func() db.SessionRepository { return sessionRepo }
+	t.Cleanup(func() { newSessionRepository = db.NewSessionRepository })
+
+	app := newUserRolesTestApp(t, &fakeUserRepo{users: map[string]*db.User{"abc": {ID: 1, Hash: "abc"}}})
+	app.Delete("/api/admin/users/:userHash/sessions", RevokeUserSessionsHandler)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodDelete, "/api/admin/users/abc/sessions", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	var body auth.RevokeSessionsResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Revoked != 3 {
+		t.Fatalf("expected 3 revoked sessions, got %d", body.Revoked)
+	}
+	if _, ok := sessionRepo.sessions[2]; !ok {
+		t.Fatalf("expected sessions of other users to be kept")
+	}
+
+	res, err = app.Test(httptest.NewRequest(http.MethodDelete, "/api/admin/users/missing/sessions", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown user, got %d", res.StatusCode)
+	}
+}
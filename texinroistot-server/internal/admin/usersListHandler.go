@@ -1,22 +1,120 @@
 package admin
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 )
 
+var newUserRepository = db.NewUserRepository
+
+const (
+	defaultUsersPageSize = 25
+	maxUsersPageSize     = 100
+)
+
 type UserInfo struct {
 	LoggedIn bool   `json:"loggedIn"`
 	Email    string `json:"email"`
 }
 
+type UserListFilters struct {
+	Q    string `json:"q"`
+	Role string `json:"role"`
+}
+
+type UsersListResponse struct {
+	Users   []*db.User      `json:"users"`
+	Meta    api.PageMeta    `json:"meta"`
+	Filters UserListFilters `json:"filters"`
+}
+
+// userHashForEmail matches the hash auth stores for a user's email.
+func userHashForEmail(email string) string {
+	return crypt.Hash(strings.ToLower(strings.TrimSpace(email)))
+}
+
+func parseUsersPageValue(raw string, fallback int, name string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, errors.New(name + " must be a positive integer")
+	}
+	return value, nil
+}
+
+// parseUserListParams reads page, pageSize, role and q. A q with an @ is an
+// email and matches its hash exactly; anything else matches the beginning of
+// the hash, since stored emails are encrypted and cannot be searched.
+func parseUserListParams(c *fiber.Ctx) (db.UserListParams, UserListFilters, error) {
+	var filters UserListFilters
+
+	page, err := parseUsersPageValue(c.Query("page"), 1, "page")
+	if err != nil {
+		return db.UserListParams{}, filters, err
+	}
+	pageSize, err := parseUsersPageValue(c.Query("pageSize"), defaultUsersPageSize, "pageSize")
+	if err != nil {
+		return db.UserListParams{}, filters, err
+	}
+	if pageSize > maxUsersPageSize {
+		pageSize = maxUsersPageSize
+	}
+
+	filters.Role = strings.TrimSpace(c.Query("role"))
+	if filters.Role != "" && !db.IsRole(filters.Role) {
+		return db.UserListParams{}, filters, errors.New("role is invalid")
+	}
+
+	params := db.UserListParams{Role: filters.Role, Page: page, PageSize: pageSize}
+	filters.Q = strings.TrimSpace(c.Query("q"))
+	if strings.Contains(filters.Q, "@") {
+		params.Hash = userHashForEmail(filters.Q)
+	} else {
+		params.Search = strings.ToLower(filters.Q)
+	}
+	return params, filters, nil
+}
+
+// withDisplayEmail decrypts the stored email of the user, if any. A value
+// that fails to decrypt is left out rather than failing the request.
+func withDisplayEmail(user *db.User) *db.User {
+	if user == nil || user.EmailContent == "" {
+		return user
+	}
+	email, err := crypt.Decrypt(crypt.NewEncrypted(user.EmailIv, user.EmailContent))
+	if err == nil {
+		user.Email = email
+	}
+	return user
+}
+
 func ListUsersHandler(c *fiber.Ctx) error {
-	userRepo := db.NewUserRepository()
-	users, _, err := userRepo.List(0)
+	params, filters, err := parseUserListParams(c)
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
 
+	userRepo := newUserRepository()
+	users, meta, err := userRepo.List(params)
 	if err != nil {
-		return err
+		return c.Status(500).JSON(api.Error("failed to list users"))
+	}
+	for _, user := range users {
+		withDisplayEmail(user)
 	}
 
-	return c.JSON(fiber.Map{"users": users})
+	return c.JSON(UsersListResponse{
+		Users:   users,
+		Meta:    api.NewPageMeta(meta.Total, meta.PageIndex+1, meta.PageSize),
+		Filters: filters,
+	})
 }
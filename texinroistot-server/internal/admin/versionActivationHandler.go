@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+// activationHistoryLimit caps how many past activations are listed.
+const activationHistoryLimit = 50
+
+var newVersionActivationRepository = db.NewVersionActivationRepository
+
+type ActivationHistoryResponse struct {
+	Activations []*db.VersionActivation `json:"activations"`
+}
+
+type ScheduledActivationsResponse struct {
+	ScheduledActivations []*db.ScheduledActivation `json:"scheduledActivations"`
+}
+
+type ScheduledActivationResponse struct {
+	ScheduledActivation *db.ScheduledActivation `json:"scheduledActivation"`
+}
+
+type ScheduleActivationPayload struct {
+	// RFC 3339 timestamp in the future
+	ActivateAt string `json:"activateAt"`
+	Force      bool   `json:"force"`
+}
+
+type CancelScheduledActivationResponse struct {
+	Cancelled  bool `json:"cancelled"`
+	ScheduleID int  `json:"scheduleID"`
+}
+
+func ListActivationsHandler(c *fiber.Ctx) error {
+	activations, err := newVersionActivationRepository().History(activationHistoryLimit)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list activations"))
+	}
+
+	return c.JSON(ActivationHistoryResponse{Activations: activations})
+}
+
+// RollbackVersionHandler reactivates the version that was active before the
+// current one. Rollbacks skip the activation checks: the previous version
+// was already live, and a rollback must not be blocked when things go wrong.
+func RollbackVersionHandler(c *fiber.Ctx) error {
+	previousID, err := newVersionActivationRepository().Previous()
+	if err != nil {
+		if errors.Is(err, db.ErrNoPreviousVersion) {
+			return c.Status(409).JSON(api.Error("no previously active version to roll back to"))
+		}
+		return c.Status(500).JSON(api.Error("failed to find previous version"))
+	}
+	setAuditTarget(c, fmt.Sprintf("versionID=%d", previousID))
+
+	versionRepo := newVersionRepository()
+	report, err := versionRepo.SetActive(previousID, db.Activation{
+		UserID: currentUserID(c),
+		Reason: db.ActivationRollback,
+		Force:  true,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrVersionNotFound) {
+			return c.Status(409).JSON(api.Error("previous version no longer exists"))
+		}
+		return c.Status(500).JSON(api.Error("failed to roll back version"))
+	}
+
+	version, err := versionRepo.Read(previousID)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to load active version"))
+	}
+
+	return c.JSON(ActivateVersionResponse{Version: version, Report: report})
+}
+
+func ListScheduledActivationsHandler(c *fiber.Ctx) error {
+	scheduled, err := newVersionActivationRepository().ListScheduled()
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list scheduled activations"))
+	}
+
+	return c.JSON(ScheduledActivationsResponse{ScheduledActivations: scheduled})
+}
+
+// ScheduleActivationHandler schedules a version to be activated at the given
+// time. The activation checks run when the activation is due, so a version
+// that fails them then is not activated unless force is set.
+func ScheduleActivationHandler(c *fiber.Ctx) error {
+	versionID, err := parseVersionID(c.Params("versionID"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	var payload ScheduleActivationPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(api.Error("invalid request body"))
+	}
+	activateAt, err := parseActivateAt(payload.ActivateAt, time.Now())
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	if _, err := newVersionRepository().Read(versionID); err != nil {
+		if errors.Is(err, db.ErrVersionNotFound) {
+			return c.Status(404).JSON(api.Error("version not found"))
+		}
+		return c.Status(500).JSON(api.Error("failed to load version"))
+	}
+
+	scheduled, err := newVersionActivationRepository().Schedule(versionID, activateAt, payload.Force, currentUserID(c))
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to schedule activation"))
+	}
+
+	return c.Status(201).JSON(ScheduledActivationResponse{ScheduledActivation: scheduled})
+}
+
+func parseActivateAt(raw string, now time.Time) (time.Time, error) {
+	activateAt, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+	if err != nil {
+		return time.Time{}, errors.New("activateAt must be an RFC 3339 timestamp")
+	}
+	if !activateAt.After(now) {
+		return time.Time{}, errors.New("activateAt must be in the future")
+	}
+	return activateAt, nil
+}
+
+func CancelScheduledActivationHandler(c *fiber.Ctx) error {
+	scheduleID, err := strconv.Atoi(strings.TrimSpace(c.Params("scheduleID")))
+	if err != nil || scheduleID <= 0 {
+		return c.Status(400).JSON(api.Error("scheduleID must be a positive integer"))
+	}
+
+	if err := newVersionActivationRepository().CancelScheduled(scheduleID); err != nil {
+		if errors.Is(err, db.ErrScheduledActivationNotFound) {
+			return c.Status(404).JSON(api.Error("scheduled activation not found"))
+		}
+		if errors.Is(err, db.ErrScheduledActivationNotPending) {
+			return c.Status(409).JSON(api.Error("scheduled activation has already been processed"))
+		}
+		return c.Status(500).JSON(api.Error("failed to cancel scheduled activation"))
+	}
+
+	return c.JSON(CancelScheduledActivationResponse{Cancelled: true, ScheduleID: scheduleID})
+}
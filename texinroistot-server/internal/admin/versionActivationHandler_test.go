@@ -0,0 +1,220 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type fakeVersionActivationRepo struct {
+	db.VersionActivationRepository
+	previousID int
+	scheduled  []*db.ScheduledActivation
+	finished   map[int]string
+	// lockedElsewhere stands in for another replica holding the scheduled
+	// activation lock.
+	lockedElsewhere bool
+}
+
+func (r *fakeVersionActivationRepo) acquireLock() (databaseLock, error) {
+	if r.lockedElsewhere {
+		return nil, db.ErrScheduledActivationLocked
+	}
+	return &fakeImportLock{held: true}, nil
+}
+
+func (r *fakeVersionActivationRepo) Previous() (int, error) {
+	if r.previousID == 0 {
+		return 0, db.ErrNoPreviousVersion
+	}
+	return r.previousID, nil
+}
+
+func (r *fakeVersionActivationRepo) Schedule(
+	versionID int,
+	activateAt time.Time,
+	force bool,
+	userID int,
+) (*db.ScheduledActivation, error) {
+	scheduled := &db.ScheduledActivation{
+		ID:          len(r.scheduled) + 1,
+		VersionID:   versionID,
+		ActivateAt:  activateAt,
+		Force:       force,
+		Status:      db.ScheduledActivationPending,
+		CreatedByID: userID,
+	}
+	r.scheduled = append(r.scheduled, scheduled)
+	return scheduled, nil
+}
+
+func (r *fakeVersionActivationRepo) ListScheduled() ([]*db.ScheduledActivation, error) {
+	var pending []*db.ScheduledActivation
+	for _, scheduled := range r.scheduled {
+		if scheduled.Status == db.ScheduledActivationPending || scheduled.Status == db.ScheduledActivationRunning {
+			pending = append(pending, scheduled)
+		}
+	}
+	return pending, nil
+}
+
+func (r *fakeVersionActivationRepo) ClaimDue() ([]*db.ScheduledActivation, error) {
+	var due []*db.ScheduledActivation
+	for _, scheduled := range r.scheduled {
+		if scheduled.Status == db.ScheduledActivationPending && !scheduled.ActivateAt.After(time.Now()) {
+			scheduled.Status = db.ScheduledActivationRunning
+			due = append(due, scheduled)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeVersionActivationRepo) FinishScheduled(scheduleID int, failure string) error {
+	if r.finished == nil {
+		r.finished = map[int]string{}
+	}
+	r.finished[scheduleID] = failure
+	return nil
+}
+
+func (r *fakeVersionActivationRepo) FailRunning(failure string) (int, error) {
+	failed := 0
+	for _, scheduled := range r.scheduled {
+		if scheduled.Status == db.ScheduledActivationRunning {
+			scheduled.Status = db.ScheduledActivationFailed
+			scheduled.Error = failure
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+func newActivationTestApp(
+	t *testing.T,
+	versionRepo *fakeVersionRepo,
+	activationRepo *fakeVersionActivationRepo,
+) *fiber.App {
+	t.Helper()
+
+	newVersionRepository = func() db.VersionRepository { return versionRepo }
+	newVersionActivationRepository = func() db.VersionActivationRepository { return activationRepo }
+	acquireScheduledActivationLock = activationRepo.acquireLock
+	stubAuditLog(t)
+	t.Cleanup(func() {
+		newVersionRepository = db.NewVersionRepository
+		newVersionActivationRepository = db.NewVersionActivationRepository
+		acquireScheduledActivationLock = tryDatabaseScheduledActivationLock
+	})
+
+	app := fiber.New()
+	app.Post("/api/admin/versions/rollback", RollbackVersionHandler)
+	app.Post("/api/admin/versions/:versionID/schedule-activation", ScheduleActivationHandler)
+	return app
+}
+
+func TestRollbackVersionHandlerForcesPreviousVersion(t *testing.T) {
+	versionRepo := &fakeVersionRepo{issues: []db.ActivationIssue{
+		{Check: "count-drop", Message: "villains dropped"},
+	}}
+	app := newActivationTestApp(t, versionRepo, &fakeVersionActivationRepo{previousID: 3})
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/rollback", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected %d, got %d", fiber.StatusOK, res.StatusCode)
+	}
+	if len(versionRepo.activations) != 1 {
+		t.Fatalf("expected one activation, got %d", len(versionRepo.activations))
+	}
+	activation := versionRepo.activations[0]
+	if versionRepo.activated[0] != 3 || activation.Reason != db.ActivationRollback || !activation.Force {
+		t.Fatalf("expected forced rollback to version 3, got %d %+v", versionRepo.activated[0], activation)
+	}
+}
+
+func TestRollbackVersionHandlerWithoutPreviousVersion(t *testing.T) {
+	app := newActivationTestApp(t, &fakeVersionRepo{}, &fakeVersionActivationRepo{})
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/rollback", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusConflict {
+		t.Fatalf("expected %d, got %d", fiber.StatusConflict, res.StatusCode)
+	}
+}
+
+func TestScheduleActivationHandler(t *testing.T) {
+	activateAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "future time",
+			path:       "/api/admin/versions/5/schedule-activation",
+			body:       `{"activateAt":"` + activateAt.Format(time.RFC3339) + `","force":true}`,
+			wantStatus: fiber.StatusCreated,
+		},
+		{
+			name:       "past time",
+			path:       "/api/admin/versions/5/schedule-activation",
+			body:       `{"activateAt":"2020-01-01T00:00:00Z"}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "invalid time",
+			path:       "/api/admin/versions/5/schedule-activation",
+			body:       `{"activateAt":"tomorrow"}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "unknown version",
+			path:       "/api/admin/versions/404/schedule-activation",
+			body:       `{"activateAt":"` + activateAt.Format(time.RFC3339) + `"}`,
+			wantStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activationRepo := &fakeVersionActivationRepo{}
+			app := newActivationTestApp(t, &fakeVersionRepo{}, activationRepo)
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusCreated {
+				if len(activationRepo.scheduled) != 0 {
+					t.Fatalf("expected nothing to be scheduled")
+				}
+				return
+			}
+
+			var payload ScheduledActivationResponse
+			if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			scheduled := payload.ScheduledActivation
+			if scheduled.VersionID != 5 || !scheduled.Force || !scheduled.ActivateAt.Equal(activateAt) {
+				t.Fatalf("unexpected scheduled activation %+v", scheduled)
+			}
+		})
+	}
+}
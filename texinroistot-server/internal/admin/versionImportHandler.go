@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -13,6 +15,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/config"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 	"github.com/kokkoniemi/texinroistot/internal/importer"
@@ -20,42 +23,113 @@ import (
 
 const (
 	importRequestTimeout = 2 * time.Minute
+	maxImportRedirects   = 5
 	maxImportFileBytes   = 20 * 1024 * 1024
 	importUserAgent      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64)"
+
+	// MaxImportRequestBytes is the request body limit the server needs for
+	// spreadsheet uploads: the file plus room for multipart headers.
+	MaxImportRequestBytes = maxImportFileBytes + 64*1024
 )
 
 var (
 	errInvalidImportURL         = errors.New("invalid import url")
 	errImportDownloadFailed     = errors.New("failed to download import file")
-	errImportInvalidSpreadsheet = errors.New("downloaded file is not a valid xlsx")
-
-	importStateMu    sync.Mutex
-	importRunning    bool
-	runVersionImport = importVersionFromURL
+	errImportInvalidSpreadsheet = errors.New("downloaded file is not a valid spreadsheet")
+	errImportInvalidUpload      = errors.New("uploaded file is not a valid spreadsheet")
+	errImportFileTooLarge       = errors.New("import file is too large")
+
+	importStateMu          sync.Mutex
+	importRunning          bool
+	runVersionImport       = importVersionFromSource
+	newImportJobRepository = db.NewImportJobRepository
+	acquireImportLock      = tryDatabaseImportLock
 )
 
+// databaseLock is an advisory lock that keeps other replicas from importing
+// or running scheduled activations at the same time.
+type databaseLock interface {
+	Release() error
+}
+
+func tryDatabaseImportLock() (databaseLock, error) {
+	return db.TryImportLock()
+}
+
+type ImportVersionPayload struct {
+	URL string `json:"url" form:"url"`
+}
+
+// ImportVersionUpload documents the multipart form ImportVersionHandler
+// accepts. Either field may be given; the file wins when both are.
+type ImportVersionUpload struct {
+	File *multipart.FileHeader `form:"file"`
+	URL  string                `form:"url"`
+}
+
+type ImportJobResponse struct {
+	Job *db.ImportJob `json:"job"`
+}
+
+// importSource is either an uploaded file or a URL to download the
+// spreadsheet from.
+type importSource struct {
+	url      string
+	fileName string
+	content  []byte
+	// userID is the admin who started the import, zero for scheduled imports
+	userID int
+}
+
+// ImportVersionHandler queues an import and returns the job right away.
+// Progress is polled from ImportJobHandler. The spreadsheet is taken from
+// the multipart "file" field, the "url" field when its host is allowed, or
+// the configured import URL.
 func ImportVersionHandler(c *fiber.Ctx) error {
+	source, err := readImportSource(c)
+	if err != nil {
+		if errors.Is(err, errImportFileTooLarge) {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(api.Error(err.Error()))
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(api.Error(err.Error()))
+	}
+	source.userID = currentUserID(c)
+
 	if !startImport() {
-		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "import already running"})
+		return c.Status(fiber.StatusConflict).JSON(api.Error("import already running"))
 	}
-	defer finishImport()
 
-	version, err := runVersionImport(config.ImportExcelURL)
+	lock, err := acquireImportLock()
 	if err != nil {
-		if errors.Is(err, errInvalidImportURL) ||
-			errors.Is(err, errImportDownloadFailed) ||
-			errors.Is(err, errImportInvalidSpreadsheet) {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		finishImport()
+		if errors.Is(err, db.ErrImportLocked) {
+			return c.Status(fiber.StatusConflict).JSON(api.Error("import already running"))
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to import version"})
+		return c.Status(fiber.StatusInternalServerError).JSON(api.Error("failed to acquire import lock"))
 	}
 
-	return c.JSON(fiber.Map{
-		"imported": true,
-		"version":  version,
-	})
+	jobRepo := newImportJobRepository()
+	job, err := jobRepo.Create(source.url, source.fileName)
+	if err != nil {
+		releaseImportLock(lock)
+		finishImport()
+		return c.Status(fiber.StatusInternalServerError).JSON(api.Error("failed to create import job"))
+	}
+
+	setAuditTarget(c, fmt.Sprintf("jobID=%d", job.ID))
+
+	go func() {
+		defer finishImport()
+		defer releaseImportLock(lock)
+		runImportJob(jobRepo, job.ID, source)
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(ImportJobResponse{Job: job})
 }
 
+// startImport is an in-process guard checked before the database lock so
+// that concurrent requests to the same replica do not each hold a
+// connection while waiting for the lock.
 func startImport() bool {
 	importStateMu.Lock()
 	defer importStateMu.Unlock()
@@ -73,18 +147,107 @@ func finishImport() {
 	importRunning = false
 }
 
-func importVersionFromURL(rawURL string) (*db.Version, error) {
+func releaseImportLock(lock databaseLock) {
+	if err := lock.Release(); err != nil {
+		log.Printf("failed to release import lock: %v", err)
+	}
+}
+
+func readImportSource(c *fiber.Ctx) (importSource, error) {
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		content, err := readUploadedSpreadsheet(fileHeader)
+		if err != nil {
+			return importSource{}, err
+		}
+		return importSource{fileName: fileHeader.Filename, content: content}, nil
+	}
+
+	payload := new(ImportVersionPayload)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(payload); err != nil {
+			return importSource{}, errors.New("invalid request body")
+		}
+	}
+
+	rawURL := config.ImportExcelURL
+	if overrideURL := strings.TrimSpace(payload.URL); overrideURL != "" {
+		if err := checkImportHostAllowed(overrideURL); err != nil {
+			return importSource{}, err
+		}
+		rawURL = overrideURL
+	}
+
 	fileURL, err := buildImportURL(rawURL)
 	if err != nil {
-		return nil, err
+		return importSource{}, err
+	}
+	return importSource{url: fileURL}, nil
+}
+
+func readUploadedSpreadsheet(fileHeader *multipart.FileHeader) ([]byte, error) {
+	if fileHeader.Size > maxImportFileBytes {
+		return nil, errImportFileTooLarge
 	}
 
-	content, err := downloadSpreadsheet(fileURL)
+	file, err := fileHeader.Open()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", errImportInvalidUpload, err)
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxImportFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errImportInvalidUpload, err)
+	}
+	if len(content) > maxImportFileBytes {
+		return nil, errImportFileTooLarge
+	}
+
+	if err := spreadsheetProblem(content, fileHeader.Header.Get("Content-Type")); err != nil {
+		return nil, fmt.Errorf("%w: %v", errImportInvalidUpload, err)
+	}
+	return content, nil
+}
+
+// checkImportHostAllowed limits override URLs to ROISTOT_IMPORT_ALLOWED_HOSTS
+// so that admins cannot make the server fetch arbitrary addresses. A listed
+// host also allows its subdomains.
+func checkImportHostAllowed(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidImportURL, err)
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range strings.Split(config.ImportAllowedHosts, ",") {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q is not allowed", errInvalidImportURL, host)
+}
+
+func importVersionFromSource(source importSource, tracker importTracker) (*db.Version, error) {
+	content := source.content
+	if content == nil {
+		tracker.setStatus(db.ImportJobDownloading)
+		downloaded, err := downloadSpreadsheet(source.url)
+		if err != nil {
+			return nil, err
+		}
+		content = downloaded
 	}
+	tracker.setContentHash(importer.ContentHash(content))
 
-	version, err := importer.ImportSpreadsheetFromBytes(content)
+	version, err := importer.ImportSpreadsheetWithProgress(content, importer.Source{
+		ImportedByID: source.userID,
+		SourceURL:    source.url,
+		FileName:     source.fileName,
+	}, tracker.setProgress)
 	if err != nil {
 		return nil, err
 	}
@@ -133,8 +296,9 @@ func downloadSpreadsheet(sourceURL string) ([]byte, error) {
 	}
 
 	client := &http.Client{
-		Timeout: importRequestTimeout,
-		Jar:     jar,
+		Timeout:       importRequestTimeout,
+		Jar:           jar,
+		CheckRedirect: checkImportRedirect,
 	}
 
 	content, contentType, err := downloadSpreadsheetOnce(client, sourceURL)
@@ -147,6 +311,22 @@ func downloadSpreadsheet(sourceURL string) ([]byte, error) {
 	return content, nil
 }
 
+// checkImportRedirect keeps downloads on the host of the import URL or on
+// the allowed hosts, so that an allowed host cannot redirect the server to
+// an arbitrary address.
+func checkImportRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxImportRedirects {
+		return fmt.Errorf("%w: too many redirects", errImportDownloadFailed)
+	}
+	if req.URL.Scheme != "https" && req.URL.Scheme != "http" {
+		return fmt.Errorf("%w: redirect to unsupported scheme", errInvalidImportURL)
+	}
+	if strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+		return nil
+	}
+	return checkImportHostAllowed(req.URL.String())
+}
+
 func downloadSpreadsheetOnce(client *http.Client, sourceURL string) ([]byte, string, error) {
 	req, err := http.NewRequest(http.MethodGet, sourceURL, nil)
 	if err != nil {
@@ -195,20 +375,35 @@ func isLikelyHTMLResponse(content []byte, contentType string) bool {
 		strings.Contains(sample, "login.live.com")
 }
 
-func validateSpreadsheet(content []byte, contentType string) error {
+var errReceivedHTML = errors.New("received html page")
+
+// spreadsheetProblem tells why content cannot be imported, or returns nil.
+func spreadsheetProblem(content []byte, contentType string) error {
 	if len(content) == 0 {
-		return fmt.Errorf("%w: empty response", errImportInvalidSpreadsheet)
+		return errors.New("empty file")
 	}
-	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
-		return nil
+
+	// HTML is valid UTF-8 text, so rule it out before CSV detection
+	if !bytes.HasPrefix(content, []byte("PK\x03\x04")) && isLikelyHTMLResponse(content, contentType) {
+		return errReceivedHTML
 	}
 
-	if isLikelyHTMLResponse(content, contentType) {
+	if _, err := importer.DetectFormat(content); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateSpreadsheet(content []byte, contentType string) error {
+	err := spreadsheetProblem(content, contentType)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, errReceivedHTML) {
 		return fmt.Errorf(
 			"%w: received html/login page. ensure OneDrive sharing link is publicly downloadable",
 			errImportInvalidSpreadsheet,
 		)
 	}
-
-	return fmt.Errorf("%w: missing xlsx zip signature", errImportInvalidSpreadsheet)
+	return fmt.Errorf("%w: %v", errImportInvalidSpreadsheet, err)
 }
@@ -3,12 +3,16 @@ package admin
 import (
 	"bytes"
 	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
 	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/importer"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -55,6 +59,45 @@ func TestDownloadSpreadsheetSupportsRedirect(t *testing.T) {
 	}
 }
 
+func TestDownloadSpreadsheetChecksRedirects(t *testing.T) {
+	allowedHosts := config.ImportAllowedHosts
+	config.ImportAllowedHosts = "example.com"
+	t.Cleanup(func() { config.ImportAllowedHosts = allowedHosts })
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/other-host":
+			// Same server, but under a host name that is not allowed.
+			http.Redirect(w, r, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/file", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = w.Write([]byte("Nimi;Rooli\n"))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{name: "host not allowed", path: "/other-host", want: errInvalidImportURL},
+		{name: "too many redirects", path: "/loop", want: errImportDownloadFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := downloadSpreadsheet(server.URL + tt.path)
+			if !errors.Is(err, errImportDownloadFailed) || !strings.Contains(err.Error(), tt.want.Error()) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
 func TestDownloadSpreadsheetRejectsHTML(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
@@ -85,10 +128,12 @@ func TestImportVersionHandlerReturnsConflictWhenImportIsRunning(t *testing.T) {
 	}
 }
 
-func TestImportVersionHandlerSuccess(t *testing.T) {
-	resetImportState(t)
+func TestImportVersionHandlerQueuesJob(t *testing.T) {
+	jobRepo := resetImportState(t)
 
-	runVersionImport = func(_ string) (*db.Version, error) {
+	runVersionImport = func(_ importSource, tracker importTracker) (*db.Version, error) {
+		tracker.setStatus(db.ImportJobDownloading)
+		tracker.setProgress(importer.Progress{Stage: importer.StageParsing, Rows: 2})
 		return &db.Version{ID: 123, IsActive: false}, nil
 	}
 
@@ -100,15 +145,30 @@ func TestImportVersionHandlerSuccess(t *testing.T) {
 	if err != nil {
 		t.Fatalf("request failed: %v", err)
 	}
-	if res.StatusCode != fiber.StatusOK {
-		t.Fatalf("expected %d, got %d", fiber.StatusOK, res.StatusCode)
+	if res.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("expected %d, got %d", fiber.StatusAccepted, res.StatusCode)
+	}
+
+	job := jobRepo.wait(t, 1)
+	if job.Status != db.ImportJobDone || job.VersionID == nil || *job.VersionID != 123 {
+		t.Fatalf("expected finished job for version 123, got %+v", job)
+	}
+	if job.Rows != 2 {
+		t.Fatalf("expected progress to be recorded, got %+v", job)
+	}
+	expectedStatuses := []string{db.ImportJobDownloading, db.ImportJobParsing}
+	if strings.Join(jobRepo.statuses, ",") != strings.Join(expectedStatuses, ",") {
+		t.Fatalf("expected statuses %v, got %v", expectedStatuses, jobRepo.statuses)
+	}
+	if !startImport() {
+		t.Fatalf("expected import guard to be released after the job")
 	}
 }
 
-func TestImportVersionHandlerMapsSpreadsheetValidationErrorToBadRequest(t *testing.T) {
-	resetImportState(t)
+func TestImportVersionHandlerRecordsSpreadsheetValidationError(t *testing.T) {
+	jobRepo := resetImportState(t)
 
-	runVersionImport = func(_ string) (*db.Version, error) {
+	runVersionImport = func(_ importSource, _ importTracker) (*db.Version, error) {
 		return nil, errImportInvalidSpreadsheet
 	}
 
@@ -120,20 +180,52 @@ func TestImportVersionHandlerMapsSpreadsheetValidationErrorToBadRequest(t *testi
 	if err != nil {
 		t.Fatalf("request failed: %v", err)
 	}
-	if res.StatusCode != fiber.StatusBadRequest {
-		t.Fatalf("expected %d, got %d", fiber.StatusBadRequest, res.StatusCode)
+	if res.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("expected %d, got %d", fiber.StatusAccepted, res.StatusCode)
+	}
+
+	job := jobRepo.wait(t, 1)
+	if job.Status != db.ImportJobFailed || job.Error != errImportInvalidSpreadsheet.Error() {
+		t.Fatalf("expected failed job with spreadsheet error, got %+v", job)
 	}
 }
 
-func resetImportState(t *testing.T) {
+func TestImportVersionHandlerHidesInternalErrors(t *testing.T) {
+	jobRepo := resetImportState(t)
+
+	runVersionImport = func(_ importSource, _ importTracker) (*db.Version, error) {
+		return nil, errors.New("pq: connection refused")
+	}
+
+	app := fiber.New()
+	app.Post("/api/admin/versions/import", ImportVersionHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/versions/import", nil)
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	job := jobRepo.wait(t, 1)
+	if job.Error != "failed to import version" {
+		t.Fatalf("expected generic error message, got %q", job.Error)
+	}
+}
+
+func resetImportState(t *testing.T) *fakeImportJobRepo {
 	t.Helper()
 
-	runVersionImport = importVersionFromURL
+	jobRepo := newFakeImportJobRepo()
+	runVersionImport = importVersionFromSource
+	newImportJobRepository = func() db.ImportJobRepository { return jobRepo }
+	acquireImportLock = jobRepo.importLock.acquire
 	importRunning = false
 	t.Cleanup(func() {
-		runVersionImport = importVersionFromURL
+		runVersionImport = importVersionFromSource
+		newImportJobRepository = db.NewImportJobRepository
+		acquireImportLock = tryDatabaseImportLock
 		importRunning = false
 	})
+	return jobRepo
 }
 
 func mustBuildXLSX(t *testing.T) []byte {
@@ -152,3 +244,162 @@ func mustBuildXLSX(t *testing.T) []byte {
 
 	return buffer.Bytes()
 }
+
+func TestValidateSpreadsheetAcceptsCSV(t *testing.T) {
+	if err := validateSpreadsheet([]byte("Nimi;Rooli\nMefisto;johtaja\n"), "text/csv"); err != nil {
+		t.Fatalf("expected csv to be accepted, got %v", err)
+	}
+}
+
+func TestImportVersionHandlerReportsMissingColumns(t *testing.T) {
+	jobRepo := resetImportState(t)
+
+	runVersionImport = func(_ importSource, _ importTracker) (*db.Version, error) {
+		return nil, &importer.MissingColumnsError{Columns: []importer.MissingColumn{
+			{Key: "roles", Expected: []string{"Rooli"}, Suggestion: "Roolit"},
+		}}
+	}
+
+	app := fiber.New()
+	app.Post("/api/admin/versions/import", ImportVersionHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/versions/import", nil)
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	job := jobRepo.wait(t, 1)
+	if !strings.Contains(job.Error, `did you mean "Roolit"?`) {
+		t.Fatalf("expected suggestion in job error, got %q", job.Error)
+	}
+}
+
+func newImportTestApp() *fiber.App {
+	app := fiber.New(fiber.Config{BodyLimit: MaxImportRequestBytes})
+	app.Post("/api/admin/versions/import", ImportVersionHandler)
+	return app
+}
+
+func newUploadRequest(t *testing.T, fileName string, content []byte) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("failed to write form file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/versions/import", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestImportVersionHandlerImportsUploadedFile(t *testing.T) {
+	jobRepo := resetImportState(t)
+
+	uploaded := []byte("Nimi;Rooli\nMefisto;johtaja\n")
+	received := make(chan importSource, 1)
+	runVersionImport = func(source importSource, _ importTracker) (*db.Version, error) {
+		received <- source
+		return &db.Version{ID: 5}, nil
+	}
+
+	res, err := newImportTestApp().Test(newUploadRequest(t, "roistot.csv", uploaded))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("expected %d, got %d", fiber.StatusAccepted, res.StatusCode)
+	}
+
+	job := jobRepo.wait(t, 1)
+	if job.FileName != "roistot.csv" || job.SourceURL != "" {
+		t.Fatalf("expected job to record the uploaded file name, got %+v", job)
+	}
+	source := <-received
+	if !bytes.Equal(source.content, uploaded) {
+		t.Fatalf("expected uploaded content to be imported, got %q", source.content)
+	}
+}
+
+func TestImportVersionHandlerRejectsInvalidUploads(t *testing.T) {
+	resetImportState(t)
+
+	cases := map[string]struct {
+		content  []byte
+		expected int
+	}{
+		"html":      {[]byte("<!doctype html><html></html>"), fiber.StatusBadRequest},
+		"xls":       {[]byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"), fiber.StatusBadRequest},
+		"empty":     {nil, fiber.StatusBadRequest},
+		"too large": {bytes.Repeat([]byte("a;b\n"), maxImportFileBytes/4+1), fiber.StatusRequestEntityTooLarge},
+	}
+	for name, tc := range cases {
+		res, err := newImportTestApp().Test(newUploadRequest(t, "roistot.csv", tc.content))
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", name, err)
+		}
+		if res.StatusCode != tc.expected {
+			t.Fatalf("%s: expected %d, got %d", name, tc.expected, res.StatusCode)
+		}
+	}
+	if isImportRunning() {
+		t.Fatalf("expected rejected uploads not to start an import")
+	}
+}
+
+func TestImportVersionHandlerOverrideURL(t *testing.T) {
+	jobRepo := resetImportState(t)
+	runVersionImport = func(_ importSource, _ importTracker) (*db.Version, error) {
+		return &db.Version{ID: 6}, nil
+	}
+
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"/api/admin/versions/import",
+		strings.NewReader(`{"url":"https://evil.example.com/roistot.xlsx"}`),
+	)
+	req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	res, err := newImportTestApp().Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected disallowed host to return %d, got %d", fiber.StatusBadRequest, res.StatusCode)
+	}
+
+	req = httptest.NewRequest(
+		http.MethodPost,
+		"/api/admin/versions/import",
+		strings.NewReader(`{"url":"https://onedrive.live.com/download?resid=abc"}`),
+	)
+	req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+	res, err = newImportTestApp().Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("expected allowed host to return %d, got %d", fiber.StatusAccepted, res.StatusCode)
+	}
+
+	job := jobRepo.wait(t, 1)
+	if !strings.HasPrefix(job.SourceURL, "https://onedrive.live.com/download?") {
+		t.Fatalf("expected override url to be imported, got %q", job.SourceURL)
+	}
+}
+
+func TestCheckImportHostAllowedMatchesSubdomains(t *testing.T) {
+	if err := checkImportHostAllowed("https://foo.onedrive.live.com/x"); err != nil {
+		t.Fatalf("expected subdomain to be allowed, got %v", err)
+	}
+	if err := checkImportHostAllowed("https://notonedrive.live.com/x"); !errors.Is(err, errInvalidImportURL) {
+		t.Fatalf("expected lookalike host to be rejected, got %v", err)
+	}
+}
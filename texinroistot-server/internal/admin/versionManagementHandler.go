@@ -2,10 +2,14 @@ package admin
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/auth"
 	"github.com/kokkoniemi/texinroistot/internal/config"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 )
@@ -18,57 +22,184 @@ func parseVersionID(raw string) (int, error) {
 	return versionID, nil
 }
 
+type VersionListResponse struct {
+	Versions  []*db.Version `json:"versions"`
+	ImportURL string        `json:"importUrl"`
+}
+
+type VersionResponse struct {
+	Version *db.Version `json:"version"`
+}
+
+// UpdateVersionPayload changes only the fields that are present; an empty
+// string clears a field.
+type UpdateVersionPayload struct {
+	Label *string `json:"label,omitempty"`
+	Notes *string `json:"notes,omitempty"`
+}
+
+const (
+	maxVersionLabelLength = 100
+	maxVersionNotesLength = 5000
+)
+
+type ActivateVersionResponse struct {
+	Version *db.Version          `json:"version"`
+	Report  *db.ActivationReport `json:"report"`
+}
+
+type ActivationFailedResponse struct {
+	Error  string               `json:"error"`
+	Report *db.ActivationReport `json:"report"`
+}
+
+type DeleteVersionResponse struct {
+	Deleted   bool `json:"deleted"`
+	VersionID int  `json:"versionID"`
+}
+
 func ListVersionsHandler(c *fiber.Ctx) error {
-	versionRepo := db.NewVersionRepository()
+	versionRepo := newVersionRepository()
 	versions, err := versionRepo.List()
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to list versions"})
+		return c.Status(500).JSON(api.Error("failed to list versions"))
 	}
 
-	return c.JSON(fiber.Map{
-		"versions":  versions,
-		"importUrl": config.ImportExcelURL,
+	return c.JSON(VersionListResponse{
+		Versions:  versions,
+		ImportURL: config.ImportExcelURL,
 	})
 }
 
+func UpdateVersionHandler(c *fiber.Ctx) error {
+	versionID, err := parseVersionID(c.Params("versionID"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	var payload UpdateVersionPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return c.Status(400).JSON(api.Error("invalid request body"))
+	}
+	update, err := payload.versionUpdate()
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	version, err := newVersionRepository().Update(versionID, update)
+	if err != nil {
+		if errors.Is(err, db.ErrVersionNotFound) {
+			return c.Status(404).JSON(api.Error("version not found"))
+		}
+		return c.Status(500).JSON(api.Error("failed to update version"))
+	}
+
+	return c.JSON(VersionResponse{Version: version})
+}
+
+func (p UpdateVersionPayload) versionUpdate() (db.VersionUpdate, error) {
+	if p.Label == nil && p.Notes == nil {
+		return db.VersionUpdate{}, errors.New("label or notes is required")
+	}
+
+	update := db.VersionUpdate{Notes: p.Notes}
+	if p.Label != nil {
+		label := strings.TrimSpace(*p.Label)
+		if utf8.RuneCountInString(label) > maxVersionLabelLength {
+			return db.VersionUpdate{}, fmt.Errorf("label must be at most %d characters", maxVersionLabelLength)
+		}
+		update.Label = &label
+	}
+	if p.Notes != nil && utf8.RuneCountInString(*p.Notes) > maxVersionNotesLength {
+		return db.VersionUpdate{}, fmt.Errorf("notes must be at most %d characters", maxVersionNotesLength)
+	}
+	return update, nil
+}
+
+// ActivateVersionHandler activates a version when it passes the activation
+// checks. With ?force=true the version is activated despite reported issues.
 func ActivateVersionHandler(c *fiber.Ctx) error {
 	versionID, err := parseVersionID(c.Params("versionID"))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+	force, err := parseForce(c.Query("force"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
-	versionRepo := db.NewVersionRepository()
-	if err := versionRepo.SetActive(versionID); err != nil {
+	versionRepo := newVersionRepository()
+	report, err := versionRepo.SetActive(versionID, db.Activation{
+		UserID: currentUserID(c),
+		Reason: db.ActivationManual,
+		Force:  force,
+	})
+	if err != nil {
 		if errors.Is(err, db.ErrVersionNotFound) {
-			return c.Status(404).JSON(fiber.Map{"error": "version not found"})
+			return c.Status(404).JSON(api.Error("version not found"))
+		}
+		if errors.Is(err, db.ErrActivationChecksFailed) {
+			return c.Status(422).JSON(ActivationFailedResponse{
+				Error:  "version failed activation checks; pass force=true to activate anyway",
+				Report: report,
+			})
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "failed to set active version"})
+		return c.Status(500).JSON(api.Error("failed to set active version"))
 	}
 
 	version, err := versionRepo.Read(versionID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to load active version"})
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 
-	return c.JSON(fiber.Map{"version": version})
+	return c.JSON(ActivateVersionResponse{Version: version, Report: report})
+}
+
+// currentUserID returns the database ID of the signed in admin, or zero
+// when the request did not pass ProtectedRoute.
+func currentUserID(c *fiber.Ctx) int {
+	if user := auth.CurrentUser(c); user != nil {
+		return user.UserID
+	}
+	return 0
+}
+
+func parseForce(raw string) (bool, error) {
+	if strings.TrimSpace(raw) == "" {
+		return false, nil
+	}
+	force, err := strconv.ParseBool(strings.TrimSpace(raw))
+	if err != nil {
+		return false, errors.New("force must be true or false")
+	}
+	return force, nil
+}
+
+// formatActivationIssues joins the report issues for logs and errors.
+func formatActivationIssues(report *db.ActivationReport) string {
+	messages := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		messages = append(messages, issue.Message)
+	}
+	return strings.Join(messages, "; ")
 }
 
 func DeleteVersionHandler(c *fiber.Ctx) error {
 	versionID, err := parseVersionID(c.Params("versionID"))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
-	versionRepo := db.NewVersionRepository()
+	versionRepo := newVersionRepository()
 	if err := versionRepo.Remove(versionID); err != nil {
 		if errors.Is(err, db.ErrCannotDeleteActiveVersion) {
-			return c.Status(409).JSON(fiber.Map{"error": "active version cannot be deleted"})
+			return c.Status(409).JSON(api.Error("active version cannot be deleted"))
 		}
 		if errors.Is(err, db.ErrVersionNotFound) {
-			return c.Status(404).JSON(fiber.Map{"error": "version not found"})
+			return c.Status(404).JSON(api.Error("version not found"))
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "failed to delete version"})
+		return c.Status(500).JSON(api.Error("failed to delete version"))
 	}
 
-	return c.JSON(fiber.Map{"deleted": true, "versionID": versionID})
+	return c.JSON(DeleteVersionResponse{Deleted: true, VersionID: versionID})
 }
@@ -1,28 +1,237 @@
 package admin
 
-import "testing"
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
 
-func TestParseVersionID(t *testing.T) {
-	id, err := parseVersionID(" 42 ")
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+// fakeVersionRepo activates versions unless issues are set, in which case
+// only forced activations go through.
+type fakeVersionRepo struct {
+	db.VersionRepository
+	issues      []db.ActivationIssue
+	activated   []int
+	activations []db.Activation
+}
+
+func (r *fakeVersionRepo) Read(versionID int) (*db.Version, error) {
+	if versionID == 404 {
+		return nil, db.ErrVersionNotFound
+	}
+	return &db.Version{ID: versionID, IsActive: len(r.activated) > 0 && r.activated[len(r.activated)-1] == versionID}, nil
+}
+
+func (r *fakeVersionRepo) SetActive(versionID int, activation db.Activation) (*db.ActivationReport, error) {
+	if _, err := r.Read(versionID); err != nil {
+		return nil, err
+	}
+
+	report := &db.ActivationReport{VersionID: versionID, Issues: r.issues}
+	if !report.Passed() {
+		if !activation.Force {
+			return report, db.ErrActivationChecksFailed
+		}
+		report.Forced = true
+	}
+	r.activated = append(r.activated, versionID)
+	r.activations = append(r.activations, activation)
+	return report, nil
+}
+
+func (r *fakeVersionRepo) Update(versionID int, update db.VersionUpdate) (*db.Version, error) {
+	version, err := r.Read(versionID)
 	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
+		return nil, err
+	}
+	if update.Label != nil {
+		version.Label = *update.Label
 	}
-	if id != 42 {
-		t.Fatalf("expected 42, got %d", id)
+	if update.Notes != nil {
+		version.Notes = *update.Notes
 	}
+	return version, nil
 }
 
-func TestParseVersionIDRejectsInvalidValue(t *testing.T) {
-	if _, err := parseVersionID("abc"); err == nil {
-		t.Fatalf("expected error for non-numeric value")
+func (r *fakeVersionRepo) Remove(versionID int) error {
+	version, err := r.Read(versionID)
+	if err != nil {
+		return err
 	}
+	if version.IsActive {
+		return db.ErrCannotDeleteActiveVersion
+	}
+	return nil
 }
 
-func TestParseVersionIDRejectsZeroOrNegative(t *testing.T) {
-	if _, err := parseVersionID("0"); err == nil {
-		t.Fatalf("expected error for zero")
+func newActivateTestApp(t *testing.T, versionRepo *fakeVersionRepo) *fiber.App {
+	t.Helper()
+
+	newVersionRepository = func() db.VersionRepository { return versionRepo }
+	t.Cleanup(func() { newVersionRepository = db.NewVersionRepository })
+
+	app := fiber.New()
+	app.Post("/api/admin/versions/:versionID/activate", ActivateVersionHandler)
+	app.Patch("/api/admin/versions/:versionID", UpdateVersionHandler)
+	app.Delete("/api/admin/versions/:versionID", DeleteVersionHandler)
+	return app
+}
+
+func TestActivateVersionHandlerReportsFailedChecks(t *testing.T) {
+	versionRepo := &fakeVersionRepo{issues: []db.ActivationIssue{
+		{Check: "empty-version", Message: "version has no villains"},
+	}}
+	app := newActivateTestApp(t, versionRepo)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/5/activate", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusUnprocessableEntity {
+		t.Fatalf("expected %d, got %d", fiber.StatusUnprocessableEntity, res.StatusCode)
+	}
+
+	var payload ActivationFailedResponse
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if payload.Report == nil || len(payload.Report.Issues) != 1 || payload.Report.Issues[0].Check != "empty-version" {
+		t.Fatalf("expected report with the failed check, got %+v", payload.Report)
+	}
+	if len(versionRepo.activated) != 0 {
+		t.Fatalf("expected version not to be activated")
+	}
+}
+
+func TestActivateVersionHandlerForce(t *testing.T) {
+	versionRepo := &fakeVersionRepo{issues: []db.ActivationIssue{
+		{Check: "empty-version", Message: "version has no villains"},
+	}}
+	app := newActivateTestApp(t, versionRepo)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/5/activate?force=true", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected %d, got %d", fiber.StatusOK, res.StatusCode)
+	}
+
+	var payload ActivateVersionResponse
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !payload.Report.Forced || !payload.Version.IsActive {
+		t.Fatalf("expected forced activation, got %+v %+v", payload.Version, payload.Report)
+	}
+}
+
+func TestActivateVersionHandlerRejectsInvalidForce(t *testing.T) {
+	app := newActivateTestApp(t, &fakeVersionRepo{})
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/5/activate?force=maybe", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", fiber.StatusBadRequest, res.StatusCode)
+	}
+}
+
+func TestUpdateVersionHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantLabel  string
+		wantNotes  string
+	}{
+		{
+			name:       "label and notes",
+			path:       "/api/admin/versions/5",
+			body:       `{"label":"  Syksy 2026  ","notes":"Korjattu Kronikka-numerot"}`,
+			wantStatus: fiber.StatusOK,
+			wantLabel:  "Syksy 2026",
+			wantNotes:  "Korjattu Kronikka-numerot",
+		},
+		{
+			name:       "notes only",
+			path:       "/api/admin/versions/5",
+			body:       `{"notes":"vain muistiinpano"}`,
+			wantStatus: fiber.StatusOK,
+			wantNotes:  "vain muistiinpano",
+		},
+		{
+			name:       "no fields",
+			path:       "/api/admin/versions/5",
+			body:       `{}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "label too long",
+			path:       "/api/admin/versions/5",
+			body:       `{"label":"` + strings.Repeat("a", maxVersionLabelLength+1) + `"}`,
+			wantStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:       "unknown version",
+			path:       "/api/admin/versions/404",
+			body:       `{"label":"x"}`,
+			wantStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newActivateTestApp(t, &fakeVersionRepo{})
+
+			req := httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var payload VersionResponse
+			if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if payload.Version.Label != tt.wantLabel || payload.Version.Notes != tt.wantNotes {
+				t.Fatalf("unexpected version %+v", payload.Version)
+			}
+		})
+	}
+}
+
+func TestDeleteVersionHandler(t *testing.T) {
+	versionRepo := &fakeVersionRepo{activated: []int{3}}
+	app := newActivateTestApp(t, versionRepo)
+
+	cases := map[string]int{
+		"/api/admin/versions/2":   fiber.StatusOK,
+		"/api/admin/versions/3":   fiber.StatusConflict,
+		"/api/admin/versions/404": fiber.StatusNotFound,
+		"/api/admin/versions/abc": fiber.StatusBadRequest,
 	}
-	if _, err := parseVersionID("-5"); err == nil {
-		t.Fatalf("expected error for negative")
+	for target, expected := range cases {
+		res, err := app.Test(httptest.NewRequest(http.MethodDelete, target, nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if res.StatusCode != expected {
+			t.Fatalf("%s: expected %d, got %d", target, expected, res.StatusCode)
+		}
 	}
 }
@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type PruneVersionsResponse struct {
+	Plan    *db.PrunePlan `json:"plan"`
+	Deleted []int         `json:"deleted"`
+}
+
+func PinVersionHandler(c *fiber.Ctx) error {
+	return setVersionPinned(c, true)
+}
+
+func UnpinVersionHandler(c *fiber.Ctx) error {
+	return setVersionPinned(c, false)
+}
+
+func setVersionPinned(c *fiber.Ctx, pinned bool) error {
+	versionID, err := parseVersionID(c.Params("versionID"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	versionRepo := newVersionRepository()
+	if err := versionRepo.SetPinned(versionID, pinned); err != nil {
+		if errors.Is(err, db.ErrVersionNotFound) {
+			return c.Status(404).JSON(api.Error("version not found"))
+		}
+		return c.Status(500).JSON(api.Error("failed to update version"))
+	}
+
+	version, err := versionRepo.Read(versionID)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to load version"))
+	}
+	return c.JSON(VersionResponse{Version: version})
+}
+
+func VersionDiskUsageHandler(c *fiber.Ctx) error {
+	usage, err := newVersionRepository().DiskUsage()
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to measure disk usage"))
+	}
+	return c.JSON(usage)
+}
+
+// PreviewPruneHandler lists the versions PruneVersionsHandler would delete
+// without deleting anything.
+func PreviewPruneHandler(c *fiber.Ctx) error {
+	policy, err := parseRetentionPolicy(c.Query("keep"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	plan, err := db.PlanPruning(newVersionRepository(), newVersionActivationRepository(), policy)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to plan pruning"))
+	}
+	return c.JSON(plan)
+}
+
+// PruneVersionsHandler deletes the versions the retention policy does not
+// keep. It holds the import lock so that a version being imported is never
+// pruned before it is complete.
+func PruneVersionsHandler(c *fiber.Ctx) error {
+	policy, err := parseRetentionPolicy(c.Query("keep"))
+	if err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	if !startImport() {
+		return c.Status(409).JSON(api.Error("import running, try again when it has finished"))
+	}
+	defer finishImport()
+
+	lock, err := acquireImportLock()
+	if err != nil {
+		if errors.Is(err, db.ErrImportLocked) {
+			return c.Status(409).JSON(api.Error("import running, try again when it has finished"))
+		}
+		return c.Status(500).JSON(api.Error("failed to acquire import lock"))
+	}
+	defer releaseImportLock(lock)
+
+	versionRepo := newVersionRepository()
+	plan, err := db.PlanPruning(versionRepo, newVersionActivationRepository(), policy)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to plan pruning"))
+	}
+
+	deleted, err := db.ApplyPruning(versionRepo, plan)
+	if err != nil {
+		log.Printf("pruning failed after deleting versions %v: %v", deleted, err)
+		return c.Status(500).JSON(api.Error("failed to prune versions"))
+	}
+	return c.JSON(PruneVersionsResponse{Plan: plan, Deleted: deleted})
+}
+
+// parseRetentionPolicy reads the optional keep override; the default comes
+// from ROISTOT_RETENTION_KEEP_VERSIONS.
+func parseRetentionPolicy(raw string) (db.RetentionPolicy, error) {
+	if strings.TrimSpace(raw) == "" {
+		return db.RetentionPolicy{KeepLatest: config.RetentionKeepVersions}, nil
+	}
+	keep, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || keep < 0 {
+		return db.RetentionPolicy{}, errors.New("keep must be a non-negative integer")
+	}
+	return db.RetentionPolicy{KeepLatest: keep}, nil
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type fakePruneVersionRepo struct {
+	db.VersionRepository
+	versions []*db.Version
+	removed  []int
+}
+
+func (r *fakePruneVersionRepo) List() ([]*db.Version, error) {
+	return r.versions, nil
+}
+
+func (r *fakePruneVersionRepo) DiskUsage() (*db.DiskUsageReport, error) {
+	report := &db.DiskUsageReport{}
+	for _, version := range r.versions {
+		report.Versions = append(report.Versions, &db.VersionDiskUsage{VersionID: version.ID, Bytes: 100})
+	}
+	return report, nil
+}
+
+func (r *fakePruneVersionRepo) Remove(versionID int) error {
+	r.removed = append(r.removed, versionID)
+	return nil
+}
+
+func newPruneTestApp(t *testing.T, versionRepo *fakePruneVersionRepo) *fiber.App {
+	t.Helper()
+
+	resetImportState(t)
+	newVersionRepository = func() db.VersionRepository { return versionRepo }
+	newVersionActivationRepository = func() db.VersionActivationRepository { return &fakeVersionActivationRepo{} }
+	t.Cleanup(func() {
+		newVersionRepository = db.NewVersionRepository
+		newVersionActivationRepository = db.NewVersionActivationRepository
+	})
+
+	app := fiber.New()
+	app.Get("/api/admin/versions/prune", PreviewPruneHandler)
+	app.Post("/api/admin/versions/prune", PruneVersionsHandler)
+	return app
+}
+
+func pruneTestVersions() []*db.Version {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []*db.Version{
+		{ID: 1, CreatedAt: start},
+		{ID: 2, CreatedAt: start.Add(time.Hour), IsActive: true},
+		{ID: 3, CreatedAt: start.Add(2 * time.Hour)},
+		{ID: 4, CreatedAt: start.Add(3 * time.Hour)},
+	}
+}
+
+func TestPreviewPruneHandlerDoesNotDelete(t *testing.T) {
+	versionRepo := &fakePruneVersionRepo{versions: pruneTestVersions()}
+	app := newPruneTestApp(t, versionRepo)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/admin/versions/prune?keep=1", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected %d, got %d", fiber.StatusOK, res.StatusCode)
+	}
+
+	var plan db.PrunePlan
+	if err := json.NewDecoder(res.Body).Decode(&plan); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(plan.Prune) != 2 || plan.PruneBytes != 200 {
+		t.Fatalf("expected two versions and 200 bytes to prune, got %+v", plan)
+	}
+	if len(versionRepo.removed) != 0 {
+		t.Fatalf("expected preview not to delete versions, deleted %v", versionRepo.removed)
+	}
+}
+
+func TestPruneVersionsHandler(t *testing.T) {
+	versionRepo := &fakePruneVersionRepo{versions: pruneTestVersions()}
+	app := newPruneTestApp(t, versionRepo)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/prune?keep=1", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected %d, got %d", fiber.StatusOK, res.StatusCode)
+	}
+
+	var payload PruneVersionsResponse
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := []int{3, 1}; !reflect.DeepEqual(payload.Deleted, want) || !reflect.DeepEqual(versionRepo.removed, want) {
+		t.Fatalf("expected versions %v to be deleted, got %v", want, payload.Deleted)
+	}
+	if isImportRunning() {
+		t.Fatalf("expected pruning to release the import guard")
+	}
+}
+
+func TestPruneVersionsHandlerWaitsForImport(t *testing.T) {
+	versionRepo := &fakePruneVersionRepo{versions: pruneTestVersions()}
+	app := newPruneTestApp(t, versionRepo)
+	importRunning = true
+
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/api/admin/versions/prune", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if res.StatusCode != fiber.StatusConflict {
+		t.Fatalf("expected %d, got %d", fiber.StatusConflict, res.StatusCode)
+	}
+	if len(versionRepo.removed) != 0 {
+		t.Fatalf("expected no versions to be deleted")
+	}
+}
+
+func TestPruneHandlersRejectInvalidKeep(t *testing.T) {
+	app := newPruneTestApp(t, &fakePruneVersionRepo{})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		res, err := app.Test(httptest.NewRequest(method, "/api/admin/versions/prune?keep=-1", nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if res.StatusCode != fiber.StatusBadRequest {
+			t.Fatalf("%s: expected %d, got %d", method, fiber.StatusBadRequest, res.StatusCode)
+		}
+	}
+}
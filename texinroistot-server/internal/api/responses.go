@@ -0,0 +1,36 @@
+package api
+
+// ErrorResponse is the body of every JSON error response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func Error(message string) ErrorResponse {
+	return ErrorResponse{Error: message}
+}
+
+// PageMeta describes page-based pagination of list responses.
+type PageMeta struct {
+	Total      int `json:"total"`
+	Page       int `json:"page"`
+	PageSize   int `json:"pageSize"`
+	TotalPages int `json:"totalPages"`
+}
+
+func NewPageMeta(total int, page int, pageSize int) PageMeta {
+	totalPages := 0
+	if total > 0 {
+		totalPages = (total + pageSize - 1) / pageSize
+	}
+	return PageMeta{
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}
+}
+
+// CountMeta is used by unpaginated list responses.
+type CountMeta struct {
+	Total int `json:"total"`
+}
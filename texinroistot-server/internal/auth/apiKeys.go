@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var newAPIKeyRepository = db.NewAPIKeyRepository
+
+const (
+	// apiKeyMarker starts every key so that leaked keys are easy to spot.
+	apiKeyMarker = "trk_"
+	// apiKeyPrefixLength is how much of the key is stored in clear text.
+	apiKeyPrefixLength = len(apiKeyMarker) + 8
+)
+
+// NewAPIKey returns a random key and the prefix shown in key listings.
+func NewAPIKey() (key string, prefix string, err error) {
+	keyBytes, err := crypt.RandomBytes(24)
+	if err != nil {
+		return "", "", err
+	}
+	key = apiKeyMarker + hex.EncodeToString(keyBytes)
+	return key, key[:apiKeyPrefixLength], nil
+}
+
+// APIKeyHash returns the stored form of an API key.
+func APIKeyHash(key string) string {
+	return crypt.Hash(key)
+}
+
+// bearerToken returns the token of an Authorization: Bearer header, or ""
+// when the request has none.
+func bearerToken(c *fiber.Ctx) string {
+	scheme, token, found := strings.Cut(strings.TrimSpace(c.Get(fiber.HeaderAuthorization)), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// apiKeyUserInfo authenticates an API key. The key acts as its owner, but
+// only with the scopes the owner still holds. Unknown, expired and revoked
+// keys are rejected with 401.
+func apiKeyUserInfo(key string) (*UserInfo, error) {
+	apiKey, err := newAPIKeyRepository().Authenticate(APIKeyHash(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid api key")
+	}
+
+	roles := apiKey.Roles()
+	return &UserInfo{
+		LoggedIn: true,
+		IsAdmin:  len(roles) > 0,
+		Roles:    roles,
+		Hash:     apiKey.UserHash,
+		UserID:   apiKey.UserID,
+		APIKeyID: apiKey.ID,
+	}, nil
+}
@@ -29,12 +29,22 @@ type JWTUserClaims struct {
 }
 
 func (a *AuthService) CreateAccessToken(sharedToken string, email string) (string, error) {
-	accessShared, err := crypt.Encrypt(sharedToken)
+	return a.createUserToken(config.CookieAccessSecret, config.LoginExpiresAfter, sharedToken, email)
+}
+
+// CreateMagicLinkToken creates the token of an emailed login link. The nonce
+// is kept in a cookie of the browser that asked for the link.
+func (a *AuthService) CreateMagicLinkToken(nonce string, email string) (string, error) {
+	return a.createUserToken(config.MagicLinkSecret, config.MagicLinkExpiresAfter, nonce, email)
+}
+
+func (a *AuthService) createUserToken(secret string, expiresAfter time.Duration, sharedToken string, email string) (string, error) {
+	shared, err := crypt.Encrypt(sharedToken)
 	if err != nil {
 		return "", err
 	}
 
-	signingKey := []byte(config.CookieAccessSecret)
+	signingKey := []byte(secret)
 	userHash := userHashForEmail(email)
 	encryptedEmail, err := crypt.Encrypt(email)
 	if err != nil {
@@ -47,10 +57,10 @@ func (a *AuthService) CreateAccessToken(sharedToken string, email string) (strin
 			encryptedEmail.GetContent(),
 			encryptedEmail.GetIv(),
 		},
-		accessShared.GetContent(),
-		accessShared.GetIv(),
+		shared.GetContent(),
+		shared.GetIv(),
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresAfter)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -72,7 +82,7 @@ func (a *AuthService) CreateRefreshToken(sharedKey string) (string, error) {
 		refreshShared.GetContent(),
 		refreshShared.GetIv(),
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.RefreshExpiresAfter)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -89,24 +99,71 @@ func (a AuthService) VerifyAccessToken(token string, jwtOpts ...JWTVerifyOptionO
 	return a.verifyToken(config.CookieAccessSecret, token, jwtOpts...)
 }
 
+// DevLoginClaims are the claims of a development login token.
+type DevLoginClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// CreateDevLoginToken signs a development login token for the email. Only
+// servers with ROISTOT_DEV_LOGIN accept it.
+func (a *AuthService) CreateDevLoginToken(email string, expiresAfter time.Duration) (string, error) {
+	if len(config.DevLoginSecret) == 0 {
+		return "", fmt.Errorf("ROISTOT_DEV_LOGIN_SECRET is not set")
+	}
+	claims := DevLoginClaims{
+		email,
+		jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresAfter)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(config.DevLoginSecret))
+}
+
+func (a AuthService) VerifyMagicLinkToken(token string, jwtOpts ...JWTVerifyOptionOverride) (*JWTClaims, error) {
+	return a.verifyToken(config.MagicLinkSecret, token, jwtOpts...)
+}
+
 type JWTVerifyOption struct {
-	key interface{}
+	key          interface{}
+	ignoreExpiry bool
 }
 
 type JWTVerifyOptionOverride func(o *JWTVerifyOption)
 
+// IgnoreExpiry accepts an expired but otherwise valid token. The refresh
+// flow uses it to read the access token it replaces.
+func IgnoreExpiry() JWTVerifyOptionOverride {
+	return func(o *JWTVerifyOption) {
+		o.ignoreExpiry = true
+	}
+}
+
 var (
 	unexpectedSigningMethodError = "unexpected signing method: %v"
 )
 
 func (a AuthService) verifyToken(secret string, token string, jwtOpts ...JWTVerifyOptionOverride) (*JWTClaims, error) {
-	options := &JWTVerifyOption{[]byte(secret)}
+	// An empty secret would accept tokens signed by anyone.
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("jwt secret is not configured")
+	}
+
+	options := &JWTVerifyOption{key: []byte(secret)}
 
 	for _, opt := range jwtOpts {
 		opt(options)
 	}
 
-	jwtToken, err := jwt.ParseWithClaims(token, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+	var parserOpts []jwt.ParserOption
+	if options.ignoreExpiry {
+		parserOpts = append(parserOpts, jwt.WithoutClaimsValidation())
+	}
+
+	jwtToken, err := jwt.NewParser(parserOpts...).ParseWithClaims(token, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf(unexpectedSigningMethodError, token.Header["alg"])
 		}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/kokkoniemi/texinroistot/internal/config"
 )
@@ -26,3 +28,15 @@ func authCookieValue(c *fiber.Ctx, baseName string) string {
 
 	return c.Cookies("__Host-" + baseName)
 }
+
+// setLoginCookie stores short-lived state of a login in progress.
+func setLoginCookie(c *fiber.Ctx, baseName string, value string, maxAge time.Duration) {
+	c.Cookie(&fiber.Cookie{
+		Name:     authCookieName(baseName),
+		Value:    value,
+		HTTPOnly: true,
+		SameSite: "lax",
+		Secure:   config.CookieSecure,
+		MaxAge:   int(maxAge.Seconds()),
+	})
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+// CSRFHeader carries the token of the csrf cookie on requests that change
+// data. Other sites can make the browser send the cookie, but cannot read
+// it to set the header.
+const CSRFHeader = "X-CSRF-Token"
+
+var CSRFHeaderParameter = openapi.Parameter{
+	Name:        CSRFHeader,
+	In:          openapi.ParamInHeader,
+	Description: "Token from GET /api/csrf, required when the request carries authentication cookies",
+}
+
+type CSRFTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// CSRFProtection requires the token of the csrf cookie in the X-CSRF-Token
+// header of requests that are not GET, HEAD or OPTIONS. Only requests with
+// authentication cookies are checked: API keys are not sent by browsers on
+// their own, and login requests are checked by their provider (Google's
+// g_csrf_token, OIDC state, magic link nonce).
+func CSRFProtection(c *fiber.Ctx) error {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return c.Next()
+	}
+
+	if bearerToken(c) != "" || isLoginPath(c.Path()) {
+		return c.Next()
+	}
+	if authCookieValue(c, "a") == "" && authCookieValue(c, "r") == "" {
+		return c.Next()
+	}
+
+	token := authCookieValue(c, "csrf")
+	header := c.Get(CSRFHeader)
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(header)) != 1 {
+		return fiber.NewError(fiber.StatusForbidden, "missing or invalid CSRF token")
+	}
+	return c.Next()
+}
+
+// CSRFTokenHandler returns the token to send in the X-CSRF-Token header,
+// setting the csrf cookie when the browser has none.
+func CSRFTokenHandler(c *fiber.Ctx) error {
+	token, err := ensureCSRFToken(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(CSRFTokenResponse{Token: token})
+}
+
+// ensureCSRFToken returns the token of the csrf cookie, setting a new cookie
+// when there is none. The cookie is readable by scripts on purpose.
+func ensureCSRFToken(c *fiber.Ctx) (string, error) {
+	if token := authCookieValue(c, "csrf"); token != "" {
+		return token, nil
+	}
+
+	tokenBytes, err := crypt.RandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	c.Cookie(&fiber.Cookie{
+		Name:     authCookieName("csrf"),
+		Value:    token,
+		HTTPOnly: false,
+		SameSite: "lax",
+		Secure:   config.CookieSecure,
+	})
+	return token, nil
+}
+
+func isLoginPath(path string) bool {
+	return path == "/api/login" || strings.HasPrefix(path, "/api/login/")
+}
+
+// WithCSRFHeader documents the X-CSRF-Token header on the operations that
+// CSRFProtection checks.
+func WithCSRFHeader(operations []openapi.Operation) []openapi.Operation {
+	for i, op := range operations {
+		switch op.Method {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			continue
+		}
+		if isLoginPath(op.Path) {
+			continue
+		}
+		op.Parameters = append(append([]openapi.Parameter{}, op.Parameters...), CSRFHeaderParameter)
+		responses := make(map[int]openapi.Response, len(op.Responses)+1)
+		for status, response := range op.Responses {
+			responses[status] = response
+		}
+		forbidden, ok := responses[fiber.StatusForbidden]
+		if ok {
+			forbidden.Description += ", or the CSRF token is missing or invalid"
+		} else {
+			forbidden = openapi.Response{Description: "CSRF token missing or invalid"}
+		}
+		responses[fiber.StatusForbidden] = forbidden
+		op.Responses = responses
+		operations[i] = op
+	}
+	return operations
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+func setupCSRFTest(t *testing.T) *fiber.App {
+	t.Helper()
+
+	cookieSecure := config.CookieSecure
+	config.CookieSecure = false
+	t.Cleanup(func() { config.CookieSecure = cookieSecure })
+
+	app := fiber.New()
+	api := app.Group("/api", CSRFProtection)
+	api.Get("/csrf", CSRFTokenHandler)
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	api.Get("/me", ok)
+	api.Post("/logout", ok)
+	api.Post("/login", ok)
+	return app
+}
+
+func TestCSRFTokenHandler(t *testing.T) {
+	app := setupCSRFTest(t)
+
+	res, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/csrf", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var body CSRFTokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	var cookie *http.Cookie
+	for _, c := range res.Cookies() {
+		if c.Name == "csrf" {
+			cookie = c
+		}
+	}
+	if body.Token == "" || cookie == nil || cookie.Value != body.Token {
+		t.Fatalf("expected the token to be returned and set as a cookie, got %q and %v", body.Token, cookie)
+	}
+	if cookie.HttpOnly {
+		t.Fatalf("expected the csrf cookie to be readable by scripts")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/csrf", nil)
+	req.AddCookie(&http.Cookie{Name: "csrf", Value: "existing"})
+	res, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Token != "existing" || len(res.Cookies()) != 0 {
+		t.Fatalf("expected the existing token to be kept, got %q", body.Token)
+	}
+}
+
+func TestCSRFProtection(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		cookies    map[string]string
+		header     string
+		bearer     string
+		wantStatus int
+	}{
+		{
+			name:       "safe method",
+			method:     http.MethodGet,
+			path:       "/api/me",
+			cookies:    map[string]string{"a": "token"},
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "no authentication cookies",
+			method:     http.MethodPost,
+			path:       "/api/logout",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "missing header",
+			method:     http.MethodPost,
+			path:       "/api/logout",
+			cookies:    map[string]string{"a": "token", "csrf": "csrf-token"},
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "missing cookie",
+			method:     http.MethodPost,
+			path:       "/api/logout",
+			cookies:    map[string]string{"r": "token"},
+			header:     "csrf-token",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "mismatching header",
+			method:     http.MethodPost,
+			path:       "/api/logout",
+			cookies:    map[string]string{"a": "token", "csrf": "csrf-token"},
+			header:     "other-token",
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "matching header",
+			method:     http.MethodPost,
+			path:       "/api/logout",
+			cookies:    map[string]string{"a": "token", "csrf": "csrf-token"},
+			header:     "csrf-token",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "api key",
+			method:     http.MethodPost,
+			path:       "/api/logout",
+			cookies:    map[string]string{"a": "token"},
+			bearer:     "trk_key",
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "login",
+			method:     http.MethodPost,
+			path:       "/api/login",
+			cookies:    map[string]string{"a": "token"},
+			wantStatus: fiber.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := setupCSRFTest(t)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			if tt.header != "" {
+				req.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+		})
+	}
+}
@@ -1,10 +1,16 @@
 package auth
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 )
 
+type DeleteMeResponse struct {
+	Deleted bool `json:"deleted"`
+}
+
 func DeleteMeHandler(c *fiber.Ctx) error {
 	user, err := getUserInfo(c)
 	if err != nil {
@@ -14,13 +20,16 @@ func DeleteMeHandler(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
 	}
 
-	userRepo := db.NewUserRepository()
+	userRepo := newUserRepository()
 	if err := userRepo.Remove(user.Hash); err != nil {
+		if errors.Is(err, db.ErrLastUserAdmin) {
+			return fiber.NewError(fiber.StatusConflict, "the last user admin cannot delete their account")
+		}
 		return err
 	}
 
 	trashCookie(c, "a")
 	trashCookie(c, "r")
 
-	return c.JSON(fiber.Map{"deleted": true})
+	return c.JSON(DeleteMeResponse{Deleted: true})
 }
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+const LoginProviderDev = "dev"
+
+// devLoginProvider signs in without network access, for local development
+// and integration tests. Tokens are created with cmd/devlogin.
+type devLoginProvider struct{}
+
+// devLoginEnabled reports whether development login is on. Secure cookies
+// mean a deployed server, where it is never allowed.
+func devLoginEnabled() bool {
+	return config.DevLogin && !config.CookieSecure
+}
+
+func (devLoginProvider) Start(c *fiber.Ctx) error {
+	return fiber.NewError(fiber.StatusNotFound, "dev login has no start step")
+}
+
+// Verify reads the token from the dev_token form field, or from the query
+// of a callback request.
+func (devLoginProvider) Verify(c *fiber.Ctx) (string, error) {
+	if !devLoginEnabled() {
+		return "", errors.New("dev login is disabled")
+	}
+	if config.DevLoginEmail == "" || config.DevLoginSecret == "" {
+		return "", errors.New("dev login is not configured")
+	}
+
+	token := c.FormValue("dev_token")
+	if token == "" {
+		token = c.Query("dev_token")
+	}
+
+	claims := &DevLoginClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf(unexpectedSigningMethodError, token.Header["alg"])
+		}
+		return []byte(config.DevLoginSecret), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid dev login token: %w", err)
+	}
+
+	if normalizeEmail(claims.Email) != normalizeEmail(config.DevLoginEmail) {
+		return "", errors.New("dev login token is not for ROISTOT_DEV_LOGIN_EMAIL")
+	}
+	return claims.Email, nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+func setupDevLoginTest(t *testing.T) (*fiber.App, *fakeSessionRepo) {
+	t.Helper()
+
+	app, _, sessionRepo := setupLoginTest(t)
+	app.Post("/api/login", LoginHandler)
+
+	devLogin, email, secret := config.DevLogin, config.DevLoginEmail, config.DevLoginSecret
+	config.DevLogin = true
+	config.DevLoginEmail = "Dev@Example.com"
+	config.DevLoginSecret = "dev-secret"
+	t.Cleanup(func() {
+		config.DevLogin, config.DevLoginEmail, config.DevLoginSecret = devLogin, email, secret
+	})
+	return app, sessionRepo
+}
+
+func devLoginRequest(t *testing.T, app *fiber.App, token string) *http.Response {
+	t.Helper()
+
+	form := url.Values{"dev_token": {token}}
+	req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return loginRequest(t, app, req)
+}
+
+func TestDevLogin(t *testing.T) {
+	app, sessionRepo := setupDevLoginTest(t)
+
+	if got := enabledLoginProviders(); !reflect.DeepEqual(got, []string{LoginProviderOIDC, LoginProviderMagicLink, LoginProviderDev}) {
+		t.Fatalf("expected dev login to be listed last, got %v", got)
+	}
+
+	token, err := NewAuthService().CreateDevLoginToken("dev@example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	res := devLoginRequest(t, app, token)
+	if res.StatusCode != fiber.StatusFound || res.Header.Get("Location") != "/hallinta" {
+		t.Fatalf("expected redirect to /hallinta, got %d %q", res.StatusCode, res.Header.Get("Location"))
+	}
+	if len(sessionRepo.sessions) != 1 || responseCookie(res, "a") == nil {
+		t.Fatalf("expected a session and its cookies")
+	}
+
+	// Callbacks work too, so a browser can sign in from a link.
+	res = loginRequest(t, app, httptest.NewRequest(http.MethodGet, "/api/login/dev/callback?dev_token="+url.QueryEscape(token), nil))
+	if res.StatusCode != fiber.StatusFound {
+		t.Fatalf("expected the callback to sign in, got %d", res.StatusCode)
+	}
+}
+
+func TestDevLoginRejectsTokens(t *testing.T) {
+	app, sessionRepo := setupDevLoginTest(t)
+
+	otherEmail, err := NewAuthService().CreateDevLoginToken("other@example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	expired, err := NewAuthService().CreateDevLoginToken("dev@example.com", -time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	config.DevLoginSecret = "another-secret"
+	otherSecret, err := NewAuthService().CreateDevLoginToken("dev@example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	config.DevLoginSecret = "dev-secret"
+
+	for name, token := range map[string]string{"other email": otherEmail, "expired": expired, "other secret": otherSecret} {
+		if res := devLoginRequest(t, app, token); res.StatusCode != fiber.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", name, res.StatusCode)
+		}
+	}
+	if len(sessionRepo.sessions) != 0 {
+		t.Fatalf("expected no sessions")
+	}
+}
+
+func TestDevLoginDisabled(t *testing.T) {
+	tests := []struct {
+		name         string
+		devLogin     bool
+		cookieSecure bool
+	}{
+		{name: "flag not set", devLogin: false},
+		{name: "secure cookies", devLogin: true, cookieSecure: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, sessionRepo := setupDevLoginTest(t)
+			config.DevLogin = tt.devLogin
+			config.CookieSecure = tt.cookieSecure
+
+			token, err := NewAuthService().CreateDevLoginToken("dev@example.com", time.Minute)
+			if err != nil {
+				t.Fatalf("failed to create token: %v", err)
+			}
+			// Google login is not enabled either, so the request is refused.
+			if res := devLoginRequest(t, app, token); res.StatusCode != fiber.StatusNotFound {
+				t.Fatalf("expected 404, got %d", res.StatusCode)
+			}
+			if res := loginRequest(t, app, httptest.NewRequest(http.MethodGet, "/api/login/dev/callback?dev_token="+token, nil)); res.StatusCode != fiber.StatusNotFound {
+				t.Fatalf("expected 404 for the callback, got %d", res.StatusCode)
+			}
+			if len(sessionRepo.sessions) != 0 {
+				t.Fatalf("expected no sessions")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var (
+	newAuditLogRepository          = db.NewAuditLogRepository
+	newVersionRepository           = db.NewVersionRepository
+	newVersionActivationRepository = db.NewVersionActivationRepository
+)
+
+// ExportMeResponse is everything stored about the signed-in user. The audit
+// log has the entries made by the user and those aimed at them, e.g. role
+// changes by other admins.
+type ExportMeResponse struct {
+	ExportedAt           time.Time                 `json:"exportedAt"`
+	User                 ExportedUser              `json:"user"`
+	Sessions             []*db.Session             `json:"sessions"`
+	APIKeys              []*db.APIKey              `json:"apiKeys"`
+	ImportedVersions     []*db.Version             `json:"importedVersions"`
+	Activations          []*db.VersionActivation   `json:"activations"`
+	ScheduledActivations []*db.ScheduledActivation `json:"scheduledActivations"`
+	AuditLog             []*db.AuditEntry          `json:"auditLog"`
+}
+
+// ExportedUser is the user row. The email comes from the login, EmailStored
+// tells whether an encrypted copy of it is kept (ROISTOT_STORE_USER_EMAILS).
+type ExportedUser struct {
+	Hash        string    `json:"hash"`
+	Email       string    `json:"email"`
+	EmailStored bool      `json:"emailStored"`
+	IsAdmin     bool      `json:"isAdmin"`
+	Roles       []string  `json:"roles"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ExportMeHandler returns the data of the signed-in user as a JSON download.
+func ExportMeHandler(c *fiber.Ctx) error {
+	info, err := getUserInfo(c)
+	if err != nil {
+		return err
+	}
+	if !info.LoggedIn {
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	user, err := newUserRepository().ReadByHash(info.Hash)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	sessions, err := newSessionRepository().ListByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	apiKeys, err := newAPIKeyRepository().List(user.ID)
+	if err != nil {
+		return err
+	}
+	importedVersions, err := newVersionRepository().ListImportedBy(user.ID)
+	if err != nil {
+		return err
+	}
+	activationRepo := newVersionActivationRepository()
+	activations, err := activationRepo.HistoryBy(user.ID)
+	if err != nil {
+		return err
+	}
+	scheduledActivations, err := activationRepo.ListScheduledBy(user.ID)
+	if err != nil {
+		return err
+	}
+	auditLog, err := newAuditLogRepository().ListByUser(user.Hash)
+	if err != nil {
+		return err
+	}
+
+	export := ExportMeResponse{
+		ExportedAt: time.Now().UTC(),
+		User: ExportedUser{
+			Hash:        user.Hash,
+			Email:       info.Email,
+			EmailStored: user.EmailContent != "",
+			IsAdmin:     user.IsAdmin,
+			Roles:       user.Roles,
+			CreatedAt:   user.CreatedAt,
+		},
+		Sessions:             sessions,
+		APIKeys:              apiKeys,
+		ImportedVersions:     importedVersions,
+		Activations:          activations,
+		ScheduledActivations: scheduledActivations,
+		AuditLog:             auditLog,
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Attachment("texinroistot-" + export.ExportedAt.Format("2006-01-02") + ".json")
+	return c.JSON(export)
+}
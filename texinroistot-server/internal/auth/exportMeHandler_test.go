@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func (r *fakeSessionRepo) ListByUser(userID int) ([]*db.Session, error) {
+	sessions := []*db.Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeAPIKeyRepo) List(userID int) ([]*db.APIKey, error) {
+	keys := []*db.APIKey{}
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+type fakeAuditLogRepo struct {
+	db.AuditLogRepository
+	entries []*db.AuditEntry
+}
+
+func (r *fakeAuditLogRepo) ListByUser(userHash string) ([]*db.AuditEntry, error) {
+	matching := []*db.AuditEntry{}
+	for _, entry := range r.entries {
+		if entry.ActorHash == userHash || entry.Target == "userHash="+userHash {
+			matching = append(matching, entry)
+		}
+	}
+	return matching, nil
+}
+
+type fakeVersionRepo struct {
+	db.VersionRepository
+	versions []*db.Version
+}
+
+func (r *fakeVersionRepo) ListImportedBy(userID int) ([]*db.Version, error) {
+	imported := []*db.Version{}
+	for _, version := range r.versions {
+		if version.ImportedByID == userID {
+			imported = append(imported, version)
+		}
+	}
+	return imported, nil
+}
+
+type fakeVersionActivationRepo struct {
+	db.VersionActivationRepository
+	activations map[int][]*db.VersionActivation
+	scheduled   []*db.ScheduledActivation
+}
+
+func (r *fakeVersionActivationRepo) HistoryBy(userID int) ([]*db.VersionActivation, error) {
+	return append([]*db.VersionActivation{}, r.activations[userID]...), nil
+}
+
+func (r *fakeVersionActivationRepo) ListScheduledBy(userID int) ([]*db.ScheduledActivation, error) {
+	scheduled := []*db.ScheduledActivation{}
+	for _, activation := range r.scheduled {
+		if activation.CreatedByID == userID {
+			scheduled = append(scheduled, activation)
+		}
+	}
+	return scheduled, nil
+}
+
+func TestExportMeHandler(t *testing.T) {
+	const email = "user@example.com"
+
+	app := setupRefreshTest(t)
+	app.Get("/api/me/export", ExportMeHandler)
+	t.Cleanup(func() {
+		newAPIKeyRepository = db.NewAPIKeyRepository
+		newAuditLogRepository = db.NewAuditLogRepository
+		newVersionRepository = db.NewVersionRepository
+		newVersionActivationRepository = db.NewVersionActivationRepository
+	})
+
+	userHash := userHashForEmail(email)
+	newUserRepository = func() db.UserRepository {
+		return &fakeUserRepo{users: map[string]*db.User{userHash: {
+			ID:           1,
+			Hash:         userHash,
+			Roles:        []string{db.RoleViewer},
+			IsAdmin:      true,
+			EmailContent: "encrypted",
+		}}}
+	}
+	newSessionRepository = func() db.SessionRepository {
+		return &fakeSessionRepo{sessions: map[string]*db.Session{
+			sessionKeyHash("shared"): {ID: 1, UserID: 1, UserAgent: "test"},
+			sessionKeyHash("other"):  {ID: 2, UserID: 2},
+		}}
+	}
+	newAPIKeyRepository = func() db.APIKeyRepository {
+		return &fakeAPIKeyRepo{keys: map[string]*db.APIKey{
+			"mine":   {ID: 1, UserID: 1, Name: "script"},
+			"theirs": {ID: 2, UserID: 2},
+		}}
+	}
+	newAuditLogRepository = func() db.AuditLogRepository {
+		return &fakeAuditLogRepo{entries: []*db.AuditEntry{
+			{ID: 1, ActorHash: "someone else"},
+			{ID: 2, ActorHash: userHash},
+			{ID: 3, ActorHash: "someone else", Target: "userHash=" + userHash},
+			{ID: 4, ActorHash: "someone else", Target: "userHash=other"},
+		}}
+	}
+	newVersionRepository = func() db.VersionRepository {
+		return &fakeVersionRepo{versions: []*db.Version{{ID: 5, ImportedByID: 1}, {ID: 6, ImportedByID: 2}}}
+	}
+	activatedVersion := 5
+	newVersionActivationRepository = func() db.VersionActivationRepository {
+		return &fakeVersionActivationRepo{
+			activations: map[int][]*db.VersionActivation{
+				1: {{ID: 7, VersionID: &activatedVersion, Reason: "manual"}},
+				2: {{ID: 8, VersionID: &activatedVersion, Reason: "manual"}},
+			},
+			scheduled: []*db.ScheduledActivation{{ID: 9, VersionID: 5, CreatedByID: 1}, {ID: 10, VersionID: 6, CreatedByID: 2}},
+		}
+	}
+
+	if res := authRequest(t, app, http.MethodGet, "/api/me/export", "", ""); res.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("expected anonymous export to be refused, got %d", res.StatusCode)
+	}
+
+	accessToken, err := NewAuthService().CreateAccessToken("shared", email)
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+	res := authRequest(t, app, http.MethodGet, "/api/me/export", accessToken, "")
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	if disposition := res.Header.Get(fiber.HeaderContentDisposition); !strings.HasPrefix(disposition, "attachment") {
+		t.Fatalf("expected a download, got Content-Disposition %q", disposition)
+	}
+
+	var export ExportMeResponse
+	if err := json.NewDecoder(res.Body).Decode(&export); err != nil {
+		t.Fatalf("failed to decode export: %v", err)
+	}
+	if export.User.Hash != userHash || export.User.Email != email || !export.User.EmailStored || !export.User.IsAdmin {
+		t.Fatalf("unexpected user %+v", export.User)
+	}
+	if len(export.Sessions) != 1 || export.Sessions[0].ID != 1 {
+		t.Fatalf("expected only the user's session, got %+v", export.Sessions)
+	}
+	if len(export.APIKeys) != 1 || export.APIKeys[0].ID != 1 {
+		t.Fatalf("expected only the user's api key, got %+v", export.APIKeys)
+	}
+	if len(export.ImportedVersions) != 1 || export.ImportedVersions[0].ID != 5 {
+		t.Fatalf("expected only the versions the user imported, got %+v", export.ImportedVersions)
+	}
+	if len(export.Activations) != 1 || export.Activations[0].ID != 7 {
+		t.Fatalf("expected only the user's activations, got %+v", export.Activations)
+	}
+	if len(export.ScheduledActivations) != 1 || export.ScheduledActivations[0].ID != 9 {
+		t.Fatalf("expected only the activations the user scheduled, got %+v", export.ScheduledActivations)
+	}
+	if len(export.AuditLog) != 2 || export.AuditLog[0].ID != 2 || export.AuditLog[1].ID != 3 {
+		t.Fatalf("expected the audit entries made by and aimed at the user, got %+v", export.AuditLog)
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"google.golang.org/api/idtoken"
+)
+
+type LoginPayload struct {
+	Credential string `form:"credential"`
+	CSRFToken  string `form:"g_csrf_token"`
+	// DevToken replaces the Google fields when dev login is enabled.
+	DevToken string `form:"dev_token"`
+}
+
+// googleProvider verifies ID tokens of Google Identity Services. The button
+// runs in the browser, so the provider has no start step.
+type googleProvider struct{}
+
+func (googleProvider) Start(c *fiber.Ctx) error {
+	return fiber.NewError(fiber.StatusNotFound, "google login starts in the browser")
+}
+
+func (googleProvider) Verify(c *fiber.Ctx) (string, error) {
+	c.Accepts("application/x-www-form-urlencoded")
+
+	payload := new(LoginPayload)
+
+	if err := c.BodyParser(payload); err != nil {
+		return "", err
+	}
+
+	csrf_cookie := c.Cookies("g_csrf_token")
+	token, err := validateLogin(payload, csrf_cookie)
+
+	if err != nil {
+		return "", err
+	}
+
+	email, ok := token.Claims["email"].(string)
+	if !ok {
+		return "", fmt.Errorf("email not found")
+	}
+	return email, nil
+}
+
+func validateLogin(payload *LoginPayload, csrfCookie string) (*idtoken.Payload, error) {
+	if len(payload.CSRFToken) <= 0 {
+		return nil, fmt.Errorf("no CSRF token in request body")
+	}
+
+	if len(csrfCookie) <= 0 {
+		return nil, fmt.Errorf("no CSRF token in Cookie")
+	}
+
+	if payload.CSRFToken != csrfCookie {
+		return nil, fmt.Errorf("failed to verify double submit cookie")
+	}
+
+	return idtoken.Validate(
+		context.Background(),
+		payload.Credential,
+		config.GoogleOauth2ClientID,
+	)
+}
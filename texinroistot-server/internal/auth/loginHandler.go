@@ -1,50 +1,41 @@
 package auth
 
 import (
-	"context"
-	"encoding/hex"
-	"fmt"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/kokkoniemi/texinroistot/internal/config"
-	"github.com/kokkoniemi/texinroistot/internal/crypt"
-	"google.golang.org/api/idtoken"
 )
 
-type LoginPayload struct {
-	Credential string `form:"credential"`
-	CSRFToken  string `form:"g_csrf_token"`
+type LogoutResponse struct {
+	LoggedOut bool `json:"loggedOut"`
 }
 
+// LoginHandler signs in with a Google ID token posted by Google Identity
+// Services, or with a development token in dev_token when dev login is
+// enabled. Other providers use StartLoginHandler and LoginCallbackHandler.
 func LoginHandler(c *fiber.Ctx) error {
-	c.Accepts("application/x-www-form-urlencoded")
-
-	payload := new(LoginPayload)
-
-	if err := c.BodyParser(payload); err != nil {
-		return err
+	name := LoginProviderGoogle
+	if c.FormValue("dev_token") != "" && devLoginEnabled() {
+		name = LoginProviderDev
 	}
 
-	csrf_cookie := c.Cookies("g_csrf_token")
-	token, err := validateLogin(payload, csrf_cookie)
-
-	if err != nil {
-		return err
-	}
-
-	err = setAuthenticationCookies(token, c)
-	if err != nil {
-		return err
+	provider, ok := enabledLoginProvider(name)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "unknown login provider")
 	}
-
-	return c.Redirect("/hallinta")
+	return completeLogin(c, name, provider)
 }
 
+// LogoutHandler revokes the current session and clears its cookies.
 func LogoutHandler(c *fiber.Ctx) error {
+	err := endSession(c)
 	trashCookie(c, "a")
 	trashCookie(c, "r")
-	return c.JSON(fiber.Map{"loggedOut": true})
+	if err != nil {
+		return err
+	}
+	return c.JSON(LogoutResponse{LoggedOut: true})
 }
 
 func trashCookie(c *fiber.Ctx, cookieName string) {
@@ -61,45 +52,13 @@ func trashCookie(c *fiber.Ctx, cookieName string) {
 	})
 }
 
-func validateLogin(payload *LoginPayload, csrfCookie string) (*idtoken.Payload, error) {
-	if len(payload.CSRFToken) <= 0 {
-		return nil, fmt.Errorf("no CSRF token in request body")
-	}
-
-	if len(csrfCookie) <= 0 {
-		return nil, fmt.Errorf("no CSRF token in Cookie")
-	}
-
-	if payload.CSRFToken != csrfCookie {
-		return nil, fmt.Errorf("failed to verify double submit cookie")
-	}
-
-	return idtoken.Validate(
-		context.Background(),
-		payload.Credential,
-		config.GoogleOauth2ClientID,
-	)
-}
-
-func setAuthenticationCookies(token *idtoken.Payload, c *fiber.Ctx) error {
-	keyBytes, err := crypt.RandomBytes(8)
-	if err != nil {
-		return err
-	}
-
-	sharedKey := hex.EncodeToString(keyBytes)
-
+// setAuthenticationCookies issues a new access and refresh token pair for
+// the email. Both tokens carry the shared key of the session, which the
+// refresh flow uses to check that they belong together.
+func setAuthenticationCookies(c *fiber.Ctx, email string, sharedKey string) error {
 	// Authentication cookie
 	cookieName := authCookieName("a")
 
-	email, ok := token.Claims["email"].(string)
-	if !ok {
-		return fmt.Errorf("email not found")
-	}
-	if err := ensureUserProfile(email); err != nil {
-		return err
-	}
-
 	authService := NewAuthService()
 
 	accessToken, err := authService.CreateAccessToken(sharedKey, email)
@@ -108,8 +67,8 @@ func setAuthenticationCookies(token *idtoken.Payload, c *fiber.Ctx) error {
 	}
 
 	// Both access and refresh cookie need the same max age, although token
-	// max age differs
-	maxAge := int(time.Hour * 24 * 7)
+	// max age differs: an expired access token is still needed to refresh
+	maxAge := int(config.RefreshExpiresAfter.Seconds())
 
 	c.Cookie(&fiber.Cookie{
 		Name:     cookieName,
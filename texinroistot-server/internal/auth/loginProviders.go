@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+const (
+	LoginProviderGoogle    = "google"
+	LoginProviderOIDC      = "oidc"
+	LoginProviderMagicLink = "magic-link"
+)
+
+// LoginProvider proves the email of the user signing in. Every provider
+// ends up in the same user hash, so a user may switch providers.
+type LoginProvider interface {
+	// Start begins a login, e.g. by redirecting to the identity provider.
+	Start(c *fiber.Ctx) error
+	// Verify checks the request that completes a login and returns the
+	// verified email.
+	Verify(c *fiber.Ctx) (string, error)
+}
+
+var loginProviders = map[string]LoginProvider{
+	LoginProviderGoogle:    googleProvider{},
+	LoginProviderOIDC:      &oidcProvider{},
+	LoginProviderMagicLink: magicLinkProvider{},
+	LoginProviderDev:       devLoginProvider{},
+}
+
+type LoginProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// enabledLoginProviders returns the known providers of
+// ROISTOT_LOGIN_PROVIDERS in the configured order. The dev provider is only
+// enabled by ROISTOT_DEV_LOGIN and comes last.
+func enabledLoginProviders() []string {
+	enabled := []string{}
+	for _, name := range strings.Split(config.LoginProviders, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := loginProviders[name]; ok && name != LoginProviderDev {
+			enabled = append(enabled, name)
+		}
+	}
+	if devLoginEnabled() {
+		enabled = append(enabled, LoginProviderDev)
+	}
+	return enabled
+}
+
+func enabledLoginProvider(name string) (LoginProvider, bool) {
+	for _, enabled := range enabledLoginProviders() {
+		if enabled == name {
+			return loginProviders[name], true
+		}
+	}
+	return nil, false
+}
+
+func LoginProvidersHandler(c *fiber.Ctx) error {
+	return c.JSON(LoginProvidersResponse{Providers: enabledLoginProviders()})
+}
+
+// StartLoginHandler runs the first step of a provider, which for OpenID
+// Connect redirects to the identity provider and for magic links sends the
+// email.
+func StartLoginHandler(c *fiber.Ctx) error {
+	provider, ok := enabledLoginProvider(c.Params("provider"))
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "unknown login provider")
+	}
+	return provider.Start(c)
+}
+
+// LoginCallbackHandler completes a login started with StartLoginHandler.
+func LoginCallbackHandler(c *fiber.Ctx) error {
+	name := c.Params("provider")
+	provider, ok := enabledLoginProvider(name)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "unknown login provider")
+	}
+	return completeLogin(c, name, provider)
+}
+
+// completeLogin verifies the login, starts a session and redirects to the
+// admin UI.
+func completeLogin(c *fiber.Ctx, name string, provider LoginProvider) error {
+	email, err := provider.Verify(c)
+	if err != nil {
+		log.Printf("login with %s failed: %v", name, err)
+		return fiber.NewError(fiber.StatusUnauthorized, "login failed")
+	}
+
+	user, err := ensureUserProfile(email)
+	if err != nil {
+		return err
+	}
+
+	if err := startSession(c, user, email); err != nil {
+		return err
+	}
+
+	return c.Redirect("/hallinta")
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func (r *fakeUserRepo) Create(user db.User) (*db.User, error) {
+	if existing, ok := r.users[user.Hash]; ok {
+		return existing, nil
+	}
+	user.ID = len(r.users) + 1
+	r.users[user.Hash] = &user
+	return &user, nil
+}
+
+func (r *fakeSessionRepo) Create(session db.Session) (*db.Session, error) {
+	session.ID = len(r.sessions) + 1
+	r.sessions[session.KeyHash] = &session
+	return &session, nil
+}
+
+// setupLoginTest enables every provider and routes login requests like the
+// server does.
+func setupLoginTest(t *testing.T) (*fiber.App, *fakeUserRepo, *fakeSessionRepo) {
+	t.Helper()
+
+	app := setupRefreshTest(t)
+	app.Get("/api/login/:provider/callback", LoginCallbackHandler)
+	app.Get("/api/login/:provider", StartLoginHandler)
+	app.Post("/api/login/:provider", StartLoginHandler)
+
+	providers := config.LoginProviders
+	config.LoginProviders = "oidc,magic-link"
+	t.Cleanup(func() { config.LoginProviders = providers })
+
+	userRepo := &fakeUserRepo{users: map[string]*db.User{}}
+	sessionRepo := &fakeSessionRepo{sessions: map[string]*db.Session{}}
+	newUserRepository = func() db.UserRepository { return userRepo }
+	newSessionRepository = func() db.SessionRepository { return sessionRepo }
+	return app, userRepo, sessionRepo
+}
+
+func loginRequest(t *testing.T, app *fiber.App, req *http.Request, cookies ...*http.Cookie) *http.Response {
+	t.Helper()
+
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return res
+}
+
+func responseCookie(res *http.Response, name string) *http.Cookie {
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestEnabledLoginProviders(t *testing.T) {
+	providers := config.LoginProviders
+	t.Cleanup(func() { config.LoginProviders = providers })
+
+	config.LoginProviders = " Magic-Link, unknown,google "
+	if got := enabledLoginProviders(); !reflect.DeepEqual(got, []string{LoginProviderMagicLink, LoginProviderGoogle}) {
+		t.Fatalf("unexpected providers %v", got)
+	}
+	if _, ok := enabledLoginProvider(LoginProviderOIDC); ok {
+		t.Fatalf("expected oidc to be disabled")
+	}
+}
+
+type testIdentityProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	audience string
+	nonce    string
+}
+
+func newTestIdentityProvider(t *testing.T) *testIdentityProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	idp := &testIdentityProvider{key: key, audience: "client"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer":"` + idp.server.URL + `","authorization_endpoint":"` + idp.server.URL +
+			`/authorize","token_endpoint":"` + idp.server.URL + `/token","jwks_uri":"` + idp.server.URL + `/jwks"}`))
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		w.Write([]byte(`{"keys":[{"kty":"RSA","kid":"k1","n":"` + n + `","e":"` + e + `"}]}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, oidcClaims{
+			Nonce: idp.nonce,
+			Email: "Editor@Example.com",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    idp.server.URL,
+				Audience:  jwt.ClaimStrings{idp.audience},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		})
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"id_token":"` + signed + `"}`))
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	issuer, clientID, clientSecret, redirectURL := config.OIDCIssuer, config.OIDCClientID, config.OIDCClientSecret, config.OIDCRedirectURL
+	config.OIDCIssuer = idp.server.URL
+	config.OIDCClientID = "client"
+	config.OIDCClientSecret = "secret"
+	config.OIDCRedirectURL = "http://ui/api/login/oidc/callback"
+	loginProviders[LoginProviderOIDC] = &oidcProvider{}
+	t.Cleanup(func() {
+		config.OIDCIssuer, config.OIDCClientID, config.OIDCClientSecret, config.OIDCRedirectURL = issuer, clientID, clientSecret, redirectURL
+		loginProviders[LoginProviderOIDC] = &oidcProvider{}
+	})
+	return idp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		audience   string
+		state      func(state string) string
+		wantStatus int
+	}{
+		{name: "valid login", audience: "client", state: func(state string) string { return state }, wantStatus: fiber.StatusFound},
+		{name: "forged state", audience: "client", state: func(string) string { return "forged" }, wantStatus: fiber.StatusUnauthorized},
+		{name: "token for another client", audience: "other", state: func(state string) string { return state }, wantStatus: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, userRepo, sessionRepo := setupLoginTest(t)
+			idp := newTestIdentityProvider(t)
+			idp.audience = tt.audience
+
+			res := loginRequest(t, app, httptest.NewRequest(http.MethodGet, "/api/login/oidc", nil))
+			if res.StatusCode != fiber.StatusFound {
+				t.Fatalf("expected redirect to the identity provider, got %d", res.StatusCode)
+			}
+			location, err := url.Parse(res.Header.Get("Location"))
+			if err != nil || !strings.HasPrefix(location.String(), idp.server.URL+"/authorize?") {
+				t.Fatalf("unexpected redirect %q", res.Header.Get("Location"))
+			}
+			if location.Query().Get("client_id") != "client" || location.Query().Get("redirect_uri") != config.OIDCRedirectURL {
+				t.Fatalf("unexpected authorization request %q", location.RawQuery)
+			}
+			idp.nonce = location.Query().Get("nonce")
+			stateCookie := responseCookie(res, "oidc")
+			if stateCookie == nil {
+				t.Fatalf("expected a state cookie")
+			}
+
+			callback := "/api/login/oidc/callback?code=code&state=" + url.QueryEscape(tt.state(location.Query().Get("state")))
+			res = loginRequest(t, app, httptest.NewRequest(http.MethodGet, callback, nil), stateCookie)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusFound {
+				if len(sessionRepo.sessions) != 0 {
+					t.Fatalf("expected no session for a failed login")
+				}
+				return
+			}
+
+			if res.Header.Get("Location") != "/hallinta" {
+				t.Fatalf("expected redirect to /hallinta, got %q", res.Header.Get("Location"))
+			}
+			if _, ok := userRepo.users[userHashForEmail("editor@example.com")]; !ok {
+				t.Fatalf("expected the user to be created with the normalized email hash")
+			}
+			if len(sessionRepo.sessions) != 1 || responseCookie(res, "a") == nil {
+				t.Fatalf("expected a session and its cookies")
+			}
+		})
+	}
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	app, _, sessionRepo := setupLoginTest(t)
+
+	secret, publicURL, smtpAddr, smtpFrom := config.MagicLinkSecret, config.PublicURL, config.SMTPAddr, config.SMTPFrom
+	config.MagicLinkSecret = "magic-secret"
+	config.PublicURL = "http://ui/"
+	config.SMTPAddr = "localhost:25"
+	config.SMTPFrom = "noreply@example.com"
+	var sentTo, sentBody string
+	sendMail = func(to string, subject string, body string) error {
+		sentTo, sentBody = to, body
+		return nil
+	}
+	t.Cleanup(func() {
+		config.MagicLinkSecret, config.PublicURL, config.SMTPAddr, config.SMTPFrom = secret, publicURL, smtpAddr, smtpFrom
+		sendMail = smtpSendMail
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/login/magic-link", strings.NewReader(`{"email":"not an email"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if res := loginRequest(t, app, req); res.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid email, got %d", res.StatusCode)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/login/magic-link", strings.NewReader(`{"email":"Editor@Example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res := loginRequest(t, app, req)
+	if res.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("expected 202, got %d", res.StatusCode)
+	}
+	if sentTo != "editor@example.com" {
+		t.Fatalf("expected the link to be sent to the normalized email, got %q", sentTo)
+	}
+	nonceCookie := responseCookie(res, "ml")
+	if nonceCookie == nil {
+		t.Fatalf("expected a nonce cookie")
+	}
+	link := regexp.MustCompile(`http://ui/api/login/magic-link/callback\?token=\S+`).FindString(sentBody)
+	if link == "" {
+		t.Fatalf("expected a login link in %q", sentBody)
+	}
+	callback := strings.TrimPrefix(link, "http://ui")
+
+	if res := loginRequest(t, app, httptest.NewRequest(http.MethodGet, callback, nil)); res.StatusCode != fiber.StatusUnauthorized {
+		t.Fatalf("expected the link to fail in another browser, got %d", res.StatusCode)
+	}
+
+	res = loginRequest(t, app, httptest.NewRequest(http.MethodGet, callback, nil), nonceCookie)
+	if res.StatusCode != fiber.StatusFound || res.Header.Get("Location") != "/hallinta" {
+		t.Fatalf("expected redirect to /hallinta, got %d %q", res.StatusCode, res.Header.Get("Location"))
+	}
+	if len(sessionRepo.sessions) != 1 {
+		t.Fatalf("expected a session to be started")
+	}
+}
+
+func TestDisabledLoginProvider(t *testing.T) {
+	app, _, _ := setupLoginTest(t)
+	config.LoginProviders = "magic-link"
+
+	if res := loginRequest(t, app, httptest.NewRequest(http.MethodGet, "/api/login/oidc", nil)); res.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("expected 404 for a disabled provider, got %d", res.StatusCode)
+	}
+}
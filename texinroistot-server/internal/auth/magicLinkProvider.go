@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+type MagicLinkPayload struct {
+	Email string `json:"email" form:"email"`
+}
+
+type MagicLinkResponse struct {
+	Sent bool `json:"sent"`
+}
+
+// sendMail is swapped in tests.
+var sendMail = smtpSendMail
+
+// magicLinkProvider emails a login link. The link only works in the browser
+// that asked for it, so a leaked email alone does not sign anyone in.
+type magicLinkProvider struct{}
+
+func (magicLinkProvider) Start(c *fiber.Ctx) error {
+	if err := checkMagicLinkConfig(); err != nil {
+		return err
+	}
+
+	payload := new(MagicLinkPayload)
+	if err := c.BodyParser(payload); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "email is required")
+	}
+	address, err := mail.ParseAddress(strings.TrimSpace(payload.Email))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid email")
+	}
+	email := normalizeEmail(address.Address)
+
+	nonce, err := randomToken()
+	if err != nil {
+		return err
+	}
+	token, err := NewAuthService().CreateMagicLinkToken(nonce, email)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(config.PublicURL, "/") + "/api/login/magic-link/callback?token=" + url.QueryEscape(token)
+	if err := sendMail(email, "Kirjautumislinkki", magicLinkMessage(link)); err != nil {
+		return fmt.Errorf("failed to send magic link: %w", err)
+	}
+
+	setLoginCookie(c, "ml", nonce, config.MagicLinkExpiresAfter)
+	return c.Status(fiber.StatusAccepted).JSON(MagicLinkResponse{Sent: true})
+}
+
+func (magicLinkProvider) Verify(c *fiber.Ctx) (string, error) {
+	if err := checkMagicLinkConfig(); err != nil {
+		return "", err
+	}
+
+	nonce := authCookieValue(c, "ml")
+	trashCookie(c, "ml")
+
+	claims, err := NewAuthService().VerifyMagicLinkToken(c.Query("token"))
+	if err != nil {
+		return "", err
+	}
+	linkNonce, err := sharedKeyFromClaims(claims)
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) == 0 || subtle.ConstantTimeCompare([]byte(nonce), []byte(linkNonce)) != 1 {
+		return "", errors.New("link was requested in another browser")
+	}
+	return emailFromClaims(claims)
+}
+
+func checkMagicLinkConfig() error {
+	if config.MagicLinkSecret == "" || config.PublicURL == "" || config.SMTPAddr == "" || config.SMTPFrom == "" {
+		return fiber.NewError(fiber.StatusInternalServerError, "magic link login is not configured")
+	}
+	return nil
+}
+
+func magicLinkMessage(link string) string {
+	return fmt.Sprintf(
+		"Kirjaudu Texinroistojen hallintaan avaamalla linkki:\r\n\r\n%s\r\n\r\n"+
+			"Linkki on voimassa %d minuuttia ja toimii vain selaimessa, jolla se tilattiin.\r\n"+
+			"Jos et pyytänyt linkkiä, voit jättää tämän viestin huomiotta.\r\n",
+		link,
+		int(config.MagicLinkExpiresAfter.Minutes()),
+	)
+}
+
+func smtpSendMail(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if config.SMTPUsername != "" {
+		host, _, _ := strings.Cut(config.SMTPAddr, ":")
+		auth = smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, host)
+	}
+
+	message := "From: " + config.SMTPFrom + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body
+
+	from := config.SMTPFrom
+	if address, err := mail.ParseAddress(config.SMTPFrom); err == nil {
+		from = address.Address
+	}
+	return smtp.SendMail(config.SMTPAddr, auth, from, []string{to}, []byte(message))
+}
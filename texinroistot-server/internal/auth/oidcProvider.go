@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
+)
+
+// oidcStateMaxAge limits how long the user may stay at the identity
+// provider before the callback is rejected.
+const oidcStateMaxAge = 10 * time.Minute
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+type oidcClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	jwt.RegisteredClaims
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// oidcProvider signs in with the authorization code flow of a generic
+// OpenID Connect provider. The discovery document and signing keys are
+// cached; unknown key IDs reload the keys, so key rotation needs no restart.
+type oidcProvider struct {
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+}
+
+func (p *oidcProvider) Start(c *fiber.Ctx) error {
+	if err := checkOIDCConfig(); err != nil {
+		return err
+	}
+	discovery, err := p.loadDiscovery()
+	if err != nil {
+		return err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return err
+	}
+	setLoginCookie(c, "oidc", state+"."+nonce, oidcStateMaxAge)
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", config.OIDCClientID)
+	query.Set("redirect_uri", config.OIDCRedirectURL)
+	query.Set("scope", config.OIDCScopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return c.Redirect(discovery.AuthorizationEndpoint + separator + query.Encode())
+}
+
+func (p *oidcProvider) Verify(c *fiber.Ctx) (string, error) {
+	if err := checkOIDCConfig(); err != nil {
+		return "", err
+	}
+
+	stored := authCookieValue(c, "oidc")
+	trashCookie(c, "oidc")
+	state, nonce, found := strings.Cut(stored, ".")
+	if !found || len(state) == 0 || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		return "", errors.New("state does not match")
+	}
+	if providerError := c.Query("error"); providerError != "" {
+		return "", fmt.Errorf("identity provider returned %s", providerError)
+	}
+	code := c.Query("code")
+	if code == "" {
+		return "", errors.New("authorization code missing")
+	}
+
+	discovery, err := p.loadDiscovery()
+	if err != nil {
+		return "", err
+	}
+	idToken, err := exchangeOIDCCode(discovery, code)
+	if err != nil {
+		return "", err
+	}
+	claims, err := p.verifyIDToken(discovery, idToken)
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return "", errors.New("nonce does not match")
+	}
+	if claims.Email == "" {
+		return "", errors.New("email not found")
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return "", errors.New("email is not verified")
+	}
+	return claims.Email, nil
+}
+
+func checkOIDCConfig() error {
+	if config.OIDCIssuer == "" || config.OIDCClientID == "" || config.OIDCRedirectURL == "" {
+		return fiber.NewError(fiber.StatusInternalServerError, "OpenID Connect login is not configured")
+	}
+	return nil
+}
+
+// loadDiscovery fetches the discovery document of the configured issuer
+// once.
+func (p *oidcProvider) loadDiscovery() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	issuer := strings.TrimRight(config.OIDCIssuer, "/")
+	if p.discovery != nil && strings.TrimRight(p.discovery.Issuer, "/") == issuer {
+		return p.discovery, nil
+	}
+
+	discovery := &oidcDiscovery{}
+	if err := getJSON(issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, fmt.Errorf("failed to load OpenID Connect discovery document: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q", discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+
+	p.discovery = discovery
+	p.keys = nil
+	return discovery, nil
+}
+
+func exchangeOIDCCode(discovery *oidcDiscovery, code string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", config.OIDCRedirectURL)
+	form.Set("client_id", config.OIDCClientID)
+	form.Set("client_secret", config.OIDCClientSecret)
+
+	res, err := oidcHTTPClient.PostForm(discovery.TokenEndpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d", res.StatusCode)
+	}
+	token := &oidcTokenResponse{}
+	if err := json.NewDecoder(res.Body).Decode(token); err != nil {
+		return "", err
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return token.IDToken, nil
+}
+
+func (p *oidcProvider) verifyIDToken(discovery *oidcDiscovery, idToken string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf(unexpectedSigningMethodError, token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(discovery, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, errors.New("id token has an unexpected issuer")
+	}
+	if !claims.VerifyAudience(config.OIDCClientID, true) {
+		return nil, errors.New("id token has an unexpected audience")
+	}
+	return claims, nil
+}
+
+// signingKey returns the key with the ID, reloading the keys once when it
+// is not known. Tokens without a key ID are accepted only when the provider
+// has a single key.
+func (p *oidcProvider) signingKey(discovery *oidcDiscovery, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := pickSigningKey(p.keys, kid); key != nil {
+		return key, nil
+	}
+
+	keys, err := fetchJWKS(discovery.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+
+	if key := pickSigningKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+func pickSigningKey(keys map[string]interface{}, kid string) interface{} {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+func fetchJWKS(jwksURI string) (map[string]interface{}, error) {
+	set := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := getJSON(jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Keys of unsupported types are skipped, tokens signed with
+			// them fail as unknown keys.
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func getJSON(url string, target interface{}) error {
+	res, err := oidcHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+func randomToken() (string, error) {
+	bytes, err := crypt.RandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -2,8 +2,17 @@ package auth
 
 import "github.com/gofiber/fiber/v2"
 
+// ProtectedRoute lets through signed-in users with any role. Requests with
+// an Authorization: Bearer header are authenticated by API key instead of
+// cookies. Routes that change data add RequireRole on top of it.
 func ProtectedRoute(c *fiber.Ctx) error {
-	user, err := getUserInfo(c)
+	var user *UserInfo
+	var err error
+	if key := bearerToken(c); key != "" {
+		user, err = apiKeyUserInfo(key)
+	} else {
+		user, err = getUserInfo(c)
+	}
 
 	if err != nil {
 		return err
@@ -19,3 +28,20 @@ func ProtectedRoute(c *fiber.Ctx) error {
 	c.Locals("user", user)
 	return c.Next()
 }
+
+// RequireRole lets through users that hold at least one of the roles. It
+// must run after ProtectedRoute.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := CurrentUser(c)
+		if user == nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+		}
+		for _, role := range roles {
+			if user.HasRole(role) {
+				return c.Next()
+			}
+		}
+		return fiber.NewError(fiber.StatusForbidden, "forbidden")
+	}
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       *UserInfo
+		wantStatus int
+	}{
+		{
+			name:       "no user",
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "viewer",
+			user:       &UserInfo{LoggedIn: true, IsAdmin: true, Roles: []string{db.RoleViewer}},
+			wantStatus: fiber.StatusForbidden,
+		},
+		{
+			name:       "publisher",
+			user:       &UserInfo{LoggedIn: true, IsAdmin: true, Roles: []string{db.RoleViewer, db.RolePublisher}},
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "editor",
+			user:       &UserInfo{LoggedIn: true, IsAdmin: true, Roles: []string{db.RoleEditor}},
+			wantStatus: fiber.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get(
+				"/",
+				func(c *fiber.Ctx) error {
+					if tt.user != nil {
+						c.Locals("user", tt.user)
+					}
+					return c.Next()
+				},
+				RequireRole(db.RoleEditor, db.RolePublisher),
+				func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) },
+			)
+
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+		})
+	}
+}
+
+type fakeAPIKeyRepo struct {
+	db.APIKeyRepository
+	keys map[string]*db.APIKey
+}
+
+func (r *fakeAPIKeyRepo) Authenticate(keyHash string) (*db.APIKey, error) {
+	return r.keys[keyHash], nil
+}
+
+func TestProtectedRouteAPIKey(t *testing.T) {
+	keyRepo := &fakeAPIKeyRepo{keys: map[string]*db.APIKey{
+		APIKeyHash("trk_publisher"): {
+			ID:         1,
+			UserID:     7,
+			UserHash:   "abc",
+			Scopes:     []string{db.RoleViewer, db.RolePublisher},
+			OwnerRoles: []string{db.RoleViewer, db.RolePublisher},
+		},
+		APIKeyHash("trk_demoted"): {
+			ID:         2,
+			UserID:     7,
+			UserHash:   "abc",
+			Scopes:     []string{db.RolePublisher},
+			OwnerRoles: []string{db.RoleViewer},
+		},
+	}}
+	newAPIKeyRepository = func() db.APIKeyRepository { return keyRepo }
+	t.Cleanup(func() { newAPIKeyRepository = db.NewAPIKeyRepository })
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{
+			name:          "publisher key",
+			authorization: "Bearer trk_publisher",
+			wantStatus:    fiber.StatusOK,
+		},
+		{
+			name:          "scope the owner has lost",
+			authorization: "Bearer trk_demoted",
+			wantStatus:    fiber.StatusForbidden,
+		},
+		{
+			name:          "unknown key",
+			authorization: "Bearer trk_unknown",
+			wantStatus:    fiber.StatusUnauthorized,
+		},
+		{
+			name:          "no key or cookies",
+			authorization: "",
+			wantStatus:    fiber.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get(
+				"/",
+				ProtectedRoute,
+				RequireRole(db.RolePublisher),
+				func(c *fiber.Ctx) error {
+					if user := CurrentUser(c); user.UserID != 7 || user.APIKeyID != 1 {
+						t.Errorf("unexpected user %+v", user)
+					}
+					return c.SendStatus(fiber.StatusOK)
+				},
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, tt.authorization)
+			}
+			res, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var errInvalidSession = errors.New("invalid session")
+
+var newUserRepository = db.NewUserRepository
+
+type RefreshResponse struct {
+	Refreshed bool `json:"refreshed"`
+	// ExpiresIn is the lifetime of the new access token in seconds.
+	ExpiresIn int `json:"expiresIn"`
+}
+
+func RefreshHandler(c *fiber.Ctx) error {
+	if _, err := refreshSession(c); err != nil {
+		if !errors.Is(err, errInvalidSession) {
+			return err
+		}
+		trashCookie(c, "a")
+		trashCookie(c, "r")
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	return c.JSON(RefreshResponse{
+		Refreshed: true,
+		ExpiresIn: int(config.LoginExpiresAfter.Seconds()),
+	})
+}
+
+// refreshSession replaces the access and refresh cookies with new tokens
+// of the same session and extends the session. The refresh token must be
+// valid and carry the same shared key as the access token, which may have
+// expired. Revoked sessions and deleted users are not refreshed.
+//
+// Every refresh gives the session a new shared key, so a stolen pair of
+// tokens stops working once either holder refreshes. Requests sent in
+// parallel with the same tokens lose the race for the new key; the tokens
+// stay valid for config.SessionKeyGracePeriod, so those requests succeed
+// without touching the cookies the winning refresh set.
+func refreshSession(c *fiber.Ctx) (*authSession, error) {
+	accessToken := authCookieValue(c, "a")
+	refreshToken := authCookieValue(c, "r")
+	if len(accessToken) == 0 || len(refreshToken) == 0 {
+		return nil, errInvalidSession
+	}
+
+	authService := NewAuthService()
+	refreshClaims, err := authService.VerifyRefreshToken(refreshToken)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+	accessClaims, err := authService.VerifyAccessToken(accessToken, IgnoreExpiry())
+	if err != nil {
+		return nil, errInvalidSession
+	}
+
+	accessKey, err := sharedKeyFromClaims(accessClaims)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+	refreshKey, err := sharedKeyFromClaims(refreshClaims)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+	if len(accessKey) == 0 || subtle.ConstantTimeCompare([]byte(accessKey), []byte(refreshKey)) != 1 {
+		return nil, errInvalidSession
+	}
+
+	session, err := loadSession(accessClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.keyReplaced() {
+		// A concurrent refresh has already rotated the key.
+		return session, nil
+	}
+	keyHash := sessionKeyHash(session.SharedKey)
+
+	sharedKey, err := newSessionKey()
+	if err != nil {
+		return nil, err
+	}
+	newKeyHash := sessionKeyHash(sharedKey)
+	expiresAt := time.Now().Add(config.RefreshExpiresAfter)
+	sessionRepo := newSessionRepository()
+	extended, err := sessionRepo.Extend(session.Session.ID, keyHash, newKeyHash, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !extended {
+		// Either the session was revoked or a concurrent refresh won the
+		// race between reading the session and extending it.
+		rotated, err := sessionRepo.ReadByKey(keyHash)
+		if err != nil {
+			return nil, err
+		}
+		if rotated == nil || rotated.ID != session.Session.ID {
+			return nil, errInvalidSession
+		}
+		return session, nil
+	}
+	session.SharedKey = sharedKey
+	session.Session.KeyHash = newKeyHash
+	session.Session.ExpiresAt = expiresAt
+
+	if err := setAuthenticationCookies(c, session.Email, session.SharedKey); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
@@ -0,0 +1,477 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type fakeUserRepo struct {
+	db.UserRepository
+	users map[string]*db.User
+}
+
+func (r *fakeUserRepo) ReadByHash(userHash string) (*db.User, error) {
+	return r.users[userHash], nil
+}
+
+type fakeSessionRepo struct {
+	db.SessionRepository
+	mu       sync.Mutex
+	sessions map[string]*db.Session
+	// previous maps the key each refresh replaced to the current key of
+	// the session. Replaced keys are accepted until graceOver is set.
+	previous  map[string]string
+	graceOver bool
+	extended  []int
+	// beforeExtend, if set, is called by every ReadByKey before any
+	// session has been extended.
+	beforeExtend func()
+}
+
+// find returns the current key and the session that keyHash belongs to.
+func (r *fakeSessionRepo) find(keyHash string) (string, *db.Session) {
+	if session, ok := r.sessions[keyHash]; ok {
+		return keyHash, session
+	}
+	if current, ok := r.previous[keyHash]; ok && !r.graceOver {
+		return current, r.sessions[current]
+	}
+	return "", nil
+}
+
+func (r *fakeSessionRepo) ReadByKey(keyHash string) (*db.Session, error) {
+	r.mu.Lock()
+	waitForOthers := r.beforeExtend != nil && len(r.extended) == 0
+	r.mu.Unlock()
+	if waitForOthers {
+		r.beforeExtend()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, session := r.find(keyHash)
+	if session == nil {
+		return nil, nil
+	}
+	found := *session
+	found.KeyHash = current
+	return &found, nil
+}
+
+func (r *fakeSessionRepo) Extend(sessionID int, keyHash string, newKeyHash string, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[keyHash]
+	if !ok || session.ID != sessionID {
+		return false, nil
+	}
+	delete(r.sessions, keyHash)
+	r.sessions[newKeyHash] = session
+	for replaced, current := range r.previous {
+		if current == keyHash {
+			delete(r.previous, replaced)
+		}
+	}
+	if r.previous == nil {
+		r.previous = map[string]string{}
+	}
+	r.previous[keyHash] = newKeyHash
+	r.extended = append(r.extended, sessionID)
+	return true, nil
+}
+
+func (r *fakeSessionRepo) Remove(keyHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if current, session := r.find(keyHash); session != nil {
+		delete(r.sessions, current)
+	}
+	return nil
+}
+
+// setupRefreshTest configures secrets, so user hashes must be computed after
+// it has run.
+func setupRefreshTest(t *testing.T) *fiber.App {
+	t.Helper()
+
+	secret, salt, encryptionKeys := config.Secret, config.Salt, config.EncryptionKeys
+	accessSecret, refreshSecret := config.CookieAccessSecret, config.CookieRefreshSecret
+	loginExpiresAfter, cookieSecure := config.LoginExpiresAfter, config.CookieSecure
+	config.Secret = "0123456789abcdef0123456789abcdef"
+	config.Salt = "salt"
+	config.EncryptionKeys = ""
+	config.CookieAccessSecret = "access-secret"
+	config.CookieRefreshSecret = "refresh-secret"
+	config.CookieSecure = false
+	t.Cleanup(func() {
+		config.Secret, config.Salt, config.EncryptionKeys = secret, salt, encryptionKeys
+		config.CookieAccessSecret, config.CookieRefreshSecret = accessSecret, refreshSecret
+		config.LoginExpiresAfter, config.CookieSecure = loginExpiresAfter, cookieSecure
+		newUserRepository = db.NewUserRepository
+		newSessionRepository = db.NewSessionRepository
+	})
+
+	app := fiber.New()
+	app.Post("/api/refresh", RefreshHandler)
+	app.Post("/api/logout", LogoutHandler)
+	app.Get("/api/me", UserInfoHandler)
+	return app
+}
+
+func expiredAccessToken(t *testing.T, sharedKey string, email string) string {
+	t.Helper()
+
+	config.LoginExpiresAfter = -time.Minute
+	defer func() { config.LoginExpiresAfter = time.Hour }()
+
+	token, err := NewAuthService().CreateAccessToken(sharedKey, email)
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+	return token
+}
+
+func refreshRequest(t *testing.T, app *fiber.App, accessToken string, refreshToken string) *http.Response {
+	t.Helper()
+	return authRequest(t, app, http.MethodPost, "/api/refresh", accessToken, refreshToken)
+}
+
+func authRequest(t *testing.T, app *fiber.App, method string, path string, accessToken string, refreshToken string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(&http.Cookie{Name: "a", Value: accessToken})
+	req.AddCookie(&http.Cookie{Name: "r", Value: refreshToken})
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return res
+}
+
+func TestVerifyAccessTokenIgnoreExpiry(t *testing.T) {
+	setupRefreshTest(t)
+	token := expiredAccessToken(t, "shared", "user@example.com")
+
+	authService := NewAuthService()
+	if _, err := authService.VerifyAccessToken(token); err == nil {
+		t.Fatalf("expected expired access token to be rejected")
+	}
+	if _, err := authService.VerifyAccessToken(token, IgnoreExpiry()); err != nil {
+		t.Fatalf("expected expired access token to be accepted with IgnoreExpiry: %v", err)
+	}
+	if _, err := authService.VerifyRefreshToken(token, IgnoreExpiry()); err == nil {
+		t.Fatalf("expected access token to be rejected as a refresh token")
+	}
+}
+
+func TestRefreshHandler(t *testing.T) {
+	const email = "user@example.com"
+	const sessionID = 7
+
+	existingUser := func() map[string]*db.User {
+		return map[string]*db.User{userHashForEmail(email): {ID: 1}}
+	}
+	activeSession := func() map[string]*db.Session {
+		return map[string]*db.Session{sessionKeyHash("shared"): {ID: sessionID, UserID: 1}}
+	}
+
+	tests := []struct {
+		name       string
+		refreshKey string
+		users      func() map[string]*db.User
+		sessions   func() map[string]*db.Session
+		wantStatus int
+	}{
+		{
+			name:       "matching tokens",
+			refreshKey: "shared",
+			users:      existingUser,
+			sessions:   activeSession,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "refresh token from another login",
+			refreshKey: "other",
+			users:      existingUser,
+			sessions:   activeSession,
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "revoked session",
+			refreshKey: "shared",
+			users:      existingUser,
+			sessions:   func() map[string]*db.Session { return map[string]*db.Session{} },
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "session of another user",
+			refreshKey: "shared",
+			users:      existingUser,
+			sessions: func() map[string]*db.Session {
+				return map[string]*db.Session{sessionKeyHash("shared"): {ID: sessionID, UserID: 2}}
+			},
+			wantStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:       "deleted user",
+			refreshKey: "shared",
+			users:      func() map[string]*db.User { return map[string]*db.User{} },
+			sessions:   activeSession,
+			wantStatus: fiber.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := setupRefreshTest(t)
+			newUserRepository = func() db.UserRepository { return &fakeUserRepo{users: tt.users()} }
+			sessionRepo := &fakeSessionRepo{sessions: tt.sessions()}
+			newSessionRepository = func() db.SessionRepository { return sessionRepo }
+
+			accessToken := expiredAccessToken(t, "shared", email)
+			refreshToken, err := NewAuthService().CreateRefreshToken(tt.refreshKey)
+			if err != nil {
+				t.Fatalf("failed to create refresh token: %v", err)
+			}
+
+			res := refreshRequest(t, app, accessToken, refreshToken)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, res.StatusCode)
+			}
+
+			cookies := map[string]string{}
+			for _, cookie := range res.Cookies() {
+				cookies[cookie.Name] = cookie.Value
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				if cookies["a"] != "" || cookies["r"] != "" {
+					t.Fatalf("expected cookies to be cleared, got %v", cookies)
+				}
+				if len(sessionRepo.extended) != 0 {
+					t.Fatalf("expected no session to be extended, got %v", sessionRepo.extended)
+				}
+				return
+			}
+
+			if cookies["a"] == "" || cookies["a"] == accessToken || cookies["r"] == "" || cookies["r"] == refreshToken {
+				t.Fatalf("expected both tokens to be rotated, got %v", cookies)
+			}
+			if len(sessionRepo.extended) != 1 || sessionRepo.extended[0] != sessionID {
+				t.Fatalf("expected session %d to be extended, got %v", sessionID, sessionRepo.extended)
+			}
+			claims, err := NewAuthService().VerifyAccessToken(cookies["a"])
+			if err != nil {
+				t.Fatalf("expected a valid access token: %v", err)
+			}
+			if got, err := emailFromClaims(claims); err != nil || got != email {
+				t.Fatalf("expected access token for %s, got %q (%v)", email, got, err)
+			}
+			key, err := sharedKeyFromClaims(claims)
+			if err != nil || key == "" || key == "shared" {
+				t.Fatalf("expected a new session key, got %q (%v)", key, err)
+			}
+			if _, ok := sessionRepo.sessions[sessionKeyHash(key)]; !ok {
+				t.Fatalf("expected the session to be stored under the new key")
+			}
+
+			// During the grace period the tokens of the old key are
+			// accepted, but neither rotate the key again nor replace the
+			// cookies of the new key.
+			res = refreshRequest(t, app, accessToken, refreshToken)
+			if res.StatusCode != fiber.StatusOK || len(res.Cookies()) != 0 {
+				t.Fatalf("expected tokens of the old key to be accepted without new cookies, got %d with %d cookies", res.StatusCode, len(res.Cookies()))
+			}
+			if len(sessionRepo.extended) != 1 {
+				t.Fatalf("expected the session to be extended once, got %v", sessionRepo.extended)
+			}
+
+			// After the grace period they no longer refresh the session.
+			sessionRepo.graceOver = true
+			if res := refreshRequest(t, app, accessToken, refreshToken); res.StatusCode != fiber.StatusUnauthorized {
+				t.Fatalf("expected tokens of the old key to be rejected, got %d", res.StatusCode)
+			}
+			if res := refreshRequest(t, app, cookies["a"], cookies["r"]); res.StatusCode != fiber.StatusOK {
+				t.Fatalf("expected tokens of the new key to refresh, got %d", res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestParallelRefreshKeepsWinningCookies(t *testing.T) {
+	const email = "user@example.com"
+	const requests = 5
+
+	app := setupRefreshTest(t)
+	newUserRepository = func() db.UserRepository {
+		return &fakeUserRepo{users: map[string]*db.User{userHashForEmail(email): {ID: 1}}}
+	}
+	// Every request reads the session before any of them extends it, so
+	// all but one lose the race in Extend.
+	var reads sync.WaitGroup
+	reads.Add(requests)
+	sessionRepo := &fakeSessionRepo{
+		sessions: map[string]*db.Session{sessionKeyHash("shared"): {ID: 1, UserID: 1}},
+		beforeExtend: func() {
+			reads.Done()
+			reads.Wait()
+		},
+	}
+	newSessionRepository = func() db.SessionRepository { return sessionRepo }
+
+	accessToken := expiredAccessToken(t, "shared", email)
+	refreshToken, err := NewAuthService().CreateRefreshToken("shared")
+	if err != nil {
+		t.Fatalf("failed to create refresh token: %v", err)
+	}
+
+	responses := make([]*http.Response, requests)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+			req.AddCookie(&http.Cookie{Name: "a", Value: accessToken})
+			req.AddCookie(&http.Cookie{Name: "r", Value: refreshToken})
+			res, err := app.Test(req)
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			responses[i] = res
+		}()
+	}
+	wg.Wait()
+
+	var withCookies int
+	for _, res := range responses {
+		if res == nil {
+			t.FailNow()
+		}
+		if res.StatusCode != fiber.StatusOK {
+			t.Fatalf("expected every refresh to succeed, got %d", res.StatusCode)
+		}
+		cookies := res.Cookies()
+		if len(cookies) == 0 {
+			continue
+		}
+		withCookies++
+		for _, cookie := range cookies {
+			if cookie.Value == "" {
+				t.Fatalf("expected no refresh to clear cookie %q", cookie.Name)
+			}
+		}
+	}
+	if withCookies != 1 {
+		t.Fatalf("expected exactly one refresh to set cookies, got %d", withCookies)
+	}
+	if len(sessionRepo.extended) != 1 {
+		t.Fatalf("expected the session to be extended once, got %v", sessionRepo.extended)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	const email = "user@example.com"
+
+	app := setupRefreshTest(t)
+	newUserRepository = func() db.UserRepository {
+		return &fakeUserRepo{users: map[string]*db.User{userHashForEmail(email): {ID: 1}}}
+	}
+	sessionRepo := &fakeSessionRepo{sessions: map[string]*db.Session{
+		sessionKeyHash("shared"): {ID: 1, UserID: 1},
+		sessionKeyHash("other"):  {ID: 2, UserID: 1},
+	}}
+	newSessionRepository = func() db.SessionRepository { return sessionRepo }
+
+	accessToken, err := NewAuthService().CreateAccessToken("shared", email)
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+
+	if res := authRequest(t, app, http.MethodPost, "/api/logout", accessToken, ""); res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected logout to succeed, got %d", res.StatusCode)
+	}
+	if _, ok := sessionRepo.sessions[sessionKeyHash("shared")]; ok {
+		t.Fatalf("expected the current session to be revoked")
+	}
+	if _, ok := sessionRepo.sessions[sessionKeyHash("other")]; !ok {
+		t.Fatalf("expected other sessions to be kept")
+	}
+
+	// The access token itself is still valid, but its session is gone.
+	info, err := getUserInfoForToken(t, app, accessToken)
+	if err != nil {
+		t.Fatalf("failed to read user info: %v", err)
+	}
+	if info.LoggedIn {
+		t.Fatalf("expected a revoked session to be logged out")
+	}
+}
+
+func getUserInfoForToken(t *testing.T, app *fiber.App, accessToken string) (*UserInfo, error) {
+	t.Helper()
+
+	res := authRequest(t, app, http.MethodGet, "/api/me", accessToken, "")
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	var info UserInfo
+	err := json.NewDecoder(res.Body).Decode(&info)
+	return &info, err
+}
+
+func TestUserInfoReissuesTokensAfterKeyRotation(t *testing.T) {
+	const email = "user@example.com"
+
+	app := setupRefreshTest(t)
+	newUserRepository = func() db.UserRepository {
+		return &fakeUserRepo{users: map[string]*db.User{userHashForEmail(email): {ID: 1}}}
+	}
+	newSessionRepository = func() db.SessionRepository {
+		return &fakeSessionRepo{sessions: map[string]*db.Session{sessionKeyHash("shared"): {ID: 1, UserID: 1}}}
+	}
+
+	config.EncryptionKeys = "old:0123456789abcdef0123456789abcdef"
+	oldToken, err := NewAuthService().CreateAccessToken("shared", email)
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+
+	res := authRequest(t, app, http.MethodGet, "/api/me", oldToken, "")
+	if res.StatusCode != fiber.StatusOK || len(res.Cookies()) != 0 {
+		t.Fatalf("expected a token of the current key to be kept, got %d with %d cookies", res.StatusCode, len(res.Cookies()))
+	}
+
+	config.EncryptionKeys = "new:fedcba9876543210fedcba9876543210,old:0123456789abcdef0123456789abcdef"
+	res = authRequest(t, app, http.MethodGet, "/api/me", oldToken, "")
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected 200, got %d", res.StatusCode)
+	}
+	cookies := map[string]string{}
+	for _, cookie := range res.Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+	if cookies["a"] == "" || cookies["r"] == "" {
+		t.Fatalf("expected both tokens to be re-issued, got %v", cookies)
+	}
+
+	claims, err := NewAuthService().VerifyAccessToken(cookies["a"])
+	if err != nil {
+		t.Fatalf("expected a valid access token: %v", err)
+	}
+	if claimsNeedRotation(claims) {
+		t.Fatalf("expected the re-issued token to use the new key")
+	}
+	if key, err := sharedKeyFromClaims(claims); err != nil || key != "shared" {
+		t.Fatalf("expected the session key to be kept, got %q (%v)", key, err)
+	}
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/crypt"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+var newSessionRepository = db.NewSessionRepository
+
+// maxUserAgentLength keeps a hostile User-Agent header from bloating the
+// sessions table.
+const maxUserAgentLength = 255
+
+// authSession is a stored session together with the user it belongs to.
+type authSession struct {
+	Email     string
+	SharedKey string
+	User      *db.User
+	Session   *db.Session
+}
+
+// keyReplaced reports whether the tokens carry a key that a refresh has
+// replaced and that is only accepted during the grace period. Cookies must
+// not be re-issued for such a key.
+func (s *authSession) keyReplaced() bool {
+	return s.Session.KeyHash != sessionKeyHash(s.SharedKey)
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// LogoutEverywhereHandler revokes every session of the signed-in user,
+// including the current one.
+func LogoutEverywhereHandler(c *fiber.Ctx) error {
+	user, err := getUserInfo(c)
+	if err != nil {
+		return err
+	}
+	if !user.LoggedIn {
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+
+	revoked, err := newSessionRepository().RemoveByUser(user.UserID)
+	if err != nil {
+		return err
+	}
+
+	trashCookie(c, "a")
+	trashCookie(c, "r")
+
+	return c.JSON(RevokeSessionsResponse{Revoked: revoked})
+}
+
+// StartSessionCleanup deletes expired sessions every interval until the
+// returned stop function is called.
+func StartSessionCleanup(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := newSessionRepository().RemoveExpired(); err != nil {
+					log.Printf("session cleanup: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// startSession stores a new session for the user and sets its cookies.
+func startSession(c *fiber.Ctx, user *db.User, email string) error {
+	sharedKey, err := newSessionKey()
+	if err != nil {
+		return err
+	}
+
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	if _, err := newSessionRepository().Create(db.Session{
+		UserID:    user.ID,
+		KeyHash:   sessionKeyHash(sharedKey),
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(config.RefreshExpiresAfter),
+	}); err != nil {
+		return err
+	}
+
+	return setAuthenticationCookies(c, email, sharedKey)
+}
+
+// loadSession returns the stored session of an access token. Tokens of
+// revoked or expired sessions, and of users deleted since, are rejected
+// with errInvalidSession.
+func loadSession(claims *JWTClaims) (*authSession, error) {
+	email, err := emailFromClaims(claims)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+	sharedKey, err := sharedKeyFromClaims(claims)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+
+	session, err := newSessionRepository().ReadByKey(sessionKeyHash(sharedKey))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, errInvalidSession
+	}
+
+	user, err := newUserRepository().ReadByHash(userHashForEmail(email))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.ID != session.UserID {
+		return nil, errInvalidSession
+	}
+
+	return &authSession{
+		Email:     email,
+		SharedKey: sharedKey,
+		User:      user,
+		Session:   session,
+	}, nil
+}
+
+// endSession revokes the session of the access token, which may have
+// expired. Cookies that do not name a session are ignored.
+func endSession(c *fiber.Ctx) error {
+	accessToken := authCookieValue(c, "a")
+	if len(accessToken) == 0 {
+		return nil
+	}
+	claims, err := NewAuthService().VerifyAccessToken(accessToken, IgnoreExpiry())
+	if err != nil {
+		return nil
+	}
+	sharedKey, err := sharedKeyFromClaims(claims)
+	if err != nil || len(sharedKey) == 0 {
+		return nil
+	}
+	return newSessionRepository().Remove(sessionKeyHash(sharedKey))
+}
+
+// newSessionKey returns a random key that ties the tokens of a session to
+// its sessions row.
+func newSessionKey() (string, error) {
+	keyBytes, err := crypt.RandomBytes(8)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(keyBytes), nil
+}
+
+func sharedKeyFromClaims(claims *JWTClaims) (string, error) {
+	return crypt.Decrypt(crypt.NewEncrypted(claims.KeyIv, claims.Key))
+}
+
+func sessionKeyHash(sharedKey string) string {
+	return crypt.Hash(sharedKey)
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+var loginProviderParameter = openapi.Parameter{
+	Name: "provider",
+	In:   openapi.ParamInPath,
+	Enum: []string{LoginProviderGoogle, LoginProviderOIDC, LoginProviderMagicLink, LoginProviderDev},
+}
+
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:             "POST",
+			Path:               "/api/login",
+			Summary:            "Sign in with a Google ID token, or a development token",
+			Tag:                "auth",
+			RequestBody:        LoginPayload{},
+			RequestContentType: "application/x-www-form-urlencoded",
+			Responses: map[int]openapi.Response{
+				302: {Description: "Authentication cookies set, redirect to the admin UI"},
+				401: {Description: "Login failed"},
+				404: {Description: "Google login is not enabled"},
+				500: {Description: "Database error"},
+			},
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/login/providers",
+			Summary: "List the enabled login providers",
+			Tag:     "auth",
+			Responses: map[int]openapi.Response{
+				200: {Body: LoginProvidersResponse{}},
+			},
+		},
+		{
+			Method:     "GET",
+			Path:       "/api/login/:provider",
+			Summary:    "Start an OpenID Connect login",
+			Tag:        "auth",
+			Parameters: []openapi.Parameter{loginProviderParameter},
+			Responses: map[int]openapi.Response{
+				302: {Description: "Redirect to the identity provider"},
+				404: {Description: "Unknown or disabled login provider, or a provider without a start step"},
+				500: {Description: "Provider not configured or not reachable"},
+			},
+		},
+		{
+			Method:      "POST",
+			Path:        "/api/login/:provider",
+			Summary:     "Email a magic login link",
+			Tag:         "auth",
+			Parameters:  []openapi.Parameter{loginProviderParameter},
+			RequestBody: MagicLinkPayload{},
+			Responses: map[int]openapi.Response{
+				202: {Body: MagicLinkResponse{}},
+				400: {Description: "Invalid email"},
+				404: {Description: "Unknown or disabled login provider, or a provider without a start step"},
+				500: {Description: "Provider not configured or the email could not be sent"},
+			},
+		},
+		{
+			Method:     "GET",
+			Path:       "/api/login/:provider/callback",
+			Summary:    "Complete an OpenID Connect, magic link or development login",
+			Tag:        "auth",
+			Parameters: []openapi.Parameter{loginProviderParameter},
+			Responses: map[int]openapi.Response{
+				302: {Description: "Authentication cookies set, redirect to the admin UI"},
+				401: {Description: "Login failed"},
+				404: {Description: "Unknown or disabled login provider"},
+				500: {Description: "Database error"},
+			},
+		},
+		{
+			Method:  "POST",
+			Path:    "/api/logout",
+			Summary: "Revoke the current session and clear authentication cookies",
+			Tag:     "auth",
+			Responses: map[int]openapi.Response{
+				200: {Body: LogoutResponse{}},
+				500: {Description: "Database error, cookies are cleared anyway"},
+			},
+		},
+		{
+			Method:  "POST",
+			Path:    "/api/refresh",
+			Summary: "Replace the authentication cookies using the refresh token",
+			Tag:     "auth",
+			Responses: map[int]openapi.Response{
+				200: {Body: RefreshResponse{}},
+				401: {Description: "Refresh token missing, expired or not issued with the access token, or the session was revoked; cookies are cleared"},
+				500: {Description: "Database error"},
+			},
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/csrf",
+			Summary: "Get the token to send in the X-CSRF-Token header, setting the csrf cookie when missing",
+			Tag:     "auth",
+			Responses: map[int]openapi.Response{
+				200: {Body: CSRFTokenResponse{}},
+				500: {Description: "Failed to create the token"},
+			},
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/me",
+			Summary: "Get the signed-in user",
+			Tag:     "auth",
+			Responses: map[int]openapi.Response{
+				200: {Description: "User info, loggedIn is false for anonymous requests", Body: UserInfo{}},
+				500: {Description: "Database error"},
+			},
+		},
+		{
+			Method:    "DELETE",
+			Path:      "/api/me",
+			Summary:   "Delete the signed-in user",
+			Tag:       "auth",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: DeleteMeResponse{}},
+				401: {Description: "Not signed in"},
+				409: {Description: "The user is the last user admin"},
+				500: {Description: "Database error"},
+			},
+		},
+		{
+			Method:    "GET",
+			Path:      "/api/me/export",
+			Summary:   "Download everything stored about the signed-in user as a JSON attachment",
+			Tag:       "auth",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: ExportMeResponse{}},
+				401: {Description: "Not signed in"},
+				500: {Description: "Database error"},
+			},
+		},
+		{
+			Method:    "DELETE",
+			Path:      "/api/me/sessions",
+			Summary:   "Sign the signed-in user out on every device",
+			Tag:       "auth",
+			Protected: true,
+			Responses: map[int]openapi.Response{
+				200: {Body: RevokeSessionsResponse{}},
+				401: {Description: "Not signed in"},
+				500: {Description: "Database error"},
+			},
+		},
+	}
+}
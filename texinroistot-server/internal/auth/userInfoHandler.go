@@ -1,16 +1,37 @@
 package auth
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/kokkoniemi/texinroistot/internal/crypt"
-	"github.com/kokkoniemi/texinroistot/internal/db"
 )
 
 type UserInfo struct {
-	LoggedIn bool   `json:"loggedIn"`
-	Email    string `json:"email"`
-	IsAdmin  bool   `json:"isAdmin"`
-	Hash     string `json:"-"`
+	LoggedIn bool     `json:"loggedIn"`
+	Email    string   `json:"email"`
+	IsAdmin  bool     `json:"isAdmin"`
+	Roles    []string `json:"roles"`
+	Hash     string   `json:"-"`
+	UserID   int      `json:"-"`
+	// APIKeyID is set when the request was signed with an API key.
+	APIKeyID int `json:"-"`
+}
+
+func (u *UserInfo) HasRole(role string) bool {
+	for _, held := range u.Roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentUser returns the user ProtectedRoute stored for the request, or nil
+// outside protected routes.
+func CurrentUser(c *fiber.Ctx) *UserInfo {
+	user, _ := c.Locals("user").(*UserInfo)
+	return user
 }
 
 func UserInfoHandler(c *fiber.Ctx) error {
@@ -29,44 +50,67 @@ func getUserInfo(c *fiber.Ctx) (*UserInfo, error) {
 		return loggedOutUserInfo(), nil
 	}
 
+	var session *authSession
 	authService := NewAuthService()
 	accessClaims, err := authService.VerifyAccessToken(accessToken)
-	if err != nil {
-		return loggedOutUserInfo(), nil
+	if err == nil {
+		session, err = loadSession(accessClaims)
+		if err == nil && claimsNeedRotation(accessClaims) && !session.keyReplaced() {
+			// Tokens encrypted with a retired key are re-issued while
+			// the key is still configured.
+			err = setAuthenticationCookies(c, session.Email, session.SharedKey)
+		}
+	} else {
+		// An expired access token is renewed transparently while the
+		// refresh token and the session are valid.
+		session, err = refreshSession(c)
 	}
-
-	email, err := crypt.Decrypt(crypt.NewEncrypted(
-		accessClaims.JWTUserClaims.EmailIv,
-		accessClaims.JWTUserClaims.EmailHash,
-	))
-	if err != nil {
+	if errors.Is(err, errInvalidSession) {
 		return loggedOutUserInfo(), nil
 	}
-	emailHash := userHashForEmail(email)
-
-	if emailHash != accessClaims.JWTUserClaims.UserID {
-		return loggedOutUserInfo(), nil
-	}
-
-	userRepo := db.NewUserRepository()
-	user, err := userRepo.ReadByHash(emailHash)
 	if err != nil {
 		return nil, err
 	}
 
 	return &UserInfo{
 		LoggedIn: true,
-		Email:    email,
-		IsAdmin:  user != nil && user.IsAdmin,
-		Hash:     emailHash,
+		Email:    session.Email,
+		IsAdmin:  session.User.IsAdmin,
+		Roles:    session.User.Roles,
+		Hash:     session.User.Hash,
+		UserID:   session.User.ID,
 	}, nil
 }
 
+// emailFromClaims decrypts the email of the access token and checks it
+// against the user hash in the same token.
+func emailFromClaims(claims *JWTClaims) (string, error) {
+	email, err := crypt.Decrypt(crypt.NewEncrypted(
+		claims.JWTUserClaims.EmailIv,
+		claims.JWTUserClaims.EmailHash,
+	))
+	if err != nil {
+		return "", err
+	}
+	if userHashForEmail(email) != claims.JWTUserClaims.UserID {
+		return "", errInvalidSession
+	}
+	return email, nil
+}
+
+// claimsNeedRotation reports whether the encrypted claims use a key other
+// than the current encryption key.
+func claimsNeedRotation(claims *JWTClaims) bool {
+	return crypt.NeedsRotation(crypt.NewEncrypted(claims.JWTUserClaims.EmailIv, claims.JWTUserClaims.EmailHash)) ||
+		crypt.NeedsRotation(crypt.NewEncrypted(claims.KeyIv, claims.Key))
+}
+
 func loggedOutUserInfo() *UserInfo {
 	return &UserInfo{
 		LoggedIn: false,
 		Email:    "",
 		IsAdmin:  false,
+		Roles:    []string{},
 		Hash:     "",
 	}
 }
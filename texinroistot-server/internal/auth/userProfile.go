@@ -8,13 +8,25 @@ import (
 	"github.com/kokkoniemi/texinroistot/internal/db"
 )
 
-func ensureUserProfile(email string) error {
-	userRepo := db.NewUserRepository()
-	_, err := userRepo.Create(db.User{
-		Hash:    userHashForEmail(email),
-		IsAdmin: isConfiguredAdminEmail(email),
-	})
-	return err
+func ensureUserProfile(email string) (*db.User, error) {
+	userRepo := newUserRepository()
+	user := db.User{Hash: userHashForEmail(email)}
+	// ROISTOT_ADMIN_EMAILS users get every role; roles granted in the admin
+	// UI are kept on later logins.
+	if isConfiguredAdminEmail(email) {
+		user.Roles = db.Roles
+	}
+	// Without ROISTOT_STORE_USER_EMAILS the email is left empty, which also
+	// clears one stored earlier.
+	if config.StoreUserEmails {
+		encrypted, err := crypt.Encrypt(normalizeEmail(email))
+		if err != nil {
+			return nil, err
+		}
+		user.EmailContent = encrypted.GetContent()
+		user.EmailIv = encrypted.GetIv()
+	}
+	return userRepo.Create(user)
 }
 
 func isConfiguredAdminEmail(email string) bool {
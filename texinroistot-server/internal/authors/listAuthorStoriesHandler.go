@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
 )
 
 var allowedStoryTypes = map[string]bool{
@@ -22,40 +24,50 @@ func parseAuthorHash(raw string) (string, error) {
 	return authorHash, nil
 }
 
+type AuthorStoriesFilters struct {
+	Type string `json:"type"`
+}
+
+type AuthorStoriesResponse struct {
+	AuthorHash string               `json:"authorHash"`
+	Stories    []*db.Story          `json:"stories"`
+	Meta       api.CountMeta        `json:"meta"`
+	Filters    AuthorStoriesFilters `json:"filters"`
+}
+
 func ListAuthorStoriesHandler(c *fiber.Ctx) error {
 	authorHash, err := parseAuthorHash(c.Params("authorHash"))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
 	storyType, err := parseAllowedValue(c.Query("type"), "", allowedStoryTypes)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "type is invalid"})
+		return c.Status(400).JSON(api.Error("type is invalid"))
 	}
 
 	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
 	version, err := versionRepo.GetActive()
 	if err != nil {
-		return c.SendStatus(500)
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 
 	storyRepo := db.NewStoryRepository()
 	stories, authorFound, err := storyRepo.ListByAuthorHash(version, authorHash, storyType)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to list author stories"})
+		return c.Status(500).JSON(api.Error("failed to list author stories"))
 	}
 	if !authorFound {
-		return c.Status(404).JSON(fiber.Map{"error": "author not found"})
+		return c.Status(404).JSON(api.Error("author not found"))
+	}
+	if linkeddata.Requested(c) {
+		return linkeddata.Send(c, linkeddata.Stories(stories))
 	}
 
-	return c.JSON(fiber.Map{
-		"authorHash": authorHash,
-		"stories":    stories,
-		"meta": fiber.Map{
-			"total": len(stories),
-		},
-		"filters": fiber.Map{
-			"type": storyType,
-		},
+	return c.JSON(AuthorStoriesResponse{
+		AuthorHash: authorHash,
+		Stories:    stories,
+		Meta:       api.CountMeta{Total: len(stories)},
+		Filters:    AuthorStoriesFilters{Type: storyType},
 	})
 }
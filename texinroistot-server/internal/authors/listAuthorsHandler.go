@@ -7,7 +7,9 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
 )
 
 const (
@@ -28,7 +30,7 @@ var allowedSorts = map[string]bool{
 	"last_name":  true,
 }
 
-type authorListParams struct {
+type AuthorListParams struct {
 	Type     string
 	Sort     string
 	Search   string
@@ -59,34 +61,40 @@ func parseAllowedValue(raw string, fallback string, allowed map[string]bool) (st
 	return raw, nil
 }
 
-func parseAuthorListParams(c *fiber.Ctx) (authorListParams, error) {
-	page, err := parsePositiveInt(c.Query("page"), defaultPage)
+func parseAuthorListParams(c *fiber.Ctx) (AuthorListParams, error) {
+	return ParseAuthorListQuery(func(key string) string { return c.Query(key) })
+}
+
+// ParseAuthorListQuery validates raw author list query values and fills in
+// defaults. It is shared by the REST and GraphQL endpoints.
+func ParseAuthorListQuery(query func(key string) string) (AuthorListParams, error) {
+	page, err := parsePositiveInt(query("page"), defaultPage)
 	if err != nil {
-		return authorListParams{}, fmt.Errorf("page must be a positive integer")
+		return AuthorListParams{}, fmt.Errorf("page must be a positive integer")
 	}
 
-	pageSize, err := parsePositiveInt(c.Query("pageSize"), defaultPageSize)
+	pageSize, err := parsePositiveInt(query("pageSize"), defaultPageSize)
 	if err != nil {
-		return authorListParams{}, fmt.Errorf("pageSize must be a positive integer")
+		return AuthorListParams{}, fmt.Errorf("pageSize must be a positive integer")
 	}
 	if pageSize > maxPageSize {
 		pageSize = maxPageSize
 	}
 
-	authorType, err := parseAllowedValue(c.Query("type"), defaultType, allowedTypes)
+	authorType, err := parseAllowedValue(query("type"), defaultType, allowedTypes)
 	if err != nil {
-		return authorListParams{}, fmt.Errorf("type is invalid")
+		return AuthorListParams{}, fmt.Errorf("type is invalid")
 	}
 
-	sortValue, err := parseAllowedValue(c.Query("sort"), defaultSort, allowedSorts)
+	sortValue, err := parseAllowedValue(query("sort"), defaultSort, allowedSorts)
 	if err != nil {
-		return authorListParams{}, fmt.Errorf("sort is invalid")
+		return AuthorListParams{}, fmt.Errorf("sort is invalid")
 	}
 
-	return authorListParams{
+	return AuthorListParams{
 		Type:     authorType,
 		Sort:     sortValue,
-		Search:   strings.TrimSpace(c.Query("q", "")),
+		Search:   strings.TrimSpace(query("q")),
 		Page:     page,
 		PageSize: pageSize,
 	}, nil
@@ -144,7 +152,26 @@ func compareAuthors(sortValue string, a *db.Author, b *db.Author) bool {
 	return normalizedText(a.Hash) < normalizedText(b.Hash)
 }
 
-func paginateAuthors(authors []*db.Author, page int, pageSize int) []*db.Author {
+// FilterAuthors applies the type and search filters of params and sorts the result.
+func FilterAuthors(allAuthors []*db.Author, params AuthorListParams) []*db.Author {
+	var filteredAuthors []*db.Author
+	for _, author := range allAuthors {
+		if !authorMatchesType(author, params.Type) {
+			continue
+		}
+		if !authorNameMatches(author, params.Search) {
+			continue
+		}
+		filteredAuthors = append(filteredAuthors, author)
+	}
+
+	sort.SliceStable(filteredAuthors, func(i, j int) bool {
+		return compareAuthors(params.Sort, filteredAuthors[i], filteredAuthors[j])
+	})
+	return filteredAuthors
+}
+
+func PaginateAuthors(authors []*db.Author, page int, pageSize int) []*db.Author {
 	if len(authors) == 0 {
 		return []*db.Author{}
 	}
@@ -162,57 +189,50 @@ func paginateAuthors(authors []*db.Author, page int, pageSize int) []*db.Author
 	return authors[offset:end]
 }
 
+type AuthorListFilters struct {
+	Type string `json:"type"`
+	Sort string `json:"sort"`
+	Q    string `json:"q"`
+}
+
+type AuthorListResponse struct {
+	Authors []*db.Author      `json:"authors"`
+	Meta    api.PageMeta      `json:"meta"`
+	Filters AuthorListFilters `json:"filters"`
+}
+
 func ListAuthorsHandler(c *fiber.Ctx) error {
-	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
-	version, err := versionRepo.GetActive()
+	params, err := parseAuthorListParams(c)
 	if err != nil {
-		return c.SendStatus(500)
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
-	params, err := parseAuthorListParams(c)
+	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
+	version, err := versionRepo.GetActive()
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 
 	authorRepo := db.NewAuthorRepository()
 	allAuthors, err := authorRepo.List(version)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to list authors"})
-	}
-
-	var filteredAuthors []*db.Author
-	for _, author := range allAuthors {
-		if !authorMatchesType(author, params.Type) {
-			continue
-		}
-		if !authorNameMatches(author, params.Search) {
-			continue
-		}
-		filteredAuthors = append(filteredAuthors, author)
+		return c.Status(500).JSON(api.Error("failed to list authors"))
 	}
 
-	sort.SliceStable(filteredAuthors, func(i, j int) bool {
-		return compareAuthors(params.Sort, filteredAuthors[i], filteredAuthors[j])
-	})
-
-	total := len(filteredAuthors)
-	totalPages := 0
-	if total > 0 {
-		totalPages = (total + params.PageSize - 1) / params.PageSize
+	filteredAuthors := FilterAuthors(allAuthors, params)
+	pageAuthors := PaginateAuthors(filteredAuthors, params.Page, params.PageSize)
+	meta := api.NewPageMeta(len(filteredAuthors), params.Page, params.PageSize)
+	if linkeddata.Requested(c) {
+		return linkeddata.SendPage(c, linkeddata.Authors(pageAuthors), meta)
 	}
 
-	return c.JSON(fiber.Map{
-		"authors": paginateAuthors(filteredAuthors, params.Page, params.PageSize),
-		"meta": fiber.Map{
-			"total":      total,
-			"page":       params.Page,
-			"pageSize":   params.PageSize,
-			"totalPages": totalPages,
-		},
-		"filters": fiber.Map{
-			"type": params.Type,
-			"sort": params.Sort,
-			"q":    params.Search,
+	return c.JSON(AuthorListResponse{
+		Authors: pageAuthors,
+		Meta:    meta,
+		Filters: AuthorListFilters{
+			Type: params.Type,
+			Sort: params.Sort,
+			Q:    params.Search,
 		},
 	})
 }
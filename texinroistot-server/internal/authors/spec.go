@@ -0,0 +1,65 @@
+package authors
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+func Operations() []openapi.Operation {
+	listParameters := append(openapi.PageParameters(defaultPageSize, maxPageSize),
+		openapi.Parameter{
+			Name:    "type",
+			In:      openapi.ParamInQuery,
+			Enum:    openapi.Enum(allowedTypes),
+			Default: defaultType,
+		},
+		openapi.Parameter{
+			Name:    "sort",
+			In:      openapi.ParamInQuery,
+			Enum:    openapi.Enum(allowedSorts),
+			Default: defaultSort,
+		},
+		openapi.Parameter{
+			Name:        "q",
+			In:          openapi.ParamInQuery,
+			Description: "Free-text search over author names.",
+		},
+	)
+
+	return []openapi.Operation{
+		{
+			Method:     "GET",
+			Path:       "/api/authors",
+			Summary:    "List authors of the active version",
+			Tag:        "authors",
+			Parameters: listParameters,
+			Responses: map[int]openapi.Response{
+				200: {Body: AuthorListResponse{}, Alternatives: []string{linkeddata.MIMEApplicationLDJSON}},
+				400: {Description: "Invalid query parameter", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/authors/:authorHash/stories",
+			Summary: "List stories of an author",
+			Tag:     "authors",
+			Parameters: []openapi.Parameter{
+				{Name: "authorHash", In: openapi.ParamInPath},
+				{
+					Name:        "type",
+					In:          openapi.ParamInQuery,
+					Description: "Limit to stories the author wrote, drew or translated. All roles by default.",
+					Enum:        openapi.Enum(allowedStoryTypes),
+				},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: AuthorStoriesResponse{}, Alternatives: []string{linkeddata.MIMEApplicationLDJSON}},
+				400: {Description: "Invalid author hash or type", Body: api.ErrorResponse{}},
+				404: {Description: "Author not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+	}
+}
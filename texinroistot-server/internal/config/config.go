@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 )
@@ -16,13 +18,132 @@ var (
 	CookieRefreshSecret string = getEnvConfig("ROISTOT_COOKIE_REFRESH_SECRET", "")
 )
 
+// Encryption keys, a comma-separated list of id:secret. The first key
+// encrypts and every key decrypts, so keys can be rotated. Without it Secret
+// is the only key, with ID 0.
+var EncryptionKeys string = getEnvConfig("ROISTOT_ENCRYPTION_KEYS", "")
+
+// CryptAllowLegacy lets Secret decrypt values written with AES-CTR before
+// key IDs existed. Turn it off once cmd/rekey has re-encrypted them.
+var CryptAllowLegacy bool = getEnvConfigBool("ROISTOT_CRYPT_ALLOW_LEGACY", true)
+
+// Security headers of every response. ContentSecurityPolicy "off" leaves
+// out the header, FrameAncestors is added to it as a directive. HSTS is only
+// sent with secure cookies; a zero HSTSMaxAge disables it.
+var (
+	ContentSecurityPolicy string        = getEnvConfig("ROISTOT_CONTENT_SECURITY_POLICY", "default-src 'none'")
+	FrameAncestors        string        = getEnvConfig("ROISTOT_FRAME_ANCESTORS", "'none'")
+	ReferrerPolicy        string        = getEnvConfig("ROISTOT_REFERRER_POLICY", "no-referrer")
+	HSTSMaxAge            time.Duration = getEnvConfigDuration("ROISTOT_HSTS_MAX_AGE", 365*24*time.Hour)
+)
+
+// Rate limits are token buckets per client IP: PerMinute tokens a minute
+// refill a bucket of Burst tokens, and a zero PerMinute disables a limit.
+// Public routes have a bucket per route, searches and logins a stricter one
+// each. RateLimitStore is memory or postgres, which shares the buckets
+// between server instances.
+var (
+	RateLimitStore           string  = getEnvConfig("ROISTOT_RATE_LIMIT_STORE", "memory")
+	RateLimitPerMinute       float64 = getEnvConfigFloat("ROISTOT_RATE_LIMIT_PER_MINUTE", 120)
+	RateLimitBurst           int     = getEnvConfigInt("ROISTOT_RATE_LIMIT_BURST", 60)
+	SearchRateLimitPerMinute float64 = getEnvConfigFloat("ROISTOT_SEARCH_RATE_LIMIT_PER_MINUTE", 30)
+	SearchRateLimitBurst     int     = getEnvConfigInt("ROISTOT_SEARCH_RATE_LIMIT_BURST", 10)
+	LoginRateLimitPerMinute  float64 = getEnvConfigFloat("ROISTOT_LOGIN_RATE_LIMIT_PER_MINUTE", 5)
+	LoginRateLimitBurst      int     = getEnvConfigInt("ROISTOT_LOGIN_RATE_LIMIT_BURST", 10)
+)
+
+// Client IPs are read from ProxyHeader (e.g. X-Real-IP) on requests from
+// TrustedProxies. The server refuses to start with a header but no proxies.
+var (
+	ProxyHeader    string = getEnvConfig("ROISTOT_PROXY_HEADER", "")
+	TrustedProxies string = getEnvConfig("ROISTOT_TRUSTED_PROXIES", "")
+)
+
+// Session lifetimes. The access token is renewed with the refresh token
+// until the refresh token expires. Expired sessions are deleted every
+// SessionCleanupInterval; zero disables the cleanup. Tokens of the key a
+// refresh replaced keep working for SessionKeyGracePeriod, so that requests
+// sent in parallel with the refresh are not logged out.
+var (
+	LoginExpiresAfter      time.Duration = time.Duration(getEnvConfigInt("ROISTOT_LOGIN_EXPIRES_AFTER_MINUTES", 60)) * time.Minute
+	RefreshExpiresAfter    time.Duration = time.Duration(getEnvConfigInt("ROISTOT_REFRESH_EXPIRES_AFTER_MINUTES", 7*24*60)) * time.Minute
+	SessionCleanupInterval time.Duration = getEnvConfigDuration("ROISTOT_SESSION_CLEANUP_INTERVAL", time.Hour)
+	SessionKeyGracePeriod  time.Duration = getEnvConfigDuration("ROISTOT_SESSION_KEY_GRACE_PERIOD", 30*time.Second)
+)
+
+// Login providers, a comma-separated list of google, oidc and magic-link.
+// PublicURL is the address of the UI, used in links sent by email.
+var (
+	LoginProviders string = getEnvConfig("ROISTOT_LOGIN_PROVIDERS", "google")
+	PublicURL      string = getEnvConfig("ROISTOT_PUBLIC_URL", "")
+)
+
+// Development login accepts tokens signed with DevLoginSecret for
+// DevLoginEmail. It is for local use and tests only and is refused with
+// secure cookies.
+var (
+	DevLogin       bool   = getEnvConfigBool("ROISTOT_DEV_LOGIN", false)
+	DevLoginEmail  string = getEnvConfig("ROISTOT_DEV_LOGIN_EMAIL", "")
+	DevLoginSecret string = getEnvConfig("ROISTOT_DEV_LOGIN_SECRET", "")
+)
+
+// Generic OpenID Connect login. RedirectURL must point to
+// /api/login/oidc/callback of the UI.
+var (
+	OIDCIssuer       string = getEnvConfig("ROISTOT_OIDC_ISSUER", "")
+	OIDCClientID     string = getEnvConfig("ROISTOT_OIDC_CLIENT_ID", "")
+	OIDCClientSecret string = getEnvConfig("ROISTOT_OIDC_CLIENT_SECRET", "")
+	OIDCRedirectURL  string = getEnvConfig("ROISTOT_OIDC_REDIRECT_URL", "")
+	OIDCScopes       string = getEnvConfig("ROISTOT_OIDC_SCOPES", "openid email")
+)
+
+// Email magic link login
+var (
+	MagicLinkSecret       string        = getEnvConfig("ROISTOT_MAGIC_LINK_SECRET", "")
+	MagicLinkExpiresAfter time.Duration = time.Duration(getEnvConfigInt("ROISTOT_MAGIC_LINK_EXPIRES_AFTER_MINUTES", 15)) * time.Minute
+	SMTPAddr              string        = getEnvConfig("ROISTOT_SMTP_ADDR", "")
+	SMTPUsername          string        = getEnvConfig("ROISTOT_SMTP_USERNAME", "")
+	SMTPPassword          string        = getEnvConfig("ROISTOT_SMTP_PASSWORD", "")
+	SMTPFrom              string        = getEnvConfig("ROISTOT_SMTP_FROM", "")
+)
+
 var (
 	GoogleOauth2ClientID string = getEnvConfig("GOOGLE_OAUTH2_CLIENT_ID", "")
 	AdminEmails          string = getEnvConfig("ROISTOT_ADMIN_EMAILS", "")
-	ImportExcelURL       string = getEnvConfig(
+	// StoreUserEmails keeps an encrypted copy of each user's email so that
+	// admins can recognise users. Users are otherwise known only by hash.
+	StoreUserEmails bool   = getEnvConfigBool("ROISTOT_STORE_USER_EMAILS", false)
+	ImportExcelURL  string = getEnvConfig(
 		"ROISTOT_IMPORT_EXCEL_URL",
 		"https://1drv.ms/x/s!Alxd45tPW6_6iVdpB3HmJkpWXdyF?e=BNzoBz&download=1",
 	)
+	ImportAllowedHosts      string = getEnvConfig("ROISTOT_IMPORT_ALLOWED_HOSTS", "1drv.ms,1drv.com,onedrive.live.com")
+	ImportSheetName         string = getEnvConfig("ROISTOT_IMPORT_SHEET_NAME", "Taul1")
+	ImportColumnMappingPath string = getEnvConfig("ROISTOT_IMPORT_COLUMN_MAPPING", "")
+)
+
+// Scheduled imports. A zero interval disables the scheduler.
+var (
+	ImportScheduleInterval time.Duration = getEnvConfigDuration("ROISTOT_IMPORT_SCHEDULE_INTERVAL", 0)
+	ImportAutoActivate     bool          = getEnvConfigBool("ROISTOT_IMPORT_AUTO_ACTIVATE", false)
+)
+
+// Version activation checks
+var (
+	ActivationMaxRemovedVillainsPercent float64 = getEnvConfigFloat("ROISTOT_ACTIVATION_MAX_REMOVED_VILLAINS_PERCENT", 5)
+	ActivationMaxRemovedStoriesPercent  float64 = getEnvConfigFloat("ROISTOT_ACTIVATION_MAX_REMOVED_STORIES_PERCENT", 5)
+	ActivationMaxCountDropPercent       float64 = getEnvConfigFloat("ROISTOT_ACTIVATION_MAX_COUNT_DROP_PERCENT", 10)
+)
+
+// How often scheduled activations are checked. Zero disables them.
+var (
+	ActivationPollInterval time.Duration = getEnvConfigDuration("ROISTOT_ACTIVATION_POLL_INTERVAL", time.Minute)
+)
+
+// Version pruning keeps the active version, pinned versions and the
+// newest RetentionKeepVersions versions.
+var (
+	RetentionKeepVersions int = getEnvConfigInt("ROISTOT_RETENTION_KEEP_VERSIONS", 5)
 )
 
 var (
@@ -46,3 +167,27 @@ func getEnvConfigBool(envVar string, defaultVal bool) bool {
 	}
 	return defaultVal
 }
+
+func getEnvConfigDuration(envVar string, defaultVal time.Duration) time.Duration {
+	val, err := time.ParseDuration(os.Getenv(envVar))
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+func getEnvConfigInt(envVar string, defaultVal int) int {
+	val, err := strconv.Atoi(os.Getenv(envVar))
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
+
+func getEnvConfigFloat(envVar string, defaultVal float64) float64 {
+	val, err := strconv.ParseFloat(os.Getenv(envVar), 64)
+	if err != nil {
+		return defaultVal
+	}
+	return val
+}
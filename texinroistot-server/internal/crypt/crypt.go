@@ -6,37 +6,94 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 
 	"github.com/kokkoniemi/texinroistot/internal/config"
 )
 
+// Encrypt seals the input with AES-GCM under the first encryption key. The
+// key ID is stored in front of the content so that Decrypt can pick the key
+// after rotation.
 func Encrypt(input string) (Encrypted, error) {
 	var encrypted Encrypted
-	iv, err := RandomBytes(aes.BlockSize)
 
+	keys, err := encryptionKeys()
 	if err != nil {
-		return encrypted, fmt.Errorf("[Encrypt] creating nonce failed: %w", err)
+		return encrypted, fmt.Errorf("[Encrypt] %w", err)
 	}
+	key := keys[0]
 
-	secret := []byte(config.Secret)
-	block, err := aes.NewCipher(secret)
+	nonce, err := RandomBytes(key.aead.NonceSize())
 	if err != nil {
-		return encrypted, fmt.Errorf("[Encrypt] creating cipher failed: %w", err)
+		return encrypted, fmt.Errorf("[Encrypt] creating nonce failed: %w", err)
 	}
 
-	plainText := []byte(input)
-	cipherText := make([]byte, len(plainText))
-
-	ctr := cipher.NewCTR(block, iv)
-	ctr.XORKeyStream(cipherText, plainText)
-	encrypted.iv = hex.EncodeToString(iv)
-	encrypted.content = hex.EncodeToString(cipherText)
+	cipherText := key.aead.Seal(nil, nonce, []byte(input), []byte(key.id))
+	encrypted.iv = hex.EncodeToString(nonce)
+	encrypted.content = key.id + keyIDSeparator + hex.EncodeToString(cipherText)
 
 	return encrypted, nil
 }
 
+var ErrLegacyDisabled = errors.New("content without a key ID is not accepted, see ROISTOT_CRYPT_ALLOW_LEGACY")
+
+// Decrypt opens content written by Encrypt with any configured key. Content
+// without a key ID predates authenticated encryption and is decrypted with
+// AES-CTR and ROISTOT_SECRET while ROISTOT_CRYPT_ALLOW_LEGACY is on.
 func Decrypt(encrypted *Encrypted) (string, error) {
+	keyID, content, ok := encrypted.split()
+	if !ok {
+		if !config.CryptAllowLegacy {
+			return "", fmt.Errorf("[Decrypt] %w", ErrLegacyDisabled)
+		}
+		return decryptLegacy(encrypted)
+	}
+
+	keys, err := encryptionKeys()
+	if err != nil {
+		return "", fmt.Errorf("[Decrypt] %w", err)
+	}
+	key, found := findEncryptionKey(keys, keyID)
+	if !found {
+		return "", fmt.Errorf("[Decrypt] unknown encryption key %q", keyID)
+	}
+
+	cipherText, err := hex.DecodeString(content)
+	if err != nil {
+		return "", fmt.Errorf("[Decrypt] decoding content to []byte failed: %w", err)
+	}
+	nonce, err := hex.DecodeString(encrypted.iv)
+	if err != nil {
+		return "", fmt.Errorf("[Decrypt] decoding iv to []byte failed: %w", err)
+	}
+	if len(nonce) != key.aead.NonceSize() {
+		return "", fmt.Errorf("[Decrypt] invalid nonce length %d", len(nonce))
+	}
+
+	plainText, err := key.aead.Open(nil, nonce, cipherText, []byte(key.id))
+	if err != nil {
+		return "", fmt.Errorf("[Decrypt] authentication failed: %w", err)
+	}
+
+	return string(plainText), nil
+}
+
+// NeedsRotation reports whether the content was encrypted with anything but
+// the current key, so that it should be encrypted again.
+func NeedsRotation(encrypted *Encrypted) bool {
+	keyID, _, ok := encrypted.split()
+	if !ok {
+		return true
+	}
+	keys, err := encryptionKeys()
+	if err != nil {
+		return false
+	}
+	return keyID != keys[0].id
+}
+
+func decryptLegacy(encrypted *Encrypted) (string, error) {
 	secret := []byte(config.Secret)
 
 	cipherText, err := hex.DecodeString(encrypted.content)
@@ -53,6 +110,9 @@ func Decrypt(encrypted *Encrypted) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("[Decrypt] creating cipher failed: %w", err)
 	}
+	if len(iv) != block.BlockSize() {
+		return "", fmt.Errorf("[Decrypt] invalid iv length %d", len(iv))
+	}
 
 	plainText := make([]byte, len(cipherText))
 
@@ -62,6 +122,9 @@ func Decrypt(encrypted *Encrypted) (string, error) {
 	return string(plainText), nil
 }
 
+// Hash is deterministic, which user lookups and public content hashes in
+// URLs depend on. It is not rotated with the encryption keys: changing the
+// salt or the algorithm would orphan every stored hash.
 func Hash(input string) string {
 	hash := hashSha256(input)
 	iterations := 10
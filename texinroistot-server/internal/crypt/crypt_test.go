@@ -0,0 +1,150 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+const (
+	oldSecret = "0123456789abcdef0123456789abcdef"
+	newSecret = "fedcba9876543210fedcba9876543210"
+)
+
+func setKeys(t *testing.T, secret string, encryptionKeys string) {
+	t.Helper()
+
+	previousSecret, previousKeys := config.Secret, config.EncryptionKeys
+	config.Secret, config.EncryptionKeys = secret, encryptionKeys
+	t.Cleanup(func() { config.Secret, config.EncryptionKeys = previousSecret, previousKeys })
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	setKeys(t, oldSecret, "")
+
+	encrypted, err := Encrypt("user@example.com")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if !strings.HasPrefix(encrypted.GetContent(), legacyKeyID+keyIDSeparator) {
+		t.Fatalf("expected ROISTOT_SECRET to encrypt as key %s, got %q", legacyKeyID, encrypted.GetContent())
+	}
+
+	decrypted, err := Decrypt(&encrypted)
+	if err != nil || decrypted != "user@example.com" {
+		t.Fatalf("expected the plain text back, got %q (%v)", decrypted, err)
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	setKeys(t, oldSecret, "")
+
+	encrypted, err := Encrypt("user@example.com")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	content := []byte(encrypted.GetContent())
+	last := len(content) - 1
+	if content[last] == '0' {
+		content[last] = '1'
+	} else {
+		content[last] = '0'
+	}
+
+	if _, err := Decrypt(NewEncrypted(encrypted.GetIv(), string(content))); err == nil {
+		t.Fatalf("expected tampered content to be rejected")
+	}
+	relabelled := "other" + strings.TrimPrefix(encrypted.GetContent(), legacyKeyID)
+	if _, err := Decrypt(NewEncrypted(encrypted.GetIv(), relabelled)); err == nil {
+		t.Fatalf("expected content of an unknown key to be rejected")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	setKeys(t, "", "old:"+oldSecret)
+	encrypted, err := Encrypt("user@example.com")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if NeedsRotation(&encrypted) {
+		t.Fatalf("expected content of the current key not to need rotation")
+	}
+
+	config.EncryptionKeys = "new:" + newSecret + ",old:" + oldSecret
+	if !NeedsRotation(&encrypted) {
+		t.Fatalf("expected content of a retired key to need rotation")
+	}
+	if decrypted, err := Decrypt(&encrypted); err != nil || decrypted != "user@example.com" {
+		t.Fatalf("expected a retired key to decrypt, got %q (%v)", decrypted, err)
+	}
+
+	rotated, err := Encrypt("user@example.com")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if !strings.HasPrefix(rotated.GetContent(), "new"+keyIDSeparator) {
+		t.Fatalf("expected the first key to encrypt, got %q", rotated.GetContent())
+	}
+
+	config.EncryptionKeys = "new:" + newSecret
+	if _, err := Decrypt(&encrypted); err == nil {
+		t.Fatalf("expected a removed key not to decrypt")
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	setKeys(t, oldSecret, "")
+
+	iv := []byte("0123456789abcdef")
+	block, err := aes.NewCipher([]byte(oldSecret))
+	if err != nil {
+		t.Fatalf("creating cipher failed: %v", err)
+	}
+	cipherText := make([]byte, len("user@example.com"))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, []byte("user@example.com"))
+	legacy := NewEncrypted(hex.EncodeToString(iv), hex.EncodeToString(cipherText))
+
+	if decrypted, err := Decrypt(legacy); err != nil || decrypted != "user@example.com" {
+		t.Fatalf("expected legacy content to decrypt, got %q (%v)", decrypted, err)
+	}
+	if !NeedsRotation(legacy) {
+		t.Fatalf("expected legacy content to need rotation")
+	}
+
+	allowLegacy := config.CryptAllowLegacy
+	config.CryptAllowLegacy = false
+	t.Cleanup(func() { config.CryptAllowLegacy = allowLegacy })
+	if _, err := Decrypt(legacy); !errors.Is(err, ErrLegacyDisabled) {
+		t.Fatalf("expected legacy content to be refused, got %v", err)
+	}
+}
+
+func TestCheckKeys(t *testing.T) {
+	tests := []struct {
+		name           string
+		secret         string
+		encryptionKeys string
+		wantErr        bool
+	}{
+		{name: "secret only", secret: oldSecret},
+		{name: "key list", encryptionKeys: "new:" + newSecret + ", old:" + oldSecret},
+		{name: "nothing configured", wantErr: true},
+		{name: "missing id", encryptionKeys: ":" + newSecret, wantErr: true},
+		{name: "duplicate id", encryptionKeys: "a:" + newSecret + ",a:" + oldSecret, wantErr: true},
+		{name: "invalid key length", encryptionKeys: "a:short", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setKeys(t, tt.secret, tt.encryptionKeys)
+			if err := CheckKeys(); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,11 @@
 package crypt
 
+import "strings"
+
+// keyIDSeparator ends the key ID in front of encrypted content. Hex content
+// never contains it, so legacy content has no key ID.
+const keyIDSeparator = ":"
+
 type Encrypted struct {
 	iv      string
 	content string
@@ -16,3 +22,9 @@ func (e *Encrypted) GetContent() string {
 func NewEncrypted(iv string, content string) *Encrypted {
 	return &Encrypted{iv, content}
 }
+
+// split returns the key ID and the hex content, or false for legacy content
+// without a key ID.
+func (e *Encrypted) split() (string, string, bool) {
+	return strings.Cut(e.content, keyIDSeparator)
+}
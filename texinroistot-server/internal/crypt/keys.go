@@ -0,0 +1,84 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"strings"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+// legacyKeyID names ROISTOT_SECRET when ROISTOT_ENCRYPTION_KEYS is not set.
+const legacyKeyID = "0"
+
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// encryptionKeys returns the configured keys, the one that encrypts first.
+// Every key decrypts, which lets a new key be added in front of the old one
+// and the old one removed once nothing uses it.
+func encryptionKeys() ([]encryptionKey, error) {
+	raw := strings.TrimSpace(config.EncryptionKeys)
+	if raw == "" {
+		if config.Secret == "" {
+			return nil, fmt.Errorf("neither ROISTOT_ENCRYPTION_KEYS nor ROISTOT_SECRET is set")
+		}
+		key, err := newEncryptionKey(legacyKeyID, config.Secret)
+		if err != nil {
+			return nil, err
+		}
+		return []encryptionKey{key}, nil
+	}
+
+	keys := []encryptionKey{}
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(raw, ",") {
+		id, secret, found := strings.Cut(strings.TrimSpace(entry), ":")
+		id = strings.TrimSpace(id)
+		if !found || id == "" || secret == "" {
+			return nil, fmt.Errorf("ROISTOT_ENCRYPTION_KEYS entries must be id:secret")
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("ROISTOT_ENCRYPTION_KEYS lists key %q twice", id)
+		}
+		seen[id] = true
+
+		key, err := newEncryptionKey(id, secret)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func newEncryptionKey(id string, secret string) (encryptionKey, error) {
+	block, err := aes.NewCipher([]byte(secret))
+	if err != nil {
+		return encryptionKey{}, fmt.Errorf("encryption key %q: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return encryptionKey{}, fmt.Errorf("encryption key %q: %w", id, err)
+	}
+	return encryptionKey{id: id, aead: aead}, nil
+}
+
+func findEncryptionKey(keys []encryptionKey, id string) (encryptionKey, bool) {
+	for _, key := range keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return encryptionKey{}, false
+}
+
+// CheckKeys reports a missing or invalid encryption key configuration, so
+// that the server fails on start rather than on the first login.
+func CheckKeys() error {
+	_, err := encryptionKeys()
+	return err
+}
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+var ErrActivationChecksFailed = errors.New("version failed activation checks")
+
+// ActivationCheck inspects a candidate version before SetActive activates
+// it and returns a message for every problem found.
+type ActivationCheck struct {
+	Name  string
+	Check func(input *ActivationInput) []string
+}
+
+// ActivationChecks run on every activation. Append to it to add checks.
+var ActivationChecks = []ActivationCheck{
+	{Name: "empty-version", Check: checkEmptyVersion},
+	{Name: "count-drop", Check: checkCountDrop},
+	{Name: "removed-entities", Check: checkRemovedEntities},
+	{Name: "stories-without-publications", Check: checkStoriesWithoutPublications},
+	{Name: "villains-without-appearances", Check: checkVillainsWithoutAppearances},
+	{Name: "duplicate-author-names", Check: checkDuplicateAuthorNames},
+}
+
+// VersionQuality counts data problems inside a single version.
+type VersionQuality struct {
+	StoriesWithoutPublications int      `json:"storiesWithoutPublications"`
+	VillainsWithoutAppearances int      `json:"villainsWithoutAppearances"`
+	DuplicateAuthorNames       []string `json:"duplicateAuthorNames"`
+}
+
+// ActivationInput is what the checks see. The Active fields are nil when no
+// version is active.
+type ActivationInput struct {
+	Candidate     *VersionStats
+	Quality       *VersionQuality
+	Active        *VersionStats
+	ActiveQuality *VersionQuality
+	Diff          *VersionDiff
+}
+
+type ActivationIssue struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+type ActivationReport struct {
+	VersionID       int               `json:"versionID"`
+	ActiveVersionID *int              `json:"activeVersionID"`
+	Forced          bool              `json:"forced"`
+	Issues          []ActivationIssue `json:"issues"`
+}
+
+func (r *ActivationReport) Passed() bool {
+	return len(r.Issues) == 0
+}
+
+// RunActivationChecks runs ActivationChecks against input.
+func RunActivationChecks(versionID int, activeVersionID *int, input *ActivationInput) *ActivationReport {
+	report := &ActivationReport{
+		VersionID:       versionID,
+		ActiveVersionID: activeVersionID,
+		Issues:          []ActivationIssue{},
+	}
+	for _, check := range ActivationChecks {
+		for _, message := range check.Check(input) {
+			report.Issues = append(report.Issues, ActivationIssue{Check: check.Name, Message: message})
+		}
+	}
+	return report
+}
+
+func checkEmptyVersion(input *ActivationInput) []string {
+	var issues []string
+	if input.Candidate.Villains == 0 {
+		issues = append(issues, "version has no villains")
+	}
+	if input.Candidate.Stories == 0 {
+		issues = append(issues, "version has no stories")
+	}
+	return issues
+}
+
+func checkCountDrop(input *ActivationInput) []string {
+	if input.Active == nil {
+		return nil
+	}
+
+	counts := []struct {
+		name          string
+		before, after int
+	}{
+		{"villains", input.Active.Villains, input.Candidate.Villains},
+		{"stories", input.Active.Stories, input.Candidate.Stories},
+		{"writers", input.Active.Writers, input.Candidate.Writers},
+		{"drawers", input.Active.Drawers, input.Candidate.Drawers},
+		{"translators", input.Active.Translators, input.Candidate.Translators},
+	}
+
+	var issues []string
+	for _, count := range counts {
+		if count.before == 0 || count.after >= count.before {
+			continue
+		}
+		drop := 100 * float64(count.before-count.after) / float64(count.before)
+		if drop > config.ActivationMaxCountDropPercent {
+			issues = append(issues, fmt.Sprintf(
+				"%s dropped from %d to %d (%.1f%%, max %.1f%%)",
+				count.name,
+				count.before,
+				count.after,
+				drop,
+				config.ActivationMaxCountDropPercent,
+			))
+		}
+	}
+	return issues
+}
+
+func checkRemovedEntities(input *ActivationInput) []string {
+	if input.Diff == nil {
+		return nil
+	}
+
+	var issues []string
+	if removed := input.Diff.Villains.RemovedPercent(); removed > config.ActivationMaxRemovedVillainsPercent {
+		issues = append(issues, fmt.Sprintf(
+			"%d of %d active villains are missing (%.1f%%, max %.1f%%)",
+			input.Diff.Villains.Removed,
+			input.Diff.Villains.Before,
+			removed,
+			config.ActivationMaxRemovedVillainsPercent,
+		))
+	}
+	if removed := input.Diff.Stories.RemovedPercent(); removed > config.ActivationMaxRemovedStoriesPercent {
+		issues = append(issues, fmt.Sprintf(
+			"%d of %d active stories are missing (%.1f%%, max %.1f%%)",
+			input.Diff.Stories.Removed,
+			input.Diff.Stories.Before,
+			removed,
+			config.ActivationMaxRemovedStoriesPercent,
+		))
+	}
+	return issues
+}
+
+// The quality checks only complain about problems the active version does
+// not already have, so known gaps in the sheet do not block every import.
+
+func checkStoriesWithoutPublications(input *ActivationInput) []string {
+	count, allowed := input.Quality.StoriesWithoutPublications, 0
+	if input.ActiveQuality != nil {
+		allowed = input.ActiveQuality.StoriesWithoutPublications
+	}
+	if count > allowed {
+		return []string{fmt.Sprintf("%d stories have no publications (active version: %d)", count, allowed)}
+	}
+	return nil
+}
+
+func checkVillainsWithoutAppearances(input *ActivationInput) []string {
+	count, allowed := input.Quality.VillainsWithoutAppearances, 0
+	if input.ActiveQuality != nil {
+		allowed = input.ActiveQuality.VillainsWithoutAppearances
+	}
+	if count > allowed {
+		return []string{fmt.Sprintf("%d villains do not appear in any story (active version: %d)", count, allowed)}
+	}
+	return nil
+}
+
+func checkDuplicateAuthorNames(input *ActivationInput) []string {
+	known := map[string]bool{}
+	if input.ActiveQuality != nil {
+		for _, names := range input.ActiveQuality.DuplicateAuthorNames {
+			known[names] = true
+		}
+	}
+
+	var issues []string
+	for _, names := range input.Quality.DuplicateAuthorNames {
+		if !known[names] {
+			issues = append(issues, fmt.Sprintf("author names differ only by case: %s", names))
+		}
+	}
+	return issues
+}
+
+const versionQualitySQL = `
+SELECT
+	(SELECT COUNT(*) FROM stories s WHERE s.version = $1
+		AND NOT EXISTS (SELECT 1 FROM stories_in_publications sp WHERE sp.story = s.id)),
+	(SELECT COUNT(*) FROM villains v WHERE v.version = $1
+		AND NOT EXISTS (SELECT 1 FROM villains_in_stories vs WHERE vs.villain = v.id));
+`
+
+const duplicateAuthorNamesSQL = `
+SELECT string_agg(DISTINCT concat_ws(' ', first_name, last_name), ' / ')
+FROM authors
+WHERE version = $1
+GROUP BY lower(COALESCE(first_name, '')), lower(COALESCE(last_name, ''))
+HAVING COUNT(DISTINCT concat_ws(' ', first_name, last_name)) > 1
+ORDER BY 1;
+`
+
+func readVersionQuality(q querier, versionID int) (*VersionQuality, error) {
+	quality := &VersionQuality{}
+	if err := q.QueryRow(versionQualitySQL, versionID).Scan(
+		&quality.StoriesWithoutPublications,
+		&quality.VillainsWithoutAppearances,
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(duplicateAuthorNamesSQL, versionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var names string
+		if err := rows.Scan(&names); err != nil {
+			return nil, err
+		}
+		quality.DuplicateAuthorNames = append(quality.DuplicateAuthorNames, strings.TrimSpace(names))
+	}
+	return quality, rows.Err()
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func healthyActivationInput() *ActivationInput {
+	stats := &VersionStats{Villains: 100, Stories: 50, Writers: 10, Drawers: 10, Translators: 5}
+	return &ActivationInput{
+		Candidate:     stats,
+		Quality:       &VersionQuality{StoriesWithoutPublications: 1},
+		Active:        stats,
+		ActiveQuality: &VersionQuality{StoriesWithoutPublications: 1},
+		Diff: &VersionDiff{
+			Villains: EntityDiff{Before: 100, After: 100},
+			Stories:  EntityDiff{Before: 50, After: 50},
+		},
+	}
+}
+
+func TestRunActivationChecksPassesUnchangedVersion(t *testing.T) {
+	report := RunActivationChecks(2, nil, healthyActivationInput())
+	if !report.Passed() {
+		t.Fatalf("expected no issues, got %+v", report.Issues)
+	}
+}
+
+func TestRunActivationChecksReportsIssues(t *testing.T) {
+	cases := map[string]struct {
+		modify func(input *ActivationInput)
+		check  string
+	}{
+		"empty version": {
+			func(input *ActivationInput) { input.Candidate = &VersionStats{Stories: 50} },
+			"empty-version",
+		},
+		"count drop": {
+			func(input *ActivationInput) {
+				input.Candidate = &VersionStats{Villains: 100, Stories: 50, Writers: 5, Drawers: 10, Translators: 5}
+			},
+			"count-drop",
+		},
+		"removed by hash": {
+			func(input *ActivationInput) {
+				input.Diff.Villains = EntityDiff{Before: 100, After: 100, Added: 30, Removed: 30}
+			},
+			"removed-entities",
+		},
+		"new stories without publications": {
+			func(input *ActivationInput) { input.Quality.StoriesWithoutPublications = 3 },
+			"stories-without-publications",
+		},
+		"villains without appearances": {
+			func(input *ActivationInput) { input.Quality.VillainsWithoutAppearances = 1 },
+			"villains-without-appearances",
+		},
+		"author names differing by case": {
+			func(input *ActivationInput) {
+				input.Quality.DuplicateAuthorNames = []string{"Gian Luigi Bonelli / Gian luigi Bonelli"}
+			},
+			"duplicate-author-names",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			input := healthyActivationInput()
+			tc.modify(input)
+
+			report := RunActivationChecks(2, nil, input)
+			if len(report.Issues) == 0 || report.Issues[0].Check != tc.check {
+				t.Fatalf("expected a %s issue first, got %+v", tc.check, report.Issues)
+			}
+		})
+	}
+}
+
+func TestRunActivationChecksIgnoresKnownDuplicateAuthorNames(t *testing.T) {
+	input := healthyActivationInput()
+	input.Quality.DuplicateAuthorNames = []string{"Mauro Boselli / mauro Boselli"}
+	input.ActiveQuality.DuplicateAuthorNames = []string{"Mauro Boselli / mauro Boselli"}
+
+	if report := RunActivationChecks(2, nil, input); !report.Passed() {
+		t.Fatalf("expected known duplicates to pass, got %+v", report.Issues)
+	}
+}
+
+func TestRunActivationChecksWithoutActiveVersion(t *testing.T) {
+	input := &ActivationInput{
+		Candidate: &VersionStats{Villains: 0, Stories: 10},
+		Quality:   &VersionQuality{VillainsWithoutAppearances: 2},
+	}
+
+	report := RunActivationChecks(1, nil, input)
+	var checks []string
+	for _, issue := range report.Issues {
+		checks = append(checks, issue.Check)
+	}
+	if strings.Join(checks, ",") != "empty-version,villains-without-appearances" {
+		t.Fatalf("unexpected issues %v", checks)
+	}
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAPIKeyNotFound is returned when the key does not exist or belongs to
+// another user.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type apiKeyRepo struct{}
+
+const apiKeyColumns = `api_keys.id, api_keys.user_id, users.hash, api_keys.name, api_keys.prefix,
+api_keys.key_hash, api_keys.scopes::text[], users.roles::text[], api_keys.created_at,
+api_keys.last_used_at, api_keys.expires_at`
+
+var createAPIKeySQL = fmt.Sprintf(`
+WITH created AS (
+	INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+	VALUES ($1, $2, $3, $4, ARRAY(SELECT DISTINCT unnest(COALESCE($5::user_role[], '{}')) ORDER BY 1), $6)
+	RETURNING *
+)
+SELECT %s FROM created AS api_keys JOIN users ON users.id = api_keys.user_id;
+`, apiKeyColumns)
+
+var listAPIKeysSQL = fmt.Sprintf(`
+SELECT %s FROM api_keys JOIN users ON users.id = api_keys.user_id
+WHERE $1 = 0 OR api_keys.user_id = $1
+ORDER BY api_keys.created_at DESC, api_keys.id DESC;
+`, apiKeyColumns)
+
+// Authenticating a key stamps its last use in the same statement.
+var authenticateAPIKeySQL = fmt.Sprintf(`
+UPDATE api_keys SET last_used_at = now()
+FROM users
+WHERE users.id = api_keys.user_id
+AND api_keys.key_hash = $1
+AND (api_keys.expires_at IS NULL OR api_keys.expires_at > now())
+RETURNING %s;
+`, apiKeyColumns)
+
+const removeAPIKeySQL = `
+DELETE FROM api_keys WHERE id = $1 AND ($2 = 0 OR user_id = $2);
+`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	if err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.UserHash,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		ArrayParam(&key.Scopes),
+		ArrayParam(&key.OwnerRoles),
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.ExpiresAt,
+	); err != nil {
+		return nil, err
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	return &key, nil
+}
+
+// Create implements APIKeyRepository. Scopes are stored in enum order.
+func (*apiKeyRepo) Create(key APIKey) (*APIKey, error) {
+	rows, err := Query(
+		createAPIKeySQL,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		ArrayParam(key.Scopes),
+		key.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, fmt.Errorf("failed to create api key")
+	}
+
+	return scanAPIKey(rows)
+}
+
+// List implements APIKeyRepository. Newest keys come first.
+func (*apiKeyRepo) List(userID int) ([]*APIKey, error) {
+	rows, err := Query(listAPIKeysSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Authenticate implements APIKeyRepository. Unknown and expired keys are
+// returned as nil.
+func (*apiKeyRepo) Authenticate(keyHash string) (*APIKey, error) {
+	rows, err := Query(authenticateAPIKeySQL, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	return scanAPIKey(rows)
+}
+
+// Remove implements APIKeyRepository.
+func (*apiKeyRepo) Remove(keyID int, userID int) error {
+	result, err := Execute(removeAPIKeySQL, keyID, userID)
+	if err != nil {
+		return err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func NewAPIKeyRepository() APIKeyRepository {
+	return &apiKeyRepo{}
+}
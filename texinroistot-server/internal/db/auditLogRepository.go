@@ -0,0 +1,170 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+type auditLogRepo struct{}
+
+const auditColumns = "id, created_at, actor_hash, api_key_id, action, target, method, path, ip, user_agent, status, outcome"
+
+const createAuditEntrySQL = `
+INSERT INTO audit_log (actor_hash, api_key_id, action, target, method, path, ip, user_agent, status, outcome)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+`
+
+func scanAuditEntry(row rowScanner) (*AuditEntry, error) {
+	var entry AuditEntry
+	if err := row.Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.ActorHash,
+		&entry.APIKeyID,
+		&entry.Action,
+		&entry.Target,
+		&entry.Method,
+		&entry.Path,
+		&entry.IP,
+		&entry.UserAgent,
+		&entry.Status,
+		&entry.Outcome,
+	); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Create implements AuditLogRepository.
+func (*auditLogRepo) Create(entry AuditEntry) error {
+	_, err := Execute(
+		createAuditEntrySQL,
+		entry.ActorHash,
+		entry.APIKeyID,
+		entry.Action,
+		entry.Target,
+		entry.Method,
+		entry.Path,
+		entry.IP,
+		entry.UserAgent,
+		entry.Status,
+		entry.Outcome,
+	)
+	return err
+}
+
+func buildAuditListWhere(params AuditListParams) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if params.ActorHash != "" {
+		add("actor_hash = $%d", params.ActorHash)
+	}
+	if params.Action != "" {
+		add("action = $%d", params.Action)
+	}
+	if params.Target != "" {
+		add("target = $%d", params.Target)
+	}
+	if params.Outcome != "" {
+		add("outcome = $%d", params.Outcome)
+	}
+	if !params.From.IsZero() {
+		add("created_at >= $%d", params.From)
+	}
+	if !params.To.IsZero() {
+		add("created_at < $%d", params.To)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// List implements AuditLogRepository. Newest entries come first.
+func (*auditLogRepo) List(params AuditListParams) ([]*AuditEntry, *ListMeta, error) {
+	if params.Page <= 0 || params.PageSize <= 0 {
+		return nil, nil, fmt.Errorf("invalid parameters")
+	}
+
+	whereClause, whereArgs := buildAuditListWhere(params)
+	meta := &ListMeta{PageIndex: params.Page - 1, PageSize: params.PageSize}
+
+	countRows, err := Query(fmt.Sprintf("SELECT COUNT(*) FROM audit_log WHERE %s;", whereClause), whereArgs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer countRows.Close()
+
+	if countRows.Next() {
+		if err = countRows.Scan(&meta.Total); err != nil {
+			return nil, nil, err
+		}
+	}
+	if meta.Total == 0 {
+		return []*AuditEntry{}, meta, nil
+	}
+
+	querySQL := fmt.Sprintf(`
+SELECT %s
+FROM audit_log
+WHERE %s
+ORDER BY created_at DESC, id DESC
+LIMIT $%d OFFSET $%d;
+`, auditColumns, whereClause, len(whereArgs)+1, len(whereArgs)+2)
+
+	args := append(whereArgs, params.PageSize, meta.PageIndex*params.PageSize)
+	rows, err := Query(querySQL, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return entries, meta, nil
+}
+
+var listUserAuditEntriesSQL = fmt.Sprintf(`
+SELECT %s
+FROM audit_log
+WHERE actor_hash = $1 OR target = 'userHash=' || $1
+ORDER BY id DESC;
+`, auditColumns)
+
+// ListByUser implements AuditLogRepository. It returns every entry made by
+// the user or aimed at them, newest first, in one query so that entries
+// written meanwhile cannot shift the result.
+func (*auditLogRepo) ListByUser(userHash string) ([]*AuditEntry, error) {
+	rows, err := Query(listUserAuditEntriesSQL, userHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func NewAuditLogRepository() AuditLogRepository {
+	return &auditLogRepo{}
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildAuditListWhere(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	where, args := buildAuditListWhere(AuditListParams{ActorHash: "abc", Outcome: AuditDenied, From: from})
+
+	if want := "TRUE AND actor_hash = $1 AND outcome = $2 AND created_at >= $3"; where != want {
+		t.Fatalf("expected where %q, got %q", want, where)
+	}
+	if want := []interface{}{"abc", AuditDenied, from}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("expected args %v, got %v", want, args)
+	}
+}
+
+func TestListAuditEntriesByUser(t *testing.T) {
+	requireDB(t)
+
+	repo := NewAuditLogRepository()
+	userHash := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	for _, entry := range []AuditEntry{
+		{ActorHash: userHash, Action: "POST /api/admin/versions/import"},
+		{ActorHash: "other", Action: "PUT /api/admin/users/:userHash/roles", Target: "userHash=" + userHash},
+		{ActorHash: "other", Action: "PUT /api/admin/users/:userHash/roles", Target: "userHash=" + userHash + "x"},
+	} {
+		entry.Method, entry.Path, entry.Status, entry.Outcome = "POST", "/api/admin", 200, AuditSuccess
+		if err := repo.Create(entry); err != nil {
+			t.Fatalf("failed to create audit entry: %v", err)
+		}
+	}
+
+	entries, err := repo.ListByUser(userHash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Target != "userHash="+userHash || entries[1].ActorHash != userHash {
+		t.Fatalf("expected the entries by and aimed at the user, newest first, got %+v", entries)
+	}
+}
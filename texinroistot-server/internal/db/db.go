@@ -1,5 +1,7 @@
 package db
 
+import "time"
+
 const (
 	DefaultPageSize   = 25
 	StartPage         = 0
@@ -29,22 +31,113 @@ type VillainListParams struct {
 	PageSize    int
 }
 
+// UserListParams filters users. Hash matches exactly, Search matches the
+// beginning of the hash; Page starts from 1.
+type UserListParams struct {
+	Hash     string
+	Search   string
+	Role     string
+	Page     int
+	PageSize int
+}
+
 type UserRepository interface {
-	List(pageIndex int) ([]*User, *ListMeta, error)
+	List(params UserListParams) ([]*User, *ListMeta, error)
 	ReadByHash(userHash string) (*User, error)
 	Create(user User) (*User, error)
 	Remove(userHash string) error
-	SetAdmin(userHash string) (*User, error)
+	SetRoles(userHash string, roles []string) (*User, error)
+	ListWithEmail() ([]*User, error)
+	SetEmail(userHash string, emailContent string, emailIv string) error
+}
+
+type SessionRepository interface {
+	Create(session Session) (*Session, error)
+	ReadByKey(keyHash string) (*Session, error)
+	ListByUser(userID int) ([]*Session, error)
+	Extend(sessionID int, keyHash string, newKeyHash string, expiresAt time.Time) (bool, error)
+	Remove(keyHash string) error
+	RemoveByUser(userID int) (int, error)
+	RemoveExpired() (int, error)
+}
+
+// APIKeyRepository lists and removes the keys of one user, or of every user
+// when userID is zero.
+type APIKeyRepository interface {
+	Create(key APIKey) (*APIKey, error)
+	List(userID int) ([]*APIKey, error)
+	Authenticate(keyHash string) (*APIKey, error)
+	Remove(keyID int, userID int) error
+}
+
+// AuditListParams filters the audit log. Empty fields and zero times match
+// everything; Page starts from 1.
+type AuditListParams struct {
+	ActorHash string
+	Action    string
+	Target    string
+	Outcome   string
+	From      time.Time
+	To        time.Time
+	Page      int
+	PageSize  int
+}
+
+// AuditLogRepository appends to the audit log and reads it newest first.
+type AuditLogRepository interface {
+	Create(entry AuditEntry) error
+	List(params AuditListParams) ([]*AuditEntry, *ListMeta, error)
+	ListByUser(userHash string) ([]*AuditEntry, error)
+}
+
+// RateLimitRepository keeps token buckets shared between server instances.
+// Take refills the bucket of key by perMinute tokens a minute up to burst,
+// removes a token when there is a whole one and returns the tokens there were
+// before that.
+type RateLimitRepository interface {
+	Take(key string, perMinute float64, burst int) (float64, error)
+	RemoveIdle(before time.Time) (int, error)
 }
 
 type VersionRepository interface {
 	List() ([]*Version, error)
+	ListImportedBy(userID int) ([]*Version, error)
 	Read(versionID int) (*Version, error)
 	Create(version Version) (*Version, error)
+	Update(versionID int, update VersionUpdate) (*Version, error)
 	Remove(versionID int) error
-	SetActive(versionID int) error
+	SetActive(versionID int, activation Activation) (*ActivationReport, error)
 	GetActive() (*Version, error)
 	GetStats(versionID int) (*VersionStats, error)
+	Compare(fromVersionID int, toVersionID int) (*VersionDiff, error)
+	SetPinned(versionID int, pinned bool) error
+	DiskUsage() (*DiskUsageReport, error)
+}
+
+type VersionActivationRepository interface {
+	History(limit int) ([]*VersionActivation, error)
+	HistoryBy(userID int) ([]*VersionActivation, error)
+	Previous() (int, error)
+	Schedule(versionID int, activateAt time.Time, force bool, userID int) (*ScheduledActivation, error)
+	ListScheduled() ([]*ScheduledActivation, error)
+	ListScheduledBy(userID int) ([]*ScheduledActivation, error)
+	CancelScheduled(scheduleID int) error
+	ClaimDue() ([]*ScheduledActivation, error)
+	FinishScheduled(scheduleID int, failure string) error
+	FailRunning(failure string) (int, error)
+}
+
+type ImportJobRepository interface {
+	Create(sourceURL string, fileName string) (*ImportJob, error)
+	SetContentHash(jobID int, contentHash string) error
+	LastContentHash() (string, error)
+	Read(jobID int) (*ImportJob, error)
+	ListUnfinished() ([]*ImportJob, error)
+	SetStatus(jobID int, status string) error
+	SetProgress(jobID int, versionID int, rows int, parsed ImportEntityCounts, persisted ImportEntityCounts) error
+	Finish(jobID int, versionID int) error
+	Fail(jobID int, message string) error
+	FailInterrupted(jobID int, message string) (int, error)
 }
 
 type AuthorRepository interface {
@@ -57,6 +150,8 @@ type StoryRepository interface {
 	List(version *Version, limit int, offset int) ([]*Story, error)
 	ListFiltered(version *Version, params StoryListParams) ([]*Story, int, error)
 	ListByAuthorHash(version *Version, authorHash string, authorType string) ([]*Story, bool, error)
+	ListByAuthorIDs(authorIDs []int) (map[int][]*Story, error)
+	ReadByHash(version *Version, storyHash string) (*Story, error)
 	BulkCreate(stories []*Story, version *Version) ([]*Story, error)
 	BulkCreatePublications(publications []*Publication, version *Version) ([]*Publication, error)
 }
@@ -65,5 +160,6 @@ type VillainRepository interface {
 	BulkCreate(villains []*Villain, version *Version) ([]*Villain, error)
 	ListFiltered(version *Version, params VillainListParams) ([]*Villain, int, error)
 	ListByStoryHash(version *Version, storyHash string) ([]*Villain, bool, error)
+	ListByStoryIDs(storyIDs []int) (map[int][]*Villain, error)
 	//BulkCreateStoryVillain(storyVillains []*StoryVillain) ([]*StoryVillain, error)
 }
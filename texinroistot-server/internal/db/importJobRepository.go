@@ -0,0 +1,376 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrImportJobNotFound      = errors.New("import job not found")
+	ErrInvalidImportJobStatus = errors.New("invalid import job status")
+)
+
+type importJobRepo struct{}
+
+// importJobStageColumns maps a status to the column recording when the job
+// entered it.
+var importJobStageColumns = map[string]string{
+	ImportJobDownloading: "downloading_at",
+	ImportJobParsing:     "parsing_at",
+	ImportJobPersisting:  "persisting_at",
+	ImportJobDone:        "finished_at",
+	ImportJobFailed:      "finished_at",
+}
+
+const importJobColumns = `
+	id,
+	status,
+	COALESCE(source_url, ''),
+	COALESCE(file_name, ''),
+	COALESCE(content_hash, ''),
+	version,
+	COALESCE(error, ''),
+	rows,
+	parsed,
+	persisted,
+	created_at,
+	updated_at,
+	downloading_at,
+	parsing_at,
+	persisting_at,
+	finished_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanImportJob(row rowScanner) (*ImportJob, error) {
+	var job ImportJob
+	var versionID sql.NullInt64
+	var parsed, persisted []byte
+	var downloadingAt, parsingAt, persistingAt, finishedAt sql.NullTime
+
+	if err := row.Scan(
+		&job.ID,
+		&job.Status,
+		&job.SourceURL,
+		&job.FileName,
+		&job.ContentHash,
+		&versionID,
+		&job.Error,
+		&job.Rows,
+		&parsed,
+		&persisted,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&downloadingAt,
+		&parsingAt,
+		&persistingAt,
+		&finishedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if versionID.Valid {
+		id := int(versionID.Int64)
+		job.VersionID = &id
+	}
+	if err := json.Unmarshal(parsed, &job.Parsed); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(persisted, &job.Persisted); err != nil {
+		return nil, err
+	}
+	job.FinishedAt = nullTimePtr(finishedAt)
+	job.Stages = importJobStages(
+		&job.CreatedAt,
+		nullTimePtr(downloadingAt),
+		nullTimePtr(parsingAt),
+		nullTimePtr(persistingAt),
+		job.FinishedAt,
+	)
+
+	return &job, nil
+}
+
+// importJobStages lists the working stages with their start times. A stage
+// ends when the next reached stage starts or the job finishes.
+func importJobStages(queuedAt, downloadingAt, parsingAt, persistingAt, finishedAt *time.Time) []*ImportJobStage {
+	names := []string{ImportJobQueued, ImportJobDownloading, ImportJobParsing, ImportJobPersisting}
+	starts := []*time.Time{queuedAt, downloadingAt, parsingAt, persistingAt}
+
+	stages := make([]*ImportJobStage, len(names))
+	for index, name := range names {
+		stage := &ImportJobStage{Name: name, StartedAt: starts[index]}
+		if stage.StartedAt != nil {
+			stage.FinishedAt = finishedAt
+			for _, next := range starts[index+1:] {
+				if next != nil {
+					stage.FinishedAt = next
+					break
+				}
+			}
+		}
+		stages[index] = stage
+	}
+	return stages
+}
+
+func nullTimePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t := value.Time
+	return &t
+}
+
+var createImportJobSQL = fmt.Sprintf(`
+INSERT INTO import_jobs (source_url, file_name)
+VALUES (NULLIF($1, ''), NULLIF($2, ''))
+RETURNING %s;
+`, importJobColumns)
+
+// Create implements ImportJobRepository. Jobs importing an uploaded file
+// have a fileName and no sourceURL.
+func (*importJobRepo) Create(sourceURL string, fileName string) (*ImportJob, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanImportJob(db.QueryRow(createImportJobSQL, sourceURL, fileName))
+}
+
+var readImportJobSQL = fmt.Sprintf(`
+SELECT %s
+FROM import_jobs
+WHERE id = $1;
+`, importJobColumns)
+
+// Read implements ImportJobRepository.
+func (*importJobRepo) Read(jobID int) (*ImportJob, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := scanImportJob(db.QueryRow(readImportJobSQL, jobID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImportJobNotFound
+	}
+	return job, err
+}
+
+var listUnfinishedImportJobsSQL = fmt.Sprintf(`
+SELECT %s
+FROM import_jobs
+WHERE status NOT IN ('done', 'failed')
+ORDER BY id;
+`, importJobColumns)
+
+// ListUnfinished implements ImportJobRepository.
+func (*importJobRepo) ListUnfinished() ([]*ImportJob, error) {
+	rows, err := Query(listUnfinishedImportJobsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*ImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// SetStatus implements ImportJobRepository.
+func (*importJobRepo) SetStatus(jobID int, status string) error {
+	column, ok := importJobStageColumns[status]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidImportJobStatus, status)
+	}
+
+	return updateImportJob(
+		fmt.Sprintf(`
+UPDATE import_jobs
+SET status = $2, %s = now(), updated_at = now()
+WHERE id = $1;
+`, column),
+		jobID,
+		status,
+	)
+}
+
+const setImportJobProgressSQL = `
+UPDATE import_jobs
+SET version = $2, rows = $3, parsed = $4, persisted = $5, updated_at = now()
+WHERE id = $1;
+`
+
+// SetProgress implements ImportJobRepository. A zero versionID leaves the
+// version unset.
+func (*importJobRepo) SetProgress(
+	jobID int,
+	versionID int,
+	rows int,
+	parsed ImportEntityCounts,
+	persisted ImportEntityCounts,
+) error {
+	parsedJSON, err := json.Marshal(parsed)
+	if err != nil {
+		return err
+	}
+	persistedJSON, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+
+	var version interface{}
+	if versionID > 0 {
+		version = versionID
+	}
+	return updateImportJob(setImportJobProgressSQL, jobID, version, rows, parsedJSON, persistedJSON)
+}
+
+const setImportJobContentHashSQL = `
+UPDATE import_jobs
+SET content_hash = $2, updated_at = now()
+WHERE id = $1;
+`
+
+// SetContentHash implements ImportJobRepository.
+func (*importJobRepo) SetContentHash(jobID int, contentHash string) error {
+	return updateImportJob(setImportJobContentHashSQL, jobID, contentHash)
+}
+
+// The last finished attempt decides: a failed import of the same file is
+// not retried until the file changes. Deleting a version clears
+// import_jobs.version, so a file whose version was removed is no longer
+// considered imported.
+const lastImportContentHashSQL = `
+SELECT content_hash
+FROM import_jobs
+WHERE content_hash IS NOT NULL
+AND (status = 'failed' OR (status = 'done' AND version IS NOT NULL))
+ORDER BY finished_at DESC, id DESC
+LIMIT 1;
+`
+
+// LastContentHash implements ImportJobRepository. It returns the hash of
+// the file of the last finished import attempt, failed ones included, or an
+// empty string when there is none.
+func (*importJobRepo) LastContentHash() (string, error) {
+	db, err := GetDB()
+	if err != nil {
+		return "", err
+	}
+
+	var contentHash string
+	err = db.QueryRow(lastImportContentHashSQL).Scan(&contentHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return contentHash, err
+}
+
+const finishImportJobSQL = `
+UPDATE import_jobs
+SET status = 'done', version = $2, finished_at = now(), updated_at = now()
+WHERE id = $1;
+`
+
+// Finish implements ImportJobRepository.
+func (*importJobRepo) Finish(jobID int, versionID int) error {
+	return updateImportJob(finishImportJobSQL, jobID, versionID)
+}
+
+const failImportJobSQL = `
+UPDATE import_jobs
+SET status = 'failed', error = $2, finished_at = now(), updated_at = now()
+WHERE id = $1;
+`
+
+// Fail implements ImportJobRepository.
+func (*importJobRepo) Fail(jobID int, message string) error {
+	return updateImportJob(failImportJobSQL, jobID, message)
+}
+
+// The content hash is cleared because the file itself did not fail, so the
+// scheduler should import it again.
+const failInterruptedImportJobSQL = `
+UPDATE import_jobs
+SET status = 'failed', error = $2, content_hash = NULL, finished_at = now(), updated_at = now()
+WHERE id = $1
+AND status NOT IN ('done', 'failed')
+RETURNING version;
+`
+
+const removePartialVersionSQL = `
+DELETE FROM versions
+WHERE id = $1
+AND is_active = false;
+`
+
+// FailInterrupted implements ImportJobRepository. It fails a job whose
+// import was cut off and removes the partly written version in the same
+// transaction, so the version list never shows half an import. It returns
+// the id of the removed version, or 0 when the job had none.
+func (*importJobRepo) FailInterrupted(jobID int, message string) (int, error) {
+	txn, err := StartTransaction()
+	if err != nil {
+		return 0, err
+	}
+	defer txn.Rollback()
+
+	var versionID sql.NullInt64
+	if err := txn.QueryRow(failInterruptedImportJobSQL, jobID, message).Scan(&versionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrImportJobNotFound
+		}
+		return 0, err
+	}
+
+	removed := 0
+	if versionID.Valid {
+		result, err := txn.Exec(removePartialVersionSQL, versionID.Int64)
+		if err != nil {
+			return 0, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if rowsAffected > 0 {
+			removed = int(versionID.Int64)
+		}
+	}
+
+	return removed, txn.Commit()
+}
+
+func updateImportJob(query string, jobID int, args ...any) error {
+	result, err := Execute(query, append([]any{jobID}, args...)...)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrImportJobNotFound
+	}
+	return nil
+}
+
+func NewImportJobRepository() ImportJobRepository {
+	return &importJobRepo{}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFailInterruptedRemovesPartialVersion(t *testing.T) {
+	requireDB(t)
+
+	versionRepo := NewVersionRepository()
+	jobRepo := NewImportJobRepository()
+
+	partial, err := versionRepo.Create(Version{})
+	if err != nil {
+		t.Fatalf("failed to create version: %v", err)
+	}
+	job, err := jobRepo.Create("https://example.com/roistot.xlsx", "")
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	if err := jobRepo.SetProgress(job.ID, partial.ID, 10, ImportEntityCounts{}, ImportEntityCounts{}); err != nil {
+		t.Fatalf("failed to record progress: %v", err)
+	}
+
+	removed, err := jobRepo.FailInterrupted(job.ID, "interrupted")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != partial.ID {
+		t.Fatalf("expected version %d to be removed, got %d", partial.ID, removed)
+	}
+	if _, err := versionRepo.Read(partial.ID); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("expected partial version to be gone, got %v", err)
+	}
+
+	failed, err := jobRepo.Read(job.ID)
+	if err != nil {
+		t.Fatalf("failed to read job: %v", err)
+	}
+	if failed.Status != ImportJobFailed || failed.Error != "interrupted" || failed.VersionID != nil {
+		t.Fatalf("expected job to be failed without a version, got %+v", failed)
+	}
+
+	if _, err := jobRepo.FailInterrupted(job.ID, "interrupted"); !errors.Is(err, ErrImportJobNotFound) {
+		t.Fatalf("expected finished job to be left alone, got %v", err)
+	}
+}
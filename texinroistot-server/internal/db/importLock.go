@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+const (
+	// importLockKey identifies the session-level advisory lock that
+	// serializes version imports across every backend replica and the
+	// importer command.
+	importLockKey int64 = 0x74657869_6d706f72
+
+	// scheduledActivationLockKey identifies the session-level advisory lock
+	// held while a replica runs claimed scheduled activations. It must differ
+	// from activationLockKey, which SetActive takes on another connection.
+	scheduledActivationLockKey int64 = 0x73636865_64756c65
+)
+
+var (
+	ErrImportLocked              = errors.New("import already running")
+	ErrScheduledActivationLocked = errors.New("scheduled activations already running")
+)
+
+const tryAdvisoryLockSQL = `SELECT pg_try_advisory_lock($1);`
+
+const releaseAdvisoryLockSQL = `SELECT pg_advisory_unlock($1);`
+
+// AdvisoryLock holds a session-level advisory lock. Advisory locks belong to
+// a database session, so the lock keeps its own connection out of the pool
+// until Release is called. If the process dies, Postgres drops the lock
+// together with the connection.
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryImportLock acquires the import lock without waiting. It returns
+// ErrImportLocked when another process is importing.
+func TryImportLock() (*AdvisoryLock, error) {
+	return tryAdvisoryLock(importLockKey, ErrImportLocked)
+}
+
+// TryScheduledActivationLock acquires the lock of the activation scheduler
+// without waiting. It returns ErrScheduledActivationLocked when another
+// replica is running scheduled activations.
+func TryScheduledActivationLock() (*AdvisoryLock, error) {
+	return tryAdvisoryLock(scheduledActivationLockKey, ErrScheduledActivationLocked)
+}
+
+func tryAdvisoryLock(key int64, errLocked error) (*AdvisoryLock, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(context.Background(), tryAdvisoryLockSQL, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, errLocked
+	}
+
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Release unlocks the lock and returns the connection to the pool.
+func (l *AdvisoryLock) Release() error {
+	defer l.conn.Close()
+
+	var released bool
+	if err := l.conn.QueryRowContext(context.Background(), releaseAdvisoryLockSQL, l.key).Scan(&released); err != nil {
+		return err
+	}
+	if !released {
+		return errors.New("advisory lock was not held")
+	}
+	return nil
+}
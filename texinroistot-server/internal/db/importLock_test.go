@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+// requireDB skips tests that need a Postgres database with the schema
+// applied. Point DB_CONNECTION_STRING to a disposable database to run them.
+func requireDB(t *testing.T) {
+	t.Helper()
+
+	if config.DBConnectionString == "" {
+		t.Skip("DB_CONNECTION_STRING is not set")
+	}
+	if _, err := GetDB(); err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+}
+
+func TestImportLockAllowsOneImportAtATime(t *testing.T) {
+	requireDB(t)
+
+	// Both imports try to take the lock before either releases it, the way
+	// two replicas receiving an import request at the same time would.
+	var attempted sync.WaitGroup
+	attempted.Add(2)
+
+	type result struct {
+		version *Version
+		err     error
+	}
+	results := make(chan result, 2)
+
+	for range 2 {
+		go func() {
+			lock, err := TryImportLock()
+			attempted.Done()
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			attempted.Wait()
+			defer lock.Release()
+
+			version, err := NewVersionRepository().Create(Version{})
+			results <- result{version: version, err: err}
+		}()
+	}
+
+	var imported []*Version
+	locked := 0
+	for range 2 {
+		res := <-results
+		switch {
+		case errors.Is(res.err, ErrImportLocked):
+			locked++
+		case res.err != nil:
+			t.Fatalf("unexpected error: %v", res.err)
+		default:
+			imported = append(imported, res.version)
+		}
+	}
+	for _, version := range imported {
+		t.Cleanup(func() { NewVersionRepository().Remove(version.ID) })
+	}
+
+	if len(imported) != 1 || locked != 1 {
+		t.Fatalf("expected one import and one locked attempt, got %d imports and %d locked", len(imported), locked)
+	}
+
+	lock, err := TryImportLock()
+	if err != nil {
+		t.Fatalf("expected lock to be free after import, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+}
+
+func TestCreateVersionReturnsInsertedRow(t *testing.T) {
+	requireDB(t)
+
+	const count = 5
+	versions := make(chan *Version, count)
+	errs := make(chan error, count)
+
+	var wg sync.WaitGroup
+	for range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			version, err := NewVersionRepository().Create(Version{})
+			if err != nil {
+				errs <- err
+				return
+			}
+			versions <- version
+		}()
+	}
+	wg.Wait()
+	close(versions)
+	close(errs)
+
+	seen := map[int]bool{}
+	for version := range versions {
+		t.Cleanup(func() { NewVersionRepository().Remove(version.ID) })
+		if version.IsActive {
+			t.Fatalf("expected version %d to be inactive", version.ID)
+		}
+		if seen[version.ID] {
+			t.Fatalf("version %d was returned twice", version.ID)
+		}
+		seen[version.ID] = true
+	}
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != count {
+		t.Fatalf("expected %d distinct versions, got %d", count, len(seen))
+	}
+}
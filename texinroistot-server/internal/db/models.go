@@ -5,11 +5,119 @@ import (
 	"time"
 )
 
+// User roles. Any role gives read access to the admin API; the others
+// unlock the routes listed in docs/api-reference.md.
+const (
+	RoleViewer    = "viewer"
+	RoleEditor    = "editor"
+	RolePublisher = "publisher"
+	RoleUserAdmin = "user-admin"
+)
+
+// Roles lists every role in the order they are stored and returned.
+var Roles = []string{RoleViewer, RoleEditor, RolePublisher, RoleUserAdmin}
+
+func IsRole(role string) bool {
+	for _, known := range Roles {
+		if role == known {
+			return true
+		}
+	}
+	return false
+}
+
 type User struct {
 	ID        int       `json:"-"`
 	CreatedAt time.Time `json:"createdAt"`
 	Hash      string    `json:"hash"`
-	IsAdmin   bool      `json:"isAdmin"`
+	Roles     []string  `json:"roles"`
+	// IsAdmin is true for users with any role.
+	IsAdmin bool `json:"isAdmin"`
+	// Email is the decrypted EmailContent, filled in by the admin API.
+	Email        string `json:"email,omitempty"`
+	EmailContent string `json:"-"`
+	EmailIv      string `json:"-"`
+}
+
+func (u *User) HasRole(role string) bool {
+	for _, held := range u.Roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is one sign-in. The tokens of the session carry a random shared
+// key, and only its hash is stored.
+type Session struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"-"`
+	KeyHash     string    `json:"-"`
+	UserAgent   string    `json:"userAgent"`
+	CreatedAt   time.Time `json:"createdAt"`
+	RefreshedAt time.Time `json:"refreshedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// APIKey lets scripts use the admin API. Only the hash of the key is
+// stored; Prefix identifies the key in listings.
+type APIKey struct {
+	ID       int      `json:"id"`
+	UserID   int      `json:"-"`
+	UserHash string   `json:"userHash"`
+	Name     string   `json:"name"`
+	Prefix   string   `json:"prefix"`
+	KeyHash  string   `json:"-"`
+	Scopes   []string `json:"scopes"`
+	// OwnerRoles are the current roles of the owner, read by Authenticate.
+	OwnerRoles []string   `json:"-"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// Roles returns the scopes the owner still holds, in Roles order.
+func (k *APIKey) Roles() []string {
+	roles := []string{}
+	for _, role := range Roles {
+		if contains(k.Scopes, role) && contains(k.OwnerRoles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records one request that changed, or tried to change, data
+// through the admin API.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	ActorHash string    `json:"actorHash"`
+	APIKeyID  *int      `json:"apiKeyID"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Status    int       `json:"status"`
+	Outcome   string    `json:"outcome"`
 }
 
 type Author struct {
@@ -66,10 +174,114 @@ func (a *AuthorBlueprint) ToAuthor() *Author {
 	return to
 }
 
+// Version is one imported snapshot of the spreadsheet. Label and Notes are
+// edited by admins; the other metadata is recorded by the importer.
 type Version struct {
-	ID        int       `json:"id"`
-	CreatedAt time.Time `json:"createdAt"`
-	IsActive  bool      `json:"isActive"`
+	ID               int                `json:"id"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	IsActive         bool               `json:"isActive"`
+	IsPinned         bool               `json:"isPinned"`
+	Label            string             `json:"label"`
+	Notes            string             `json:"notes"`
+	ImportedByID     int                `json:"-"`
+	ImportedBy       string             `json:"importedBy,omitempty"`
+	SourceURL        string             `json:"sourceUrl,omitempty"`
+	FileName         string             `json:"fileName,omitempty"`
+	ContentHash      string             `json:"contentHash,omitempty"`
+	ImporterRevision string             `json:"importerRevision,omitempty"`
+	Rows             int                `json:"rows"`
+	Counts           ImportEntityCounts `json:"counts"`
+}
+
+// VersionUpdate changes the admin-editable fields of a version. Nil fields
+// are left as they are.
+type VersionUpdate struct {
+	Label *string `json:"label"`
+	Notes *string `json:"notes"`
+}
+
+// VersionDiskUsage approximates the space taken by the rows of one version.
+type VersionDiskUsage struct {
+	VersionID int   `json:"versionID"`
+	Rows      int64 `json:"rows"`
+	Bytes     int64 `json:"bytes"`
+}
+
+type DiskUsageReport struct {
+	Versions      []*VersionDiskUsage `json:"versions"`
+	DatabaseBytes int64               `json:"databaseBytes"`
+}
+
+const (
+	ActivationManual    = "manual"
+	ActivationScheduled = "scheduled"
+	ActivationRollback  = "rollback"
+	ActivationImport    = "import"
+)
+
+// Activation tells SetActive who activates a version and why. A zero
+// UserID means the server itself, e.g. after a scheduled import.
+type Activation struct {
+	UserID int
+	Reason string
+	Force  bool
+}
+
+// VersionActivation is one change of the active version. VersionID and
+// PreviousVersionID are nil once that version has been deleted.
+type VersionActivation struct {
+	ID                int       `json:"id"`
+	VersionID         *int      `json:"versionID"`
+	PreviousVersionID *int      `json:"previousVersionID"`
+	ActivatedBy       string    `json:"activatedBy,omitempty"`
+	Reason            string    `json:"reason"`
+	Forced            bool      `json:"forced"`
+	ActivatedAt       time.Time `json:"activatedAt"`
+}
+
+const (
+	ScheduledActivationPending   = "pending"
+	ScheduledActivationRunning   = "running"
+	ScheduledActivationDone      = "done"
+	ScheduledActivationFailed    = "failed"
+	ScheduledActivationCancelled = "cancelled"
+)
+
+type ScheduledActivation struct {
+	ID          int        `json:"id"`
+	VersionID   int        `json:"versionID"`
+	ActivateAt  time.Time  `json:"activateAt"`
+	Force       bool       `json:"force"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedByID int        `json:"-"`
+	CreatedBy   string     `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ProcessedAt *time.Time `json:"processedAt"`
+}
+
+// EntityDiff compares entities of two versions by their hash.
+type EntityDiff struct {
+	Before  int `json:"before"`
+	After   int `json:"after"`
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// RemovedPercent is the share of entities of the older version missing from
+// the newer one.
+func (d EntityDiff) RemovedPercent() float64 {
+	if d.Before == 0 {
+		return 0
+	}
+	return 100 * float64(d.Removed) / float64(d.Before)
+}
+
+type VersionDiff struct {
+	From     int        `json:"from"`
+	To       int        `json:"to"`
+	Villains EntityDiff `json:"villains"`
+	Stories  EntityDiff `json:"stories"`
 }
 
 type VersionStats struct {
@@ -80,6 +292,62 @@ type VersionStats struct {
 	Translators int `json:"translators"`
 }
 
+const (
+	ImportJobQueued      = "queued"
+	ImportJobDownloading = "downloading"
+	ImportJobParsing     = "parsing"
+	ImportJobPersisting  = "persisting"
+	ImportJobDone        = "done"
+	ImportJobFailed      = "failed"
+)
+
+// ImportJobStatuses lists the import job states in the order they are passed.
+var ImportJobStatuses = []string{
+	ImportJobQueued,
+	ImportJobDownloading,
+	ImportJobParsing,
+	ImportJobPersisting,
+	ImportJobDone,
+	ImportJobFailed,
+}
+
+type ImportEntityCounts struct {
+	Authors      int `json:"authors"`
+	Publications int `json:"publications"`
+	Stories      int `json:"stories"`
+	Villains     int `json:"villains"`
+}
+
+type ImportJobStage struct {
+	Name       string     `json:"name"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// ImportJob tracks a background version import. Version is set as soon as
+// the persisting stage has created it, so a failed job points to the
+// partially written version.
+type ImportJob struct {
+	ID          int                `json:"id"`
+	Status      string             `json:"status"`
+	SourceURL   string             `json:"sourceUrl,omitempty"`
+	FileName    string             `json:"fileName,omitempty"`
+	ContentHash string             `json:"contentHash,omitempty"`
+	VersionID   *int               `json:"versionID"`
+	Error       string             `json:"error,omitempty"`
+	Rows        int                `json:"rows"`
+	Parsed      ImportEntityCounts `json:"parsed"`
+	Persisted   ImportEntityCounts `json:"persisted"`
+	Stages      []*ImportJobStage  `json:"stages"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	FinishedAt  *time.Time         `json:"finishedAt"`
+}
+
+func (j *ImportJob) IsFinished() bool {
+	return j.Status == ImportJobDone || j.Status == ImportJobFailed
+}
+
 // Publication may have multiple stories and a story can be published in multiple publications
 type Publication struct {
 	ID    int    `json:"-"`
@@ -51,6 +51,13 @@ func Query(q string, args ...any) (*sql.Rows, error) {
 	return db.QueryContext(context.Background(), q, args...)
 }
 
+// querier is implemented by both *sql.DB and *sql.Tx, for reads that also
+// run inside transactions.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func StartTransaction() (*sql.Tx, error) {
 	db, err := GetDB()
 	if err != nil {
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+type rateLimitRepo struct{}
+
+// createRateLimitBucketSQL creates a missing bucket full. A concurrent insert
+// of the same key makes it wait until that one commits, so afterwards the row
+// exists for every request of the client.
+const createRateLimitBucketSQL = `
+INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2::float8, now())
+ON CONFLICT (key) DO NOTHING;
+`
+
+// The bucket row is locked while it is refilled, so concurrent requests of
+// one client cannot take the same token.
+const takeRateLimitTokenSQL = `
+UPDATE rate_limits AS r
+SET tokens = CASE WHEN bucket.tokens >= 1 THEN bucket.tokens - 1 ELSE bucket.tokens END, updated_at = now()
+FROM (
+	SELECT LEAST($3::float8, tokens + EXTRACT(EPOCH FROM now() - updated_at)::float8 / 60 * $2::float8) AS tokens
+	FROM rate_limits WHERE key = $1 FOR UPDATE
+) AS bucket
+WHERE r.key = $1
+RETURNING bucket.tokens;
+`
+
+const removeIdleRateLimitsSQL = `
+DELETE FROM rate_limits WHERE updated_at < $1;
+`
+
+// Take implements RateLimitRepository.
+func (*rateLimitRepo) Take(key string, perMinute float64, burst int) (float64, error) {
+	if _, err := Execute(createRateLimitBucketSQL, key, burst); err != nil {
+		return 0, err
+	}
+
+	rows, err := Query(takeRateLimitTokenSQL, key, perMinute, burst)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, fmt.Errorf("failed to take a rate limit token")
+	}
+
+	var tokens float64
+	if err := rows.Scan(&tokens); err != nil {
+		return 0, err
+	}
+	return tokens, nil
+}
+
+// RemoveIdle implements RateLimitRepository.
+func (*rateLimitRepo) RemoveIdle(before time.Time) (int, error) {
+	result, err := Execute(removeIdleRateLimitsSQL, before)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+func NewRateLimitRepository() RateLimitRepository {
+	return &rateLimitRepo{}
+}
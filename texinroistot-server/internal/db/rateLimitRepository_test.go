@@ -0,0 +1,44 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentFirstTakesShareOneBucket(t *testing.T) {
+	requireDB(t)
+
+	const requests = 8
+	repo := NewRateLimitRepository()
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	t.Cleanup(func() { _, _ = Execute(`DELETE FROM rate_limits WHERE key = $1;`, key) })
+
+	// With a bucket of one token and no refill, only one of the first
+	// requests of a new client may get a token.
+	tokens := make([]float64, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = repo.Take(key, 0, 1)
+		}()
+	}
+	wg.Wait()
+
+	allowed := 0
+	for i := range tokens {
+		if errs[i] != nil {
+			t.Fatalf("take failed: %v", errs[i])
+		}
+		if tokens[i] >= 1 {
+			allowed++
+		}
+	}
+	if allowed != 1 {
+		t.Fatalf("expected one request to get a token, got %d", allowed)
+	}
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Reasons a version is kept by pruning.
+const (
+	RetainActive    = "active"
+	RetainPinned    = "pinned"
+	RetainLatest    = "latest"
+	RetainScheduled = "scheduled"
+	RetainRollback  = "rollback"
+)
+
+// RetentionPolicy decides which versions pruning keeps: the active version,
+// pinned versions, versions waiting for a scheduled activation, the version
+// a rollback would return to and the newest KeepLatest versions.
+type RetentionPolicy struct {
+	KeepLatest int
+}
+
+type RetainedVersion struct {
+	Version *Version `json:"version"`
+	Reasons []string `json:"reasons"`
+}
+
+type PrunePlan struct {
+	KeepLatest int                `json:"keepLatest"`
+	Keep       []*RetainedVersion `json:"keep"`
+	Prune      []*Version         `json:"prune"`
+	// Row data freed by pruning, see VersionRepository.DiskUsage
+	PruneBytes int64 `json:"pruneBytes"`
+}
+
+// Plan sorts versions into kept and prunable ones. scheduled holds the IDs
+// of versions with pending scheduled activations and rollbackID the rollback
+// target, zero when there is none.
+func (p RetentionPolicy) Plan(versions []*Version, scheduled map[int]bool, rollbackID int) *PrunePlan {
+	newestFirst := append([]*Version(nil), versions...)
+	sort.SliceStable(newestFirst, func(i, j int) bool {
+		if newestFirst[i].CreatedAt.Equal(newestFirst[j].CreatedAt) {
+			return newestFirst[i].ID > newestFirst[j].ID
+		}
+		return newestFirst[i].CreatedAt.After(newestFirst[j].CreatedAt)
+	})
+
+	plan := &PrunePlan{
+		KeepLatest: p.KeepLatest,
+		Keep:       []*RetainedVersion{},
+		Prune:      []*Version{},
+	}
+	for index, version := range newestFirst {
+		var reasons []string
+		if version.IsActive {
+			reasons = append(reasons, RetainActive)
+		}
+		if version.IsPinned {
+			reasons = append(reasons, RetainPinned)
+		}
+		if scheduled[version.ID] {
+			reasons = append(reasons, RetainScheduled)
+		}
+		if rollbackID != 0 && version.ID == rollbackID {
+			reasons = append(reasons, RetainRollback)
+		}
+		if index < p.KeepLatest {
+			reasons = append(reasons, RetainLatest)
+		}
+
+		if len(reasons) > 0 {
+			plan.Keep = append(plan.Keep, &RetainedVersion{Version: version, Reasons: reasons})
+		} else {
+			plan.Prune = append(plan.Prune, version)
+		}
+	}
+	return plan
+}
+
+// PlanPruning applies policy to the stored versions and adds the disk space
+// pruning would free.
+func PlanPruning(
+	versionRepo VersionRepository,
+	activationRepo VersionActivationRepository,
+	policy RetentionPolicy,
+) (*PrunePlan, error) {
+	versions, err := versionRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := activationRepo.ListScheduled()
+	if err != nil {
+		return nil, err
+	}
+	scheduled := map[int]bool{}
+	for _, activation := range pending {
+		scheduled[activation.VersionID] = true
+	}
+
+	rollbackID, err := activationRepo.Previous()
+	if errors.Is(err, ErrNoPreviousVersion) {
+		rollbackID = 0
+	} else if err != nil {
+		return nil, err
+	}
+
+	plan := policy.Plan(versions, scheduled, rollbackID)
+	if len(plan.Prune) == 0 {
+		return plan, nil
+	}
+
+	usage, err := versionRepo.DiskUsage()
+	if err != nil {
+		return nil, err
+	}
+	bytes := map[int]int64{}
+	for _, version := range usage.Versions {
+		bytes[version.VersionID] = version.Bytes
+	}
+	for _, version := range plan.Prune {
+		plan.PruneBytes += bytes[version.ID]
+	}
+	return plan, nil
+}
+
+// ApplyPruning deletes the versions plan marks for pruning and returns the
+// IDs it deleted. Versions deleted or activated since planning are skipped.
+func ApplyPruning(versionRepo VersionRepository, plan *PrunePlan) ([]int, error) {
+	deleted := []int{}
+	for _, version := range plan.Prune {
+		err := versionRepo.Remove(version.ID)
+		if errors.Is(err, ErrVersionNotFound) || errors.Is(err, ErrCannotDeleteActiveVersion) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete version %d: %w", version.ID, err)
+		}
+		deleted = append(deleted, version.ID)
+	}
+	return deleted, nil
+}
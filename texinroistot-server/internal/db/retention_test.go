@@ -0,0 +1,101 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func retentionTestVersions() []*Version {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := make([]*Version, 6)
+	for index := range versions {
+		versions[index] = &Version{ID: index + 1, CreatedAt: start.Add(time.Duration(index) * time.Hour)}
+	}
+	versions[1].IsActive = true
+	versions[2].IsPinned = true
+	return versions
+}
+
+func TestRetentionPolicyPlan(t *testing.T) {
+	plan := RetentionPolicy{KeepLatest: 2}.Plan(retentionTestVersions(), map[int]bool{4: true}, 0)
+
+	kept := map[int][]string{}
+	for _, retained := range plan.Keep {
+		kept[retained.Version.ID] = retained.Reasons
+	}
+	wantKept := map[int][]string{
+		6: {RetainLatest},
+		5: {RetainLatest},
+		4: {RetainScheduled},
+		3: {RetainPinned},
+		2: {RetainActive},
+	}
+	if !reflect.DeepEqual(kept, wantKept) {
+		t.Fatalf("expected kept %v, got %v", wantKept, kept)
+	}
+
+	if len(plan.Prune) != 1 || plan.Prune[0].ID != 1 {
+		t.Fatalf("expected only version 1 to be pruned, got %+v", plan.Prune)
+	}
+}
+
+func TestRetentionPolicyPlanKeepZero(t *testing.T) {
+	plan := RetentionPolicy{KeepLatest: 0}.Plan(retentionTestVersions(), nil, 0)
+
+	var pruned []int
+	for _, version := range plan.Prune {
+		pruned = append(pruned, version.ID)
+	}
+	if want := []int{6, 5, 4, 1}; !reflect.DeepEqual(pruned, want) {
+		t.Fatalf("expected pruned %v, got %v", want, pruned)
+	}
+}
+
+func TestRetentionPolicyPlanKeepsRollbackTarget(t *testing.T) {
+	plan := RetentionPolicy{KeepLatest: 0}.Plan(retentionTestVersions(), nil, 1)
+
+	var rollback *RetainedVersion
+	for _, retained := range plan.Keep {
+		if retained.Version.ID == 1 {
+			rollback = retained
+		}
+	}
+	if rollback == nil || !reflect.DeepEqual(rollback.Reasons, []string{RetainRollback}) {
+		t.Fatalf("expected version 1 to be kept as the rollback target, got %+v", rollback)
+	}
+	for _, version := range plan.Prune {
+		if version.ID == 1 {
+			t.Fatalf("expected the rollback target not to be pruned")
+		}
+	}
+}
+
+type fakeRemoveVersionRepo struct {
+	VersionRepository
+	removed []int
+}
+
+func (r *fakeRemoveVersionRepo) Remove(versionID int) error {
+	switch versionID {
+	case 2:
+		return ErrVersionNotFound
+	case 3:
+		return ErrCannotDeleteActiveVersion
+	}
+	r.removed = append(r.removed, versionID)
+	return nil
+}
+
+func TestApplyPruningSkipsChangedVersions(t *testing.T) {
+	versionRepo := &fakeRemoveVersionRepo{}
+	plan := &PrunePlan{Prune: []*Version{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}}
+
+	deleted, err := ApplyPruning(versionRepo, plan)
+	if err != nil {
+		t.Fatalf("ApplyPruning failed: %v", err)
+	}
+	if want := []int{1, 4}; !reflect.DeepEqual(deleted, want) {
+		t.Fatalf("expected deleted %v, got %v", want, deleted)
+	}
+}
@@ -95,15 +95,125 @@ COMMENT ON COLUMN "public"."publications"."hash" IS 'consistent identifier betwe
 
 -- USERS:
 
+DROP TYPE IF EXISTS "public"."user_role";
+CREATE TYPE "public"."user_role" AS ENUM (
+	'viewer',
+	'editor',
+	'publisher',
+	'user-admin'
+);
+
 -- Table Definition
 CREATE TABLE "public"."users" (
 	    "id" int8 GENERATED ALWAYS AS IDENTITY,
 	    "created_at" timestamptz NOT NULL DEFAULT now(),
 	    "hash" varchar NOT NULL,
-	    "is_admin" bool NOT NULL DEFAULT false,
+	    "roles" "public"."user_role"[] NOT NULL DEFAULT '{}',
+	    "email" varchar,
+	    "email_iv" varchar,
 	    PRIMARY KEY ("id")
 );
 
+-- Column Comment
+COMMENT ON COLUMN "public"."users"."roles" IS 'any role gives read access to the admin API; scripts/migrate_user_roles.sh converts the former is_admin flag';
+COMMENT ON COLUMN "public"."users"."email" IS 'email encrypted with the current encryption key, only stored with ROISTOT_STORE_USER_EMAILS=true and cleared on the next login when it is off';
+
+
+-- SESSIONS:
+
+-- Table Definition
+CREATE TABLE "public"."sessions" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "user_id" int8 NOT NULL,
+	    "key_hash" varchar NOT NULL,
+	    "previous_key_hash" varchar,
+	    "user_agent" varchar NOT NULL DEFAULT '',
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "refreshed_at" timestamptz NOT NULL DEFAULT now(),
+	    "expires_at" timestamptz NOT NULL,
+	    PRIMARY KEY ("id")
+);
+
+-- Comments
+COMMENT ON TABLE "public"."sessions" IS 'Signed-in sessions. Deleting a row revokes the session';
+COMMENT ON COLUMN "public"."sessions"."key_hash" IS 'hash of the random shared key in the access and refresh tokens';
+COMMENT ON COLUMN "public"."sessions"."previous_key_hash" IS 'key replaced by the last refresh, accepted for a short grace period after refreshed_at';
+COMMENT ON COLUMN "public"."sessions"."expires_at" IS 'moved forward on every refresh; expired rows are deleted periodically';
+
+
+-- API KEYS:
+
+-- Table Definition
+CREATE TABLE "public"."api_keys" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "user_id" int8 NOT NULL,
+	    "name" varchar NOT NULL,
+	    "prefix" varchar NOT NULL,
+	    "key_hash" varchar NOT NULL,
+	    "scopes" "public"."user_role"[] NOT NULL DEFAULT '{}',
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "last_used_at" timestamptz,
+	    "expires_at" timestamptz,
+	    PRIMARY KEY ("id")
+);
+
+-- Comments
+COMMENT ON TABLE "public"."api_keys" IS 'Keys for scripts, sent as Authorization: Bearer. Deleting a row revokes the key';
+COMMENT ON COLUMN "public"."api_keys"."prefix" IS 'start of the key, shown so that users can tell their keys apart';
+COMMENT ON COLUMN "public"."api_keys"."scopes" IS 'roles the key may use; roles the owner has lost since are not used';
+
+
+-- AUDIT LOG:
+
+-- Table Definition
+CREATE TABLE "public"."audit_log" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "actor_hash" varchar NOT NULL DEFAULT '',
+	    "api_key_id" int8,
+	    "action" varchar NOT NULL,
+	    "target" varchar NOT NULL DEFAULT '',
+	    "method" varchar NOT NULL,
+	    "path" varchar NOT NULL,
+	    "ip" varchar NOT NULL DEFAULT '',
+	    "user_agent" varchar NOT NULL DEFAULT '',
+	    "status" int4 NOT NULL,
+	    "outcome" varchar NOT NULL CHECK (outcome IN ('success', 'denied', 'failure')),
+	    PRIMARY KEY ("id")
+);
+
+-- Comments
+COMMENT ON TABLE "public"."audit_log" IS 'Changes made through the admin API. Rows cannot be updated or deleted';
+COMMENT ON COLUMN "public"."audit_log"."actor_hash" IS 'hash of the user, kept after the user is deleted; empty when not signed in, system for scheduler changes';
+COMMENT ON COLUMN "public"."audit_log"."action" IS 'method and route pattern, e.g. POST /api/admin/versions/:versionID/activate';
+
+CREATE FUNCTION "public"."audit_log_append_only"() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON "public"."audit_log"
+	FOR EACH ROW EXECUTE FUNCTION "public"."audit_log_append_only"();
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON "public"."audit_log"
+	FOR EACH STATEMENT EXECUTE FUNCTION "public"."audit_log_append_only"();
+
+
+-- RATE LIMITS:
+
+-- Table Definition, unlogged since losing the buckets in a crash only resets
+-- the limits
+CREATE UNLOGGED TABLE "public"."rate_limits" (
+	    "key" varchar NOT NULL,
+	    "tokens" float8 NOT NULL,
+	    "updated_at" timestamptz NOT NULL DEFAULT now(),
+	    PRIMARY KEY ("key")
+);
+
+-- Comments
+COMMENT ON TABLE "public"."rate_limits" IS 'Token buckets of ROISTOT_RATE_LIMIT_STORE=postgres, shared by every server instance';
+COMMENT ON COLUMN "public"."rate_limits"."tokens" IS 'tokens left at updated_at; refilled on the next request';
+
 
 -- VERSIONS:
 
@@ -112,11 +222,106 @@ CREATE TABLE "public"."versions" (
 	    "id" int8 GENERATED ALWAYS AS IDENTITY,
 	    "created_at" timestamptz NOT NULL DEFAULT now(),
 	    "is_active" bool NOT NULL DEFAULT false,
+	    "is_pinned" bool NOT NULL DEFAULT false,
+	    "label" varchar NOT NULL DEFAULT '',
+	    "notes" text NOT NULL DEFAULT '',
+	    "imported_by" int8,
+	    "source_url" varchar,
+	    "file_name" varchar,
+	    "content_hash" varchar,
+	    "importer_revision" varchar,
+	    "rows" int4 NOT NULL DEFAULT 0,
+	    "counts" jsonb NOT NULL DEFAULT '{}',
 	    PRIMARY KEY ("id")
 );
 
 -- Comments
 COMMENT ON TABLE "public"."versions" IS 'Every row in database is related to certain version';
+COMMENT ON COLUMN "public"."versions"."is_pinned" IS 'pinned versions are kept by version pruning';
+COMMENT ON COLUMN "public"."versions"."rows" IS 'spreadsheet data rows the version was imported from';
+COMMENT ON COLUMN "public"."versions"."counts" IS 'imported entity counts, see db.ImportEntityCounts';
+
+
+-- VERSION ACTIVATIONS:
+
+-- Table Definition
+CREATE TABLE "public"."version_activations" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "version" int8,
+	    "previous_version" int8,
+	    "activated_by" int8,
+	    "reason" varchar NOT NULL,
+	    "forced" bool NOT NULL DEFAULT false,
+	    "activated_at" timestamptz NOT NULL DEFAULT now(),
+	    PRIMARY KEY ("id")
+);
+
+-- Comments
+COMMENT ON TABLE "public"."version_activations" IS 'Activation history. previous_version is the version that was active before, used for rollback';
+COMMENT ON COLUMN "public"."version_activations"."version" IS 'null once the activated version is deleted, so that the history is kept';
+COMMENT ON COLUMN "public"."version_activations"."activated_by" IS 'null when the server activated the version, e.g. after a scheduled import';
+
+DROP TYPE IF EXISTS "public"."scheduled_activation_status";
+CREATE TYPE "public"."scheduled_activation_status" AS ENUM (
+	'pending',
+	'running',
+	'done',
+	'failed',
+	'cancelled'
+);
+
+-- Table Definition
+CREATE TABLE "public"."scheduled_activations" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "version" int8 NOT NULL,
+	    "activate_at" timestamptz NOT NULL,
+	    "force" bool NOT NULL DEFAULT false,
+	    "status" "public"."scheduled_activation_status" NOT NULL DEFAULT 'pending',
+	    "error" varchar,
+	    "created_by" int8,
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "processed_at" timestamptz,
+	    PRIMARY KEY ("id")
+);
+
+
+-- IMPORT JOBS:
+
+DROP TYPE IF EXISTS "public"."import_job_status";
+CREATE TYPE "public"."import_job_status" AS ENUM (
+	'queued',
+	'downloading',
+	'parsing',
+	'persisting',
+	'done',
+	'failed'
+);
+
+-- Table Definition
+CREATE TABLE "public"."import_jobs" (
+	    "id" int8 GENERATED ALWAYS AS IDENTITY,
+	    "status" "public"."import_job_status" NOT NULL DEFAULT 'queued',
+	    "source_url" varchar,
+	    "file_name" varchar,
+	    "content_hash" varchar,
+	    "version" int8,
+	    "error" varchar,
+	    "rows" int8 NOT NULL DEFAULT 0,
+	    "parsed" jsonb NOT NULL DEFAULT '{}',
+	    "persisted" jsonb NOT NULL DEFAULT '{}',
+	    "created_at" timestamptz NOT NULL DEFAULT now(),
+	    "updated_at" timestamptz NOT NULL DEFAULT now(),
+	    "downloading_at" timestamptz,
+	    "parsing_at" timestamptz,
+	    "persisting_at" timestamptz,
+	    "finished_at" timestamptz,
+	    PRIMARY KEY ("id")
+);
+
+-- Comments
+COMMENT ON TABLE "public"."import_jobs" IS 'Background spreadsheet imports. Stage columns hold the time each stage started';
+COMMENT ON COLUMN "public"."import_jobs"."file_name" IS 'set instead of source_url when the spreadsheet was uploaded';
+COMMENT ON COLUMN "public"."import_jobs"."content_hash" IS 'sha256 of the imported file, used by scheduled imports to skip unchanged files';
 
 
 -- VILLAINS:
@@ -162,8 +367,20 @@ ALTER TABLE "public"."stories_in_publications" ADD FOREIGN KEY ("publication") R
 ALTER TABLE "public"."villains" ADD FOREIGN KEY ("version") REFERENCES "public"."versions"("id") ON DELETE CASCADE;
 ALTER TABLE "public"."villains_in_stories" ADD FOREIGN KEY ("story") REFERENCES "public"."stories"("id") ON DELETE CASCADE;
 ALTER TABLE "public"."villains_in_stories" ADD FOREIGN KEY ("villain") REFERENCES "public"."villains"("id") ON DELETE CASCADE;
+ALTER TABLE "public"."import_jobs" ADD FOREIGN KEY ("version") REFERENCES "public"."versions"("id") ON DELETE SET NULL;
+ALTER TABLE "public"."versions" ADD FOREIGN KEY ("imported_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
+ALTER TABLE "public"."version_activations" ADD FOREIGN KEY ("version") REFERENCES "public"."versions"("id") ON DELETE SET NULL;
+ALTER TABLE "public"."version_activations" ADD FOREIGN KEY ("previous_version") REFERENCES "public"."versions"("id") ON DELETE SET NULL;
+ALTER TABLE "public"."version_activations" ADD FOREIGN KEY ("activated_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
+ALTER TABLE "public"."scheduled_activations" ADD FOREIGN KEY ("version") REFERENCES "public"."versions"("id") ON DELETE CASCADE;
+ALTER TABLE "public"."scheduled_activations" ADD FOREIGN KEY ("created_by") REFERENCES "public"."users"("id") ON DELETE SET NULL;
+ALTER TABLE "public"."sessions" ADD FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE;
+ALTER TABLE "public"."api_keys" ADD FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE CASCADE;
 
 CREATE UNIQUE INDEX users_hash_key ON public.users USING btree (hash);
+CREATE UNIQUE INDEX sessions_key_hash_key ON public.sessions USING btree (key_hash);
+CREATE INDEX idx_sessions_previous_key_hash ON public.sessions USING btree (previous_key_hash);
+CREATE UNIQUE INDEX api_keys_key_hash_key ON public.api_keys USING btree (key_hash);
 
 -- Query performance indexes for listing and filtering endpoints
 CREATE INDEX IF NOT EXISTS idx_villains_version ON public.villains USING btree (version);
@@ -173,4 +390,13 @@ CREATE INDEX IF NOT EXISTS idx_villains_in_stories_story ON public.villains_in_s
 CREATE INDEX IF NOT EXISTS idx_stories_in_publications_story ON public.stories_in_publications USING btree (story);
 CREATE INDEX IF NOT EXISTS idx_stories_in_publications_publication ON public.stories_in_publications USING btree (publication);
 CREATE INDEX IF NOT EXISTS idx_publications_type ON public.publications USING btree (type);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON public.import_jobs USING btree (status);
+CREATE INDEX IF NOT EXISTS idx_version_activations_activated_at ON public.version_activations USING btree (activated_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_activations_pending ON public.scheduled_activations USING btree (activate_at) WHERE status = 'pending';
 CREATE INDEX IF NOT EXISTS idx_authors_in_stories_story ON public.authors_in_stories USING btree (story);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON public.sessions USING btree (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON public.sessions USING btree (expires_at);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON public.api_keys USING btree (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON public.audit_log USING btree (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_hash ON public.audit_log USING btree (actor_hash);
+CREATE INDEX IF NOT EXISTS idx_rate_limits_updated_at ON public.rate_limits USING btree (updated_at);
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+type sessionRepo struct{}
+
+const sessionColumns = "id, user_id, key_hash, user_agent, created_at, refreshed_at, expires_at"
+
+var createSessionSQL = fmt.Sprintf(`
+INSERT INTO sessions (user_id, key_hash, user_agent, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING %s;
+`, sessionColumns)
+
+// matchSessionKey matches the current key of a session, or the key the last
+// refresh replaced while its grace period of $2 seconds lasts.
+const matchSessionKey = `(key_hash = $1 OR (previous_key_hash = $1 AND refreshed_at > now() - make_interval(secs => $2)))`
+
+var readSessionByKeySQL = fmt.Sprintf(`
+SELECT %s FROM sessions WHERE %s AND expires_at > now() LIMIT 1;
+`, sessionColumns, matchSessionKey)
+
+var listUserSessionsSQL = fmt.Sprintf(`
+SELECT %s FROM sessions WHERE user_id = $1 AND expires_at > now() ORDER BY created_at DESC;
+`, sessionColumns)
+
+const extendSessionSQL = `
+UPDATE sessions
+SET previous_key_hash = key_hash, key_hash = $3, refreshed_at = now(), expires_at = $4
+WHERE id = $1 AND key_hash = $2 AND expires_at > now();
+`
+
+var removeSessionSQL = fmt.Sprintf(`
+DELETE FROM sessions WHERE %s;
+`, matchSessionKey)
+
+const removeUserSessionsSQL = `
+DELETE FROM sessions WHERE user_id = $1;
+`
+
+const removeExpiredSessionsSQL = `
+DELETE FROM sessions WHERE expires_at <= now();
+`
+
+func scanSession(row rowScanner) (*Session, error) {
+	var session Session
+	if err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.KeyHash,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.RefreshedAt,
+		&session.ExpiresAt,
+	); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Create implements SessionRepository.
+func (*sessionRepo) Create(session Session) (*Session, error) {
+	rows, err := Query(createSessionSQL, session.UserID, session.KeyHash, session.UserAgent, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, fmt.Errorf("failed to create session")
+	}
+
+	return scanSession(rows)
+}
+
+// ReadByKey implements SessionRepository. Expired and revoked sessions are
+// returned as nil. A key replaced by a refresh less than
+// config.SessionKeyGracePeriod ago still finds its session; the returned
+// session then carries the current key hash.
+func (*sessionRepo) ReadByKey(keyHash string) (*Session, error) {
+	rows, err := Query(readSessionByKeySQL, keyHash, keyGraceSeconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	return scanSession(rows)
+}
+
+// Extend implements SessionRepository. The session key is replaced in the
+// same statement, so tokens of the old key stop working once the grace
+// period of config.SessionKeyGracePeriod has passed. It returns false
+// when the session no longer has keyHash, e.g. because a concurrent refresh
+// already rotated it or the session was revoked.
+func (*sessionRepo) Extend(sessionID int, keyHash string, newKeyHash string, expiresAt time.Time) (bool, error) {
+	result, err := Execute(extendSessionSQL, sessionID, keyHash, newKeyHash, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated == 1, err
+}
+
+// Remove implements SessionRepository. Removing an unknown session is not an
+// error.
+func (*sessionRepo) Remove(keyHash string) error {
+	_, err := Execute(removeSessionSQL, keyHash, keyGraceSeconds())
+	return err
+}
+
+func keyGraceSeconds() float64 {
+	return max(config.SessionKeyGracePeriod, 0).Seconds()
+}
+
+// ListByUser implements SessionRepository. Newest sessions come first.
+func (*sessionRepo) ListByUser(userID int) ([]*Session, error) {
+	rows, err := Query(listUserSessionsSQL, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RemoveByUser implements SessionRepository. It returns the number of
+// sessions removed.
+func (*sessionRepo) RemoveByUser(userID int) (int, error) {
+	return removeSessions(removeUserSessionsSQL, userID)
+}
+
+// RemoveExpired implements SessionRepository. It returns the number of
+// sessions removed.
+func (*sessionRepo) RemoveExpired() (int, error) {
+	return removeSessions(removeExpiredSessionsSQL)
+}
+
+func removeSessions(query string, args ...interface{}) (int, error) {
+	result, err := Execute(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	return int(removed), err
+}
+
+func NewSessionRepository() SessionRepository {
+	return &sessionRepo{}
+}
@@ -287,6 +287,112 @@ func (s *storyRepo) ListByAuthorHash(version *Version, authorHash string, author
 	return stories, true, nil
 }
 
+const selectStoriesByAuthorIDsSQL = `
+SELECT DISTINCT
+	sa.author,
+	s.id,
+	s.hash,
+	s.order_num
+FROM authors_in_stories AS sa
+JOIN stories AS s ON s.id = sa.story
+WHERE sa.author = ANY($1)
+ORDER BY s.order_num ASC NULLS LAST, s.id ASC;
+`
+
+// ListByAuthorIDs implements StoryRepository. Stories are returned grouped by author ID.
+func (s *storyRepo) ListByAuthorIDs(authorIDs []int) (map[int][]*Story, error) {
+	storiesByAuthor := make(map[int][]*Story)
+	if len(authorIDs) == 0 {
+		return storiesByAuthor, nil
+	}
+
+	rows, err := Query(selectStoriesByAuthorIDsSQL, ArrayParam(authorIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	storyByID := make(map[int]*Story)
+	var stories []*Story
+	var storyIDs []int
+
+	for rows.Next() {
+		var authorID int
+		var story Story
+		var orderNum sql.NullInt64
+		if err = rows.Scan(&authorID, &story.ID, &story.Hash, &orderNum); err != nil {
+			return nil, err
+		}
+		if orderNum.Valid {
+			story.OrderNumber = int(orderNum.Int64)
+		}
+
+		existing := storyByID[story.ID]
+		if existing == nil {
+			existing = &story
+			storyByID[story.ID] = existing
+			stories = append(stories, existing)
+			storyIDs = append(storyIDs, existing.ID)
+		}
+		storiesByAuthor[authorID] = append(storiesByAuthor[authorID], existing)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stories) > 0 {
+		if err = s.hydrateStories(stories, storyIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return storiesByAuthor, nil
+}
+
+const selectStoryByHashSQL = `
+SELECT
+	s.id,
+	s.hash,
+	s.order_num
+FROM stories AS s
+WHERE
+	s.version = $1
+	AND s.hash = $2
+LIMIT 1;
+`
+
+// ReadByHash implements StoryRepository. Returns nil when the story does not exist.
+func (s *storyRepo) ReadByHash(version *Version, storyHash string) (*Story, error) {
+	if version == nil || version.ID == 0 {
+		return nil, fmt.Errorf("invalid version")
+	}
+
+	rows, err := Query(selectStoryByHashSQL, version.ID, strings.TrimSpace(storyHash))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	var story Story
+	var orderNum sql.NullInt64
+	if err = rows.Scan(&story.ID, &story.Hash, &orderNum); err != nil {
+		return nil, err
+	}
+	if orderNum.Valid {
+		story.OrderNumber = int(orderNum.Int64)
+	}
+
+	if err = s.hydrateStories([]*Story{&story}, []int{story.ID}); err != nil {
+		return nil, err
+	}
+	return &story, nil
+}
+
 // ListFiltered implements StoryRepository.
 func (s *storyRepo) ListFiltered(version *Version, params StoryListParams) ([]*Story, int, error) {
 	stories, storyIDs, total, err := s.selectStoryRowsFiltered(version, params)
@@ -2,22 +2,75 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ErrLastUserAdmin is returned when a change would leave no user with the
+// user-admin role, so that nobody could manage roles any more.
+var ErrLastUserAdmin = errors.New("cannot remove the last user admin")
+
 type userRepo struct{}
 
-// Create implements UserRepository.
+// Roles are kept sorted in enum order, so reading them needs no ORDER BY.
+const userColumns = "id, created_at, hash, roles::text[], COALESCE(email, ''), COALESCE(email_iv, '')"
+
+var createUserSQL = fmt.Sprintf(`
+INSERT INTO users (hash, roles, email, email_iv)
+VALUES ($1, ARRAY(SELECT DISTINCT unnest(COALESCE($2::user_role[], '{}')) ORDER BY 1), NULLIF($3, ''), NULLIF($4, ''))
+ON CONFLICT (hash) DO UPDATE
+SET roles = ARRAY(SELECT DISTINCT unnest(users.roles || EXCLUDED.roles) ORDER BY 1),
+	email = EXCLUDED.email,
+	email_iv = EXCLUDED.email_iv
+RETURNING %s;
+`, userColumns)
+
+var readUserByHashSQL = fmt.Sprintf(`
+SELECT %s FROM users WHERE hash = $1 LIMIT 1;
+`, userColumns)
+
+var setUserRolesSQL = fmt.Sprintf(`
+UPDATE users
+SET roles = ARRAY(SELECT DISTINCT unnest(COALESCE($2::user_role[], '{}')) ORDER BY 1)
+WHERE hash = $1
+RETURNING %s;
+`, userColumns)
+
+// lockUserAdminsSQL serialises role changes and removals that could leave
+// no user admin behind.
+const lockUserAdminsSQL = `
+SELECT hash FROM users WHERE 'user-admin' = ANY(roles) FOR UPDATE;
+`
+
+const removeUserSQL = `
+DELETE FROM users WHERE hash = $1;
+`
+
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	if err := row.Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Hash,
+		ArrayParam(&user.Roles),
+		&user.EmailContent,
+		&user.EmailIv,
+	); err != nil {
+		return nil, err
+	}
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+	user.IsAdmin = len(user.Roles) > 0
+	return &user, nil
+}
+
+// Create implements UserRepository. Roles of an existing user are merged
+// with the given ones, never removed. The stored email is replaced, so an
+// empty one clears it.
 func (*userRepo) Create(user User) (*User, error) {
-	rows, err := Query(
-		`INSERT INTO users (hash, is_admin)
-		 VALUES ($1, $2)
-		 ON CONFLICT (hash) DO UPDATE
-		 SET is_admin = users.is_admin OR EXCLUDED.is_admin
-		 RETURNING id, created_at, hash, is_admin;`,
-		user.Hash,
-		user.IsAdmin,
-	)
+	rows, err := Query(createUserSQL, user.Hash, ArrayParam(user.Roles), user.EmailContent, user.EmailIv)
 	if err != nil {
 		return nil, err
 	}
@@ -27,41 +80,121 @@ func (*userRepo) Create(user User) (*User, error) {
 		return nil, fmt.Errorf("failed to create or update user")
 	}
 
-	var created User
-	if err = rows.Scan(&created.ID, &created.CreatedAt, &created.Hash, &created.IsAdmin); err != nil {
-		return nil, err
+	return scanUser(rows)
+}
+
+func buildUserListWhere(params UserListParams) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+
+	if params.Hash != "" {
+		args = append(args, params.Hash)
+		conditions = append(conditions, fmt.Sprintf("hash = $%d", len(args)))
+	}
+	if params.Search != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(params.Search)
+		args = append(args, escaped+"%")
+		conditions = append(conditions, fmt.Sprintf("hash LIKE $%d", len(args)))
+	}
+	if params.Role != "" {
+		args = append(args, params.Role)
+		conditions = append(conditions, fmt.Sprintf("$%d::user_role = ANY(roles)", len(args)))
 	}
 
-	return &created, nil
+	return strings.Join(conditions, " AND "), args
 }
 
 // List implements UserRepository.
-func (*userRepo) List(pageIndex int) ([]*User, *ListMeta, error) {
-	rows, err := Query("SELECT id, created_at, hash, is_admin FROM users ORDER BY created_at ASC;")
+func (*userRepo) List(params UserListParams) ([]*User, *ListMeta, error) {
+	if params.Page <= 0 || params.PageSize <= 0 {
+		return nil, nil, fmt.Errorf("invalid parameters")
+	}
+
+	whereClause, whereArgs := buildUserListWhere(params)
+	meta := &ListMeta{PageIndex: params.Page - 1, PageSize: params.PageSize}
+
+	countRows, err := Query(fmt.Sprintf("SELECT COUNT(*) FROM users WHERE %s;", whereClause), whereArgs...)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer rows.Close()
+	defer countRows.Close()
+
+	if countRows.Next() {
+		if err = countRows.Scan(&meta.Total); err != nil {
+			return nil, nil, err
+		}
+	}
+	if meta.Total == 0 {
+		return []*User{}, meta, nil
+	}
+
+	querySQL := fmt.Sprintf(`
+SELECT %s
+FROM users
+WHERE %s
+ORDER BY created_at ASC, id ASC
+LIMIT $%d OFFSET $%d;
+`, userColumns, whereClause, len(whereArgs)+1, len(whereArgs)+2)
 
-	var users []*User
+	args := append(whereArgs, params.PageSize, meta.PageIndex*params.PageSize)
+	rows, err := Query(querySQL, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
 
+	users := []*User{}
 	for rows.Next() {
-		var u User
-		if err = rows.Scan(&u.ID, &u.CreatedAt, &u.Hash, &u.IsAdmin); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, nil, err
 		}
-		users = append(users, &u)
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return users, meta, nil
+}
+
+var listUsersWithEmailSQL = fmt.Sprintf(`
+SELECT %s FROM users WHERE email IS NOT NULL ORDER BY id ASC;
+`, userColumns)
+
+const setUserEmailSQL = `
+UPDATE users SET email = $2, email_iv = $3 WHERE hash = $1;
+`
+
+// ListWithEmail implements UserRepository. It returns the users with a
+// stored email, for re-encrypting them with a new key.
+func (*userRepo) ListWithEmail() ([]*User, error) {
+	rows, err := Query(listUsersWithEmailSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
 	}
+	return users, rows.Err()
+}
 
-	return users, nil, nil
+// SetEmail implements UserRepository.
+func (*userRepo) SetEmail(userHash string, emailContent string, emailIv string) error {
+	_, err := Execute(setUserEmailSQL, userHash, emailContent, emailIv)
+	return err
 }
 
 // ReadByHash implements UserRepository.
 func (*userRepo) ReadByHash(userHash string) (*User, error) {
-	rows, err := Query(
-		"SELECT id, created_at, hash, is_admin FROM users WHERE hash = $1 LIMIT 1;",
-		userHash,
-	)
+	rows, err := Query(readUserByHashSQL, userHash)
 	if err != nil {
 		return nil, err
 	}
@@ -71,44 +204,82 @@ func (*userRepo) ReadByHash(userHash string) (*User, error) {
 		return nil, nil
 	}
 
-	var user User
-	if err = rows.Scan(&user.ID, &user.CreatedAt, &user.Hash, &user.IsAdmin); err != nil {
-		return nil, err
-	}
-
-	return &user, nil
+	return scanUser(rows)
 }
 
-// Remove implements UserRepository.
+// Remove implements UserRepository. The last user admin cannot be removed.
 func (*userRepo) Remove(userHash string) error {
-	_, err := Execute("DELETE FROM users WHERE hash = $1;", userHash)
-	return err
+	txn, err := StartTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Rollback()
+
+	if err := checkLastUserAdmin(txn, userHash, false); err != nil {
+		return err
+	}
+	if _, err := txn.Exec(removeUserSQL, userHash); err != nil {
+		return err
+	}
+	return txn.Commit()
 }
 
-// SetAdmin implements UserRepository.
-func (*userRepo) SetAdmin(userHash string) (*User, error) {
-	rows, err := Query(
-		`UPDATE users
-		 SET is_admin = true
-		 WHERE hash = $1
-		 RETURNING id, created_at, hash, is_admin;`,
-		userHash,
-	)
+// SetRoles implements UserRepository. It replaces the roles of the user, so
+// it both grants and revokes. Revoking user-admin from the last user admin
+// returns ErrLastUserAdmin.
+func (*userRepo) SetRoles(userHash string, roles []string) (*User, error) {
+	txn, err := StartTransaction()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer txn.Rollback()
 
-	if !rows.Next() {
-		return nil, sql.ErrNoRows
+	keepsUserAdmin := false
+	for _, role := range roles {
+		keepsUserAdmin = keepsUserAdmin || role == RoleUserAdmin
+	}
+	if err := checkLastUserAdmin(txn, userHash, keepsUserAdmin); err != nil {
+		return nil, err
 	}
 
-	var updated User
-	if err = rows.Scan(&updated.ID, &updated.CreatedAt, &updated.Hash, &updated.IsAdmin); err != nil {
+	user, err := scanUser(txn.QueryRow(setUserRolesSQL, userHash, ArrayParam(roles)))
+	if err != nil {
 		return nil, err
 	}
+	return user, txn.Commit()
+}
+
+// checkLastUserAdmin locks the user admins and fails if userHash is the only
+// one and is about to lose the role.
+func checkLastUserAdmin(txn *sql.Tx, userHash string, keepsUserAdmin bool) error {
+	rows, err := txn.Query(lockUserAdminsSQL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var userAdmins []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return err
+		}
+		userAdmins = append(userAdmins, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if removesLastUserAdmin(userAdmins, userHash, keepsUserAdmin) {
+		return ErrLastUserAdmin
+	}
+	return nil
+}
 
-	return &updated, nil
+// removesLastUserAdmin reports whether userHash is the only user admin and
+// would lose the role.
+func removesLastUserAdmin(userAdmins []string, userHash string, keepsUserAdmin bool) bool {
+	return !keepsUserAdmin && len(userAdmins) == 1 && userAdmins[0] == userHash
 }
 
 func NewUserRepository() UserRepository {
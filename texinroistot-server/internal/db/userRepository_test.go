@@ -0,0 +1,40 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemovesLastUserAdmin(t *testing.T) {
+	tests := []struct {
+		name           string
+		userAdmins     []string
+		userHash       string
+		keepsUserAdmin bool
+		want           bool
+	}{
+		{name: "last user admin loses role", userAdmins: []string{"a"}, userHash: "a", want: true},
+		{name: "last user admin keeps role", userAdmins: []string{"a"}, userHash: "a", keepsUserAdmin: true},
+		{name: "another user admin remains", userAdmins: []string{"a", "b"}, userHash: "a"},
+		{name: "not a user admin", userAdmins: []string{"a"}, userHash: "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removesLastUserAdmin(tt.userAdmins, tt.userHash, tt.keepsUserAdmin); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildUserListWhere(t *testing.T) {
+	where, args := buildUserListWhere(UserListParams{Search: "ab_%", Role: RoleEditor})
+
+	if want := "TRUE AND hash LIKE $1 AND $2::user_role = ANY(roles)"; where != want {
+		t.Fatalf("expected where %q, got %q", want, where)
+	}
+	if want := []interface{}{`ab\_\%%`, RoleEditor}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("expected args %v, got %v", want, args)
+	}
+}
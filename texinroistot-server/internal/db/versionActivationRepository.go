@@ -0,0 +1,413 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNoPreviousVersion             = errors.New("no previously active version")
+	ErrScheduledActivationNotFound   = errors.New("scheduled activation not found")
+	ErrScheduledActivationNotPending = errors.New("scheduled activation is not pending")
+)
+
+type versionActivationRepo struct{}
+
+const listVersionActivationsSQL = `
+SELECT
+	va.id,
+	va.version,
+	va.previous_version,
+	COALESCE(u.hash, ''),
+	va.reason,
+	va.forced,
+	va.activated_at
+FROM version_activations va
+LEFT JOIN users u ON u.id = va.activated_by
+ORDER BY va.activated_at DESC, va.id DESC
+LIMIT $1;
+`
+
+const listUserVersionActivationsSQL = `
+SELECT
+	va.id,
+	va.version,
+	va.previous_version,
+	COALESCE(u.hash, ''),
+	va.reason,
+	va.forced,
+	va.activated_at
+FROM version_activations va
+LEFT JOIN users u ON u.id = va.activated_by
+WHERE va.activated_by = $1
+ORDER BY va.activated_at DESC, va.id DESC;
+`
+
+// History implements VersionActivationRepository. The latest activation
+// comes first.
+func (*versionActivationRepo) History(limit int) ([]*VersionActivation, error) {
+	return queryVersionActivations(listVersionActivationsSQL, limit)
+}
+
+// HistoryBy implements VersionActivationRepository. It lists the
+// activations made by the user, latest first.
+func (*versionActivationRepo) HistoryBy(userID int) ([]*VersionActivation, error) {
+	return queryVersionActivations(listUserVersionActivationsSQL, userID)
+}
+
+func queryVersionActivations(query string, args ...interface{}) ([]*VersionActivation, error) {
+	rows, err := Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activations := []*VersionActivation{}
+	for rows.Next() {
+		var activation VersionActivation
+		var versionID, previousID sql.NullInt64
+		if err := rows.Scan(
+			&activation.ID,
+			&versionID,
+			&previousID,
+			&activation.ActivatedBy,
+			&activation.Reason,
+			&activation.Forced,
+			&activation.ActivatedAt,
+		); err != nil {
+			return nil, err
+		}
+		activation.VersionID = nullIntPtr(versionID)
+		activation.PreviousVersionID = nullIntPtr(previousID)
+		activations = append(activations, &activation)
+	}
+	return activations, rows.Err()
+}
+
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	id := int(value.Int64)
+	return &id
+}
+
+const listActivationStepsSQL = `
+SELECT version, reason
+FROM version_activations
+ORDER BY activated_at, id;
+`
+
+const readActiveVersionIDSQL = `
+SELECT id
+FROM versions
+WHERE is_active = true;
+`
+
+// activationStep is one row of the activation history. versionID is nil
+// once the version has been deleted.
+type activationStep struct {
+	versionID *int
+	reason    string
+}
+
+// Previous implements VersionActivationRepository. It returns
+// ErrNoPreviousVersion when there is nothing to roll back to.
+func (*versionActivationRepo) Previous() (int, error) {
+	db, err := GetDB()
+	if err != nil {
+		return 0, err
+	}
+
+	var activeID int
+	err = db.QueryRow(readActiveVersionIDSQL).Scan(&activeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoPreviousVersion
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.Query(listActivationStepsSQL)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var steps []activationStep
+	for rows.Next() {
+		var step activationStep
+		var versionID sql.NullInt64
+		if err := rows.Scan(&versionID, &step.reason); err != nil {
+			return 0, err
+		}
+		step.versionID = nullIntPtr(versionID)
+		steps = append(steps, step)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	previousID, ok := rollbackTarget(steps, activeID)
+	if !ok {
+		return 0, ErrNoPreviousVersion
+	}
+	return previousID, nil
+}
+
+// rollbackTarget replays the activation history, oldest first, as a stack:
+// an activation pushes its version and a rollback pops back to the version
+// it reactivated. The target is the closest version below the active one
+// that still exists, so repeated rollbacks keep walking back in history
+// instead of toggling between the last two versions.
+func rollbackTarget(steps []activationStep, activeID int) (int, bool) {
+	var stack []*int
+	for _, step := range steps {
+		if step.reason == ActivationRollback && len(stack) > 0 {
+			stack = stack[:len(stack)-1]
+			for len(stack) > 0 && stack[len(stack)-1] == nil {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) > 0 && sameVersion(stack[len(stack)-1], step.versionID) {
+				continue
+			}
+		}
+		stack = append(stack, step.versionID)
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] != nil && *stack[i] != activeID {
+			return *stack[i], true
+		}
+	}
+	return 0, false
+}
+
+func sameVersion(a *int, b *int) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+const scheduledActivationColumns = `
+	sa.id,
+	sa.version,
+	sa.activate_at,
+	sa.force,
+	sa.status,
+	COALESCE(sa.error, ''),
+	COALESCE(sa.created_by, 0),
+	COALESCE(u.hash, ''),
+	sa.created_at,
+	sa.processed_at
+`
+
+func scanScheduledActivation(row rowScanner) (*ScheduledActivation, error) {
+	var activation ScheduledActivation
+	var processedAt sql.NullTime
+	if err := row.Scan(
+		&activation.ID,
+		&activation.VersionID,
+		&activation.ActivateAt,
+		&activation.Force,
+		&activation.Status,
+		&activation.Error,
+		&activation.CreatedByID,
+		&activation.CreatedBy,
+		&activation.CreatedAt,
+		&processedAt,
+	); err != nil {
+		return nil, err
+	}
+	activation.ProcessedAt = nullTimePtr(processedAt)
+	return &activation, nil
+}
+
+var scheduleActivationSQL = fmt.Sprintf(`
+WITH sa AS (
+	INSERT INTO scheduled_activations (version, activate_at, force, created_by)
+	VALUES ($1, $2, $3, NULLIF($4, 0))
+	RETURNING *
+)
+SELECT %s
+FROM sa
+LEFT JOIN users u ON u.id = sa.created_by;
+`, scheduledActivationColumns)
+
+// Schedule implements VersionActivationRepository.
+func (*versionActivationRepo) Schedule(
+	versionID int,
+	activateAt time.Time,
+	force bool,
+	userID int,
+) (*ScheduledActivation, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanScheduledActivation(db.QueryRow(scheduleActivationSQL, versionID, activateAt, force, userID))
+}
+
+var listScheduledActivationsSQL = fmt.Sprintf(`
+SELECT %s
+FROM scheduled_activations sa
+LEFT JOIN users u ON u.id = sa.created_by
+WHERE sa.status IN ('pending', 'running')
+ORDER BY sa.activate_at, sa.id;
+`, scheduledActivationColumns)
+
+// ListScheduled implements VersionActivationRepository. Only activations
+// that have not been processed yet are listed.
+func (*versionActivationRepo) ListScheduled() ([]*ScheduledActivation, error) {
+	return queryScheduledActivations(listScheduledActivationsSQL)
+}
+
+var listUserScheduledActivationsSQL = fmt.Sprintf(`
+SELECT %s
+FROM scheduled_activations sa
+LEFT JOIN users u ON u.id = sa.created_by
+WHERE sa.created_by = $1
+ORDER BY sa.created_at DESC, sa.id DESC;
+`, scheduledActivationColumns)
+
+// ListScheduledBy implements VersionActivationRepository. It lists every
+// activation the user has scheduled, processed or not, newest first.
+func (*versionActivationRepo) ListScheduledBy(userID int) ([]*ScheduledActivation, error) {
+	return queryScheduledActivations(listUserScheduledActivationsSQL, userID)
+}
+
+const cancelScheduledActivationSQL = `
+UPDATE scheduled_activations
+SET status = 'cancelled', processed_at = now()
+WHERE id = $1
+AND status = 'pending';
+`
+
+const readScheduledActivationStatusSQL = `
+SELECT status
+FROM scheduled_activations
+WHERE id = $1;
+`
+
+// CancelScheduled implements VersionActivationRepository. Only pending
+// activations can be cancelled.
+func (*versionActivationRepo) CancelScheduled(scheduleID int) error {
+	result, err := Execute(cancelScheduledActivationSQL, scheduleID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return err
+	}
+	var status string
+	err = db.QueryRow(readScheduledActivationStatusSQL, scheduleID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrScheduledActivationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrScheduledActivationNotPending
+}
+
+// Claiming flips due rows to running in one statement, so each scheduled
+// activation is handled by exactly one replica.
+var claimDueActivationsSQL = fmt.Sprintf(`
+WITH sa AS (
+	UPDATE scheduled_activations
+	SET status = 'running'
+	WHERE status = 'pending'
+	AND activate_at <= now()
+	RETURNING *
+)
+SELECT %s
+FROM sa
+LEFT JOIN users u ON u.id = sa.created_by
+ORDER BY sa.activate_at, sa.id;
+`, scheduledActivationColumns)
+
+// ClaimDue implements VersionActivationRepository.
+func (*versionActivationRepo) ClaimDue() ([]*ScheduledActivation, error) {
+	return queryScheduledActivations(claimDueActivationsSQL)
+}
+
+const finishScheduledActivationSQL = `
+UPDATE scheduled_activations
+SET status = $2, error = NULLIF($3, ''), processed_at = now()
+WHERE id = $1;
+`
+
+// FinishScheduled implements VersionActivationRepository. An empty failure
+// marks the activation done.
+func (*versionActivationRepo) FinishScheduled(scheduleID int, failure string) error {
+	status := ScheduledActivationDone
+	if failure != "" {
+		status = ScheduledActivationFailed
+	}
+
+	result, err := Execute(finishScheduledActivationSQL, scheduleID, status, failure)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrScheduledActivationNotFound
+	}
+	return nil
+}
+
+const failRunningScheduledActivationsSQL = `
+UPDATE scheduled_activations
+SET status = 'failed', error = $1, processed_at = now()
+WHERE status = 'running';
+`
+
+// FailRunning implements VersionActivationRepository. It marks activations
+// left running by a stopped process as failed and returns how many there
+// were.
+func (*versionActivationRepo) FailRunning(failure string) (int, error) {
+	result, err := Execute(failRunningScheduledActivationsSQL, failure)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(rowsAffected), nil
+}
+
+func queryScheduledActivations(query string, args ...interface{}) ([]*ScheduledActivation, error) {
+	rows, err := Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activations := []*ScheduledActivation{}
+	for rows.Next() {
+		activation, err := scanScheduledActivation(rows)
+		if err != nil {
+			return nil, err
+		}
+		activations = append(activations, activation)
+	}
+	return activations, rows.Err()
+}
+
+func NewVersionActivationRepository() VersionActivationRepository {
+	return &versionActivationRepo{}
+}
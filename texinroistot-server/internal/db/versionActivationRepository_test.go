@@ -0,0 +1,68 @@
+package db
+
+import "testing"
+
+func activationSteps(steps ...interface{}) []activationStep {
+	var result []activationStep
+	for i := 0; i+1 < len(steps); i += 2 {
+		step := activationStep{reason: steps[i+1].(string)}
+		if id, ok := steps[i].(int); ok {
+			step.versionID = &id
+		}
+		result = append(result, step)
+	}
+	return result
+}
+
+func TestRollbackTarget(t *testing.T) {
+	cases := map[string]struct {
+		steps    []activationStep
+		activeID int
+		want     int
+		ok       bool
+	}{
+		"first activation": {
+			steps:    activationSteps(1, ActivationImport),
+			activeID: 1,
+		},
+		"previous activation": {
+			steps:    activationSteps(1, ActivationImport, 2, ActivationManual),
+			activeID: 2,
+			want:     1,
+			ok:       true,
+		},
+		"repeated rollbacks walk back": {
+			steps:    activationSteps(1, ActivationImport, 2, ActivationManual, 3, ActivationScheduled, 2, ActivationRollback),
+			activeID: 2,
+			want:     1,
+			ok:       true,
+		},
+		"nothing left after rolling back to the first version": {
+			steps:    activationSteps(1, ActivationImport, 2, ActivationManual, 1, ActivationRollback),
+			activeID: 1,
+		},
+		"reactivated version": {
+			steps:    activationSteps(1, ActivationImport, 2, ActivationManual, 1, ActivationManual),
+			activeID: 1,
+			want:     2,
+			ok:       true,
+		},
+		"deleted versions are skipped": {
+			steps:    activationSteps(1, ActivationImport, nil, ActivationManual, 3, ActivationManual),
+			activeID: 3,
+			want:     1,
+			ok:       true,
+		},
+		"rollback past a deleted version": {
+			steps:    activationSteps(1, ActivationImport, nil, ActivationManual, 3, ActivationManual, 1, ActivationRollback),
+			activeID: 1,
+		},
+	}
+
+	for name, tc := range cases {
+		got, ok := rollbackTarget(tc.steps, tc.activeID)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("%s: expected %d %v, got %d %v", name, tc.want, tc.ok, got, ok)
+		}
+	}
+}
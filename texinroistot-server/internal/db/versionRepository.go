@@ -2,17 +2,65 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 )
 
 var (
 	ErrVersionNotFound           = errors.New("version not found")
+	ErrNoActiveVersion           = errors.New("no active version")
 	ErrCannotDeleteActiveVersion = errors.New("cannot delete active version")
 )
 
 type versionRepo struct{}
 
+// versionColumns also works in RETURNING clauses; the importing admin is
+// looked up with a subquery for that reason.
+const versionColumns = `
+	id,
+	created_at,
+	is_active,
+	is_pinned,
+	label,
+	notes,
+	COALESCE(imported_by, 0),
+	COALESCE((SELECT hash FROM users WHERE users.id = versions.imported_by), ''),
+	COALESCE(source_url, ''),
+	COALESCE(file_name, ''),
+	COALESCE(content_hash, ''),
+	COALESCE(importer_revision, ''),
+	rows,
+	counts
+`
+
+func scanVersion(row rowScanner) (*Version, error) {
+	var v Version
+	var counts []byte
+	if err := row.Scan(
+		&v.ID,
+		&v.CreatedAt,
+		&v.IsActive,
+		&v.IsPinned,
+		&v.Label,
+		&v.Notes,
+		&v.ImportedByID,
+		&v.ImportedBy,
+		&v.SourceURL,
+		&v.FileName,
+		&v.ContentHash,
+		&v.ImporterRevision,
+		&v.Rows,
+		&counts,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(counts, &v.Counts); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 const readVersionIDSQL = `
 SELECT id
 FROM versions
@@ -32,37 +80,128 @@ SET is_active = true
 WHERE id = $1;
 `
 
-// SetActive implements VersionRepository.
-func (*versionRepo) SetActive(versionID int) error {
+// activationLockKey identifies the transaction-level advisory lock that
+// serializes activations. Locking the active row alone is not enough: when
+// no version is active there is no row to lock.
+const activationLockKey int64 = 0x61637469_76617465
+
+const lockActivationSQL = `SELECT pg_advisory_xact_lock($1);`
+
+const lockActiveVersionSQL = `
+SELECT id
+FROM versions
+WHERE is_active = true
+FOR UPDATE;
+`
+
+const recordVersionActivationSQL = `
+INSERT INTO version_activations (version, previous_version, activated_by, reason, forced)
+VALUES ($1, $2, NULLIF($3, 0), $4, $5);
+`
+
+// SetActive implements VersionRepository. It runs ActivationChecks first and
+// returns ErrActivationChecksFailed with the report when the candidate has
+// issues, unless activation.Force is set. Every change of the active version
+// is recorded in version_activations.
+//
+// The checks run in the same transaction after the activation lock is taken,
+// so that they compare against the version that is actually replaced.
+func (v *versionRepo) SetActive(versionID int, activation Activation) (*ActivationReport, error) {
 	txn, err := StartTransaction()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer txn.Rollback()
 
+	if _, err := txn.Exec(lockActivationSQL, activationLockKey); err != nil {
+		return nil, err
+	}
+
 	var existingID int
 	if err := txn.QueryRow(readVersionIDSQL, versionID).Scan(&existingID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return ErrVersionNotFound
+			return nil, ErrVersionNotFound
 		}
-		return err
+		return nil, err
+	}
+
+	var previousID sql.NullInt64
+	if err := txn.QueryRow(lockActiveVersionSQL).Scan(&previousID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var activeID *int
+	if previousID.Valid {
+		id := int(previousID.Int64)
+		activeID = &id
+	}
+	report, err := checkActivation(txn, versionID, activeID)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Passed() {
+		if !activation.Force {
+			return report, ErrActivationChecksFailed
+		}
+		report.Forced = true
+	}
+
+	if activeID != nil && *activeID == versionID {
+		return report, txn.Commit()
 	}
 
 	if _, err := txn.Exec(clearOtherActiveVersionsSQL, versionID); err != nil {
-		return err
+		return nil, err
 	}
 	if _, err := txn.Exec(setVersionActiveSQL, versionID); err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := txn.Exec(
+		recordVersionActivationSQL,
+		versionID,
+		previousID,
+		activation.UserID,
+		activation.Reason,
+		report.Forced,
+	); err != nil {
+		return nil, err
 	}
 
-	return txn.Commit()
+	return report, txn.Commit()
 }
 
-const getActiveVersionSQL = `
-SELECT id, created_at, is_active
+// checkActivation compares the candidate to the active version, if any,
+// using version stats, hash overlap and per-version quality counts.
+func checkActivation(q querier, versionID int, activeID *int) (*ActivationReport, error) {
+	stats, err := readVersionStats(q, versionID)
+	if err != nil {
+		return nil, err
+	}
+	quality, err := readVersionQuality(q, versionID)
+	if err != nil {
+		return nil, err
+	}
+	input := &ActivationInput{Candidate: stats, Quality: quality}
+
+	if activeID != nil && *activeID != versionID {
+		if input.Active, err = readVersionStats(q, *activeID); err != nil {
+			return nil, err
+		}
+		if input.ActiveQuality, err = readVersionQuality(q, *activeID); err != nil {
+			return nil, err
+		}
+		if input.Diff, err = compareVersions(q, *activeID, versionID); err != nil {
+			return nil, err
+		}
+	}
+	return RunActivationChecks(versionID, activeID, input), nil
+}
+
+var getActiveVersionSQL = fmt.Sprintf(`
+SELECT %s
 FROM versions
 WHERE is_active = TRUE;
-`
+`, versionColumns)
 
 func (*versionRepo) GetActive() (*Version, error) {
 	rows, err := Query(getActiveVersionSQL)
@@ -71,18 +210,21 @@ func (*versionRepo) GetActive() (*Version, error) {
 	}
 	defer rows.Close()
 
-	var v Version
+	var v *Version
 	count := 0
 	for rows.Next() {
-		if err = rows.Scan(&v.ID, &v.CreatedAt, &v.IsActive); err != nil {
+		if v, err = scanVersion(rows); err != nil {
 			return nil, err
 		}
 		count++
 	}
+	if count == 0 {
+		return nil, ErrNoActiveVersion
+	}
 	if count != 1 {
 		return nil, fmt.Errorf("invalid number of active versions: %d", count)
 	}
-	return &v, nil
+	return v, nil
 }
 
 const getVersionStatsSQL = `
@@ -96,87 +238,182 @@ SELECT
 
 // GetStats implements VersionRepository.
 func (*versionRepo) GetStats(versionID int) (*VersionStats, error) {
-	rows, err := Query(getVersionStatsSQL, versionID)
+	db, err := GetDB()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return readVersionStats(db, versionID)
+}
 
+func readVersionStats(q querier, versionID int) (*VersionStats, error) {
 	var stats VersionStats
-	for rows.Next() {
-		if err = rows.Scan(
-			&stats.Villains,
-			&stats.Stories,
-			&stats.Drawers,
-			&stats.Writers,
-			&stats.Translators,
-		); err != nil {
-			return nil, err
-		}
-		return &stats, nil
+	if err := q.QueryRow(getVersionStatsSQL, versionID).Scan(
+		&stats.Villains,
+		&stats.Stories,
+		&stats.Drawers,
+		&stats.Writers,
+		&stats.Translators,
+	); err != nil {
+		return nil, err
 	}
-
 	return &stats, nil
 }
 
-const createVersionSQL = `INSERT INTO versions(is_active) VALUES(false);`
+const compareVersionsSQL = `
+SELECT
+	(SELECT COUNT(*) FROM villains WHERE version = $1),
+	(SELECT COUNT(*) FROM villains WHERE version = $2),
+	(SELECT COUNT(*) FROM villains t WHERE t.version = $2
+		AND NOT EXISTS (SELECT 1 FROM villains f WHERE f.version = $1 AND f.hash = t.hash)),
+	(SELECT COUNT(*) FROM villains f WHERE f.version = $1
+		AND NOT EXISTS (SELECT 1 FROM villains t WHERE t.version = $2 AND t.hash = f.hash)),
+	(SELECT COUNT(*) FROM stories WHERE version = $1),
+	(SELECT COUNT(*) FROM stories WHERE version = $2),
+	(SELECT COUNT(*) FROM stories t WHERE t.version = $2
+		AND NOT EXISTS (SELECT 1 FROM stories f WHERE f.version = $1 AND f.hash = t.hash)),
+	(SELECT COUNT(*) FROM stories f WHERE f.version = $1
+		AND NOT EXISTS (SELECT 1 FROM stories t WHERE t.version = $2 AND t.hash = f.hash));
+`
 
-// Create implements VersionRepository.
-func (v *versionRepo) Create(version Version) (*Version, error) {
-	_, err := Execute(createVersionSQL)
+// Compare implements VersionRepository. Villains and stories are matched by
+// hash, which stays the same across versions.
+func (*versionRepo) Compare(fromVersionID int, toVersionID int) (*VersionDiff, error) {
+	db, err := GetDB()
 	if err != nil {
 		return nil, err
 	}
+	return compareVersions(db, fromVersionID, toVersionID)
+}
 
-	versions, err := v.List()
+func compareVersions(q querier, fromVersionID int, toVersionID int) (*VersionDiff, error) {
+	diff := &VersionDiff{From: fromVersionID, To: toVersionID}
+	if err := q.QueryRow(compareVersionsSQL, fromVersionID, toVersionID).Scan(
+		&diff.Villains.Before,
+		&diff.Villains.After,
+		&diff.Villains.Added,
+		&diff.Villains.Removed,
+		&diff.Stories.Before,
+		&diff.Stories.After,
+		&diff.Stories.Added,
+		&diff.Stories.Removed,
+	); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+var createVersionSQL = fmt.Sprintf(`
+INSERT INTO versions(
+	is_active,
+	label,
+	imported_by,
+	source_url,
+	file_name,
+	content_hash,
+	importer_revision,
+	rows,
+	counts
+)
+VALUES(false, $1, NULLIF($2, 0), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8)
+RETURNING %s;
+`, versionColumns)
+
+// Create implements VersionRepository. New versions are always inactive;
+// the import metadata of version is stored with it.
+func (*versionRepo) Create(version Version) (*Version, error) {
+	counts, err := json.Marshal(version.Counts)
 	if err != nil {
 		return nil, err
 	}
-	lastVersion := versions[len(versions)-1]
 
-	return lastVersion, nil
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanVersion(db.QueryRow(
+		createVersionSQL,
+		version.Label,
+		version.ImportedByID,
+		version.SourceURL,
+		version.FileName,
+		version.ContentHash,
+		version.ImporterRevision,
+		version.Rows,
+		counts,
+	))
 }
 
-const readVersionSQL = `
-SELECT
-	id,
-	created_at,
-	is_active
+var readVersionSQL = fmt.Sprintf(`
+SELECT %s
 FROM versions
 WHERE id = $1;
-`
+`, versionColumns)
 
 // Read implements VersionRepository.
 func (*versionRepo) Read(versionID int) (*Version, error) {
-	rows, err := Query(readVersionSQL, versionID)
+	db, err := GetDB()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var v Version
-	for rows.Next() {
-		if err = rows.Scan(&v.ID, &v.CreatedAt, &v.IsActive); err != nil {
-			return nil, err
-		}
-		return &v, nil
+	version, err := scanVersion(db.QueryRow(readVersionSQL, versionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVersionNotFound
 	}
+	return version, err
+}
 
-	return nil, ErrVersionNotFound
+var updateVersionSQL = fmt.Sprintf(`
+UPDATE versions
+SET label = COALESCE($2, label), notes = COALESCE($3, notes)
+WHERE id = $1
+RETURNING %s;
+`, versionColumns)
+
+// Update implements VersionRepository.
+func (*versionRepo) Update(versionID int, update VersionUpdate) (*Version, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := scanVersion(db.QueryRow(updateVersionSQL, versionID, update.Label, update.Notes))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVersionNotFound
+	}
+	return version, err
 }
 
-const listVersionsSQL = `
-SELECT
-	id,
-	created_at,
-	is_active
+var listVersionsSQL = fmt.Sprintf(`
+SELECT %s
 FROM versions
 ORDER BY created_at;
-`
+`, versionColumns)
+
+var listVersionsImportedBySQL = fmt.Sprintf(`
+SELECT %s
+FROM versions
+WHERE imported_by = $1
+ORDER BY created_at;
+`, versionColumns)
 
 // List implements VersionRepository.
 func (*versionRepo) List() ([]*Version, error) {
-	rows, err := Query(listVersionsSQL)
+	return queryVersions(listVersionsSQL)
+}
+
+// ListImportedBy implements VersionRepository.
+func (*versionRepo) ListImportedBy(userID int) ([]*Version, error) {
+	versions, err := queryVersions(listVersionsImportedBySQL, userID)
+	if versions == nil && err == nil {
+		versions = []*Version{}
+	}
+	return versions, err
+}
+
+func queryVersions(query string, args ...interface{}) ([]*Version, error) {
+	rows, err := Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -185,14 +422,14 @@ func (*versionRepo) List() ([]*Version, error) {
 	var versions []*Version
 
 	for rows.Next() {
-		var v Version
-		if err = rows.Scan(&v.ID, &v.CreatedAt, &v.IsActive); err != nil {
+		v, err := scanVersion(rows)
+		if err != nil {
 			return nil, err
 		}
-		versions = append(versions, &v)
+		versions = append(versions, v)
 	}
 
-	return versions, nil
+	return versions, rows.Err()
 }
 
 const removeVersionSQL = `
@@ -242,6 +479,78 @@ func (*versionRepo) Remove(versionID int) error {
 	return txn.Commit()
 }
 
+const setVersionPinnedSQL = `
+UPDATE versions
+SET is_pinned = $2
+WHERE id = $1;
+`
+
+// SetPinned implements VersionRepository. Pinned versions are never pruned.
+func (*versionRepo) SetPinned(versionID int, pinned bool) error {
+	result, err := Execute(setVersionPinnedSQL, versionID, pinned)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrVersionNotFound
+	}
+	return nil
+}
+
+// Link tables have no version column, so their rows are attributed to the
+// version of the story or villain they belong to.
+const versionDiskUsageSQL = `
+WITH version_rows AS (
+	SELECT t.version, pg_column_size(t.*) AS bytes FROM authors t
+	UNION ALL SELECT t.version, pg_column_size(t.*) FROM stories t
+	UNION ALL SELECT t.version, pg_column_size(t.*) FROM publications t
+	UNION ALL SELECT t.version, pg_column_size(t.*) FROM villains t
+	UNION ALL SELECT s.version, pg_column_size(t.*) FROM authors_in_stories t JOIN stories s ON s.id = t.story
+	UNION ALL SELECT s.version, pg_column_size(t.*) FROM stories_in_publications t JOIN stories s ON s.id = t.story
+	UNION ALL SELECT v.version, pg_column_size(t.*) FROM villains_in_stories t JOIN villains v ON v.id = t.villain
+)
+SELECT versions.id, COUNT(version_rows.version), COALESCE(SUM(version_rows.bytes), 0)
+FROM versions
+LEFT JOIN version_rows ON version_rows.version = versions.id
+GROUP BY versions.id
+ORDER BY versions.id;
+`
+
+const databaseSizeSQL = `SELECT pg_database_size(current_database());`
+
+// DiskUsage implements VersionRepository. Version sizes count row data
+// only; indexes and dead tuples are included in DatabaseBytes alone.
+func (*versionRepo) DiskUsage() (*DiskUsageReport, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DiskUsageReport{Versions: []*VersionDiskUsage{}}
+	if err := db.QueryRow(databaseSizeSQL).Scan(&report.DatabaseBytes); err != nil {
+		return nil, err
+	}
+
+	rows, err := Query(versionDiskUsageSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var usage VersionDiskUsage
+		if err := rows.Scan(&usage.VersionID, &usage.Rows, &usage.Bytes); err != nil {
+			return nil, err
+		}
+		report.Versions = append(report.Versions, &usage)
+	}
+	return report, rows.Err()
+}
+
 func NewVersionRepository() VersionRepository {
 	return &versionRepo{}
 }
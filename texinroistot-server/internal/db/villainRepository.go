@@ -621,6 +621,79 @@ func (*villainRepo) ListByStoryHash(version *Version, storyHash string) ([]*Vill
 	return villains, storyFound, nil
 }
 
+const selectVillainsByStoryIDsSQL = `
+SELECT
+	vis.story,
+	v.id,
+	v.hash,
+	COALESCE(v.ranks, ARRAY[]::varchar[]),
+	COALESCE(v.first_names, ARRAY[]::varchar[]),
+	COALESCE(v.last_name, ''),
+	vis.id,
+	vis.hash,
+	COALESCE(vis.nicknames, ARRAY[]::varchar[]),
+	COALESCE(vis.other_names, ARRAY[]::varchar[]),
+	COALESCE(vis.code_names, ARRAY[]::varchar[]),
+	COALESCE(vis.roles, ARRAY[]::varchar[]),
+	COALESCE(vis.destiny, ARRAY[]::varchar[])
+FROM villains_in_stories AS vis
+JOIN villains AS v ON v.id = vis.villain
+WHERE vis.story = ANY($1)
+ORDER BY
+	vis.story ASC,
+	NULLIF(regexp_replace(lower(COALESCE(array_to_string(v.first_names, ' '), '') || COALESCE(v.last_name, '')), '[[:punct:][:space:]]+', '', 'g'), '') ASC NULLS LAST,
+	v.id ASC,
+	vis.id ASC;
+`
+
+// ListByStoryIDs returns villains grouped by story ID. Each villain only
+// carries the appearance in the story it is grouped under.
+func (*villainRepo) ListByStoryIDs(storyIDs []int) (map[int][]*Villain, error) {
+	villainsByStory := make(map[int][]*Villain)
+	if len(storyIDs) == 0 {
+		return villainsByStory, nil
+	}
+
+	rows, err := Query(selectVillainsByStoryIDsSQL, ArrayParam(storyIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var storyID int
+		var villain Villain
+		var storyVillain StoryVillain
+		if err = rows.Scan(
+			&storyID,
+			&villain.ID,
+			&villain.Hash,
+			ArrayParam(&villain.Ranks),
+			ArrayParam(&villain.FirstNames),
+			&villain.LastName,
+			&storyVillain.ID,
+			&storyVillain.Hash,
+			ArrayParam(&storyVillain.Nicknames),
+			ArrayParam(&storyVillain.OtherNames),
+			ArrayParam(&storyVillain.CodeNames),
+			ArrayParam(&storyVillain.Roles),
+			ArrayParam(&storyVillain.Destiny),
+		); err != nil {
+			return nil, err
+		}
+
+		storyVillain.Story = &Story{ID: storyID}
+		villain.As = []*StoryVillain{&storyVillain}
+		villainsByStory[storyID] = append(villainsByStory[storyID], &villain)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return villainsByStory, nil
+}
+
 // ListFiltered implements VillainRepository.
 func (v *villainRepo) ListFiltered(version *Version, params VillainListParams) ([]*Villain, int, error) {
 	villains, villainIDs, total, err := v.selectVillainRowsFiltered(version, params)
@@ -0,0 +1,73 @@
+package gql
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+)
+
+const maxQueryLength = 10000
+
+type RequestPayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+var (
+	schemaOnce sync.Once
+	schema     graphql.Schema
+	schemaErr  error
+)
+
+func getSchema() (graphql.Schema, error) {
+	schemaOnce.Do(func() {
+		schema, schemaErr = NewSchema()
+	})
+	return schema, schemaErr
+}
+
+// GraphQLHandler serves read-only catalog queries. Both GET (query string)
+// and POST (JSON body) requests are accepted.
+func GraphQLHandler(c *fiber.Ctx) error {
+	payload := new(RequestPayload)
+	if c.Method() == fiber.MethodGet {
+		payload.Query = c.Query("query")
+		payload.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &payload.Variables); err != nil {
+				return c.Status(400).JSON(api.Error("variables must be a JSON object"))
+			}
+		}
+	} else if err := c.BodyParser(payload); err != nil {
+		return c.Status(400).JSON(api.Error("invalid request body"))
+	}
+
+	if payload.Query == "" {
+		return c.Status(400).JSON(api.Error("query is required"))
+	}
+	if len(payload.Query) > maxQueryLength {
+		return c.Status(400).JSON(api.Error("query is too long"))
+	}
+
+	s, err := getSchema()
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to build schema"))
+	}
+	if err := checkQueryCost(s, payload.Query); err != nil {
+		return c.Status(400).JSON(api.Error(err.Error()))
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s,
+		RequestString:  payload.Query,
+		OperationName:  payload.OperationName,
+		VariableValues: payload.Variables,
+		Context:        withLoaders(c.UserContext(), newLoaders()),
+	})
+
+	return c.JSON(result)
+}
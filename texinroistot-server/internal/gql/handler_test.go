@@ -0,0 +1,45 @@
+package gql
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestGraphQLHandlerReadsVariablesFromQueryString(t *testing.T) {
+	app := fiber.New()
+	app.Get("/graphql", GraphQLHandler)
+
+	query := `query($sort: String) { stories(sort: $sort) { meta { total } } }`
+	tests := []struct {
+		name      string
+		variables string
+		status    int
+		want      string
+	}{
+		{name: "variables are passed to the query", variables: `{"sort":"unknown"}`, status: fiber.StatusOK, want: "sort is invalid"},
+		{name: "invalid variables", variables: `{"sort":`, status: fiber.StatusBadRequest, want: "variables must be a JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := url.Values{"query": {query}, "variables": {tt.variables}}
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/graphql?"+params.Encode(), nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !json.Valid(body) || !strings.Contains(string(body), tt.want) {
+				t.Fatalf("expected response to contain %q, got %s", tt.want, body)
+			}
+		})
+	}
+}
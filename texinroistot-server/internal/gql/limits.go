@@ -0,0 +1,161 @@
+package gql
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const (
+	// maxQueryDepth allows e.g. stories { items { villains { as { story { hash } } } } }.
+	maxQueryDepth = 8
+	// maxQueryComplexity is the number of fields a query may resolve, where
+	// each list is counted as listComplexityFactor items.
+	maxQueryComplexity   = 2000
+	listComplexityFactor = 10
+)
+
+var (
+	errQueryTooDeep    = errors.New("query is too deep")
+	errQueryTooComplex = errors.New("query is too complex")
+)
+
+// checkQueryCost refuses queries nested deeper than maxQueryDepth or
+// resolving more than maxQueryComplexity fields, before anything is
+// resolved. Queries that do not parse are left for graphql.Do to report.
+// Introspection fields are not counted, they never reach the database.
+func checkQueryCost(s graphql.Schema, query string) error {
+	document, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok && fragment.Name != nil {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	// Depth is checked first so that a deep query is reported as such even
+	// when it is also too complex.
+	for _, countComplexity := range []bool{false, true} {
+		cost := &queryCost{
+			schema:          s,
+			fragments:       fragments,
+			visiting:        map[string]bool{},
+			countComplexity: countComplexity,
+		}
+		for _, definition := range document.Definitions {
+			operation, ok := definition.(*ast.OperationDefinition)
+			if !ok {
+				continue
+			}
+			if err := cost.selectionSet(operation.SelectionSet, s.QueryType(), 1, 1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type queryCost struct {
+	schema          graphql.Schema
+	fragments       map[string]*ast.FragmentDefinition
+	visiting        map[string]bool
+	countComplexity bool
+	complexity      int
+}
+
+// selectionSet counts the fields of set, resolved multiplier times on
+// objects of parent at the given depth.
+func (q *queryCost) selectionSet(set *ast.SelectionSet, parent graphql.Type, depth int, multiplier int) error {
+	if set == nil {
+		return nil
+	}
+	if depth > maxQueryDepth {
+		return errQueryTooDeep
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if err := q.field(selection, parent, depth, multiplier); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			fragmentType := parent
+			if selection.TypeCondition != nil && selection.TypeCondition.Name != nil {
+				fragmentType = q.schema.Type(selection.TypeCondition.Name.Value)
+			}
+			if err := q.selectionSet(selection.SelectionSet, fragmentType, depth, multiplier); err != nil {
+				return err
+			}
+		case *ast.FragmentSpread:
+			if selection.Name == nil {
+				continue
+			}
+			name := selection.Name.Value
+			fragment, ok := q.fragments[name]
+			// Cycles are reported by validation.
+			if !ok || q.visiting[name] {
+				continue
+			}
+			fragmentType := parent
+			if fragment.TypeCondition != nil && fragment.TypeCondition.Name != nil {
+				fragmentType = q.schema.Type(fragment.TypeCondition.Name.Value)
+			}
+			q.visiting[name] = true
+			err := q.selectionSet(fragment.SelectionSet, fragmentType, depth, multiplier)
+			delete(q.visiting, name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *queryCost) field(field *ast.Field, parent graphql.Type, depth int, multiplier int) error {
+	if field.Name == nil || strings.HasPrefix(field.Name.Value, "__") {
+		return nil
+	}
+
+	if q.countComplexity {
+		q.complexity += multiplier
+		if q.complexity > maxQueryComplexity {
+			return errQueryTooComplex
+		}
+	}
+
+	var fieldType graphql.Type
+	if object, ok := parent.(*graphql.Object); ok {
+		if definition, ok := object.Fields()[field.Name.Value]; ok {
+			fieldType = definition.Type
+		}
+	}
+	childType, isList := unwrapType(fieldType)
+	if isList {
+		multiplier *= listComplexityFactor
+	}
+	return q.selectionSet(field.SelectionSet, childType, depth+1, multiplier)
+}
+
+// unwrapType returns the named type of t and whether it is a list.
+func unwrapType(t graphql.Type) (graphql.Type, bool) {
+	isList := false
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		case *graphql.List:
+			isList = true
+			t = wrapped.OfType
+		default:
+			return t, isList
+		}
+	}
+}
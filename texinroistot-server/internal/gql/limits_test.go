@@ -0,0 +1,60 @@
+package gql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckQueryCost(t *testing.T) {
+	s, err := NewSchema()
+	if err != nil {
+		t.Fatalf("failed to build schema: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  error
+	}{
+		{
+			name:  "listing with creators and villains",
+			query: `{ stories(pageSize: 25) { meta { total } items { hash writtenBy { firstName lastName } villains { hash lastName } } } }`,
+		},
+		{
+			name:  "villain appearances",
+			query: `{ villains { items { as { story { hash } } } } }`,
+		},
+		{
+			name:  "introspection",
+			query: `{ __schema { types { fields { type { ofType { ofType { ofType { ofType { name } } } } } } } } }`,
+		},
+		{
+			name:  "too deep",
+			query: `{ stories { items { villains { as { story { villains { as { story { hash } } } } } } } } }`,
+			want:  errQueryTooDeep,
+		},
+		{
+			name:  "too deep through fragments",
+			query: `{ stories { items { ...S } } } fragment S on Story { villains { as { story { villains { as { story { hash } } } } } } }`,
+			want:  errQueryTooDeep,
+		},
+		{
+			name:  "too complex",
+			query: `{ stories { items { villains { as { story { villains { hash lastName } } } } } } }`,
+			want:  errQueryTooComplex,
+		},
+		{
+			name:  "too many aliases",
+			query: "{ " + strings.Repeat("a: stories { items { hash } } ", 200) + "}",
+			want:  errQueryTooComplex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkQueryCost(s, tt.query); err != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
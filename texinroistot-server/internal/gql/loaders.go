@@ -0,0 +1,136 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+type contextKey string
+
+const loadersContextKey contextKey = "gqlLoaders"
+
+// Repository constructors are variables so that tests can count the queries
+// a GraphQL request makes.
+var (
+	newVersionRepository = db.NewVersionRepository
+	newAuthorRepository  = db.NewAuthorRepository
+	newStoryRepository   = db.NewStoryRepository
+	newVillainRepository = db.NewVillainRepository
+)
+
+// batchLoader collects keys requested while resolving one level of the query
+// and fetches them with a single call when the first result is needed.
+// graphql-go resolves thunks breadth-first, so sibling fields on a list are
+// all registered before any of them is dethunked.
+type batchLoader[V any] struct {
+	mu      sync.Mutex
+	fetch   func(keys []int) (map[int]V, error)
+	pending []int
+	results map[int]V
+	errs    map[int]error
+}
+
+func newBatchLoader[V any](fetch func(keys []int) (map[int]V, error)) *batchLoader[V] {
+	return &batchLoader[V]{
+		fetch:   fetch,
+		results: make(map[int]V),
+		errs:    make(map[int]error),
+	}
+}
+
+func (l *batchLoader[V]) load(key int) func() (V, error) {
+	l.mu.Lock()
+	if _, done := l.results[key]; !done {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+			fetched, err := l.fetch(uniqueKeys(keys))
+			for _, k := range keys {
+				if err != nil {
+					l.errs[k] = err
+					continue
+				}
+				l.results[k] = fetched[k]
+			}
+		}
+		return l.results[key], l.errs[key]
+	}
+}
+
+func uniqueKeys(keys []int) []int {
+	seen := make(map[int]bool, len(keys))
+	var unique []int
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		unique = append(unique, k)
+	}
+	return unique
+}
+
+// loaders holds request-scoped caches so that nested fields don't cause
+// N+1 queries.
+type loaders struct {
+	versionOnce     sync.Once
+	version         *db.Version
+	versionErr      error
+	authorsOnce     sync.Once
+	authors         []*db.Author
+	authorsErr      error
+	villainsByStory *batchLoader[[]*db.Villain]
+	storiesByAuthor *batchLoader[[]*db.Story]
+}
+
+func newLoaders() *loaders {
+	villainRepo := newVillainRepository()
+	storyRepo := newStoryRepository()
+
+	return &loaders{
+		villainsByStory: newBatchLoader(villainRepo.ListByStoryIDs),
+		storiesByAuthor: newBatchLoader(storyRepo.ListByAuthorIDs),
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersContextKey, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	if l, ok := ctx.Value(loadersContextKey).(*loaders); ok {
+		return l
+	}
+	return newLoaders()
+}
+
+func (l *loaders) activeVersion() (*db.Version, error) {
+	l.versionOnce.Do(func() {
+		versionRepo := newVersionRepository()
+		l.version, l.versionErr = versionRepo.GetActive()
+	})
+	return l.version, l.versionErr
+}
+
+func (l *loaders) activeAuthors() ([]*db.Author, error) {
+	l.authorsOnce.Do(func() {
+		version, err := l.activeVersion()
+		if err != nil {
+			l.authorsErr = err
+			return
+		}
+		authorRepo := newAuthorRepository()
+		l.authors, l.authorsErr = authorRepo.List(version)
+	})
+	return l.authors, l.authorsErr
+}
@@ -0,0 +1,441 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/authors"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/stories"
+	"github.com/kokkoniemi/texinroistot/internal/villains"
+)
+
+type listPage struct {
+	Items []interface{}
+	Meta  api.PageMeta
+}
+
+// queryFromArgs adapts GraphQL arguments to the raw query lookups used by the
+// REST list parsers, so both endpoints share validation and defaults.
+func queryFromArgs(args map[string]interface{}, names map[string]string) func(key string) string {
+	return func(key string) string {
+		argName, ok := names[key]
+		if !ok {
+			argName = key
+		}
+		switch value := args[argName].(type) {
+		case string:
+			return value
+		case int:
+			return strconv.Itoa(value)
+		default:
+			return ""
+		}
+	}
+}
+
+var listArgNames = map[string]string{"q": "search"}
+
+var (
+	pageMetaType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageMeta",
+		Fields: graphql.Fields{
+			"total":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"page":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"pageSize":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"totalPages": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	versionStatsType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "VersionStats",
+		Fields: graphql.Fields{
+			"villains":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"stories":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"drawers":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"writers":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"translators": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	versionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Version",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"isActive":  &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"label":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"stats": &graphql.Field{
+				Type: versionStatsType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					version, ok := p.Source.(*db.Version)
+					if !ok {
+						return nil, nil
+					}
+					versionRepo := newVersionRepository()
+					return versionRepo.GetStats(version.ID)
+				},
+			},
+		},
+	})
+
+	publicationType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Publication",
+		Fields: graphql.Fields{
+			"hash":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"type":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"year":  &graphql.Field{Type: graphql.Int},
+			"issue": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	storyPublicationType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "StoryPublication",
+		Fields: graphql.Fields{
+			"title": &graphql.Field{Type: graphql.String},
+			"in":    &graphql.Field{Type: publicationType},
+		},
+	})
+
+	authorType       *graphql.Object
+	storyType        *graphql.Object
+	villainType      *graphql.Object
+	storyVillainType *graphql.Object
+)
+
+func init() {
+	authorType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Author",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"hash":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"firstName":    &graphql.Field{Type: graphql.String},
+				"lastName":     &graphql.Field{Type: graphql.String},
+				"details":      &graphql.Field{Type: graphql.String, Description: "Per-story details, such as translated pages"},
+				"isWriter":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"isDrawer":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"isTranslator": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"stories": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(storyType)),
+					Args: graphql.FieldConfigArgument{
+						"type": &graphql.ArgumentConfig{
+							Type:        graphql.String,
+							Description: "writer|drawer|translator",
+						},
+					},
+					Resolve: resolveAuthorStories,
+				},
+			}
+		}),
+	})
+
+	storyType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Story",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"hash":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"orderNumber":  &graphql.Field{Type: graphql.Int},
+				"writtenBy":    &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(authorType))},
+				"drawnBy":      &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(authorType))},
+				"translatedBy": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(authorType))},
+				"publications": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(storyPublicationType))},
+				"villains": &graphql.Field{
+					Type:        graphql.NewList(graphql.NewNonNull(villainType)),
+					Description: "Villains appearing in the story. Each villain's `as` only contains this story.",
+					Resolve:     resolveStoryVillains,
+				},
+			}
+		}),
+	})
+
+	storyVillainType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "StoryVillain",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"hash":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"nicknames":  &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"otherNames": &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"codeNames":  &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"roles":      &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"destiny":    &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"story":      &graphql.Field{Type: storyType},
+			}
+		}),
+	})
+
+	villainType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Villain",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"hash":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"ranks":      &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"firstNames": &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"lastName":   &graphql.Field{Type: graphql.String},
+				"as":         &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(storyVillainType))},
+			}
+		}),
+	})
+}
+
+func pageType(name string, itemType *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: name,
+		Fields: graphql.Fields{
+			"items": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(itemType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(listPage).Items, nil
+				},
+			},
+			"meta": &graphql.Field{
+				Type: graphql.NewNonNull(pageMetaType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(listPage).Meta, nil
+				},
+			},
+		},
+	})
+}
+
+func paginationArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"search":   &graphql.ArgumentConfig{Type: graphql.String},
+		"sort":     &graphql.ArgumentConfig{Type: graphql.String},
+		"page":     &graphql.ArgumentConfig{Type: graphql.Int},
+		"pageSize": &graphql.ArgumentConfig{Type: graphql.Int},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+// NewSchema builds the read-only catalog schema.
+func NewSchema() (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"version": &graphql.Field{
+				Type:        versionType,
+				Description: "Currently active version",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).activeVersion()
+				},
+			},
+			"stories": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("StoryPage", storyType)),
+				Args: paginationArgs(graphql.FieldConfigArgument{
+					"publication": &graphql.ArgumentConfig{Type: graphql.String},
+					"year":        &graphql.ArgumentConfig{Type: graphql.Int},
+				}),
+				Resolve: resolveStories,
+			},
+			"story": &graphql.Field{
+				Type: storyType,
+				Args: graphql.FieldConfigArgument{
+					"hash": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: resolveStory,
+			},
+			"villains": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("VillainPage", villainType)),
+				Args: paginationArgs(graphql.FieldConfigArgument{
+					"publication": &graphql.ArgumentConfig{Type: graphql.String},
+				}),
+				Resolve: resolveVillains,
+			},
+			"authors": &graphql.Field{
+				Type: graphql.NewNonNull(pageType("AuthorPage", authorType)),
+				Args: paginationArgs(graphql.FieldConfigArgument{
+					"type": &graphql.ArgumentConfig{Type: graphql.String},
+				}),
+				Resolve: resolveAuthors,
+			},
+			"author": &graphql.Field{
+				Type: authorType,
+				Args: graphql.FieldConfigArgument{
+					"hash": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: resolveAuthor,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func resolveStories(p graphql.ResolveParams) (interface{}, error) {
+	params, err := stories.ParseStoryListQuery(queryFromArgs(p.Args, listArgNames))
+	if err != nil {
+		return nil, err
+	}
+	version, err := loadersFrom(p.Context).activeVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	storyRepo := newStoryRepository()
+	result, total, err := storyRepo.ListFiltered(version, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stories")
+	}
+
+	items := make([]interface{}, 0, len(result))
+	for _, s := range result {
+		items = append(items, s)
+	}
+	return listPage{Items: items, Meta: api.NewPageMeta(total, params.Page, params.PageSize)}, nil
+}
+
+func resolveStory(p graphql.ResolveParams) (interface{}, error) {
+	hash, _ := p.Args["hash"].(string)
+	version, err := loadersFrom(p.Context).activeVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	storyRepo := newStoryRepository()
+	story, err := storyRepo.ReadByHash(version, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load story")
+	}
+	if story == nil {
+		return nil, nil
+	}
+	return story, nil
+}
+
+func resolveVillains(p graphql.ResolveParams) (interface{}, error) {
+	params, err := villains.ParseVillainListQuery(queryFromArgs(p.Args, listArgNames))
+	if err != nil {
+		return nil, err
+	}
+	version, err := loadersFrom(p.Context).activeVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	villainRepo := newVillainRepository()
+	result, total, err := villainRepo.ListFiltered(version, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list villains")
+	}
+
+	items := make([]interface{}, 0, len(result))
+	for _, v := range result {
+		items = append(items, v)
+	}
+	return listPage{Items: items, Meta: api.NewPageMeta(total, params.Page, params.PageSize)}, nil
+}
+
+func resolveAuthors(p graphql.ResolveParams) (interface{}, error) {
+	params, err := authors.ParseAuthorListQuery(queryFromArgs(p.Args, listArgNames))
+	if err != nil {
+		return nil, err
+	}
+	allAuthors, err := loadersFrom(p.Context).activeAuthors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authors")
+	}
+
+	filtered := authors.FilterAuthors(allAuthors, params)
+	page := authors.PaginateAuthors(filtered, params.Page, params.PageSize)
+
+	items := make([]interface{}, 0, len(page))
+	for _, a := range page {
+		items = append(items, a)
+	}
+	return listPage{Items: items, Meta: api.NewPageMeta(len(filtered), params.Page, params.PageSize)}, nil
+}
+
+func resolveAuthor(p graphql.ResolveParams) (interface{}, error) {
+	hash, _ := p.Args["hash"].(string)
+	hash = strings.TrimSpace(hash)
+	allAuthors, err := loadersFrom(p.Context).activeAuthors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authors")
+	}
+	for _, a := range allAuthors {
+		if a.Hash == hash {
+			return a, nil
+		}
+	}
+	return nil, nil
+}
+
+func resolveStoryVillains(p graphql.ResolveParams) (interface{}, error) {
+	story, ok := p.Source.(*db.Story)
+	if !ok || story.ID == 0 {
+		return nil, nil
+	}
+
+	thunk := loadersFrom(p.Context).villainsByStory.load(story.ID)
+	return func() (interface{}, error) {
+		result, err := thunk()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list story villains")
+		}
+		for _, v := range result {
+			for _, sv := range v.As {
+				sv.Story = story
+			}
+		}
+		return result, nil
+	}, nil
+}
+
+func resolveAuthorStories(p graphql.ResolveParams) (interface{}, error) {
+	author, ok := p.Source.(*db.Author)
+	if !ok || author.ID == 0 {
+		return nil, nil
+	}
+	linkType, _ := p.Args["type"].(string)
+	linkType = strings.ToLower(strings.TrimSpace(linkType))
+	switch linkType {
+	case "", "writer", "drawer", "translator":
+	default:
+		return nil, fmt.Errorf("type is invalid")
+	}
+
+	thunk := loadersFrom(p.Context).storiesByAuthor.load(author.ID)
+	return func() (interface{}, error) {
+		result, err := thunk()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list author stories")
+		}
+		if linkType == "" {
+			return result, nil
+		}
+
+		var filtered []*db.Story
+		for _, s := range result {
+			if authorListed(storyAuthorsByType(s, linkType), author.ID) {
+				filtered = append(filtered, s)
+			}
+		}
+		return filtered, nil
+	}, nil
+}
+
+func storyAuthorsByType(s *db.Story, authorType string) []*db.Author {
+	switch authorType {
+	case "writer":
+		return s.WrittenBy
+	case "drawer":
+		return s.DrawnBy
+	default:
+		return s.TranslatedBy
+	}
+}
+
+func authorListed(list []*db.Author, authorID int) bool {
+	for _, a := range list {
+		if a.ID == authorID {
+			return true
+		}
+	}
+	return false
+}
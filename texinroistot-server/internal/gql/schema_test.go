@@ -0,0 +1,216 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func runQuery(t *testing.T, query string) *graphql.Result {
+	t.Helper()
+
+	s, err := NewSchema()
+	if err != nil {
+		t.Fatalf("failed to build schema: %v", err)
+	}
+	return graphql.Do(graphql.Params{
+		Schema:        s,
+		RequestString: query,
+		Context:       withLoaders(context.Background(), newLoaders()),
+	})
+}
+
+func TestSchemaExposesCatalogQueries(t *testing.T) {
+	result := runQuery(t, `{ __schema { queryType { fields { name } } } }`)
+	if result.HasErrors() {
+		t.Fatalf("introspection failed: %v", result.Errors)
+	}
+
+	data := result.Data.(map[string]interface{})
+	fields := data["__schema"].(map[string]interface{})["queryType"].(map[string]interface{})["fields"].([]interface{})
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.(map[string]interface{})["name"].(string))
+	}
+	joined := strings.Join(names, ",")
+	for _, expected := range []string{"version", "stories", "story", "villains", "authors", "author"} {
+		if !strings.Contains(joined, expected) {
+			t.Fatalf("expected query field %q, got %v", expected, names)
+		}
+	}
+}
+
+func TestStoriesRejectsInvalidSortBeforeQueryingDB(t *testing.T) {
+	result := runQuery(t, `{ stories(sort: "unknown") { meta { total } } }`)
+	if !result.HasErrors() {
+		t.Fatalf("expected error for invalid sort")
+	}
+	if result.Errors[0].Message != "sort is invalid" {
+		t.Fatalf("expected REST validation message, got %q", result.Errors[0].Message)
+	}
+}
+
+func TestVillainsRejectsInvalidPage(t *testing.T) {
+	result := runQuery(t, `{ villains(page: 0) { meta { total } } }`)
+	if !result.HasErrors() || result.Errors[0].Message != "page must be a positive integer" {
+		t.Fatalf("expected page validation error, got %v", result.Errors)
+	}
+}
+
+func TestQueryFromArgsMapsSearchAndInts(t *testing.T) {
+	query := queryFromArgs(map[string]interface{}{
+		"search":   "kit",
+		"pageSize": 50,
+	}, listArgNames)
+
+	if query("q") != "kit" {
+		t.Fatalf("expected q to map to search arg, got %q", query("q"))
+	}
+	if query("pageSize") != "50" {
+		t.Fatalf("expected pageSize to be stringified, got %q", query("pageSize"))
+	}
+	if query("page") != "" {
+		t.Fatalf("expected missing arg to be empty, got %q", query("page"))
+	}
+}
+
+func TestBatchLoaderFetchesPendingKeysOnce(t *testing.T) {
+	calls := 0
+	var fetchedKeys []int
+	loader := newBatchLoader(func(keys []int) (map[int]string, error) {
+		calls++
+		fetchedKeys = keys
+		result := make(map[int]string)
+		for _, k := range keys {
+			result[k] = strings.Repeat("x", k)
+		}
+		return result, nil
+	})
+
+	first := loader.load(1)
+	second := loader.load(2)
+	duplicate := loader.load(2)
+
+	if v, err := second(); err != nil || v != "xx" {
+		t.Fatalf("unexpected result %q, %v", v, err)
+	}
+	if v, err := first(); err != nil || v != "x" {
+		t.Fatalf("unexpected result %q, %v", v, err)
+	}
+	if v, _ := duplicate(); v != "xx" {
+		t.Fatalf("unexpected result %q", v)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single batched fetch, got %d", calls)
+	}
+	if len(fetchedKeys) != 2 {
+		t.Fatalf("expected deduplicated keys, got %v", fetchedKeys)
+	}
+
+	if v, _ := loader.load(1)(); v != "x" || calls != 1 {
+		t.Fatalf("expected cached result without refetch, got %q after %d calls", v, calls)
+	}
+}
+
+type countingVersionRepo struct {
+	db.VersionRepository
+	calls int
+}
+
+func (r *countingVersionRepo) GetActive() (*db.Version, error) {
+	r.calls++
+	return &db.Version{ID: 1, IsActive: true}, nil
+}
+
+type countingStoryRepo struct {
+	db.StoryRepository
+	stories        []*db.Story
+	listCalls      int
+	byAuthorsCalls int
+}
+
+func (r *countingStoryRepo) ListFiltered(version *db.Version, params db.StoryListParams) ([]*db.Story, int, error) {
+	r.listCalls++
+	return r.stories, len(r.stories), nil
+}
+
+func (r *countingStoryRepo) ListByAuthorIDs(authorIDs []int) (map[int][]*db.Story, error) {
+	r.byAuthorsCalls++
+	result := make(map[int][]*db.Story)
+	for _, id := range authorIDs {
+		result[id] = r.stories
+	}
+	return result, nil
+}
+
+type countingVillainRepo struct {
+	db.VillainRepository
+	byStoriesCalls int
+}
+
+func (r *countingVillainRepo) ListByStoryIDs(storyIDs []int) (map[int][]*db.Villain, error) {
+	r.byStoriesCalls++
+	result := make(map[int][]*db.Villain)
+	for _, id := range storyIDs {
+		result[id] = []*db.Villain{
+			{ID: id, Hash: fmt.Sprintf("villain-%d", id), As: []*db.StoryVillain{{}}},
+		}
+	}
+	return result, nil
+}
+
+func TestNestedQueryBatchesRepositoryCalls(t *testing.T) {
+	versionRepo := &countingVersionRepo{}
+	storyRepo := &countingStoryRepo{}
+	villainRepo := &countingVillainRepo{}
+	for i := 1; i <= 5; i++ {
+		author := &db.Author{ID: i, Hash: fmt.Sprintf("author-%d", i), IsWriter: true}
+		storyRepo.stories = append(storyRepo.stories, &db.Story{
+			ID:        i,
+			Hash:      fmt.Sprintf("story-%d", i),
+			WrittenBy: []*db.Author{author},
+		})
+	}
+
+	origVersion, origStory, origVillain := newVersionRepository, newStoryRepository, newVillainRepository
+	newVersionRepository = func() db.VersionRepository { return versionRepo }
+	newStoryRepository = func() db.StoryRepository { return storyRepo }
+	newVillainRepository = func() db.VillainRepository { return villainRepo }
+	t.Cleanup(func() {
+		newVersionRepository, newStoryRepository, newVillainRepository = origVersion, origStory, origVillain
+	})
+
+	result := runQuery(t, `{
+		stories {
+			items {
+				hash
+				villains { hash as { story { hash villains { hash } } } }
+				writtenBy { hash stories { hash villains { hash } } }
+			}
+		}
+	}`)
+	if result.HasErrors() {
+		t.Fatalf("query failed: %v", result.Errors)
+	}
+
+	items := result.Data.(map[string]interface{})["stories"].(map[string]interface{})["items"].([]interface{})
+	if len(items) != 5 {
+		t.Fatalf("expected 5 stories, got %d", len(items))
+	}
+	if versionRepo.calls != 1 {
+		t.Fatalf("expected the active version to be read once, got %d", versionRepo.calls)
+	}
+	if storyRepo.listCalls != 1 {
+		t.Fatalf("expected one story list query, got %d", storyRepo.listCalls)
+	}
+	if storyRepo.byAuthorsCalls != 1 {
+		t.Fatalf("expected one batched author stories query, got %d", storyRepo.byAuthorsCalls)
+	}
+	if villainRepo.byStoriesCalls != 1 {
+		t.Fatalf("expected one batched story villains query, got %d", villainRepo.byStoriesCalls)
+	}
+}
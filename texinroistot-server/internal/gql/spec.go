@@ -0,0 +1,38 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+func Operations() []openapi.Operation {
+	responses := map[int]openapi.Response{
+		200: {Description: "Query result, resolver errors are listed in errors", Body: graphql.Result{}},
+		400: {Description: "Missing, oversized, too deep or too complex query", Body: api.ErrorResponse{}},
+		500: {Description: "Schema could not be built", Body: api.ErrorResponse{}},
+	}
+
+	return []openapi.Operation{
+		{
+			Method:  "GET",
+			Path:    "/api/graphql",
+			Summary: "Run a read-only GraphQL query",
+			Tag:     "graphql",
+			Parameters: []openapi.Parameter{
+				{Name: "query", In: openapi.ParamInQuery, Required: true},
+				{Name: "operationName", In: openapi.ParamInQuery},
+				{Name: "variables", In: openapi.ParamInQuery, Description: "JSON object"},
+			},
+			Responses: responses,
+		},
+		{
+			Method:      "POST",
+			Path:        "/api/graphql",
+			Summary:     "Run a read-only GraphQL query",
+			Tag:         "graphql",
+			RequestBody: RequestPayload{},
+			Responses:   responses,
+		},
+	}
+}
@@ -71,8 +71,8 @@ func splitTranslatorFirstNameAndDetails(firstName string) (string, string) {
 	return firstName, ""
 }
 
-func splitAuthorColumnNames(raw string) []string {
-	parts := strings.Split(raw, ";")
+func splitAuthorColumnNames(raw string, delimiter string) []string {
+	parts := strings.Split(raw, delimiter)
 	var names []string
 
 	for _, part := range parts {
@@ -157,7 +157,7 @@ func splitTranslatorGroup(raw string) ([]string, string) {
 }
 
 func (i *importer) loadTranslatorColumn(r row) []parsedTranslator {
-	names := strings.Split(r.getValue("story_translated_by"), ";")
+	names := i.splitValues(r.getValue("story_translated_by"))
 	var translators []parsedTranslator
 
 	for _, rawGroup := range names {
@@ -252,7 +252,7 @@ func (i *importer) addAuthor(author *db.Author) *importerAuthor {
 }
 
 func (i *importer) loadAuthorColumn(r row, columnName string) []*importerAuthor {
-	names := splitAuthorColumnNames(r.getValue(columnName))
+	names := splitAuthorColumnNames(r.getValue(columnName), i.delimiter)
 	var authors []*importerAuthor
 	for _, n := range names {
 		if len(strings.TrimSpace(n)) == 0 {
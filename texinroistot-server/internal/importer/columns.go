@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultDelimiter = ";"
+
+// ColumnMapping tells the importer which spreadsheet headers belong to which
+// importer key, which keys must be present and how multi-value cells are
+// separated.
+type ColumnMapping struct {
+	// Columns maps importer keys (e.g. "first_names") to accepted headers.
+	Columns   map[string][]string `json:"columns" yaml:"columns"`
+	Required  []string            `json:"required" yaml:"required"`
+	Delimiter string              `json:"delimiter" yaml:"delimiter"`
+}
+
+// MissingColumn describes a required key whose header was not found.
+// Suggestion holds the closest unmatched header, if any looked like a typo
+// or a renamed version of an expected header.
+type MissingColumn struct {
+	Key        string
+	Expected   []string
+	Suggestion string
+}
+
+type MissingColumnsError struct {
+	Columns []MissingColumn
+}
+
+func (e *MissingColumnsError) Error() string {
+	descriptions := make([]string, 0, len(e.Columns))
+	for _, column := range e.Columns {
+		description := column.Key
+		if len(column.Expected) > 0 {
+			description += fmt.Sprintf(" (expected %q", column.Expected[0])
+			if column.Suggestion != "" {
+				description += fmt.Sprintf("; did you mean %q?", column.Suggestion)
+			}
+			description += ")"
+		}
+		descriptions = append(descriptions, description)
+	}
+	return "missing required columns: " + strings.Join(descriptions, ", ")
+}
+
+// DefaultColumnMapping returns the headers used by the Texinroistot master
+// spreadsheet.
+func DefaultColumnMapping() *ColumnMapping {
+	columns := map[string][]string{}
+	for title, key := range defaultColumns {
+		columns[key] = append(columns[key], title)
+	}
+	return &ColumnMapping{
+		Columns:   columns,
+		Required:  append([]string(nil), requiredColumnKeys...),
+		Delimiter: defaultDelimiter,
+	}
+}
+
+// LoadColumnMapping reads a YAML or JSON mapping file. An empty path returns
+// the default mapping.
+func LoadColumnMapping(path string) (*ColumnMapping, error) {
+	if strings.TrimSpace(path) == "" {
+		return DefaultColumnMapping(), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseColumnMapping(content)
+}
+
+// ParseColumnMapping merges a mapping document over the default mapping.
+// Aliases are accepted in addition to the default headers and required keys
+// are required in addition to the default ones, so a mapping file can relax
+// header names but never drop a column the importer needs. Delimiter
+// replaces the default when set.
+func ParseColumnMapping(content []byte) (*ColumnMapping, error) {
+	var override ColumnMapping
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		err = json.Unmarshal(content, &override)
+	} else {
+		err = yaml.Unmarshal(content, &override)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid column mapping: %w", err)
+	}
+
+	mapping := DefaultColumnMapping()
+	for key, aliases := range override.Columns {
+		if _, known := mapping.Columns[key]; !known {
+			return nil, fmt.Errorf("invalid column mapping: unknown column key %q", key)
+		}
+		mapping.Columns[key] = append(mapping.Columns[key], aliases...)
+	}
+	if err := mapping.checkHeaderCollisions(); err != nil {
+		return nil, err
+	}
+	for _, key := range override.Required {
+		if _, known := mapping.Columns[key]; !known {
+			return nil, fmt.Errorf("invalid column mapping: unknown required key %q", key)
+		}
+		if !slices.Contains(mapping.Required, key) {
+			mapping.Required = append(mapping.Required, key)
+		}
+	}
+	if override.Delimiter != "" {
+		mapping.Delimiter = override.Delimiter
+	}
+
+	return mapping, nil
+}
+
+// checkHeaderCollisions rejects headers that would match more than one key,
+// since the column they end up in would depend on map iteration order.
+func (m *ColumnMapping) checkHeaderCollisions() error {
+	keys := make([]string, 0, len(m.Columns))
+	for key := range m.Columns {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	owners := make(map[string]string)
+	for _, key := range keys {
+		for _, header := range m.Columns[key] {
+			normalized := normalizeHeader(header)
+			if owner, taken := owners[normalized]; taken && owner != key {
+				return fmt.Errorf("invalid column mapping: header %q is mapped to both %q and %q", header, owner, key)
+			}
+			owners[normalized] = key
+		}
+	}
+	return nil
+}
+
+func (m *ColumnMapping) keysByHeader() map[string]string {
+	keys := make(map[string]string)
+	for key, headers := range m.Columns {
+		for _, header := range headers {
+			keys[normalizeHeader(header)] = key
+		}
+	}
+	return keys
+}
+
+func (m *ColumnMapping) missingColumns(columnIndexes map[string]int, unmatchedHeaders []string) []MissingColumn {
+	var missing []MissingColumn
+	for _, key := range m.Required {
+		if _, ok := columnIndexes[key]; ok {
+			continue
+		}
+		expected := append([]string(nil), m.Columns[key]...)
+		sort.Strings(expected)
+		missing = append(missing, MissingColumn{
+			Key:        key,
+			Expected:   expected,
+			Suggestion: suggestHeader(expected, unmatchedHeaders),
+		})
+	}
+	return missing
+}
+
+// normalizeHeader makes header matching insensitive to case and to stray
+// whitespace, which spreadsheet editors tend to introduce.
+func normalizeHeader(header string) string {
+	return strings.Join(strings.Fields(strings.ToLower(header)), " ")
+}
+
+func suggestHeader(expected []string, candidates []string) string {
+	suggestion := ""
+	bestDistance := -1
+
+	for _, candidate := range candidates {
+		normalizedCandidate := normalizeHeader(candidate)
+		for _, header := range expected {
+			normalizedHeader := normalizeHeader(header)
+			distance := levenshtein(normalizedHeader, normalizedCandidate)
+			if !isNearMiss(normalizedHeader, normalizedCandidate, distance) {
+				continue
+			}
+			if bestDistance < 0 || distance < bestDistance {
+				suggestion = candidate
+				bestDistance = distance
+			}
+		}
+	}
+	return suggestion
+}
+
+// isNearMiss accepts small edits and shortened or extended versions of a
+// header, e.g. "Etunimi" for "Etunimi (sisältää nimet, ...)".
+func isNearMiss(header string, candidate string, distance int) bool {
+	shorter := utf8.RuneCountInString(header)
+	if candidateLength := utf8.RuneCountInString(candidate); candidateLength < shorter {
+		shorter = candidateLength
+	}
+	if shorter >= 4 && (strings.HasPrefix(header, candidate) || strings.HasPrefix(candidate, header)) {
+		return true
+	}
+
+	maxDistance := utf8.RuneCountInString(header) / 4
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	return distance <= maxDistance
+}
+
+func levenshtein(a string, b string) int {
+	source := []rune(a)
+	target := []rune(b)
+
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func defaultTitleRow() []string {
+	var titles []string
+	for title := range defaultColumns {
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+func replaceTitle(titles []string, old string, new string) []string {
+	replaced := append([]string(nil), titles...)
+	for index, title := range replaced {
+		if title == old {
+			replaced[index] = new
+		}
+	}
+	return replaced
+}
+
+func TestNewSpreadsheetImporterMatchesDefaultHeaders(t *testing.T) {
+	titles := replaceTitle(defaultTitleRow(), "Rooli", "  rooli ")
+
+	i, err := NewSpreadsheetImporter(titles, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(i.columnIndexes) != len(requiredColumnKeys) {
+		t.Fatalf("expected %d mapped columns, got %d", len(requiredColumnKeys), len(i.columnIndexes))
+	}
+	if i.delimiter != defaultDelimiter {
+		t.Fatalf("expected default delimiter, got %q", i.delimiter)
+	}
+}
+
+func TestNewSpreadsheetImporterSuggestsNearMissHeaders(t *testing.T) {
+	firstNames := "Etunimi (sisältää nimet, joita käytetään kuin etunimeä)"
+	titles := replaceTitle(defaultTitleRow(), firstNames, "Etunimi")
+	titles = replaceTitle(titles, "Kohtalo", "Kohtaloo")
+	titles = replaceTitle(titles, "Vuosi", "Jotain muuta")
+
+	_, err := NewSpreadsheetImporter(titles, nil)
+	var missingErr *MissingColumnsError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("expected MissingColumnsError, got %v", err)
+	}
+
+	suggestions := map[string]string{}
+	for _, column := range missingErr.Columns {
+		suggestions[column.Key] = column.Suggestion
+	}
+	if len(suggestions) != 3 {
+		t.Fatalf("expected three missing columns, got %v", missingErr.Columns)
+	}
+	if suggestions["first_names"] != "Etunimi" {
+		t.Fatalf("expected shortened header suggestion, got %q", suggestions["first_names"])
+	}
+	if suggestions["destiny"] != "Kohtaloo" {
+		t.Fatalf("expected typo suggestion, got %q", suggestions["destiny"])
+	}
+	if suggestions["pub_year"] != "" {
+		t.Fatalf("expected no suggestion for unrelated header, got %q", suggestions["pub_year"])
+	}
+	if !strings.HasPrefix(err.Error(), "missing required columns: ") ||
+		!strings.Contains(err.Error(), `did you mean "Etunimi"?`) {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestParseColumnMappingAddsAliases(t *testing.T) {
+	mapping, err := ParseColumnMapping([]byte(`
+delimiter: "|"
+columns:
+  first_names:
+    - Etunimet
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	titles := replaceTitle(defaultTitleRow(), "Etunimi (sisältää nimet, joita käytetään kuin etunimeä)", "Etunimet")
+	i, err := NewSpreadsheetImporter(titles, mapping)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if titles[i.columnIndexes["first_names"]] != "Etunimet" {
+		t.Fatalf("unexpected column indexes %v", i.columnIndexes)
+	}
+	if got := i.splitValues("a|b"); len(got) != 2 {
+		t.Fatalf("expected custom delimiter to split values, got %q", got)
+	}
+}
+
+func TestParseColumnMappingAcceptsJSON(t *testing.T) {
+	mapping, err := ParseColumnMapping([]byte("{\n\t\"columns\": {\"roles\": [\"Roolit\"]}\n}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mapping.Delimiter != defaultDelimiter || len(mapping.Required) != len(requiredColumnKeys) {
+		t.Fatalf("expected defaults to be kept, got %+v", mapping)
+	}
+	if !strings.Contains(strings.Join(mapping.Columns["roles"], ","), "Roolit") {
+		t.Fatalf("expected alias to be added, got %v", mapping.Columns["roles"])
+	}
+}
+
+func TestParseColumnMappingRejectsUnknownKeys(t *testing.T) {
+	for _, content := range []string{
+		"columns:\n  nickname: [Lempinimet]\n",
+		"required: [unknown]\n",
+		"columns: [",
+	} {
+		if _, err := ParseColumnMapping([]byte(content)); err == nil {
+			t.Fatalf("expected error for %q", content)
+		}
+	}
+}
+
+func TestParseColumnMappingRejectsCollidingHeaders(t *testing.T) {
+	for _, content := range []string{
+		"columns:\n  first_names: [Tarina]\n",
+		"columns:\n  destiny: [\"  rooli \"]\n",
+		"columns:\n  roles: [Osa]\n  destiny: [osa]\n",
+	} {
+		if _, err := ParseColumnMapping([]byte(content)); err == nil || !strings.Contains(err.Error(), "is mapped to both") {
+			t.Fatalf("expected collision error for %q, got %v", content, err)
+		}
+	}
+}
+
+func TestParseColumnMappingKeepsDefaultRequiredKeys(t *testing.T) {
+	mapping, err := ParseColumnMapping([]byte("required:\n  - first_names\n  - last_name\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range requiredColumnKeys {
+		if !slices.Contains(mapping.Required, key) {
+			t.Fatalf("expected default required key %q to be kept, got %v", key, mapping.Required)
+		}
+	}
+	if len(mapping.Required) != len(requiredColumnKeys) {
+		t.Fatalf("expected required keys not to be duplicated, got %v", mapping.Required)
+	}
+}
@@ -0,0 +1,39 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+)
+
+var utf8BOM = []byte("\xEF\xBB\xBF")
+
+// readCSVRows parses CSV exported from Google Sheets or LibreOffice. The
+// delimiter is sniffed from the header row since Finnish locales default to
+// semicolons.
+func readCSVRows(content []byte) ([][]string, error) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = sniffCSVDelimiter(content)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	return reader.ReadAll()
+}
+
+func sniffCSVDelimiter(content []byte) rune {
+	header := content
+	if end := bytes.IndexByte(content, '\n'); end >= 0 {
+		header = content[:end]
+	}
+
+	delimiter := ','
+	maxCount := bytes.Count(header, []byte{','})
+	for _, candidate := range []rune{';', '\t'} {
+		if count := bytes.Count(header, []byte(string(candidate))); count > maxCount {
+			delimiter = candidate
+			maxCount = count
+		}
+	}
+	return delimiter
+}
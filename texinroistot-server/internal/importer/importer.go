@@ -15,40 +15,56 @@ type importer struct {
 	villains          []*importerVillain
 	storyVillains     []*importerStoryVillain
 	columnIndexes     map[string]int
-	columnNames       map[string]string
+	delimiter         string
 	totalEntities     uint64
+	progress          Progress
+	onProgress        ProgressFunc
+	metadata          db.Version
 }
 
-func NewSpreadsheetImporter(titleRow []string) (*importer, error) {
-	columnNames := defaultColumns
+// NewSpreadsheetImporter maps the title row to importer keys. A nil mapping
+// uses DefaultColumnMapping. Missing required columns are reported as a
+// *MissingColumnsError with near-miss header suggestions.
+func NewSpreadsheetImporter(titleRow []string, mapping *ColumnMapping) (*importer, error) {
+	if mapping == nil {
+		mapping = DefaultColumnMapping()
+	}
+	keysByHeader := mapping.keysByHeader()
 	columnIndexes := map[string]int{}
+	var unmatchedHeaders []string
 
 	for index, title := range titleRow {
-		key, ok := columnNames[strings.TrimSpace(title)]
+		key, ok := keysByHeader[normalizeHeader(title)]
 		if !ok {
+			if strings.TrimSpace(title) != "" {
+				unmatchedHeaders = append(unmatchedHeaders, strings.TrimSpace(title))
+			}
 			continue
 		}
 		columnIndexes[key] = index
 	}
 
-	var missingColumns []string
-	for _, key := range requiredColumnKeys {
-		if _, ok := columnIndexes[key]; !ok {
-			missingColumns = append(missingColumns, key)
-		}
-	}
-	if len(missingColumns) > 0 {
-		return nil, fmt.Errorf("missing required columns: %s", strings.Join(missingColumns, ", "))
+	if missing := mapping.missingColumns(columnIndexes, unmatchedHeaders); len(missing) > 0 {
+		return nil, &MissingColumnsError{Columns: missing}
 	}
 
 	return &importer{
-		columnNames:   defaultColumns,
 		columnIndexes: columnIndexes,
+		delimiter:     mapping.Delimiter,
 		totalEntities: 0,
 	}, nil
 }
 
+// OnProgress registers a callback for stage changes and entity counts.
+func (i *importer) OnProgress(report ProgressFunc) {
+	i.onProgress = report
+}
+
 func (i *importer) LoadData(dataRows [][]string) error {
+	i.progress.Stage = StageParsing
+	i.progress.Rows = len(dataRows)
+	i.reportProgress()
+
 	for index, dataRow := range dataRows {
 		row := row{importer: i, cells: dataRow, index: index}
 
@@ -93,6 +109,8 @@ func (i *importer) LoadData(dataRows [][]string) error {
 		}
 	}
 
+	i.progress.Parsed = i.parsedCounts()
+	i.reportProgress()
 	return nil
 }
 
@@ -101,12 +119,21 @@ func (i *importer) PersistData() error {
 	return err
 }
 
+// PersistDataWithVersion writes the loaded data into a new version carrying
+// the import metadata and the parsed row and entity counts.
 func (i *importer) PersistDataWithVersion() (*db.Version, error) {
+	metadata := i.metadata
+	metadata.Rows = i.progress.Rows
+	metadata.Counts = i.parsedCounts()
+
 	versionRepo := db.NewVersionRepository()
-	version, err := versionRepo.Create(db.Version{IsActive: false})
+	version, err := versionRepo.Create(metadata)
 	if err != nil {
 		return nil, err
 	}
+	i.progress.Stage = StagePersisting
+	i.progress.VersionID = version.ID
+	i.reportProgress()
 
 	// Save other models in following order:
 	//  1. Author -> 2. Publication -> 3. Story -> 4. StoryPublication -> 5. Villain -> 6. StoryVillain
@@ -121,23 +148,38 @@ func (i *importer) PersistDataWithVersion() (*db.Version, error) {
 	if err != nil {
 		return nil, err
 	}
+	i.progress.Persisted.Authors = len(i.authors)
+	i.reportProgress()
 
 	err = i.persistPublications(version)
 	if err != nil {
 		return nil, err
 	}
+	i.progress.Persisted.Publications = len(i.publications)
+	i.reportProgress()
+
 	err = i.persistStories(version)
 	if err != nil {
 		return nil, err
 	}
+	i.progress.Persisted.Stories = len(i.stories)
+	i.reportProgress()
+
 	err = i.persistVillains(version)
 	if err != nil {
 		return nil, err
 	}
+	i.progress.Persisted.Villains = len(i.villains)
+	i.reportProgress()
 
 	return version, nil
 }
 
+// splitValues splits a multi-value cell on the configured delimiter.
+func (i *importer) splitValues(str string) []string {
+	return strings.Split(str, i.delimiter)
+}
+
 func (i *importer) TrimmedSplit(str string, delimiter string) []string {
 	values := strings.Split(str, delimiter)
 	for index := range values {
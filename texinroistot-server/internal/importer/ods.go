@@ -0,0 +1,263 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	odsOfficeNamespace = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTableNamespace  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNamespace   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
+	// LibreOffice pads sheets with blank rows and columns repeated up to the
+	// format limits. Blank repeats are only materialized when followed by
+	// content. Sheets that would expand beyond these limits are rejected, so
+	// that a small file with huge repeat counts cannot exhaust memory. The
+	// master spreadsheet has a few dozen columns and some thousand rows.
+	odsMaxColumns = 1024
+	odsMaxRows    = 100000
+	odsMaxCells   = 2000000
+)
+
+var (
+	errODSTooManyColumns = fmt.Errorf("ods sheet has more than %d columns", odsMaxColumns)
+	errODSTooManyRows    = fmt.Errorf("ods sheet has more than %d rows", odsMaxRows)
+	errODSTooManyCells   = fmt.Errorf("ods sheet has more than %d cells", odsMaxCells)
+)
+
+// readODSRows reads the displayed cell text of sheetName from an
+// OpenDocument spreadsheet. Trailing blank cells and rows are dropped the
+// same way excelize does for xlsx.
+func readODSRows(content []byte, sheetName string) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	var contentFile *zip.File
+	for _, file := range archive.File {
+		if file.Name == "content.xml" {
+			contentFile = file
+			break
+		}
+	}
+	if contentFile == nil {
+		return nil, fmt.Errorf("ods file has no content.xml")
+	}
+
+	reader, err := contentFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("sheet %s does not exist", sheetName)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || !isODSElement(start.Name, odsTableNamespace, "table") {
+			continue
+		}
+		if odsAttr(start, odsTableNamespace, "name") != sheetName {
+			if err := decoder.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return readODSTable(decoder)
+	}
+}
+
+func readODSTable(decoder *xml.Decoder) ([][]string, error) {
+	var rows [][]string
+	pendingEmptyRows := 0
+	cellCount := 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odsTableNamespace {
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "table-row":
+				cells, err := readODSRow(decoder)
+				if err != nil {
+					return nil, err
+				}
+				repeat := odsRepeat(t, "number-rows-repeated", odsMaxRows)
+				if len(cells) == 0 {
+					pendingEmptyRows += repeat
+					continue
+				}
+				if repeat > odsMaxRows-len(rows)-pendingEmptyRows {
+					return nil, errODSTooManyRows
+				}
+				if repeat > (odsMaxCells-cellCount)/len(cells) {
+					return nil, errODSTooManyCells
+				}
+				for ; pendingEmptyRows > 0; pendingEmptyRows-- {
+					rows = append(rows, []string{})
+				}
+				for r := 0; r < repeat; r++ {
+					rows = append(rows, append([]string(nil), cells...))
+				}
+				cellCount += repeat * len(cells)
+			case "table-header-rows", "table-row-group", "table-rows":
+				// rows nested in groups are read by the surrounding loop
+			default:
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if isODSElement(t.Name, odsTableNamespace, "table") {
+				return rows, nil
+			}
+		}
+	}
+}
+
+func readODSRow(decoder *xml.Decoder) ([]string, error) {
+	var cells []string
+	pendingEmptyCells := 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !isODSElement(t.Name, odsTableNamespace, "table-cell") &&
+				!isODSElement(t.Name, odsTableNamespace, "covered-table-cell") {
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			value, err := readODSCellText(decoder)
+			if err != nil {
+				return nil, err
+			}
+			repeat := odsRepeat(t, "number-columns-repeated", odsMaxColumns)
+			if value == "" {
+				pendingEmptyCells += repeat
+				continue
+			}
+			if repeat > odsMaxColumns-len(cells)-pendingEmptyCells {
+				return nil, errODSTooManyColumns
+			}
+			for ; pendingEmptyCells > 0; pendingEmptyCells-- {
+				cells = append(cells, "")
+			}
+			for c := 0; c < repeat; c++ {
+				cells = append(cells, value)
+			}
+		case xml.EndElement:
+			return cells, nil
+		}
+	}
+}
+
+// readODSCellText collects the text paragraphs of a cell, expanding the
+// whitespace elements ODS uses in place of literal spaces and tabs.
+func readODSCellText(decoder *xml.Decoder) (string, error) {
+	var paragraphs []string
+	var current strings.Builder
+	depth := 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if isODSElement(t.Name, odsOfficeNamespace, "annotation") {
+				if err := decoder.Skip(); err != nil {
+					return "", err
+				}
+				continue
+			}
+			depth++
+			if t.Name.Space != odsTextNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				current.Reset()
+			case "s":
+				spaces, err := strconv.Atoi(odsAttr(t, odsTextNamespace, "c"))
+				if err != nil || spaces < 1 {
+					spaces = 1
+				}
+				current.WriteString(strings.Repeat(" ", spaces))
+			case "tab":
+				current.WriteString("\t")
+			case "line-break":
+				current.WriteString("\n")
+			}
+		case xml.CharData:
+			if depth > 0 {
+				current.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				return strings.Join(paragraphs, "\n"), nil
+			}
+			depth--
+			if isODSElement(t.Name, odsTextNamespace, "p") || isODSElement(t.Name, odsTextNamespace, "h") {
+				paragraphs = append(paragraphs, current.String())
+			}
+		}
+	}
+}
+
+func isODSElement(name xml.Name, space string, local string) bool {
+	return name.Space == space && name.Local == local
+}
+
+func odsAttr(start xml.StartElement, space string, local string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat returns the repeat count of a row or cell. Counts over limit are
+// clamped to limit+1, which is still rejected once materialized but cannot
+// overflow when blank repeats are summed up.
+func odsRepeat(start xml.StartElement, attrName string, limit int) int {
+	repeat, err := strconv.Atoi(odsAttr(start, odsTableNamespace, attrName))
+	if err != nil || repeat < 1 {
+		return 1
+	}
+	return min(repeat, limit+1)
+}
@@ -0,0 +1,36 @@
+package importer
+
+import "github.com/kokkoniemi/texinroistot/internal/db"
+
+const (
+	StageParsing    = db.ImportJobParsing
+	StagePersisting = db.ImportJobPersisting
+)
+
+// Progress is a snapshot of a running import. Parsed is known once the
+// parsing stage ends; Persisted grows entity kind by entity kind while the
+// new version is written.
+type Progress struct {
+	Stage     string
+	VersionID int
+	Rows      int
+	Parsed    db.ImportEntityCounts
+	Persisted db.ImportEntityCounts
+}
+
+type ProgressFunc func(Progress)
+
+func (i *importer) reportProgress() {
+	if i.onProgress != nil {
+		i.onProgress(i.progress)
+	}
+}
+
+func (i *importer) parsedCounts() db.ImportEntityCounts {
+	return db.ImportEntityCounts{
+		Authors:      len(i.authors),
+		Publications: len(i.publications),
+		Stories:      len(i.stories),
+		Villains:     len(i.villains),
+	}
+}
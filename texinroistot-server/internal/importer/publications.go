@@ -129,7 +129,7 @@ func (i *importer) handleBasePublications(
 		to = from
 	}
 
-	titles := strings.Split(r.getValue(titleCol), ";")
+	titles := i.splitValues(r.getValue(titleCol))
 	if len(titles) == 0 {
 		return fmt.Errorf("title is missing")
 	}
@@ -176,7 +176,7 @@ func (i *importer) loadItalianBasePublication(storyID id, r row) error {
 
 // parseNonBaseTitle parses the title for publications other than PUB_PERUS, PUB_IT_PERUS, PUB_IT_ERIK
 func (i *importer) parseNonBaseTitle(pubType string, r row) (string, error) {
-	titles := strings.Split(r.getValue("story_title"), ";")
+	titles := i.splitValues(r.getValue("story_title"))
 	if len(titles) == 0 {
 		return "", fmt.Errorf("Could not find title")
 	}
@@ -252,7 +252,7 @@ func (i *importer) loadItalianSpecialPublication(storyID id, r row) error {
 		Issue: val,
 	}
 
-	titles := strings.Split(r.getValue("italy_story_title"), ";")
+	titles := i.splitValues(r.getValue("italy_story_title"))
 	if len(titles) == 0 {
 		return fmt.Errorf("title is missing")
 	}
@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime/debug"
+)
+
+// BuildRevision overrides the revision read from the Go build info. Docker
+// builds have no VCS data and set it with
+// -ldflags "-X github.com/kokkoniemi/texinroistot/internal/importer.BuildRevision=<rev>".
+var BuildRevision string
+
+// Revision identifies the importer build that created a version. It is
+// empty when the binary carries no revision, e.g. with go run.
+func Revision() string {
+	if BuildRevision != "" {
+		return BuildRevision
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// ContentHash is the sha256 of imported spreadsheet content. Scheduled
+// imports compare it to skip unchanged files.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,19 @@
+package importer
+
+import "testing"
+
+func TestRevisionUsesBuildRevision(t *testing.T) {
+	BuildRevision = "abc1234"
+	t.Cleanup(func() { BuildRevision = "" })
+
+	if got := Revision(); got != "abc1234" {
+		t.Fatalf("expected build revision, got %q", got)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := ContentHash(nil); got != emptySHA256 {
+		t.Fatalf("expected sha256 of empty content, got %q", got)
+	}
+}
@@ -1,49 +1,145 @@
 package importer
 
 import (
+	"archive/zip"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
 
+	"github.com/kokkoniemi/texinroistot/internal/config"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 	"github.com/xuri/excelize/v2"
 )
 
-const inputSheetName = "Taul1"
+type Format string
 
-func ImportSpreadsheetFromFile(path string) (*db.Version, error) {
-	file, err := excelize.OpenFile(path)
+const (
+	FormatXLSX Format = "xlsx"
+	FormatODS  Format = "ods"
+	FormatCSV  Format = "csv"
+)
+
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+var (
+	zipSignature     = []byte("PK\x03\x04")
+	oleSignature     = []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
+	ErrUnknownFormat = errors.New("unsupported spreadsheet format")
+)
+
+// DetectFormat identifies the spreadsheet format from the file signature.
+// Zip containers are told apart by their contents; anything else that is
+// valid UTF-8 text is treated as CSV.
+func DetectFormat(content []byte) (Format, error) {
+	if len(content) == 0 {
+		return "", fmt.Errorf("%w: empty file", ErrUnknownFormat)
+	}
+
+	if bytes.HasPrefix(content, zipSignature) {
+		archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrUnknownFormat, err)
+		}
+		for _, file := range archive.File {
+			switch file.Name {
+			case "mimetype":
+				mimeType, err := readZipFile(file)
+				if err == nil && string(bytes.TrimSpace(mimeType)) == odsMimeType {
+					return FormatODS, nil
+				}
+			case "xl/workbook.xml":
+				return FormatXLSX, nil
+			}
+		}
+		return "", fmt.Errorf("%w: zip archive is neither xlsx nor ods", ErrUnknownFormat)
+	}
+
+	if bytes.HasPrefix(content, oleSignature) {
+		return "", fmt.Errorf("%w: legacy .xls files are not supported", ErrUnknownFormat)
+	}
+	if utf8.Valid(content) && !bytes.ContainsRune(content, 0) {
+		return FormatCSV, nil
+	}
+
+	return "", fmt.Errorf("%w: not a zip archive or utf-8 text", ErrUnknownFormat)
+}
+
+// ReadRows returns the cell values of sheetName as rows of strings. CSV files
+// have a single sheet, so sheetName is ignored for them.
+func ReadRows(content []byte, sheetName string) ([][]string, error) {
+	format, err := DetectFormat(content)
 	if err != nil {
 		return nil, err
 	}
-	defer closeSpreadsheet(file)
 
-	return importSpreadsheet(file)
+	switch format {
+	case FormatODS:
+		return readODSRows(content, sheetName)
+	case FormatCSV:
+		return readCSVRows(content)
+	default:
+		return readXLSXRows(content, sheetName)
+	}
 }
 
-func ImportSpreadsheetFromBytes(content []byte) (*db.Version, error) {
-	file, err := excelize.OpenReader(bytes.NewReader(content))
+// Source describes where imported content came from. It is stored on the
+// created version together with the content hash and importer revision.
+type Source struct {
+	ImportedByID int
+	SourceURL    string
+	FileName     string
+}
+
+func ImportSpreadsheetFromFile(path string) (*db.Version, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer closeSpreadsheet(file)
 
-	return importSpreadsheet(file)
+	return ImportSpreadsheetWithProgress(content, Source{FileName: filepath.Base(path)}, nil)
 }
 
-func importSpreadsheet(file *excelize.File) (*db.Version, error) {
-	rows, err := file.GetRows(inputSheetName)
+func ImportSpreadsheetFromBytes(content []byte) (*db.Version, error) {
+	return ImportSpreadsheetWithProgress(content, Source{}, nil)
+}
+
+// ImportSpreadsheetWithProgress imports content like ImportSpreadsheetFromBytes
+// and reports each stage and entity count to report, which may be nil.
+func ImportSpreadsheetWithProgress(content []byte, source Source, report ProgressFunc) (*db.Version, error) {
+	rows, err := ReadRows(content, config.ImportSheetName)
 	if err != nil {
 		return nil, err
 	}
 
+	return importRows(rows, db.Version{
+		ImportedByID:     source.ImportedByID,
+		SourceURL:        source.SourceURL,
+		FileName:         source.FileName,
+		ContentHash:      ContentHash(content),
+		ImporterRevision: Revision(),
+	}, report)
+}
+
+func importRows(rows [][]string, metadata db.Version, report ProgressFunc) (*db.Version, error) {
 	if len(rows) <= 1 {
 		return nil, fmt.Errorf("no content")
 	}
 
-	spreadsheetImporter, err := NewSpreadsheetImporter(rows[0])
+	mapping, err := LoadColumnMapping(config.ImportColumnMappingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	spreadsheetImporter, err := NewSpreadsheetImporter(rows[0], mapping)
 	if err != nil {
 		return nil, err
 	}
+	spreadsheetImporter.OnProgress(report)
+	spreadsheetImporter.metadata = metadata
 	if err := spreadsheetImporter.LoadData(rows[1:]); err != nil {
 		return nil, err
 	}
@@ -51,6 +147,27 @@ func importSpreadsheet(file *excelize.File) (*db.Version, error) {
 	return spreadsheetImporter.PersistDataWithVersion()
 }
 
+func readXLSXRows(content []byte, sheetName string) ([][]string, error) {
+	file, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer closeSpreadsheet(file)
+
+	return file.GetRows(sheetName)
+}
+
 func closeSpreadsheet(file *excelize.File) error {
 	return file.Close()
 }
+
+// readZipFile reads a small metadata entry such as the ODS mimetype file.
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(io.LimitReader(reader, 1024))
+}
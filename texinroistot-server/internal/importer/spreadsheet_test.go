@@ -0,0 +1,227 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const odsContentTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+	xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet>
+<table:table table:name="Muistiinpanot">
+	<table:table-row><table:table-cell><text:p>ignored</text:p></table:table-cell></table:table-row>
+</table:table>
+<table:table table:name="Taul1">
+	<table:table-column table:number-columns-repeated="1024"/>
+	<table:table-header-rows>
+		<table:table-row>
+			<table:table-cell><text:p>Nimi</text:p></table:table-cell>
+			<table:table-cell table:number-columns-repeated="2"/>
+			<table:table-cell><text:p>Rooli</text:p></table:table-cell>
+			<table:table-cell table:number-columns-repeated="1020"/>
+		</table:table-row>
+	</table:table-header-rows>
+	<table:table-row>
+		<table:table-cell table:number-columns-spanned="2"><text:p>Mefisto<text:s text:c="2"/>Jr</text:p><text:p>toinen</text:p></table:table-cell>
+		<table:covered-table-cell/>
+		<table:table-cell><office:annotation><text:p>comment</text:p></office:annotation><text:p><text:span>a</text:span><text:tab/>b</text:p></table:table-cell>
+		<table:table-cell table:number-columns-repeated="2"><text:p>x</text:p></table:table-cell>
+	</table:table-row>
+	<table:table-row table:number-rows-repeated="2"><table:table-cell/></table:table-row>
+	<table:table-row><table:table-cell><text:p>viimeinen</text:p></table:table-cell></table:table-row>
+	<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+</table:table>
+</office:spreadsheet></office:body>
+</office:document-content>`
+
+func mustBuildZip(t *testing.T, nameAndBody ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for i := 0; i+1 < len(nameAndBody); i += 2 {
+		writer, err := archive.Create(nameAndBody[i])
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", nameAndBody[i], err)
+		}
+		if _, err := writer.Write([]byte(nameAndBody[i+1])); err != nil {
+			t.Fatalf("failed to write %s: %v", nameAndBody[i], err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("failed to close zip archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func mustBuildODS(t *testing.T, content string) []byte {
+	t.Helper()
+	return mustBuildZip(t, "mimetype", odsMimeType, "content.xml", content)
+}
+
+func mustBuildXLSX(t *testing.T, sheetName string, rows [][]string) []byte {
+	t.Helper()
+
+	file := excelize.NewFile()
+	defer file.Close()
+	if err := file.SetSheetName("Sheet1", sheetName); err != nil {
+		t.Fatalf("failed to rename sheet: %v", err)
+	}
+	for r, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, r+1)
+		values := make([]interface{}, len(row))
+		for i, value := range row {
+			values[i] = value
+		}
+		if err := file.SetSheetRow(sheetName, cell, &values); err != nil {
+			t.Fatalf("failed to write row: %v", err)
+		}
+	}
+	buf, err := file.WriteToBuffer()
+	if err != nil {
+		t.Fatalf("failed to build xlsx: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	cases := map[string]struct {
+		content []byte
+		format  Format
+	}{
+		"xlsx": {mustBuildXLSX(t, "Taul1", [][]string{{"a"}}), FormatXLSX},
+		"ods":  {mustBuildODS(t, odsContentTemplate), FormatODS},
+		"csv":  {[]byte("Nimi;Rooli\nMefisto;johtaja\n"), FormatCSV},
+	}
+	for name, tc := range cases {
+		format, err := DetectFormat(tc.content)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if format != tc.format {
+			t.Fatalf("%s: expected %s, got %s", name, tc.format, format)
+		}
+	}
+}
+
+func TestDetectFormatRejectsUnknownContent(t *testing.T) {
+	for name, content := range map[string][]byte{
+		"empty":  nil,
+		"xls":    append([]byte(nil), oleSignature...),
+		"binary": {0xff, 0x00, 0x10},
+		"zip":    mustBuildZip(t, "readme.txt", "hello"),
+	} {
+		if _, err := DetectFormat(content); !errors.Is(err, ErrUnknownFormat) {
+			t.Fatalf("%s: expected ErrUnknownFormat, got %v", name, err)
+		}
+	}
+}
+
+func TestReadRowsFromODS(t *testing.T) {
+	rows, err := ReadRows(mustBuildODS(t, odsContentTemplate), "Taul1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := [][]string{
+		{"Nimi", "", "", "Rooli"},
+		{"Mefisto  Jr\ntoinen", "", "a\tb", "x", "x"},
+		{},
+		{},
+		{"viimeinen"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("unexpected rows:\n got %q\nwant %q", rows, expected)
+	}
+}
+
+func TestReadRowsFromODSRequiresSheet(t *testing.T) {
+	if _, err := ReadRows(mustBuildODS(t, odsContentTemplate), "Sheet1"); err == nil {
+		t.Fatalf("expected missing sheet error")
+	}
+}
+
+func TestReadRowsFromXLSXUsesSheetName(t *testing.T) {
+	content := mustBuildXLSX(t, "Roistot", [][]string{{"Nimi", "Rooli"}, {"Mefisto", "johtaja"}})
+
+	rows, err := ReadRows(content, "Roistot")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rows, [][]string{{"Nimi", "Rooli"}, {"Mefisto", "johtaja"}}) {
+		t.Fatalf("unexpected rows %q", rows)
+	}
+
+	if _, err := ReadRows(content, "Taul1"); err == nil {
+		t.Fatalf("expected missing sheet error")
+	}
+}
+
+func TestReadRowsFromCSV(t *testing.T) {
+	cases := map[string]string{
+		"comma":     "Nimi,Rooli\n\"Mefisto, Jr\",johtaja\n",
+		"semicolon": "\xEF\xBB\xBFNimi;Rooli\r\n\"Mefisto, Jr\";johtaja\r\n",
+		"tab":       "Nimi\tRooli\nMefisto, Jr\tjohtaja\n",
+	}
+	expected := [][]string{{"Nimi", "Rooli"}, {"Mefisto, Jr", "johtaja"}}
+
+	for name, content := range cases {
+		rows, err := ReadRows([]byte(content), "ignored")
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Fatalf("%s: unexpected rows %q", name, rows)
+		}
+	}
+}
+
+func TestReadRowsFromODSRejectsHostileRepeats(t *testing.T) {
+	odsSheet := func(rows string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+	xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet><table:table table:name="Taul1">` + rows + `</table:table></office:spreadsheet></office:body>
+</office:document-content>`
+	}
+
+	cases := map[string]struct {
+		rows string
+		err  error
+	}{
+		"repeated rows": {
+			rows: `<table:table-row table:number-rows-repeated="9223372036854775807"><table:table-cell><text:p>x</text:p></table:table-cell></table:table-row>`,
+			err:  errODSTooManyRows,
+		},
+		"blank rows before content": {
+			rows: `<table:table-row table:number-rows-repeated="99999"><table:table-cell/></table:table-row>` +
+				`<table:table-row table:number-rows-repeated="99999"><table:table-cell/></table:table-row>` +
+				`<table:table-row><table:table-cell><text:p>x</text:p></table:table-cell></table:table-row>`,
+			err: errODSTooManyRows,
+		},
+		"repeated columns": {
+			rows: `<table:table-row><table:table-cell table:number-columns-repeated="16384"><text:p>x</text:p></table:table-cell></table:table-row>`,
+			err:  errODSTooManyColumns,
+		},
+		"repeated rows of wide cells": {
+			rows: `<table:table-row table:number-rows-repeated="50000"><table:table-cell table:number-columns-repeated="1000"><text:p>x</text:p></table:table-cell></table:table-row>`,
+			err:  errODSTooManyCells,
+		},
+	}
+	for name, tc := range cases {
+		rows, err := ReadRows(mustBuildODS(t, odsSheet(tc.rows)), "Taul1")
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected %v, got %v (%d rows)", name, tc.err, err, len(rows))
+		}
+	}
+}
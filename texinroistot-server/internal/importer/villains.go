@@ -91,14 +91,14 @@ func (i *importer) loadVillain(storyID id, r row) error {
 	if err != nil {
 		villainID = -1
 	}
-	villainRanks := i.TrimmedSplit(r.getValue("ranks"), ";")
-	firstNames := i.TrimmedSplit(r.getValue("first_names"), ";")
+	villainRanks := i.TrimmedSplit(r.getValue("ranks"), i.delimiter)
+	firstNames := i.TrimmedSplit(r.getValue("first_names"), i.delimiter)
 	lastName := r.getValue("last_name")
-	nicknames := i.TrimmedSplit(r.getValue("nicknames"), ";")
-	otherNames := i.TrimmedSplit(r.getValue("other_names"), ";")
-	codeNames := i.TrimmedSplit(r.getValue("code_names"), ";")
-	roles := i.TrimmedSplit(r.getValue("roles"), ";")
-	destiny := i.TrimmedSplit(r.getValue("destiny"), ";")
+	nicknames := i.TrimmedSplit(r.getValue("nicknames"), i.delimiter)
+	otherNames := i.TrimmedSplit(r.getValue("other_names"), i.delimiter)
+	codeNames := i.TrimmedSplit(r.getValue("code_names"), i.delimiter)
+	roles := i.TrimmedSplit(r.getValue("roles"), i.delimiter)
+	destiny := i.TrimmedSplit(r.getValue("destiny"), i.delimiter)
 
 	var villain *importerVillain
 
@@ -10,6 +10,7 @@ import (
 
 func newVillainTestImporter() *importer {
 	return &importer{
+		delimiter: defaultDelimiter,
 		columnIndexes: map[string]int{
 			"villain_id":  0,
 			"ranks":       1,
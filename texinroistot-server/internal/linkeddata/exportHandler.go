@@ -0,0 +1,93 @@
+package linkeddata
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+const exportPageSize = 100
+
+// ExportHandler dumps the whole active version as a single JSON-LD graph.
+func ExportHandler(c *fiber.Ctx) error {
+	versionRepo := db.NewVersionRepository()
+	version, err := versionRepo.GetActive()
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to load active version"))
+	}
+
+	authorRepo := db.NewAuthorRepository()
+	authors, err := authorRepo.List(version)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list authors"))
+	}
+
+	stories, err := listAllStories(version)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list stories"))
+	}
+
+	villains, err := listAllVillains(version)
+	if err != nil {
+		return c.Status(500).JSON(api.Error("failed to list villains"))
+	}
+
+	nodes := make([]Node, 0, len(authors)+len(stories)+len(villains))
+	nodes = append(nodes, Authors(authors)...)
+	nodes = append(nodes, Stories(stories)...)
+	nodes = append(nodes, Villains(villains)...)
+
+	c.Set(
+		fiber.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="texinroistot-v%d.jsonld"`, version.ID),
+	)
+	return c.JSON(Graph(nodes), MIMEApplicationLDJSON)
+}
+
+func listAllStories(version *db.Version) ([]*db.Story, error) {
+	storyRepo := db.NewStoryRepository()
+	params := db.StoryListParams{
+		Publication: "all",
+		Sort:        "fi_pub_date",
+		Page:        1,
+		PageSize:    exportPageSize,
+	}
+
+	var stories []*db.Story
+	for {
+		page, total, err := storyRepo.ListFiltered(version, params)
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, page...)
+		if len(page) == 0 || len(stories) >= total {
+			return stories, nil
+		}
+		params.Page++
+	}
+}
+
+func listAllVillains(version *db.Version) ([]*db.Villain, error) {
+	villainRepo := db.NewVillainRepository()
+	params := db.VillainListParams{
+		Publication: "all",
+		Sort:        "default",
+		Page:        1,
+		PageSize:    exportPageSize,
+	}
+
+	var villains []*db.Villain
+	for {
+		page, total, err := villainRepo.ListFiltered(version, params)
+		if err != nil {
+			return nil, err
+		}
+		villains = append(villains, page...)
+		if len(page) == 0 || len(villains) >= total {
+			return villains, nil
+		}
+		params.Page++
+	}
+}
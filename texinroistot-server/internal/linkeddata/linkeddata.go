@@ -0,0 +1,360 @@
+package linkeddata
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+const (
+	MIMEApplicationLDJSON = "application/ld+json"
+	schemaContext         = "https://schema.org"
+	hydraNamespace        = "http://www.w3.org/ns/hydra/core#"
+	idPrefix              = "urn:texinroistot:"
+	// Wikidata item for "fictional character"; schema.org has no dedicated type for it.
+	fictionalCharacterType = "http://www.wikidata.org/entity/Q95074"
+)
+
+// Node is a single JSON-LD object.
+type Node map[string]interface{}
+
+var publicationSeriesNames = map[string]string{
+	"perus":                        "Tex Willer (perussarja)",
+	"maxi":                         "Maxi-Tex",
+	"suur":                         "Tex Willer Suuralbumi",
+	"muu_erikois":                  "Tex Willer (erikoisjulkaisu)",
+	"kronikka":                     "Tex Willer Kronikka",
+	"kirjasto":                     "Tex Willer Kirjasto",
+	"italia_perus":                 "Tex (serie regolare)",
+	"italia_erikois":               "Tex (speciale)",
+	"italia_serie_extra":           "Tex Serie Extra",
+	"italia_texone":                "Texone",
+	"italia_mini_texone_maxi_tex":  "Maxi Tex / Mini Texone",
+	"italia_almanacco_del_west":    "Almanacco del West",
+	"italia_color_tex":             "Color Tex",
+	"italia_tex_romanzi_a_fumetti": "Tex Romanzi a Fumetti",
+	"italia_tex_magazine":          "Tex Magazine",
+}
+
+// Requested reports whether the client prefers JSON-LD over plain JSON. The
+// response depends on Accept either way, so Vary is set for both.
+func Requested(c *fiber.Ctx) bool {
+	c.Vary(fiber.HeaderAccept)
+	return c.Accepts(fiber.MIMEApplicationJSON, MIMEApplicationLDJSON) == MIMEApplicationLDJSON
+}
+
+// Send writes nodes as a JSON-LD graph document.
+func Send(c *fiber.Ctx, nodes []Node) error {
+	return c.JSON(Graph(nodes), MIMEApplicationLDJSON)
+}
+
+// SendPage writes one page of a list as a JSON-LD graph document. The total
+// and the links to other pages are described with the Hydra vocabulary.
+func SendPage(c *fiber.Ctx, nodes []Node, meta api.PageMeta) error {
+	document := Graph(nodes)
+	document["@context"] = []interface{}{schemaContext, Node{"hydra": hydraNamespace}}
+	document["hydra:totalItems"] = meta.Total
+
+	view := Node{
+		"@id":         pageURL(c, meta.Page),
+		"@type":       "hydra:PartialCollectionView",
+		"hydra:first": pageURL(c, 1),
+	}
+	if meta.TotalPages > 0 {
+		view["hydra:last"] = pageURL(c, meta.TotalPages)
+	}
+	if meta.Page > 1 {
+		view["hydra:previous"] = pageURL(c, meta.Page-1)
+	}
+	if meta.Page < meta.TotalPages {
+		view["hydra:next"] = pageURL(c, meta.Page+1)
+	}
+	document["hydra:view"] = view
+
+	return c.JSON(document, MIMEApplicationLDJSON)
+}
+
+// pageURL is the URL of the request with page replaced, relative to the
+// host so that it works behind the UI proxy too.
+func pageURL(c *fiber.Ctx, page int) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Set("page", strconv.Itoa(page))
+	return c.Path() + "?" + query.Encode()
+}
+
+func Graph(nodes []Node) Node {
+	if nodes == nil {
+		nodes = []Node{}
+	}
+	return Node{
+		"@context": schemaContext,
+		"@graph":   nodes,
+	}
+}
+
+func StoryID(hash string) string {
+	return idPrefix + "story:" + hash
+}
+
+func VillainID(hash string) string {
+	return idPrefix + "villain:" + hash
+}
+
+func AuthorID(hash string) string {
+	return idPrefix + "author:" + hash
+}
+
+func PublicationID(hash string) string {
+	return idPrefix + "publication:" + hash
+}
+
+func Stories(stories []*db.Story) []Node {
+	nodes := make([]Node, 0, len(stories))
+	for _, s := range stories {
+		if s == nil {
+			continue
+		}
+		nodes = append(nodes, Story(s))
+	}
+	return nodes
+}
+
+func Story(s *db.Story) Node {
+	node := Node{
+		"@type":      "ComicStory",
+		"@id":        StoryID(s.Hash),
+		"identifier": s.Hash,
+	}
+	if name := storyName(s); name != "" {
+		node["name"] = name
+	}
+	if s.OrderNumber != 0 {
+		node["position"] = s.OrderNumber
+	}
+	if authors := authorRefs(s.WrittenBy); len(authors) > 0 {
+		node["author"] = authors
+	}
+	if authors := authorRefs(s.DrawnBy); len(authors) > 0 {
+		node["illustrator"] = authors
+	}
+	if authors := authorRefs(s.TranslatedBy); len(authors) > 0 {
+		node["translator"] = authors
+	}
+
+	var issues []Node
+	for _, sp := range s.Publications {
+		if sp == nil || sp.In == nil {
+			continue
+		}
+		issues = append(issues, publicationIssue(sp))
+	}
+	if len(issues) > 0 {
+		node["isPartOf"] = issues
+	}
+
+	return node
+}
+
+// storyName prefers the Finnish base series title, then any other title.
+func storyName(s *db.Story) string {
+	fallback := ""
+	for _, sp := range s.Publications {
+		if sp == nil {
+			continue
+		}
+		title := strings.TrimSpace(sp.Title)
+		if title == "" {
+			continue
+		}
+		if sp.In != nil && sp.In.Type == "perus" {
+			return title
+		}
+		if fallback == "" {
+			fallback = title
+		}
+	}
+	return fallback
+}
+
+func publicationIssue(sp *db.StoryPublication) Node {
+	p := sp.In
+	issue := Node{
+		"@type":       "ComicIssue",
+		"@id":         PublicationID(p.Hash),
+		"issueNumber": p.Issue,
+	}
+	if p.Year > 0 {
+		issue["datePublished"] = strconv.Itoa(p.Year)
+	}
+	if title := strings.TrimSpace(sp.Title); title != "" {
+		issue["headline"] = title
+	}
+	if strings.HasPrefix(p.Type, "italia_") {
+		issue["inLanguage"] = "it"
+	} else {
+		issue["inLanguage"] = "fi"
+	}
+	if series, ok := publicationSeriesNames[p.Type]; ok {
+		issue["isPartOf"] = Node{
+			"@type":      "Periodical",
+			"name":       series,
+			"identifier": p.Type,
+		}
+	}
+	return issue
+}
+
+func Authors(authors []*db.Author) []Node {
+	nodes := make([]Node, 0, len(authors))
+	for _, a := range authors {
+		if a == nil {
+			continue
+		}
+		nodes = append(nodes, Author(a))
+	}
+	return nodes
+}
+
+func Author(a *db.Author) Node {
+	node := Node{
+		"@type":      "Person",
+		"@id":        AuthorID(a.Hash),
+		"identifier": a.Hash,
+		"name":       fullName(a.FirstName, a.LastName),
+	}
+	if a.FirstName != "" {
+		node["givenName"] = a.FirstName
+	}
+	if a.LastName != "" {
+		node["familyName"] = a.LastName
+	}
+
+	var jobTitles []string
+	if a.IsWriter {
+		jobTitles = append(jobTitles, "writer")
+	}
+	if a.IsDrawer {
+		jobTitles = append(jobTitles, "illustrator")
+	}
+	if a.IsTranslator {
+		jobTitles = append(jobTitles, "translator")
+	}
+	if len(jobTitles) > 0 {
+		node["jobTitle"] = jobTitles
+	}
+	return node
+}
+
+func authorRefs(authors []*db.Author) []Node {
+	var refs []Node
+	for _, a := range authors {
+		if a == nil {
+			continue
+		}
+		ref := Node{
+			"@type": "Person",
+			"@id":   AuthorID(a.Hash),
+			"name":  fullName(a.FirstName, a.LastName),
+		}
+		if a.Details != "" {
+			ref["description"] = a.Details
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+func Villains(villains []*db.Villain) []Node {
+	nodes := make([]Node, 0, len(villains))
+	for _, v := range villains {
+		if v == nil {
+			continue
+		}
+		nodes = append(nodes, Villain(v))
+	}
+	return nodes
+}
+
+func Villain(v *db.Villain) Node {
+	firstNames := strings.Join(nonEmpty(v.FirstNames), " ")
+	node := Node{
+		"@type":          "Person",
+		"@id":            VillainID(v.Hash),
+		"additionalType": fictionalCharacterType,
+		"identifier":     v.Hash,
+	}
+	if name := fullName(firstNames, v.LastName); name != "" {
+		node["name"] = name
+	}
+	if firstNames != "" {
+		node["givenName"] = firstNames
+	}
+	if v.LastName != "" {
+		node["familyName"] = v.LastName
+	}
+	if ranks := nonEmpty(v.Ranks); len(ranks) > 0 {
+		node["honorificPrefix"] = strings.Join(ranks, " ")
+	}
+
+	var alternateNames []string
+	var appearances []Node
+	for _, sv := range v.As {
+		if sv == nil {
+			continue
+		}
+		alternateNames = appendUnique(alternateNames, sv.Nicknames...)
+		alternateNames = appendUnique(alternateNames, sv.OtherNames...)
+		alternateNames = appendUnique(alternateNames, sv.CodeNames...)
+		if sv.Story != nil {
+			appearance := Node{
+				"@type": "ComicStory",
+				"@id":   StoryID(sv.Story.Hash),
+			}
+			if name := storyName(sv.Story); name != "" {
+				appearance["name"] = name
+			}
+			appearances = append(appearances, appearance)
+		}
+	}
+	if len(alternateNames) > 0 {
+		node["alternateName"] = alternateNames
+	}
+	if len(appearances) > 0 {
+		node["subjectOf"] = appearances
+	}
+	return node
+}
+
+func fullName(firstName string, lastName string) string {
+	return strings.TrimSpace(strings.TrimSpace(firstName) + " " + strings.TrimSpace(lastName))
+}
+
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+func appendUnique(values []string, candidates ...string) []string {
+	for _, candidate := range nonEmpty(candidates) {
+		found := false
+		for _, value := range values {
+			if value == candidate {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, candidate)
+		}
+	}
+	return values
+}
@@ -0,0 +1,183 @@
+package linkeddata
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+func TestStoryMapsCreatorsAndPublications(t *testing.T) {
+	story := &db.Story{
+		Hash:        "s1",
+		OrderNumber: 12,
+		WrittenBy:   []*db.Author{{Hash: "w1", FirstName: "Gian Luigi", LastName: "Bonelli"}},
+		DrawnBy:     []*db.Author{{Hash: "d1", FirstName: "Aurelio", LastName: "Galleppini"}},
+		TranslatedBy: []*db.Author{
+			{Hash: "t1", FirstName: "Matti", LastName: "Virtanen", Details: "2. - 3. p"},
+		},
+		Publications: []*db.StoryPublication{
+			{Title: "La mano rossa", In: &db.Publication{Hash: "p2", Type: "italia_perus", Year: 1958, Issue: "1"}},
+			{Title: "Punainen käsi", In: &db.Publication{Hash: "p1", Type: "perus", Year: 1971, Issue: "5"}},
+		},
+	}
+
+	node := Story(story)
+
+	if node["@type"] != "ComicStory" {
+		t.Fatalf("expected ComicStory, got %v", node["@type"])
+	}
+	if node["@id"] != "urn:texinroistot:story:s1" {
+		t.Fatalf("unexpected @id %v", node["@id"])
+	}
+	if node["name"] != "Punainen käsi" {
+		t.Fatalf("expected Finnish base series title as name, got %v", node["name"])
+	}
+
+	illustrators, ok := node["illustrator"].([]Node)
+	if !ok || len(illustrators) != 1 || illustrators[0]["name"] != "Aurelio Galleppini" {
+		t.Fatalf("unexpected illustrator %v", node["illustrator"])
+	}
+	translators, ok := node["translator"].([]Node)
+	if !ok || len(translators) != 1 || translators[0]["description"] != "2. - 3. p" {
+		t.Fatalf("unexpected translator %v", node["translator"])
+	}
+
+	issues, ok := node["isPartOf"].([]Node)
+	if !ok || len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %v", node["isPartOf"])
+	}
+	if issues[0]["inLanguage"] != "it" || issues[0]["datePublished"] != "1958" {
+		t.Fatalf("unexpected italian issue %v", issues[0])
+	}
+	series, ok := issues[1]["isPartOf"].(Node)
+	if !ok || series["identifier"] != "perus" {
+		t.Fatalf("unexpected series %v", issues[1]["isPartOf"])
+	}
+}
+
+func TestVillainCollectsAlternateNamesAndAppearances(t *testing.T) {
+	villain := &db.Villain{
+		Hash:       "v1",
+		Ranks:      []string{"Kapteeni"},
+		FirstNames: []string{"John", ""},
+		LastName:   "Doe",
+		As: []*db.StoryVillain{
+			{Nicknames: []string{"Kettu"}, CodeNames: []string{"Ghost"}, Story: &db.Story{Hash: "s1"}},
+			{Nicknames: []string{"Kettu"}, Story: &db.Story{Hash: "s2"}},
+		},
+	}
+
+	node := Villain(villain)
+
+	if node["name"] != "John Doe" {
+		t.Fatalf("unexpected name %v", node["name"])
+	}
+	if node["honorificPrefix"] != "Kapteeni" {
+		t.Fatalf("unexpected honorificPrefix %v", node["honorificPrefix"])
+	}
+	names, ok := node["alternateName"].([]string)
+	if !ok || len(names) != 2 {
+		t.Fatalf("expected deduplicated alternate names, got %v", node["alternateName"])
+	}
+	appearances, ok := node["subjectOf"].([]Node)
+	if !ok || len(appearances) != 2 || appearances[1]["@id"] != "urn:texinroistot:story:s2" {
+		t.Fatalf("unexpected appearances %v", node["subjectOf"])
+	}
+}
+
+func TestRequestedUsesAcceptHeader(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		if Requested(c) {
+			return c.SendString("jsonld")
+		}
+		return c.SendString("json")
+	})
+
+	cases := map[string]bool{
+		"":                    false,
+		"*/*":                 false,
+		"application/json":    false,
+		"application/ld+json": true,
+		"application/ld+json, application/json;q=0.5": true,
+	}
+	for accept, want := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatalf("failed to read response body: %v", err)
+		}
+		if got := string(body) == "jsonld"; got != want {
+			t.Fatalf("Accept %q: expected jsonld=%v, got %q", accept, want, string(body))
+		}
+	}
+}
+
+func TestPlainJSONResponsesVaryOnAccept(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		if Requested(c) {
+			return Send(c, nil)
+		}
+		return c.JSON(fiber.Map{})
+	})
+
+	for _, accept := range []string{"application/json", "application/ld+json"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if vary := res.Header.Get("Vary"); vary != "Accept" {
+			t.Fatalf("Accept %q: expected Vary: Accept, got %q", accept, vary)
+		}
+	}
+}
+
+func TestSendPageDescribesPaging(t *testing.T) {
+	app := fiber.New()
+	app.Get("/api/stories", func(c *fiber.Ctx) error {
+		return SendPage(c, []Node{}, api.NewPageMeta(60, 2, 25))
+	})
+
+	res, err := app.Test(httptest.NewRequest("GET", "/api/stories?q=mefisto&page=2", nil))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var document map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&document); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+	if document["hydra:totalItems"] != float64(60) {
+		t.Fatalf("expected 60 total items, got %v", document["hydra:totalItems"])
+	}
+	view, ok := document["hydra:view"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected a hydra:view, got %v", document["hydra:view"])
+	}
+	want := map[string]string{
+		"@id":            "/api/stories?page=2&q=mefisto",
+		"hydra:first":    "/api/stories?page=1&q=mefisto",
+		"hydra:previous": "/api/stories?page=1&q=mefisto",
+		"hydra:next":     "/api/stories?page=3&q=mefisto",
+		"hydra:last":     "/api/stories?page=3&q=mefisto",
+	}
+	for key, url := range want {
+		if view[key] != url {
+			t.Fatalf("expected %s %q, got %v", key, url, view[key])
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package linkeddata
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:  "GET",
+			Path:    "/api/export/jsonld",
+			Summary: "Download the active version as a JSON-LD graph",
+			Tag:     "export",
+			Responses: map[int]openapi.Response{
+				200: {Body: Node{}, ContentType: MIMEApplicationLDJSON},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+	}
+}
@@ -0,0 +1,341 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+	ParamInQuery   = "query"
+	ParamInPath    = "path"
+	ParamInHeader  = "header"
+)
+
+// Parameter documents a single query or path parameter.
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Type        string // "string", "integer" or "boolean"
+	Enum        []string
+	Default     interface{}
+	Minimum     *int
+	Maximum     *int
+}
+
+// Response documents one status code. Body is a zero value of the response
+// type; its JSON schema is generated from the Go type.
+type Response struct {
+	Description string
+	Body        interface{}
+	ContentType string
+	// Alternatives lists additional content types served for the same status
+	// (for example JSON-LD via content negotiation) with a free-form schema.
+	Alternatives []string
+}
+
+// Operation documents one route. Path uses fiber syntax so that it can be
+// compared with the registered routes.
+type Operation struct {
+	Method    string
+	Path      string
+	Summary   string
+	Tag       string
+	Protected bool
+	// APIKey marks protected routes that also accept an API key as a
+	// bearer token.
+	APIKey bool
+	// Roles lists the user roles of which one is required, on top of
+	// Protected. Empty means any role.
+	Roles               []string
+	Parameters          []Parameter
+	RequestBody         interface{}
+	RequestContentType  string
+	RequestBodyOptional bool
+	Responses           map[int]Response
+}
+
+// Document is an OpenAPI 3 document built from a set of operations.
+type Document struct {
+	Operations []Operation
+	spec       map[string]interface{}
+	components map[string]interface{}
+	content    []byte
+}
+
+var (
+	fiberPathParam  = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+	nonAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// PathTemplate converts a fiber route path to an OpenAPI path template.
+func PathTemplate(path string) string {
+	return fiberPathParam.ReplaceAllString(path, "{$1}")
+}
+
+// Enum returns the sorted keys of an allow-list.
+func Enum(allowed map[string]bool) []string {
+	values := make([]string, 0, len(allowed))
+	for value, ok := range allowed {
+		if ok {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func IntPtr(value int) *int {
+	return &value
+}
+
+func NewDocument(title string, version string, operationGroups ...[]Operation) (*Document, error) {
+	builder := newSchemaBuilder()
+	paths := map[string]interface{}{}
+	var operations []Operation
+
+	for _, group := range operationGroups {
+		for _, op := range group {
+			operations = append(operations, op)
+
+			template := PathTemplate(op.Path)
+			pathItem, ok := paths[template].(map[string]interface{})
+			if !ok {
+				pathItem = map[string]interface{}{}
+				paths[template] = pathItem
+			}
+			method := strings.ToLower(op.Method)
+			if _, exists := pathItem[method]; exists {
+				return nil, fmt.Errorf("duplicate operation %s %s", op.Method, op.Path)
+			}
+			pathItem[method] = builder.operation(op)
+		}
+	}
+
+	spec := map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": builder.components,
+			"securitySchemes": map[string]interface{}{
+				"cookieAuth": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": "__Host-a",
+				},
+				"bearerAuth": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+	}
+
+	content, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		Operations: operations,
+		spec:       spec,
+		components: builder.components,
+		content:    content,
+	}, nil
+}
+
+// Handler serves the document as JSON.
+func (d *Document) Handler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(d.content)
+}
+
+// Find returns the operation documented for method and fiber path.
+func (d *Document) Find(method string, path string) (Operation, bool) {
+	for _, op := range d.Operations {
+		if strings.EqualFold(op.Method, method) && op.Path == path {
+			return op, true
+		}
+	}
+	return Operation{}, false
+}
+
+// ValidateResponse checks that status is documented for op and that body
+// matches the documented JSON schema.
+func (d *Document) ValidateResponse(op Operation, status int, body []byte) error {
+	response, ok := op.Responses[status]
+	if !ok {
+		return fmt.Errorf("%s %s: undocumented status %d", op.Method, op.Path, status)
+	}
+	if response.Body == nil {
+		return nil
+	}
+
+	schema := d.responseSchema(op, status)
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s %s: status %d body is not JSON: %v", op.Method, op.Path, status, err)
+	}
+	if err := d.validate(schema, value, "$"); err != nil {
+		return fmt.Errorf("%s %s: status %d: %v", op.Method, op.Path, status, err)
+	}
+	return nil
+}
+
+func (d *Document) responseSchema(op Operation, status int) map[string]interface{} {
+	paths := d.spec["paths"].(map[string]interface{})
+	pathItem := paths[PathTemplate(op.Path)].(map[string]interface{})
+	operation := pathItem[strings.ToLower(op.Method)].(map[string]interface{})
+	responses := operation["responses"].(map[string]interface{})
+	response := responses[strconv.Itoa(status)].(map[string]interface{})
+	content := response["content"].(map[string]interface{})
+	media := content[contentType(op.Responses[status].ContentType)].(map[string]interface{})
+	return media["schema"].(map[string]interface{})
+}
+
+func contentType(ct string) string {
+	if ct == "" {
+		return fiber.MIMEApplicationJSON
+	}
+	return ct
+}
+
+func (b *schemaBuilder) operation(op Operation) map[string]interface{} {
+	operation := map[string]interface{}{
+		"operationId": operationID(op),
+		"summary":     op.Summary,
+	}
+	if op.Tag != "" {
+		operation["tags"] = []string{op.Tag}
+	}
+	if op.Protected {
+		security := []map[string][]string{{"cookieAuth": {}}}
+		if op.APIKey {
+			security = append(security, map[string][]string{"bearerAuth": {}})
+		}
+		operation["security"] = security
+	}
+	if len(op.Roles) > 0 {
+		operation["description"] = "Requires one of the roles: " + strings.Join(op.Roles, ", ")
+		operation["x-required-roles"] = op.Roles
+	}
+
+	var parameters []map[string]interface{}
+	for _, p := range op.Parameters {
+		schema := map[string]interface{}{"type": p.Type}
+		if p.Type == "" {
+			schema["type"] = "string"
+		}
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+		if p.Default != nil {
+			schema["default"] = p.Default
+		}
+		if p.Minimum != nil {
+			schema["minimum"] = *p.Minimum
+		}
+		if p.Maximum != nil {
+			schema["maximum"] = *p.Maximum
+		}
+		param := map[string]interface{}{
+			"name":     p.Name,
+			"in":       p.In,
+			"required": p.Required || p.In == ParamInPath,
+			"schema":   schema,
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		parameters = append(parameters, param)
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	if op.RequestBody != nil {
+		operation["requestBody"] = map[string]interface{}{
+			"required": !op.RequestBodyOptional,
+			"content": map[string]interface{}{
+				contentType(op.RequestContentType): map[string]interface{}{
+					"schema": b.schemaForValue(op.RequestBody),
+				},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	for status, r := range op.Responses {
+		response := map[string]interface{}{"description": r.Description}
+		if r.Description == "" {
+			response["description"] = strconv.Itoa(status)
+		}
+		if r.Body != nil {
+			content := map[string]interface{}{
+				contentType(r.ContentType): map[string]interface{}{
+					"schema": b.schemaForValue(r.Body),
+				},
+			}
+			for _, alternative := range r.Alternatives {
+				content[alternative] = map[string]interface{}{
+					"schema": map[string]interface{}{"type": "object"},
+				}
+			}
+			response["content"] = content
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	operation["responses"] = responses
+
+	return operation
+}
+
+func operationID(op Operation) string {
+	var parts []string
+	for _, segment := range strings.Split(strings.Trim(op.Path, "/"), "/") {
+		if segment == "" || segment == "api" {
+			continue
+		}
+		for _, word := range nonAlphanumeric.Split(segment, -1) {
+			if word == "" {
+				continue
+			}
+			parts = append(parts, strings.ToUpper(word[:1])+word[1:])
+		}
+	}
+	return strings.ToLower(op.Method) + strings.Join(parts, "")
+}
+
+// PageParameters documents the page and pageSize query parameters shared by
+// the paginated list endpoints.
+func PageParameters(defaultPageSize int, maxPageSize int) []Parameter {
+	return []Parameter{
+		{
+			Name:    "page",
+			In:      ParamInQuery,
+			Type:    "integer",
+			Default: 1,
+			Minimum: IntPtr(1),
+		},
+		{
+			Name:        "pageSize",
+			In:          ParamInQuery,
+			Description: fmt.Sprintf("Values above %d are clamped to %d.", maxPageSize, maxPageSize),
+			Type:        "integer",
+			Default:     defaultPageSize,
+			Minimum:     IntPtr(1),
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"mime/multipart"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Name    string    `json:"name"`
+	Note    string    `json:"note,omitempty"`
+	Hidden  int       `json:"-"`
+	Created time.Time `json:"created"`
+}
+
+type testBody struct {
+	Items []*testItem `json:"items"`
+	Total int         `json:"total"`
+}
+
+func testDocument(t *testing.T) (*Document, Operation) {
+	t.Helper()
+
+	op := Operation{
+		Method: "GET",
+		Path:   "/api/items/:itemID",
+		Responses: map[int]Response{
+			200: {Body: testBody{}},
+		},
+	}
+	doc, err := NewDocument("test", "1", []Operation{op})
+	if err != nil {
+		t.Fatalf("failed to build document: %v", err)
+	}
+	return doc, op
+}
+
+func TestPathTemplateConvertsFiberParams(t *testing.T) {
+	if got := PathTemplate("/api/items/:itemID/parts/:partID"); got != "/api/items/{itemID}/parts/{partID}" {
+		t.Fatalf("unexpected template %q", got)
+	}
+}
+
+func TestOperationIDIsDerivedFromPath(t *testing.T) {
+	op := Operation{Method: "POST", Path: "/api/admin/users/grant-admin"}
+	if got := operationID(op); got != "postAdminUsersGrantAdmin" {
+		t.Fatalf("unexpected operationId %q", got)
+	}
+}
+
+func TestNewDocumentRejectsDuplicateOperations(t *testing.T) {
+	op := Operation{Method: "GET", Path: "/api/items"}
+	if _, err := NewDocument("test", "1", []Operation{op}, []Operation{op}); err == nil {
+		t.Fatalf("expected duplicate operation error")
+	}
+}
+
+func TestValidateResponseAcceptsMatchingBody(t *testing.T) {
+	doc, op := testDocument(t)
+
+	body := `{"items":[{"name":"a","created":"2024-01-01T00:00:00Z"},null],"total":2}`
+	if err := doc.ValidateResponse(op, 200, []byte(body)); err != nil {
+		t.Fatalf("expected valid body, got %v", err)
+	}
+}
+
+func TestValidateResponseRejectsDivergingBodies(t *testing.T) {
+	doc, op := testDocument(t)
+
+	for name, body := range map[string]string{
+		"missing required":     `{"items":[]}`,
+		"undocumented field":   `{"items":[],"total":1,"extra":true}`,
+		"wrong type":           `{"items":[],"total":"1"}`,
+		"nested missing field": `{"items":[{"name":"a"}],"total":1}`,
+	} {
+		if err := doc.ValidateResponse(op, 200, []byte(body)); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	if err := doc.ValidateResponse(op, 404, []byte(`{}`)); err == nil {
+		t.Errorf("expected undocumented status to fail")
+	}
+}
+
+func TestSchemaDescribesUploadedFilesAsBinary(t *testing.T) {
+	schema := newSchemaBuilder().schemaFor(reflect.TypeOf(struct {
+		File *multipart.FileHeader `form:"file"`
+	}{}))
+	file := schema["properties"].(map[string]interface{})["file"].(map[string]interface{})
+	if file["type"] != "string" || file["format"] != "binary" {
+		t.Fatalf("expected binary string schema, got %v", file)
+	}
+}
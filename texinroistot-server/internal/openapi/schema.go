@@ -0,0 +1,253 @@
+package openapi
+
+import (
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const componentsRefPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+type schemaBuilder struct {
+	components map[string]interface{}
+	types      map[string]reflect.Type
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: map[string]interface{}{},
+		types:      map[string]reflect.Type{},
+	}
+}
+
+func (b *schemaBuilder) schemaForValue(value interface{}) map[string]interface{} {
+	return b.schemaFor(reflect.TypeOf(value))
+}
+
+// schemaFor generates a JSON schema for t following encoding/json rules.
+// Named structs become components; nil-able Go values are marked nullable.
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := b.schemaFor(t.Elem())
+		if _, isRef := elem["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{elem}, "nullable": true}
+		}
+		elem["nullable"] = true
+		return elem
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    b.schemaFor(t.Elem()),
+			"nullable": t.Kind() == reflect.Slice,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": b.schemaFor(t.Elem()),
+			"nullable":             true,
+		}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		if t == fileHeaderType {
+			return map[string]interface{}{"type": "string", "format": "binary"}
+		}
+		if t.Name() == "" {
+			return b.objectSchema(t)
+		}
+		name := b.componentName(t)
+		if _, exists := b.components[name]; !exists {
+			// reserve the name first so recursive types terminate
+			b.components[name] = map[string]interface{}{}
+			b.components[name] = b.objectSchema(t)
+		}
+		return map[string]interface{}{"$ref": componentsRefPrefix + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (b *schemaBuilder) componentName(t reflect.Type) string {
+	name := t.Name()
+	if existing, ok := b.types[name]; ok && existing != t {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	b.types[name] = t
+	return name
+}
+
+func (b *schemaBuilder) objectSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	b.collectFields(t, properties, &required)
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (b *schemaBuilder) collectFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "" {
+			// request payloads parsed from forms only carry form tags
+			tag = field.Tag.Get("form")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.collectFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// validate checks a decoded JSON value against a generated schema. Only the
+// keywords produced by schemaFor are supported.
+func (d *Document) validate(schema map[string]interface{}, value interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		component, found := d.components[strings.TrimPrefix(ref, componentsRefPrefix)].(map[string]interface{})
+		if !found {
+			return fmt.Errorf("%s: unknown schema %s", path, ref)
+		}
+		return d.validate(component, value, path)
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable || len(schema) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", path)
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if err := d.validate(sub.(map[string]interface{}), value, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, present := object[name]; !present {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, propertyValue := range object {
+			propertySchema, documented := properties[name].(map[string]interface{})
+			if !documented {
+				if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+					propertySchema = additional
+				} else if allowed, _ := schema["additionalProperties"].(bool); !allowed && properties != nil {
+					return fmt.Errorf("%s: undocumented property %q", path, name)
+				} else {
+					continue
+				}
+			}
+			if err := d.validate(propertySchema, propertyValue, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		itemSchema, _ := schema["items"].(map[string]interface{})
+		for index, item := range items {
+			if err := d.validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if enum, ok := schema["enum"].([]string); ok && !containsString(enum, text) {
+			return fmt.Errorf("%s: %q is not one of %v", path, text, enum)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	}
+
+	return nil
+}
+
+func containsString(values []string, candidate string) bool {
+	for _, value := range values {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
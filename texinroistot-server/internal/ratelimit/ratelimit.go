@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+var store = NewMemoryStore()
+
+// Configure selects the store of ROISTOT_RATE_LIMIT_STORE.
+func Configure() error {
+	switch config.RateLimitStore {
+	case "memory":
+		store = NewMemoryStore()
+	case "postgres":
+		store = NewPostgresStore()
+	default:
+		return fmt.Errorf("unknown ROISTOT_RATE_LIMIT_STORE %q, expected memory or postgres", config.RateLimitStore)
+	}
+	return nil
+}
+
+// Enabled reports whether any of the configured limits is on.
+func Enabled() bool {
+	for _, rule := range []Rule{
+		{config.RateLimitPerMinute, config.RateLimitBurst},
+		{config.SearchRateLimitPerMinute, config.SearchRateLimitBurst},
+		{config.LoginRateLimitPerMinute, config.LoginRateLimitBurst},
+	} {
+		if !rule.disabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// Public limits the requests of each client to each route. Searches, requests
+// with the q parameter, take from the search budget of the client instead.
+func Public(c *fiber.Ctx) error {
+	if strings.TrimSpace(c.Query("q")) != "" {
+		return search(c)
+	}
+	key := "route:" + c.Method() + " " + c.Route().Path + ":" + c.IP()
+	return limit(c, key, Rule{config.RateLimitPerMinute, config.RateLimitBurst})
+}
+
+// GraphQL takes every GraphQL request from the search budget of the client.
+// Any query may search, through arguments or variables, and may also read
+// several lists at once.
+func GraphQL(c *fiber.Ctx) error {
+	return search(c)
+}
+
+func search(c *fiber.Ctx) error {
+	return limit(c, "search:"+c.IP(), Rule{config.SearchRateLimitPerMinute, config.SearchRateLimitBurst})
+}
+
+// Login limits the login attempts of each client, whichever provider they use.
+func Login(c *fiber.Ctx) error {
+	return limit(c, "login:"+c.IP(), Rule{config.LoginRateLimitPerMinute, config.LoginRateLimitBurst})
+}
+
+func limit(c *fiber.Ctx, key string, rule Rule) error {
+	if rule.disabled() {
+		return c.Next()
+	}
+
+	allowed, retryAfter, err := store.Take(key, rule)
+	if err != nil {
+		// A broken store must not take the API down with it.
+		log.Printf("rate limit: %v", err)
+		return c.Next()
+	}
+	if !allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return c.Status(fiber.StatusTooManyRequests).JSON(api.Error("too many requests"))
+	}
+	return c.Next()
+}
+
+// StartCleanup removes idle buckets every interval until the returned stop
+// function is called.
+func StartCleanup(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := store.RemoveIdle(time.Now().Add(-IdleAfter)); err != nil {
+					log.Printf("rate limit cleanup: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// WithTooManyRequests documents the 429 response of the operations under
+// pathPrefix.
+func WithTooManyRequests(operations []openapi.Operation, pathPrefix string) []openapi.Operation {
+	for i, op := range operations {
+		if !strings.HasPrefix(op.Path, pathPrefix) {
+			continue
+		}
+		responses := make(map[int]openapi.Response, len(op.Responses)+1)
+		for status, response := range op.Responses {
+			responses[status] = response
+		}
+		responses[fiber.StatusTooManyRequests] = openapi.Response{
+			Description: "Rate limit exceeded; the Retry-After header tells when to try again",
+			Body:        api.ErrorResponse{},
+		}
+		op.Responses = responses
+		operations[i] = op
+	}
+	return operations
+}
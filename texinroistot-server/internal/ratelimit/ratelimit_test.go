@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+func TestMemoryStoreRefillsBuckets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore().(*memoryStore)
+	s.now = func() time.Time { return now }
+	rule := Rule{PerMinute: 6, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := s.Take("client", rule); !allowed {
+			t.Fatalf("expected request %d of the burst to be allowed", i+1)
+		}
+	}
+	allowed, retryAfter, _ := s.Take("client", rule)
+	if allowed || retryAfter != 10*time.Second {
+		t.Fatalf("expected an empty bucket to wait 10s, got %v after %v", allowed, retryAfter)
+	}
+	if allowed, _, _ := s.Take("other", rule); !allowed {
+		t.Fatalf("expected other clients to have their own bucket")
+	}
+
+	now = now.Add(5 * time.Second)
+	if allowed, retryAfter, _ := s.Take("client", rule); allowed || retryAfter != 5*time.Second {
+		t.Fatalf("expected a half-filled token to wait 5s, got %v after %v", allowed, retryAfter)
+	}
+	now = now.Add(5 * time.Second)
+	if allowed, _, _ := s.Take("client", rule); !allowed {
+		t.Fatalf("expected a refilled token to be allowed")
+	}
+
+	// A long pause refills the bucket to its burst and no further.
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := s.Take("client", rule); !allowed {
+			t.Fatalf("expected request %d after the pause to be allowed", i+1)
+		}
+	}
+	if allowed, _, _ := s.Take("client", rule); allowed {
+		t.Fatalf("expected the bucket to hold no more than its burst")
+	}
+
+	s.RemoveIdle(now)
+	if len(s.buckets) != 1 {
+		t.Fatalf("expected only the bucket used now to be kept, got %d", len(s.buckets))
+	}
+}
+
+func setupRateLimitTest(t *testing.T) *fiber.App {
+	t.Helper()
+
+	original := store
+	perMinute, burst := config.RateLimitPerMinute, config.RateLimitBurst
+	searchPerMinute, searchBurst := config.SearchRateLimitPerMinute, config.SearchRateLimitBurst
+	loginPerMinute, loginBurst := config.LoginRateLimitPerMinute, config.LoginRateLimitBurst
+	store = NewMemoryStore()
+	config.RateLimitPerMinute, config.RateLimitBurst = 1, 2
+	config.SearchRateLimitPerMinute, config.SearchRateLimitBurst = 1, 1
+	config.LoginRateLimitPerMinute, config.LoginRateLimitBurst = 1, 1
+	t.Cleanup(func() {
+		store = original
+		config.RateLimitPerMinute, config.RateLimitBurst = perMinute, burst
+		config.SearchRateLimitPerMinute, config.SearchRateLimitBurst = searchPerMinute, searchBurst
+		config.LoginRateLimitPerMinute, config.LoginRateLimitBurst = loginPerMinute, loginBurst
+	})
+
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app := fiber.New()
+	app.Get("/api/stories", Public, ok)
+	app.Get("/api/villains", Public, ok)
+	app.Get("/api/graphql", GraphQL, ok)
+	app.Post("/api/login", Login, ok)
+	app.Get("/api/login/:provider", Login, ok)
+	return app
+}
+
+func statuses(t *testing.T, app *fiber.App, method string, targets ...string) []int {
+	t.Helper()
+
+	result := []int{}
+	for _, target := range targets {
+		res, err := app.Test(httptest.NewRequest(method, target, nil))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if res.StatusCode == fiber.StatusTooManyRequests && res.Header.Get(fiber.HeaderRetryAfter) != "60" {
+			t.Fatalf("expected Retry-After 60, got %q", res.Header.Get(fiber.HeaderRetryAfter))
+		}
+		result = append(result, res.StatusCode)
+	}
+	return result
+}
+
+func TestPublicLimitsEachRoute(t *testing.T) {
+	app := setupRateLimitTest(t)
+
+	got := statuses(t, app, http.MethodGet, "/api/stories", "/api/stories?page=2", "/api/stories", "/api/villains")
+	want := []int{200, 200, 429, 200}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestPublicLimitsSearchesSeparately(t *testing.T) {
+	app := setupRateLimitTest(t)
+
+	got := statuses(t, app, http.MethodGet, "/api/stories?q=mefisto", "/api/villains?q=mefisto", "/api/stories", "/api/villains?q=+")
+	want := []int{200, 429, 200, 200}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestGraphQLTakesFromSearchBudget(t *testing.T) {
+	app := setupRateLimitTest(t)
+
+	got := statuses(t, app, http.MethodGet, "/api/graphql", "/api/stories?q=mefisto", "/api/stories")
+	want := []int{200, 429, 200}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestLoginSharesBudgetBetweenProviders(t *testing.T) {
+	app := setupRateLimitTest(t)
+
+	if got := statuses(t, app, http.MethodPost, "/api/login"); got[0] != fiber.StatusOK {
+		t.Fatalf("expected the first login to be allowed, got %d", got[0])
+	}
+	if got := statuses(t, app, http.MethodGet, "/api/login/oidc"); got[0] != fiber.StatusTooManyRequests {
+		t.Fatalf("expected the second login to be limited, got %d", got[0])
+	}
+}
+
+func TestDisabledRuleAllowsEverything(t *testing.T) {
+	app := setupRateLimitTest(t)
+	config.RateLimitPerMinute = 0
+
+	for _, status := range statuses(t, app, http.MethodGet, "/api/stories", "/api/stories", "/api/stories") {
+		if status != fiber.StatusOK {
+			t.Fatalf("expected a disabled limit to allow every request, got %d", status)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kokkoniemi/texinroistot/internal/db"
+)
+
+// IdleAfter is how long an unused bucket is kept. A bucket of a rule that
+// refills at least Burst tokens an hour is full by then, and a removed bucket
+// starts out full.
+const IdleAfter = time.Hour
+
+// Rule is a token bucket of Burst tokens, refilled by PerMinute tokens a
+// minute. Each request takes a token.
+type Rule struct {
+	PerMinute float64
+	Burst     int
+}
+
+func (r Rule) disabled() bool {
+	return r.PerMinute <= 0 || r.Burst <= 0
+}
+
+// retryAfter is how long a bucket with the given tokens takes to refill to
+// a whole token.
+func (r Rule) retryAfter(tokens float64) time.Duration {
+	return time.Duration((1 - tokens) / r.PerMinute * float64(time.Minute))
+}
+
+// Store keeps the buckets. Take reports whether the bucket of key had a token
+// and, when it did not, how long to wait for one.
+type Store interface {
+	Take(key string, rule Rule) (bool, time.Duration, error)
+	RemoveIdle(before time.Time) error
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryStore keeps the buckets of this server instance.
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *memoryStore) Take(key string, rule Rule) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst)}
+		s.buckets[key] = b
+	} else {
+		b.tokens += now.Sub(b.updatedAt).Minutes() * rule.PerMinute
+		if b.tokens > float64(rule.Burst) {
+			b.tokens = float64(rule.Burst)
+		}
+	}
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return false, rule.retryAfter(b.tokens), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+func (s *memoryStore) RemoveIdle(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(before) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+type postgresStore struct {
+	repo db.RateLimitRepository
+}
+
+// NewPostgresStore keeps the buckets in the rate_limits table, shared by
+// every server instance.
+func NewPostgresStore() Store {
+	return &postgresStore{repo: db.NewRateLimitRepository()}
+}
+
+func (s *postgresStore) Take(key string, rule Rule) (bool, time.Duration, error) {
+	tokens, err := s.repo.Take(key, rule.PerMinute, rule.Burst)
+	if err != nil {
+		return false, 0, err
+	}
+	if tokens < 1 {
+		return false, rule.retryAfter(tokens), nil
+	}
+	return true, 0, nil
+}
+
+func (s *postgresStore) RemoveIdle(before time.Time) error {
+	_, err := s.repo.RemoveIdle(before)
+	return err
+}
@@ -0,0 +1,50 @@
+package security
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
+)
+
+// BodyLimit rejects request bodies larger than limit bytes with 413.
+// Routes listed in larger, keyed by "METHOD /path", get their own limit
+// instead, so that a single upload route does not raise the limit of the
+// whole API.
+//
+// The app must stream request bodies (fiber.Config.StreamRequestBody) for
+// this to work: fasthttp then hands bodies over its own limit to the
+// handlers instead of refusing them, and BodyLimit reads them into memory
+// only up to the limit of the route.
+func BodyLimit(limit int, larger map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := limit
+		if routeLimit, ok := larger[c.Method()+" "+c.Path()]; ok {
+			allowed = routeLimit
+		}
+
+		if c.Request().Header.ContentLength() > allowed {
+			return rejectBody(c, fiber.StatusRequestEntityTooLarge, "request body is too large")
+		}
+
+		if stream := c.Request().BodyStream(); stream != nil {
+			var body bytes.Buffer
+			if _, err := io.Copy(&body, io.LimitReader(stream, int64(allowed)+1)); err != nil {
+				return rejectBody(c, fiber.StatusBadRequest, "failed to read request body")
+			}
+			if body.Len() > allowed {
+				return rejectBody(c, fiber.StatusRequestEntityTooLarge, "request body is too large")
+			}
+			c.Request().SetBody(body.Bytes())
+		}
+		return c.Next()
+	}
+}
+
+// rejectBody closes the connection as well, because the rest of the body is
+// still unread and would otherwise be parsed as the next request.
+func rejectBody(c *fiber.Ctx, status int, message string) error {
+	c.Context().SetConnectionClose()
+	return c.Status(status).JSON(api.Error(message))
+}
@@ -0,0 +1,61 @@
+package security
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBodyLimit(t *testing.T) {
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true, BodyLimit: 16})
+	app.Use(BodyLimit(16, map[string]int{"POST /upload": 64}))
+	echo := func(c *fiber.Ctx) error {
+		return c.Send(c.Body())
+	}
+	app.Post("/small", echo)
+	app.Post("/upload", echo)
+
+	tests := []struct {
+		name    string
+		path    string
+		size    int
+		chunked bool
+		want    int
+	}{
+		{name: "within default limit", path: "/small", size: 16, want: fiber.StatusOK},
+		{name: "over default limit", path: "/small", size: 17, want: fiber.StatusRequestEntityTooLarge},
+		{name: "chunked over default limit", path: "/small", size: 17, chunked: true, want: fiber.StatusRequestEntityTooLarge},
+		{name: "upload over default limit", path: "/upload", size: 64, want: fiber.StatusOK},
+		{name: "chunked upload", path: "/upload", size: 64, chunked: true, want: fiber.StatusOK},
+		{name: "upload over its own limit", path: "/upload", size: 65, want: fiber.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.Repeat([]byte("x"), tt.size)
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(body))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, resp.StatusCode)
+			}
+			if tt.want == fiber.StatusOK {
+				got, _ := io.ReadAll(resp.Body)
+				if !bytes.Equal(got, body) {
+					t.Fatalf("expected the handler to read the whole body, got %d bytes", len(got))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+// Headers sets the security headers of every response. The API only
+// serves JSON, so the default policy forbids loading anything at all.
+func Headers(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if policy := contentSecurityPolicy(); policy != "" {
+		c.Set(fiber.HeaderContentSecurityPolicy, policy)
+	}
+	if frameOptions := frameOptions(); frameOptions != "" {
+		c.Set(fiber.HeaderXFrameOptions, frameOptions)
+	}
+	if config.ReferrerPolicy != "" {
+		c.Set(fiber.HeaderReferrerPolicy, config.ReferrerPolicy)
+	}
+	// Browsers ignore HSTS over plain HTTP, and secure cookies are only used
+	// behind HTTPS.
+	if config.CookieSecure && config.HSTSMaxAge > 0 {
+		c.Set(fiber.HeaderStrictTransportSecurity, fmt.Sprintf("max-age=%d; includeSubDomains", int(config.HSTSMaxAge.Seconds())))
+	}
+	return c.Next()
+}
+
+func contentSecurityPolicy() string {
+	directives := []string{}
+	if policy := strings.TrimSpace(config.ContentSecurityPolicy); policy != "" && policy != "off" {
+		directives = append(directives, strings.TrimSuffix(policy, ";"))
+	}
+	if config.FrameAncestors != "" {
+		directives = append(directives, "frame-ancestors "+config.FrameAncestors)
+	}
+	return strings.Join(directives, "; ")
+}
+
+// frameOptions mirrors frame-ancestors for browsers without CSP support.
+// Lists of origins have no X-Frame-Options equivalent.
+func frameOptions() string {
+	switch config.FrameAncestors {
+	case "'none'":
+		return "DENY"
+	case "'self'":
+		return "SAMEORIGIN"
+	}
+	return ""
+}
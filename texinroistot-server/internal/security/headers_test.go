@@ -0,0 +1,92 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/config"
+)
+
+func TestHeaders(t *testing.T) {
+	policy, frameAncestors, referrerPolicy := config.ContentSecurityPolicy, config.FrameAncestors, config.ReferrerPolicy
+	hstsMaxAge, cookieSecure := config.HSTSMaxAge, config.CookieSecure
+	t.Cleanup(func() {
+		config.ContentSecurityPolicy, config.FrameAncestors, config.ReferrerPolicy = policy, frameAncestors, referrerPolicy
+		config.HSTSMaxAge, config.CookieSecure = hstsMaxAge, cookieSecure
+	})
+
+	tests := []struct {
+		name      string
+		configure func()
+		want      map[string]string
+	}{
+		{
+			name: "defaults with secure cookies",
+			configure: func() {
+				config.ContentSecurityPolicy = "default-src 'none'"
+				config.FrameAncestors = "'none'"
+				config.ReferrerPolicy = "no-referrer"
+				config.HSTSMaxAge = 365 * 24 * time.Hour
+				config.CookieSecure = true
+			},
+			want: map[string]string{
+				"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "no-referrer",
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+			},
+		},
+		{
+			name: "insecure cookies leave out HSTS",
+			configure: func() {
+				config.FrameAncestors = "'self'"
+				config.CookieSecure = false
+			},
+			want: map[string]string{
+				"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'self'",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Strict-Transport-Security": "",
+			},
+		},
+		{
+			name: "disabled policy",
+			configure: func() {
+				config.ContentSecurityPolicy = "off"
+				config.FrameAncestors = "https://example.com"
+				config.ReferrerPolicy = ""
+				config.CookieSecure = true
+				config.HSTSMaxAge = 0
+			},
+			want: map[string]string{
+				"Content-Security-Policy":   "frame-ancestors https://example.com",
+				"X-Frame-Options":           "",
+				"Referrer-Policy":           "",
+				"Strict-Transport-Security": "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.configure()
+
+			app := fiber.New()
+			app.Use(Headers)
+			app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+			res, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			for header, want := range tt.want {
+				if got := res.Header.Get(header); got != want {
+					t.Fatalf("expected %s %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
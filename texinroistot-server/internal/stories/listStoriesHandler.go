@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
 )
 
 const (
@@ -66,12 +68,18 @@ func parseAllowedValue(raw string, fallback string, allowed map[string]bool) (st
 }
 
 func parseStoryListParams(c *fiber.Ctx) (db.StoryListParams, error) {
-	page, err := parsePositiveInt(c.Query("page"), defaultPage)
+	return ParseStoryListQuery(func(key string) string { return c.Query(key) })
+}
+
+// ParseStoryListQuery validates raw story list query values and fills in
+// defaults. It is shared by the REST and GraphQL endpoints.
+func ParseStoryListQuery(query func(key string) string) (db.StoryListParams, error) {
+	page, err := parsePositiveInt(query("page"), defaultPage)
 	if err != nil {
 		return db.StoryListParams{}, fmt.Errorf("page must be a positive integer")
 	}
 
-	pageSize, err := parsePositiveInt(c.Query("pageSize"), defaultPageSize)
+	pageSize, err := parsePositiveInt(query("pageSize"), defaultPageSize)
 	if err != nil {
 		return db.StoryListParams{}, fmt.Errorf("pageSize must be a positive integer")
 	}
@@ -79,17 +87,17 @@ func parseStoryListParams(c *fiber.Ctx) (db.StoryListParams, error) {
 		pageSize = maxPageSize
 	}
 
-	publication, err := parseAllowedValue(c.Query("publication"), defaultPublicationFilter, allowedPublicationFilters)
+	publication, err := parseAllowedValue(query("publication"), defaultPublicationFilter, allowedPublicationFilters)
 	if err != nil {
 		return db.StoryListParams{}, fmt.Errorf("publication filter is invalid")
 	}
 
-	sort, err := parseAllowedValue(c.Query("sort"), defaultSort, allowedSorts)
+	sort, err := parseAllowedValue(query("sort"), defaultSort, allowedSorts)
 	if err != nil {
 		return db.StoryListParams{}, fmt.Errorf("sort is invalid")
 	}
 
-	year, err := parsePositiveInt(c.Query("year"), defaultYear)
+	year, err := parsePositiveInt(query("year"), defaultYear)
 	if err != nil {
 		return db.StoryListParams{}, fmt.Errorf("year must be a positive integer")
 	}
@@ -97,49 +105,56 @@ func parseStoryListParams(c *fiber.Ctx) (db.StoryListParams, error) {
 	return db.StoryListParams{
 		Publication: publication,
 		Sort:        sort,
-		Search:      strings.TrimSpace(c.Query("q", "")),
+		Search:      strings.TrimSpace(query("q")),
 		Year:        year,
 		Page:        page,
 		PageSize:    pageSize,
 	}, nil
 }
 
+type StoryListFilters struct {
+	Publication string `json:"publication"`
+	Sort        string `json:"sort"`
+	Q           string `json:"q"`
+	Year        int    `json:"year"`
+}
+
+type StoryListResponse struct {
+	Stories []*db.Story      `json:"stories"`
+	Meta    api.PageMeta     `json:"meta"`
+	Filters StoryListFilters `json:"filters"`
+}
+
 func ListStoriesHandler(c *fiber.Ctx) error {
-	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
-	version, err := versionRepo.GetActive()
+	params, err := parseStoryListParams(c)
 	if err != nil {
-		return c.SendStatus(500)
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
-	params, err := parseStoryListParams(c)
+	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
+	version, err := versionRepo.GetActive()
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 
 	storyRepo := db.NewStoryRepository()
 	stories, total, err := storyRepo.ListFiltered(version, params)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to list stories"})
+		return c.Status(500).JSON(api.Error("failed to list stories"))
 	}
-
-	totalPages := 0
-	if total > 0 {
-		totalPages = (total + params.PageSize - 1) / params.PageSize
+	meta := api.NewPageMeta(total, params.Page, params.PageSize)
+	if linkeddata.Requested(c) {
+		return linkeddata.SendPage(c, linkeddata.Stories(stories), meta)
 	}
 
-	return c.JSON(fiber.Map{
-		"stories": stories,
-		"meta": fiber.Map{
-			"total":      total,
-			"page":       params.Page,
-			"pageSize":   params.PageSize,
-			"totalPages": totalPages,
-		},
-		"filters": fiber.Map{
-			"publication": params.Publication,
-			"sort":        params.Sort,
-			"q":           params.Search,
-			"year":        params.Year,
+	return c.JSON(StoryListResponse{
+		Stories: stories,
+		Meta:    meta,
+		Filters: StoryListFilters{
+			Publication: params.Publication,
+			Sort:        params.Sort,
+			Q:           params.Search,
+			Year:        params.Year,
 		},
 	})
 }
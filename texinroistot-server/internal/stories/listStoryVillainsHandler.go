@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
 )
 
 func parseStoryHash(raw string) (string, error) {
@@ -16,32 +18,39 @@ func parseStoryHash(raw string) (string, error) {
 	return storyHash, nil
 }
 
+type StoryVillainsResponse struct {
+	StoryHash string        `json:"storyHash"`
+	Villains  []*db.Villain `json:"villains"`
+	Meta      api.CountMeta `json:"meta"`
+}
+
 func ListStoryVillainsHandler(c *fiber.Ctx) error {
 	storyHash, err := parseStoryHash(c.Params("storyHash"))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
 	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
 	version, err := versionRepo.GetActive()
 	if err != nil {
-		return c.SendStatus(500)
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 
 	villainRepo := db.NewVillainRepository()
 	villains, storyFound, err := villainRepo.ListByStoryHash(version, storyHash)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to list story villains"})
+		return c.Status(500).JSON(api.Error("failed to list story villains"))
 	}
 	if !storyFound {
-		return c.Status(404).JSON(fiber.Map{"error": "story not found"})
+		return c.Status(404).JSON(api.Error("story not found"))
+	}
+	if linkeddata.Requested(c) {
+		return linkeddata.Send(c, linkeddata.Villains(villains))
 	}
 
-	return c.JSON(fiber.Map{
-		"storyHash": storyHash,
-		"villains":  villains,
-		"meta": fiber.Map{
-			"total": len(villains),
-		},
+	return c.JSON(StoryVillainsResponse{
+		StoryHash: storyHash,
+		Villains:  villains,
+		Meta:      api.CountMeta{Total: len(villains)},
 	})
 }
@@ -0,0 +1,66 @@
+package stories
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+func Operations() []openapi.Operation {
+	listParameters := append(openapi.PageParameters(defaultPageSize, maxPageSize),
+		openapi.Parameter{
+			Name:    "publication",
+			In:      openapi.ParamInQuery,
+			Enum:    openapi.Enum(allowedPublicationFilters),
+			Default: defaultPublicationFilter,
+		},
+		openapi.Parameter{
+			Name:    "sort",
+			In:      openapi.ParamInQuery,
+			Enum:    openapi.Enum(allowedSorts),
+			Default: defaultSort,
+		},
+		openapi.Parameter{
+			Name:        "year",
+			In:          openapi.ParamInQuery,
+			Description: "Only stories published in the given year within the publication filter.",
+			Type:        "integer",
+			Minimum:     openapi.IntPtr(1),
+		},
+		openapi.Parameter{
+			Name:        "q",
+			In:          openapi.ParamInQuery,
+			Description: "Free-text search over titles, issues and author names.",
+		},
+	)
+
+	return []openapi.Operation{
+		{
+			Method:     "GET",
+			Path:       "/api/stories",
+			Summary:    "List stories of the active version",
+			Tag:        "stories",
+			Parameters: listParameters,
+			Responses: map[int]openapi.Response{
+				200: {Body: StoryListResponse{}, Alternatives: []string{linkeddata.MIMEApplicationLDJSON}},
+				400: {Description: "Invalid query parameter", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+		{
+			Method:  "GET",
+			Path:    "/api/stories/:storyHash/villains",
+			Summary: "List villains appearing in a story",
+			Tag:     "stories",
+			Parameters: []openapi.Parameter{
+				{Name: "storyHash", In: openapi.ParamInPath},
+			},
+			Responses: map[int]openapi.Response{
+				200: {Body: StoryVillainsResponse{}, Alternatives: []string{linkeddata.MIMEApplicationLDJSON}},
+				400: {Description: "Invalid story hash", Body: api.ErrorResponse{}},
+				404: {Description: "Story not found", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+	}
+}
@@ -1,23 +1,44 @@
 package versions
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
 )
 
+// PublicVersion leaves out the import metadata only admins see.
+type PublicVersion struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	IsActive  bool      `json:"isActive"`
+	Label     string    `json:"label"`
+}
+
+type ActiveVersionResponse struct {
+	Version *PublicVersion   `json:"version"`
+	Stats   *db.VersionStats `json:"stats"`
+}
+
 func GetActiveVersionHandler(c *fiber.Ctx) error {
 	versionRepo := db.NewVersionRepository()
 	version, err := versionRepo.GetActive()
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to load active version"})
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 	stats, err := versionRepo.GetStats(version.ID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to load active version stats"})
+		return c.Status(500).JSON(api.Error("failed to load active version stats"))
 	}
 
-	return c.JSON(fiber.Map{
-		"version": version,
-		"stats":   stats,
+	return c.JSON(ActiveVersionResponse{
+		Version: &PublicVersion{
+			ID:        version.ID,
+			CreatedAt: version.CreatedAt,
+			IsActive:  version.IsActive,
+			Label:     version.Label,
+		},
+		Stats: stats,
 	})
 }
@@ -0,0 +1,21 @@
+package versions
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+func Operations() []openapi.Operation {
+	return []openapi.Operation{
+		{
+			Method:  "GET",
+			Path:    "/api/version/active",
+			Summary: "Get the active version and its statistics",
+			Tag:     "versions",
+			Responses: map[int]openapi.Response{
+				200: {Body: ActiveVersionResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/kokkoniemi/texinroistot/internal/api"
 	"github.com/kokkoniemi/texinroistot/internal/db"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
 )
 
 const (
@@ -59,12 +61,18 @@ func parseAllowedValue(raw string, fallback string, allowed map[string]bool) (st
 }
 
 func parseVillainListParams(c *fiber.Ctx) (db.VillainListParams, error) {
-	page, err := parsePositiveInt(c.Query("page"), defaultPage)
+	return ParseVillainListQuery(func(key string) string { return c.Query(key) })
+}
+
+// ParseVillainListQuery validates raw villain list query values and fills in
+// defaults. It is shared by the REST and GraphQL endpoints.
+func ParseVillainListQuery(query func(key string) string) (db.VillainListParams, error) {
+	page, err := parsePositiveInt(query("page"), defaultPage)
 	if err != nil {
 		return db.VillainListParams{}, fmt.Errorf("page must be a positive integer")
 	}
 
-	pageSize, err := parsePositiveInt(c.Query("pageSize"), defaultPageSize)
+	pageSize, err := parsePositiveInt(query("pageSize"), defaultPageSize)
 	if err != nil {
 		return db.VillainListParams{}, fmt.Errorf("pageSize must be a positive integer")
 	}
@@ -72,12 +80,12 @@ func parseVillainListParams(c *fiber.Ctx) (db.VillainListParams, error) {
 		pageSize = maxPageSize
 	}
 
-	publication, err := parseAllowedValue(c.Query("publication"), defaultPublicationFilter, allowedPublicationFilters)
+	publication, err := parseAllowedValue(query("publication"), defaultPublicationFilter, allowedPublicationFilters)
 	if err != nil {
 		return db.VillainListParams{}, fmt.Errorf("publication filter is invalid")
 	}
 
-	sort, err := parseAllowedValue(c.Query("sort"), defaultSort, allowedSorts)
+	sort, err := parseAllowedValue(query("sort"), defaultSort, allowedSorts)
 	if err != nil {
 		return db.VillainListParams{}, fmt.Errorf("sort is invalid")
 	}
@@ -85,47 +93,53 @@ func parseVillainListParams(c *fiber.Ctx) (db.VillainListParams, error) {
 	return db.VillainListParams{
 		Publication: publication,
 		Sort:        sort,
-		Search:      strings.TrimSpace(c.Query("q", "")),
+		Search:      strings.TrimSpace(query("q")),
 		Page:        page,
 		PageSize:    pageSize,
 	}, nil
 }
 
+type VillainListFilters struct {
+	Publication string `json:"publication"`
+	Sort        string `json:"sort"`
+	Q           string `json:"q"`
+}
+
+type VillainListResponse struct {
+	Villains []*db.Villain      `json:"villains"`
+	Meta     api.PageMeta       `json:"meta"`
+	Filters  VillainListFilters `json:"filters"`
+}
+
 func ListVillainsHandler(c *fiber.Ctx) error {
-	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
-	version, err := versionRepo.GetActive()
+	params, err := parseVillainListParams(c)
 	if err != nil {
-		return c.SendStatus(500)
+		return c.Status(400).JSON(api.Error(err.Error()))
 	}
 
-	params, err := parseVillainListParams(c)
+	versionRepo := db.NewVersionRepository() // TODO: move active version to fiber context
+	version, err := versionRepo.GetActive()
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(500).JSON(api.Error("failed to load active version"))
 	}
 
 	villainRepo := db.NewVillainRepository()
 	villains, total, err := villainRepo.ListFiltered(version, params)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to list villains"})
+		return c.Status(500).JSON(api.Error("failed to list villains"))
 	}
-
-	totalPages := 0
-	if total > 0 {
-		totalPages = (total + params.PageSize - 1) / params.PageSize
+	meta := api.NewPageMeta(total, params.Page, params.PageSize)
+	if linkeddata.Requested(c) {
+		return linkeddata.SendPage(c, linkeddata.Villains(villains), meta)
 	}
 
-	return c.JSON(fiber.Map{
-		"villains": villains,
-		"meta": fiber.Map{
-			"total":      total,
-			"page":       params.Page,
-			"pageSize":   params.PageSize,
-			"totalPages": totalPages,
-		},
-		"filters": fiber.Map{
-			"publication": params.Publication,
-			"sort":        params.Sort,
-			"q":           params.Search,
+	return c.JSON(VillainListResponse{
+		Villains: villains,
+		Meta:     meta,
+		Filters: VillainListFilters{
+			Publication: params.Publication,
+			Sort:        params.Sort,
+			Q:           params.Search,
 		},
 	})
 }
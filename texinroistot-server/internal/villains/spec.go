@@ -0,0 +1,44 @@
+package villains
+
+import (
+	"github.com/kokkoniemi/texinroistot/internal/api"
+	"github.com/kokkoniemi/texinroistot/internal/linkeddata"
+	"github.com/kokkoniemi/texinroistot/internal/openapi"
+)
+
+func Operations() []openapi.Operation {
+	listParameters := append(openapi.PageParameters(defaultPageSize, maxPageSize),
+		openapi.Parameter{
+			Name:    "publication",
+			In:      openapi.ParamInQuery,
+			Enum:    openapi.Enum(allowedPublicationFilters),
+			Default: defaultPublicationFilter,
+		},
+		openapi.Parameter{
+			Name:    "sort",
+			In:      openapi.ParamInQuery,
+			Enum:    openapi.Enum(allowedSorts),
+			Default: defaultSort,
+		},
+		openapi.Parameter{
+			Name:        "q",
+			In:          openapi.ParamInQuery,
+			Description: "Free-text search over villain names.",
+		},
+	)
+
+	return []openapi.Operation{
+		{
+			Method:     "GET",
+			Path:       "/api/villains",
+			Summary:    "List villains of the active version",
+			Tag:        "villains",
+			Parameters: listParameters,
+			Responses: map[int]openapi.Response{
+				200: {Body: VillainListResponse{}, Alternatives: []string{linkeddata.MIMEApplicationLDJSON}},
+				400: {Description: "Invalid query parameter", Body: api.ErrorResponse{}},
+				500: {Description: "Database error", Body: api.ErrorResponse{}},
+			},
+		},
+	}
+}
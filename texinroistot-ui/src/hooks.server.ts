@@ -1,4 +1,5 @@
-import { redirect, type Handle } from '@sveltejs/kit';
+import { redirect, type Handle, type HandleFetch } from '@sveltejs/kit';
+import { getBackendHost } from '$lib/server/backend-host';
 import {
 	hasUnpublishedAccess,
 	isUnpublishedModeEnabled,
@@ -6,7 +7,18 @@ import {
 } from '$lib/server/unpublished-gate';
 
 const UNPUBLISHED_ROUTE = '/julkaisematon';
-const UNPUBLISHED_ALLOWED_API_PATHS = new Set(['/api/login', '/api/logout', '/api/me']);
+const UNPUBLISHED_ALLOWED_API_PATHS = new Set([
+	'/api/login',
+	'/api/logout',
+	'/api/refresh',
+	'/api/csrf',
+	'/api/me',
+	'/api/me/sessions',
+	'/api/me/export'
+]);
+
+// Login provider steps, e.g. /api/login/oidc/callback.
+const UNPUBLISHED_ALLOWED_API_PREFIX = '/api/login/';
 
 function isPublicPath(pathname: string): boolean {
 	return (
@@ -37,7 +49,10 @@ export const handle: Handle = async ({ event, resolve }) => {
 		return resolve(event);
 	}
 
-	if (UNPUBLISHED_ALLOWED_API_PATHS.has(event.url.pathname)) {
+	if (
+		UNPUBLISHED_ALLOWED_API_PATHS.has(event.url.pathname) ||
+		event.url.pathname.startsWith(UNPUBLISHED_ALLOWED_API_PREFIX)
+	) {
 		return resolve(event);
 	}
 
@@ -52,3 +67,14 @@ export const handle: Handle = async ({ event, resolve }) => {
 	const nextParam = encodeURIComponent(next);
 	throw redirect(303, `${UNPUBLISHED_ROUTE}?next=${nextParam}`);
 };
+
+// The backend rate limits each client by IP, read from X-Real-IP when
+// ROISTOT_PROXY_HEADER names it. The header is always overwritten, so
+// clients cannot pick their own address.
+export const handleFetch: HandleFetch = async ({ event, request, fetch }) => {
+	if (request.url.startsWith(getBackendHost())) {
+		request.headers.set('x-real-ip', event.getClientAddress());
+	}
+
+	return fetch(request);
+};
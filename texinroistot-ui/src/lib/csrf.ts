@@ -0,0 +1,30 @@
+let csrfToken: Promise<string> | null = null;
+
+async function loadCsrfToken(): Promise<string> {
+	const response = await fetch('/api/csrf');
+	if (!response.ok) {
+		throw new Error('CSRF-tunnisteen hakeminen epäonnistui.');
+	}
+	const body = (await response.json()) as { token: string };
+	return body.token;
+}
+
+// csrfFetch sends the token of the csrf cookie in the X-CSRF-Token header. The
+// backend refuses changes made with authentication cookies without it.
+export async function csrfFetch(input: RequestInfo | URL, init: RequestInit = {}): Promise<Response> {
+	if (!csrfToken) {
+		csrfToken = loadCsrfToken().catch((error) => {
+			csrfToken = null;
+			throw error;
+		});
+	}
+
+	const headers = new Headers(init.headers);
+	headers.set('x-csrf-token', await csrfToken);
+	const response = await fetch(input, { ...init, headers });
+	if (response.status === 403) {
+		// The cookie may have been replaced, fetch the token again next time.
+		csrfToken = null;
+	}
+	return response;
+}
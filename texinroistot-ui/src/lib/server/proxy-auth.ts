@@ -8,6 +8,10 @@ export function authProxyHeaders(request: Request, initialHeaders?: HeadersInit)
 	if (cookieHeader) {
 		headers.set('cookie', cookieHeader);
 	}
+	const csrfToken = request.headers.get('x-csrf-token');
+	if (csrfToken) {
+		headers.set('x-csrf-token', csrfToken);
+	}
 
 	return headers;
 }
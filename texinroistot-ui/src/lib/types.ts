@@ -1,4 +1,4 @@
-import type { Author, StoryBase } from '$lib/listing/shared';
+import type { Author, Meta, StoryBase } from '$lib/listing/shared';
 
 export type Story = StoryBase & {
 	hash: string;
@@ -44,14 +44,135 @@ export type AuthorStoriesResponse = {
 	};
 };
 
+export type UserRole = 'viewer' | 'editor' | 'publisher' | 'user-admin';
+
+export const userRoles: UserRole[] = ['viewer', 'editor', 'publisher', 'user-admin'];
+
 export type AdminUser = {
 	hash: string;
 	isAdmin: boolean;
+	roles?: UserRole[];
+	email?: string;
 	createdAt?: string;
 };
 
+export type AdminUsersResponse = {
+	users?: AdminUser[];
+	meta?: Meta;
+};
+
+export type ApiKey = {
+	id: number;
+	userHash: string;
+	name: string;
+	prefix: string;
+	scopes: UserRole[];
+	createdAt: string;
+	lastUsedAt: string | null;
+	expiresAt: string | null;
+};
+
+export type AuditOutcome = 'success' | 'denied' | 'failure';
+
+export type AuditEntry = {
+	id: number;
+	createdAt: string;
+	actorHash: string;
+	apiKeyID: number | null;
+	action: string;
+	target: string;
+	method: string;
+	path: string;
+	ip: string;
+	userAgent: string;
+	status: number;
+	outcome: AuditOutcome;
+};
+
 export type AdminVersion = {
 	id: number;
 	createdAt?: string;
 	isActive: boolean;
+	isPinned?: boolean;
+	label?: string;
+	notes?: string;
+	importedBy?: string;
+	sourceUrl?: string;
+	fileName?: string;
+	contentHash?: string;
+	importerRevision?: string;
+	rows?: number;
+	counts?: ImportEntityCounts;
+};
+
+export type VersionDiskUsage = {
+	versionID: number;
+	rows: number;
+	bytes: number;
+};
+
+export type DiskUsageReport = {
+	versions: VersionDiskUsage[];
+	databaseBytes: number;
+};
+
+export type PrunePlan = {
+	keepLatest: number;
+	keep: { version: AdminVersion; reasons: ('active' | 'pinned' | 'latest' | 'scheduled' | 'rollback')[] }[];
+	prune: AdminVersion[];
+	pruneBytes: number;
+};
+
+export type ActivationIssue = {
+	check: string;
+	message: string;
+};
+
+export type ActivationReport = {
+	versionID: number;
+	activeVersionID: number | null;
+	forced: boolean;
+	issues: ActivationIssue[];
+};
+
+export type VersionActivation = {
+	id: number;
+	versionID: number | null;
+	previousVersionID: number | null;
+	activatedBy?: string;
+	reason: 'manual' | 'scheduled' | 'rollback' | 'import';
+	forced: boolean;
+	activatedAt: string;
+};
+
+export type ScheduledActivation = {
+	id: number;
+	versionID: number;
+	activateAt: string;
+	force: boolean;
+	status: 'pending' | 'running' | 'done' | 'failed' | 'cancelled';
+	error?: string;
+	createdBy?: string;
+	createdAt: string;
+	processedAt: string | null;
+};
+
+export type ImportEntityCounts = {
+	authors: number;
+	publications: number;
+	stories: number;
+	villains: number;
+};
+
+export type ImportJob = {
+	id: number;
+	status: 'queued' | 'downloading' | 'parsing' | 'persisting' | 'done' | 'failed';
+	versionID: number | null;
+	error?: string;
+	rows: number;
+	parsed: ImportEntityCounts;
+	persisted: ImportEntityCounts;
+	createdAt: string;
+	updatedAt: string;
+	finishedAt: string | null;
 };
@@ -0,0 +1,33 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const GET: RequestHandler = async ({ request, url, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const queryString = url.searchParams.toString();
+
+	const response = await fetch(
+		`${getBackendHost()}/api/admin/api-keys${queryString ? `?${queryString}` : ''}`,
+		{
+			method: 'GET',
+			headers
+		}
+	);
+
+	return proxiedResponse(response);
+};
+
+export const POST: RequestHandler = async ({ request, fetch }) => {
+	const payload = await request.text();
+	const headers = authProxyHeaders(request, {
+		'content-type': 'application/json'
+	});
+
+	const response = await fetch(`${getBackendHost()}/api/admin/api-keys`, {
+		method: 'POST',
+		headers,
+		body: payload
+	});
+
+	return proxiedResponse(response);
+};
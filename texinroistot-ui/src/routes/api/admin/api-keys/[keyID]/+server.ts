@@ -0,0 +1,15 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const DELETE: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const keyID = encodeURIComponent(params.keyID);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/api-keys/${keyID}`, {
+		method: 'DELETE',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
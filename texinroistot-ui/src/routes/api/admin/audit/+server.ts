@@ -0,0 +1,18 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const GET: RequestHandler = async ({ request, url, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const queryString = url.searchParams.toString();
+
+	const response = await fetch(
+		`${getBackendHost()}/api/admin/audit${queryString ? `?${queryString}` : ''}`,
+		{
+			method: 'GET',
+			headers
+		}
+	);
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,15 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const GET: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const jobID = encodeURIComponent(params.jobID);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/imports/${jobID}`, {
+		method: 'GET',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,19 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const PUT: RequestHandler = async ({ request, params, fetch }) => {
+	const payload = await request.text();
+	const headers = authProxyHeaders(request, {
+		'content-type': 'application/json'
+	});
+	const userHash = encodeURIComponent(params.userHash);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/users/${userHash}/roles`, {
+		method: 'PUT',
+		headers,
+		body: payload
+	});
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,15 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const DELETE: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const userHash = encodeURIComponent(params.userHash);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/users/${userHash}/sessions`, {
+		method: 'DELETE',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
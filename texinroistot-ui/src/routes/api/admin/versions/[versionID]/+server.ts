@@ -13,3 +13,19 @@ export const DELETE: RequestHandler = async ({ request, params, fetch }) => {
 
 	return proxiedResponse(response);
 };
+
+export const PATCH: RequestHandler = async ({ request, params, fetch }) => {
+	const payload = await request.text();
+	const headers = authProxyHeaders(request, {
+		'content-type': 'application/json'
+	});
+	const versionID = encodeURIComponent(params.versionID);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/versions/${versionID}`, {
+		method: 'PATCH',
+		headers,
+		body: payload
+	});
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,27 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const PUT: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const versionID = encodeURIComponent(params.versionID);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/versions/${versionID}/pin`, {
+		method: 'PUT',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
+
+export const DELETE: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const versionID = encodeURIComponent(params.versionID);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/versions/${versionID}/pin`, {
+		method: 'DELETE',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,22 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const POST: RequestHandler = async ({ request, params, fetch }) => {
+	const payload = await request.text();
+	const headers = authProxyHeaders(request, {
+		'content-type': 'application/json'
+	});
+	const versionID = encodeURIComponent(params.versionID);
+
+	const response = await fetch(
+		`${getBackendHost()}/api/admin/versions/${versionID}/schedule-activation`,
+		{
+			method: 'POST',
+			headers,
+			body: payload
+		}
+	);
+
+	return proxiedResponse(response);
+};
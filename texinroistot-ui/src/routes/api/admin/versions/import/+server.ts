@@ -4,10 +4,17 @@ import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
 
 export const POST: RequestHandler = async ({ request, fetch }) => {
 	const headers = authProxyHeaders(request);
+	const contentType = request.headers.get('content-type');
+	if (contentType) {
+		// multipart bodies carry their boundary in the content type
+		headers.set('content-type', contentType);
+	}
+	const body = await request.arrayBuffer();
 
 	const response = await fetch(`${getBackendHost()}/api/admin/versions/import`, {
 		method: 'POST',
-		headers
+		headers,
+		body: body.byteLength > 0 ? body : undefined
 	});
 
 	return proxiedResponse(response);
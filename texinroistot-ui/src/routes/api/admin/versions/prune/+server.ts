@@ -0,0 +1,24 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const GET: RequestHandler = async ({ request, url, fetch }) => {
+	const headers = authProxyHeaders(request);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/versions/prune${url.search}`, {
+		headers
+	});
+
+	return proxiedResponse(response);
+};
+
+export const POST: RequestHandler = async ({ request, url, fetch }) => {
+	const headers = authProxyHeaders(request);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/versions/prune${url.search}`, {
+		method: 'POST',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,14 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const POST: RequestHandler = async ({ request, fetch }) => {
+	const headers = authProxyHeaders(request);
+
+	const response = await fetch(`${getBackendHost()}/api/admin/versions/rollback`, {
+		method: 'POST',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,18 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const DELETE: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const scheduleID = encodeURIComponent(params.scheduleID);
+
+	const response = await fetch(
+		`${getBackendHost()}/api/admin/versions/scheduled-activations/${scheduleID}`,
+		{
+			method: 'DELETE',
+			headers
+		}
+	);
+
+	return proxiedResponse(response);
+};
@@ -10,6 +10,7 @@ export const POST: RequestHandler = async ({ request, fetch }) => {
 		const payload = (await request.json().catch(() => null)) as {
 			credential?: string;
 			g_csrf_token?: string;
+			dev_token?: string;
 		} | null;
 
 		if (payload?.credential) {
@@ -18,6 +19,9 @@ export const POST: RequestHandler = async ({ request, fetch }) => {
 		if (payload?.g_csrf_token) {
 			body.append('g_csrf_token', payload.g_csrf_token);
 		}
+		if (payload?.dev_token) {
+			body.append('dev_token', payload.dev_token);
+		}
 	} else {
 		const formData = await request.formData();
 		for (const [key, value] of formData.entries()) {
@@ -27,7 +31,8 @@ export const POST: RequestHandler = async ({ request, fetch }) => {
 		}
 	}
 
-	if (!body.get('credential')) {
+	// dev_token is only accepted by backends with dev login enabled.
+	if (!body.get('credential') && !body.get('dev_token')) {
 		return new Response(JSON.stringify({ error: 'Google credential missing' }), {
 			status: 400,
 			headers: { 'content-type': 'application/json' }
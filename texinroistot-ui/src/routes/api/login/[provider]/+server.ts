@@ -0,0 +1,33 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+// GET redirects to an OpenID Connect provider, POST asks for a magic link.
+export const GET: RequestHandler = async ({ request, params, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const provider = encodeURIComponent(params.provider);
+
+	const response = await fetch(`${getBackendHost()}/api/login/${provider}`, {
+		method: 'GET',
+		headers,
+		redirect: 'manual'
+	});
+
+	return proxiedResponse(response);
+};
+
+export const POST: RequestHandler = async ({ request, params, fetch }) => {
+	const payload = await request.text();
+	const headers = authProxyHeaders(request, {
+		'content-type': request.headers.get('content-type') ?? 'application/json'
+	});
+	const provider = encodeURIComponent(params.provider);
+
+	const response = await fetch(`${getBackendHost()}/api/login/${provider}`, {
+		method: 'POST',
+		headers,
+		body: payload
+	});
+
+	return proxiedResponse(response);
+};
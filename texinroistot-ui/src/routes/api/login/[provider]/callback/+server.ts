@@ -0,0 +1,20 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const GET: RequestHandler = async ({ request, params, url, fetch }) => {
+	const headers = authProxyHeaders(request);
+	const provider = encodeURIComponent(params.provider);
+	const queryString = url.searchParams.toString();
+
+	const response = await fetch(
+		`${getBackendHost()}/api/login/${provider}/callback${queryString ? `?${queryString}` : ''}`,
+		{
+			method: 'GET',
+			headers,
+			redirect: 'manual'
+		}
+	);
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,9 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { proxiedResponse } from '$lib/server/proxy-auth';
+
+export const GET: RequestHandler = async ({ fetch }) => {
+	const response = await fetch(`${getBackendHost()}/api/login/providers`);
+
+	return proxiedResponse(response);
+};
@@ -0,0 +1,14 @@
+import type { RequestHandler } from './$types';
+import { getBackendHost } from '$lib/server/backend-host';
+import { authProxyHeaders, proxiedResponse } from '$lib/server/proxy-auth';
+
+export const DELETE: RequestHandler = async ({ request, fetch }) => {
+	const headers = authProxyHeaders(request);
+
+	const response = await fetch(`${getBackendHost()}/api/me/sessions`, {
+		method: 'DELETE',
+		headers
+	});
+
+	return proxiedResponse(response);
+};
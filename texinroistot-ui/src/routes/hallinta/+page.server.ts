@@ -1,23 +1,45 @@
 import { env } from '$env/dynamic/public';
 import type { PageServerLoad } from './$types';
-import type { AdminUser, AdminVersion } from '$lib/types';
+import type { AdminUser, AdminUsersResponse, AdminVersion, UserRole } from '$lib/types';
+import type { Meta } from '$lib/listing/shared';
 
 type MePayload = {
 	loggedIn?: boolean;
 	email?: string;
 	isAdmin?: boolean;
+	roles?: UserRole[];
 };
 
+// The backend enables Google login unless configured otherwise.
+async function loadLoginProviders(fetch: typeof globalThis.fetch): Promise<string[]> {
+	try {
+		const response = await fetch('/api/login/providers');
+		if (!response.ok) {
+			return ['google'];
+		}
+		const payload = (await response.json()) as { providers?: string[] };
+		return payload.providers ?? [];
+	} catch {
+		return ['google'];
+	}
+}
+
 export const load: PageServerLoad = async ({ fetch }) => {
-	const googleClientId = env.PUBLIC_GOOGLE_OAUTH2_CLIENT_ID?.trim() ?? '';
+	const loginProviders = await loadLoginProviders(fetch);
+	const googleClientId = loginProviders.includes('google')
+		? (env.PUBLIC_GOOGLE_OAUTH2_CLIENT_ID?.trim() ?? '')
+		: '';
 	const fallbackData = {
 		user: {
 			loggedIn: false,
 			email: '',
-			isAdmin: false
+			isAdmin: false,
+			roles: [] as UserRole[]
 		},
 		googleClientId,
+		loginProviders,
 		users: [] as AdminUser[],
+		usersMeta: null as Meta | null,
 		usersError: '',
 		versions: [] as AdminVersion[],
 		versionsError: '',
@@ -34,14 +56,17 @@ export const load: PageServerLoad = async ({ fetch }) => {
 		const user = {
 			loggedIn: Boolean(payload.loggedIn),
 			email: payload.email ?? '',
-			isAdmin: Boolean(payload.isAdmin)
+			isAdmin: Boolean(payload.isAdmin),
+			roles: payload.roles ?? []
 		};
 
 		if (!user.loggedIn || !user.isAdmin) {
 			return {
 				user,
 				googleClientId,
+				loginProviders,
 				users: [],
+				usersMeta: null,
 				usersError: '',
 				versions: [],
 				versionsError: '',
@@ -49,14 +74,19 @@ export const load: PageServerLoad = async ({ fetch }) => {
 			};
 		}
 
+		// Only user admins may list users.
 		let users: AdminUser[] = [];
+		let usersMeta: Meta | null = null;
 		let usersError = '';
-		const usersResponse = await fetch('/api/admin/users');
-		if (usersResponse.ok) {
-			const usersPayload = (await usersResponse.json()) as { users?: AdminUser[] };
-			users = usersPayload.users ?? [];
-		} else {
-			usersError = 'Käyttäjien haku epäonnistui.';
+		if (user.roles.includes('user-admin')) {
+			const usersResponse = await fetch('/api/admin/users');
+			if (usersResponse.ok) {
+				const usersPayload = (await usersResponse.json()) as AdminUsersResponse;
+				users = usersPayload.users ?? [];
+				usersMeta = usersPayload.meta ?? null;
+			} else {
+				usersError = 'Käyttäjien haku epäonnistui.';
+			}
 		}
 
 		let versions: AdminVersion[] = [];
@@ -77,7 +107,9 @@ export const load: PageServerLoad = async ({ fetch }) => {
 		return {
 			user,
 			googleClientId,
+			loginProviders,
 			users,
+			usersMeta,
 			usersError,
 			versions,
 			versionsError,